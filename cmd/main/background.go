@@ -70,9 +70,21 @@ func ServeBackGround() {
 		// 取消置顶
 		c.AddFunc("0 * * * * *", unsetTop)
 
+		// 定时发布文章、晨读
+		c.AddFunc("0 * * * * *", publishScheduled)
+
 		// 每天对活跃用户奖励铜币
 		c.AddFunc("@daily", logic.DefaultUserRich.AwardCooper)
 
+		// 规则任务到期下线
+		c.AddFunc("@hourly", logic.DefaultMission.ExpireRuleMissions)
+
+		// 按规则批量发放徽章
+		c.AddFunc("@daily", logic.DefaultBadge.AwardAll)
+
+		// 重新计算用户声望等级（活跃度会衰减，注册天数会增长）
+		c.AddFunc("@daily", logic.DefaultUserLevel.RefreshAll)
+
 	}
 
 	// 两分钟刷一次浏览数（TODO：重启丢失问题？信号控制重启？）
@@ -90,6 +102,10 @@ func loadData() {
 	logic.LoadWebsiteSetting()
 	logic.LoadDefaultAvatar()
 	logic.LoadUserSetting()
+	logic.LoadRuleMissions()
+	logic.LoadUserLevels()
+	logic.LoadFilterRules()
+	logic.LoadSpamModel()
 
 	for {
 		select {
@@ -101,6 +117,14 @@ func loadData() {
 			logic.LoadRoleAuthorities()
 		case <-global.UserSettingChan:
 			logic.LoadUserSetting()
+		case <-global.MissionChan:
+			logic.LoadRuleMissions()
+		case <-global.UserLevelChan:
+			logic.LoadUserLevels()
+		case <-global.FilterRuleChan:
+			logic.LoadFilterRules()
+		case <-global.SpamModelChan:
+			logic.LoadSpamModel()
 		}
 	}
 }
@@ -157,6 +181,11 @@ func unsetTop() {
 	logic.DefaultTopic.AutoUnsetTop()
 }
 
+func publishScheduled() {
+	logic.DefaultArticle.PublishScheduled()
+	logic.DefaultReading.PublishScheduled()
+}
+
 func syncGCTTRepo() {
 	repo := config.ConfigFile.MustValue("gctt", "repo")
 	if repo == "" {
@@ -76,7 +76,8 @@ func main() {
 	admin.RegisterRoutes(adminG)
 
 	// appG := e.Group("/app", thirdmw.EchoCache())
-	appG := e.Group("/app")
+	// 服务端调用时用后台发放的应用密钥签名
+	appG := e.Group("/app", pwm.AppKeyAuth())
 	app.RegisterRoutes(appG)
 
 	std := standard.New(getAddr())
@@ -31,15 +31,25 @@ CREATE TABLE IF NOT EXISTS `topics` (
   `uid` int unsigned NOT NULL COMMENT '帖子作者',
   `lastreplyuid` int unsigned NOT NULL DEFAULT 0 COMMENT '最后回复者',
   `lastreplytime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '最后回复时间',
-  `flag` tinyint NOT NULL DEFAULT 0 COMMENT '审核标识,0-未审核;1-已审核;2-审核删除;3-用户自己删除',
+  `flag` tinyint NOT NULL DEFAULT 0 COMMENT '审核标识,0-未审核;1-已审核;2-审核删除;3-用户自己删除;4-影子封禁',
   `editor_uid` int unsigned NOT NULL DEFAULT 0 COMMENT '最后编辑人',
   `top` tinyint unsigned NOT NULL DEFAULT '0' COMMENT '置顶，0否，1置顶',
   `top_time` int unsigned NOT NULL DEFAULT 0 COMMENT '置顶时间',
   `tags` varchar(63) NOT NULL DEFAULT '' COMMENT 'tag，逗号分隔',
   `permission` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '访问权限：0-公开；1-登录用户可见；2-关注的人可见',
+  `locked` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否锁定：锁定后不能回复',
+  `closed` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否关闭：关闭后不能回复',
+  `close_reason` varchar(255) NOT NULL DEFAULT '' COMMENT '关闭原因',
+  `merged_to` int unsigned NOT NULL DEFAULT 0 COMMENT '被合并到的主题 tid',
+  `node_top` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否节点内置顶',
+  `version` int unsigned NOT NULL DEFAULT 0 COMMENT '版本号，每次修改加1，用于编辑冲突检测',
   `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `deleted_at` datetime DEFAULT NULL COMMENT '删除时间（放入回收站），NULL 表示未删除',
+  `deleted_by` int unsigned NOT NULL DEFAULT 0 COMMENT '删除人 uid',
+  `delete_reason` varchar(255) NOT NULL DEFAULT '' COMMENT '删除原因',
   PRIMARY KEY (`tid`),
+  KEY `deleted_at` (`deleted_at`),
   KEY `uid` (`uid`),
   KEY `nid` (`nid`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '主题内容表';
@@ -62,6 +72,38 @@ CREATE TABLE IF NOT EXISTS `topic_append` (
   KEY `tid` (`tid`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '主题附言表';
 
+CREATE TABLE IF NOT EXISTS `poll` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `tid` int unsigned NOT NULL DEFAULT 0 COMMENT '主题ID',
+  `multiple` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否多选',
+  `anonymous` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否匿名投票',
+  `deadline` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '截止时间，0 表示不限',
+  `closed` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否被提前结束',
+  `voters` int unsigned NOT NULL DEFAULT 0 COMMENT '投票人数',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `tid` (`tid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '主题中的投票';
+
+CREATE TABLE IF NOT EXISTS `poll_option` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `poll_id` int unsigned NOT NULL DEFAULT 0 COMMENT '投票ID',
+  `content` varchar(255) NOT NULL DEFAULT '' COMMENT '选项内容',
+  `votes` int unsigned NOT NULL DEFAULT 0 COMMENT '票数',
+  PRIMARY KEY (`id`),
+  KEY `poll_id` (`poll_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '投票选项';
+
+CREATE TABLE IF NOT EXISTS `poll_vote` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `poll_id` int unsigned NOT NULL DEFAULT 0 COMMENT '投票ID',
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '投票人',
+  `option_ids` varchar(255) NOT NULL DEFAULT '' COMMENT '选择的选项，多个逗号分隔',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `poll_uid` (`poll_id`, `uid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '用户投票记录，一人一票';
+
 CREATE TABLE IF NOT EXISTS `topics_node` (
   `nid` int unsigned NOT NULL AUTO_INCREMENT,
   `parent` int unsigned NOT NULL DEFAULT 0 COMMENT '父节点id，无父节点为0',
@@ -93,9 +135,13 @@ CREATE TABLE IF NOT EXISTS `comments` (
   `content` text NOT NULL,
   `uid` int unsigned NOT NULL COMMENT '回复者',
   `floor` int unsigned NOT NULL COMMENT '第几楼',
-  `flag` tinyint NOT NULL DEFAULT 0 COMMENT '审核标识,0-未审核;1-已审核;2-审核删除;3-用户自己删除',
+  `flag` tinyint NOT NULL DEFAULT 0 COMMENT '审核标识,0-未审核;1-已审核;2-审核删除;3-用户自己删除;4-影子封禁',
   `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `deleted_at` datetime DEFAULT NULL COMMENT '删除时间（放入回收站），NULL 表示未删除',
+  `deleted_by` int unsigned NOT NULL DEFAULT 0 COMMENT '删除人 uid',
+  `delete_reason` varchar(255) NOT NULL DEFAULT '' COMMENT '删除原因',
   PRIMARY KEY (`cid`),
+  KEY `deleted_at` (`deleted_at`),
   UNIQUE KEY (`objid`,`objtype`,`floor`),
   KEY (`uid`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '评论表（帖子回复、博客文章评论等，统一处理）';
@@ -113,8 +159,8 @@ CREATE TABLE IF NOT EXISTS `user_login` (
   `uid` int unsigned NOT NULL,
   `email` varchar(128) NOT NULL DEFAULT '',
   `username` varchar(20) NOT NULL COMMENT '用户名',
-  `passcode` char(12) NOT NULL DEFAULT '' COMMENT '加密随机数',
-  `passwd` char(32) NOT NULL DEFAULT '' COMMENT 'md5密码',
+  `passcode` char(12) NOT NULL DEFAULT '' COMMENT '加密随机数，旧的 md5 密码用',
+  `passwd` varchar(128) NOT NULL DEFAULT '' COMMENT '密码哈希：bcrypt$ 开头的是 bcrypt，否则是旧的 md5(passwd+passcode)',
   `login_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '最后一次登录时间（主动登录或cookie登录）',
   PRIMARY KEY (`uid`),
   UNIQUE KEY (`username`),
@@ -125,15 +171,15 @@ CREATE TABLE IF NOT EXISTS `user_login` (
 CREATE TABLE IF NOT EXISTS `bind_user` (
   `id` int unsigned NOT NULL AUTO_INCREMENT,
   `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '本站用户UID',
-  `type` tinyint NOT NULL DEFAULT 0 COMMENT '绑定的第三方类型,0-github',
+  `type` tinyint NOT NULL DEFAULT 0 COMMENT '绑定的第三方类型,0-github;1-gitee;2-gitlab;3-openid',
   `email` varchar(128) NOT NULL DEFAULT '' COMMENT '第三方邮箱',
   `tuid` int unsigned NOT NULL DEFAULT 0 COMMENT '第三方uid',
-  `username` varchar(20) NOT NULL DEFAULT '' COMMENT '第三方用户名',
-  `name` varchar(31) NOT NULL DEFAULT '' COMMENT '姓名',
-  `access_token` varchar(50) NOT NULL DEFAULT ''  COMMENT '第三方access_token',
-  `refresh_token` varchar(50) NOT NULL DEFAULT '' COMMENT '第三方refresh_token',
+  `username` varchar(128) NOT NULL DEFAULT '' COMMENT '第三方账号唯一标识：用户名，openid 为 sub',
+  `name` varchar(63) NOT NULL DEFAULT '' COMMENT '姓名',
+  `access_token` varchar(2048) NOT NULL DEFAULT ''  COMMENT '第三方access_token',
+  `refresh_token` varchar(2048) NOT NULL DEFAULT '' COMMENT '第三方refresh_token',
   `expire` int unsigned NOT NULL DEFAULT 0 COMMENT '过期时间',
-  `avatar` varchar(127) NOT NULL DEFAULT '' COMMENT '第三方头像',
+  `avatar` varchar(255) NOT NULL DEFAULT '' COMMENT '第三方头像',
   `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
   PRIMARY KEY (`id`),
   UNIQUE KEY `uniq_user_type` (`username`,`type`),
@@ -158,8 +204,12 @@ CREATE TABLE IF NOT EXISTS `user_info` (
   `is_third` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否通过第三方账号注册',
   `balance` int unsigned NOT NULL DEFAULT 0 COMMENT '财富余额（铜币）',
   `dau_auth` int unsigned NOT NULL DEFAULT 0 COMMENT '控制用户权限，如能否发文章等',
+  `level` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '声望等级，自动计算',
   `status` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '用户账号状态。0-默认；1-已审核；2-拒绝；3-冻结；4-停号',
   `is_root` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否超级用户，不受权限控制：1-是',
+  `email_verified` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '邮箱是否验证过：1-是',
+  `sanction` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '处罚：0-无；1-观察期；2-影子封禁',
+  `probation_left` int unsigned NOT NULL DEFAULT 0 COMMENT '观察期还需要审核的内容数',
   `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY (`uid`),
@@ -247,17 +297,59 @@ CREATE TABLE IF NOT EXISTS `wiki` (
   `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
   `title` varchar(255) NOT NULL COMMENT 'wiki标题',
   `content` longtext NOT NULL COMMENT 'wiki内容',
-  `uri` varchar(50) NOT NULL COMMENT 'uri',
+  `uri` varchar(191) NOT NULL COMMENT 'uri，层级用 / 分隔',
   `uid` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '作者',
   `cuid` varchar(100) NOT NULL DEFAULT '' COMMENT '贡献者uid,多个逗号分隔',
   `tags` varchar(63) NOT NULL DEFAULT '' COMMENT 'tag，逗号分隔',
   `viewnum` int(10) unsigned NOT NULL DEFAULT '0' COMMENT '浏览数',
+  `state` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '状态：0-正常；1-保护；2-锁定',
+  `version` int unsigned NOT NULL DEFAULT 0 COMMENT '版本号，每次修改加1，用于编辑冲突检测',
   `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `deleted_at` datetime DEFAULT NULL COMMENT '删除时间（放入回收站），NULL 表示未删除',
+  `deleted_by` int unsigned NOT NULL DEFAULT 0 COMMENT '删除人 uid',
+  `delete_reason` varchar(255) NOT NULL DEFAULT '' COMMENT '删除原因',
   PRIMARY KEY (`id`),
+  KEY `deleted_at` (`deleted_at`),
   UNIQUE KEY `uri` (`uri`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT 'wiki页';
 
+CREATE TABLE IF NOT EXISTS `wiki_revision` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `wiki_id` int unsigned NOT NULL DEFAULT 0 COMMENT 'wiki id',
+  `version` int unsigned NOT NULL DEFAULT 0 COMMENT '版本号，从1开始',
+  `title` varchar(255) NOT NULL DEFAULT '' COMMENT '该版本的标题',
+  `content` longtext NOT NULL COMMENT '该版本的内容',
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '编辑者',
+  `summary` varchar(100) NOT NULL DEFAULT '' COMMENT '编辑摘要',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `wiki_version` (`wiki_id`, `version`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT 'wiki 历史版本';
+
+CREATE TABLE IF NOT EXISTS `wiki_link` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `from_id` int unsigned NOT NULL DEFAULT 0 COMMENT '链出的 wiki id',
+  `to_uri` varchar(191) NOT NULL DEFAULT '' COMMENT '链接到的 wiki uri，页面可以还不存在',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `from_to` (`from_id`, `to_uri`),
+  KEY `to_uri` (`to_uri`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT 'wiki 页面之间的链接';
+
+CREATE TABLE IF NOT EXISTS `content_revision` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `objtype` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '类型：0-主题；1-文章；100-评论',
+  `objid` int unsigned NOT NULL DEFAULT 0 COMMENT '对象ID',
+  `version` int unsigned NOT NULL DEFAULT 0 COMMENT '版本号，1为原始版本',
+  `title` varchar(255) NOT NULL DEFAULT '' COMMENT '该版本的标题，评论为空',
+  `content` longtext NOT NULL COMMENT '该版本的内容',
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '编辑者，版本1为作者',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `obj_version` (`objtype`, `objid`, `version`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '主题、文章、评论的历史版本';
+
 CREATE TABLE IF NOT EXISTS `resource` (
   `id` int unsigned NOT NULL AUTO_INCREMENT,
   `title` varchar(255) NOT NULL COMMENT '资源标题',
@@ -269,9 +361,15 @@ CREATE TABLE IF NOT EXISTS `resource` (
   `lastreplyuid` int unsigned NOT NULL DEFAULT 0 COMMENT '最后回复者',
   `lastreplytime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '最后回复时间',
   `tags` varchar(63) NOT NULL DEFAULT '' COMMENT 'tag，逗号分隔',
+  `flag` tinyint NOT NULL DEFAULT 0 COMMENT '同 topics.flag，目前只用到 4-影子封禁',
+  `version` int unsigned NOT NULL DEFAULT 0 COMMENT '版本号，每次修改加1，用于编辑冲突检测',
   `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `deleted_at` datetime DEFAULT NULL COMMENT '删除时间（放入回收站），NULL 表示未删除',
+  `deleted_by` int unsigned NOT NULL DEFAULT 0 COMMENT '删除人 uid',
+  `delete_reason` varchar(255) NOT NULL DEFAULT '' COMMENT '删除原因',
   PRIMARY KEY (`id`),
+  KEY `deleted_at` (`deleted_at`),
   KEY (`url`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '资源';
 
@@ -315,16 +413,23 @@ CREATE TABLE IF NOT EXISTS `articles` (
   `top` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '置顶，0否，1置顶',
   `markdown` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否是markwon格式：0-否，1-是',
   `gctt` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否是 gctt 翻译：0-否则；1-是',
-  `status` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '状态：0-初始抓取；1-已上线；2-下线(审核拒绝)',
+  `status` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '状态：0-初始抓取；1-已上线；2-下线(审核拒绝)；3-定时发布；4-影子封禁',
+  `version` int unsigned NOT NULL DEFAULT 0 COMMENT '版本号，每次修改加1，用于编辑冲突检测',
+  `publish_at` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '定时发布时间',
   `op_user` varchar(20) NOT NULL DEFAULT '' COMMENT '操作人',
   `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `deleted_at` datetime DEFAULT NULL COMMENT '删除时间（放入回收站），NULL 表示未删除',
+  `deleted_by` int unsigned NOT NULL DEFAULT 0 COMMENT '删除人 uid',
+  `delete_reason` varchar(255) NOT NULL DEFAULT '' COMMENT '删除原因',
   PRIMARY KEY (`id`),
+  KEY `deleted_at` (`deleted_at`),
   UNIQUE KEY (`url`),
   KEY (`top`),
   KEY (`author_txt`),
   KEY (`domain`),
-  KEY (`mtime`)
+  KEY (`mtime`),
+  KEY `status_publish` (`status`, `publish_at`)
 )ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '网络文章聚合表';
 
 CREATE TABLE IF NOT EXISTS `article_gctt` (
@@ -429,9 +534,14 @@ CREATE TABLE IF NOT EXISTS `open_project` (
   `lastreplyuid` int unsigned NOT NULL DEFAULT 0 COMMENT '最后回复者',
   `lastreplytime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '最后回复时间',
   `status` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '状态：0-新建；1-已上线；2-下线(审核拒绝)',
+  `version` int unsigned NOT NULL DEFAULT 0 COMMENT '版本号，每次修改加1，用于编辑冲突检测',
   `ctime` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '加入时间',
   `mtime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `deleted_at` datetime DEFAULT NULL COMMENT '删除时间（放入回收站），NULL 表示未删除',
+  `deleted_by` int unsigned NOT NULL DEFAULT 0 COMMENT '删除人 uid',
+  `delete_reason` varchar(255) NOT NULL DEFAULT '' COMMENT '删除原因',
   PRIMARY KEY (`id`),
+  KEY `deleted_at` (`deleted_at`),
   KEY (`uri`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '开源项目';
 
@@ -444,8 +554,11 @@ CREATE TABLE IF NOT EXISTS `morning_reading` (
   `moreurls` varchar(1024) NOT NULL DEFAULT '' COMMENT '可能顺带推荐多篇文章；url逗号分隔',
   `clicknum` int unsigned NOT NULL DEFAULT 0 COMMENT '点击数',
   `username` varchar(20) NOT NULL DEFAULT '' COMMENT '发布人',
+  `status` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '状态：0-已发布；1-定时发布',
+  `publish_at` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '定时发布时间',
   `ctime` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
-  PRIMARY KEY (`id`)
+  PRIMARY KEY (`id`),
+  KEY `status_publish` (`status`, `publish_at`)
 )ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '技术晨读表';
 
 CREATE TABLE IF NOT EXISTS `image` (
@@ -486,7 +599,11 @@ CREATE TABLE IF NOT EXISTS `book` (
   `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '分享人UID',
   `created_at` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '创建时间',
   `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '最后更新时间',
+  `deleted_at` datetime DEFAULT NULL COMMENT '删除时间（放入回收站），NULL 表示未删除',
+  `deleted_by` int unsigned NOT NULL DEFAULT 0 COMMENT '删除人 uid',
+  `delete_reason` varchar(255) NOT NULL DEFAULT '' COMMENT '删除原因',
   PRIMARY KEY (`id`),
+  KEY `deleted_at` (`deleted_at`),
   KEY `name` (`name`),
   KEY `created_at` (`created_at`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='图书表';
@@ -573,9 +690,34 @@ CREATE TABLE IF NOT EXISTS `mission` (
   `incr` int unsigned NOT NULL DEFAULT 0 COMMENT '连续登录增量，连续型任务',
   `state` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '状态: 0-正常，未完成；1-已过期；2-已下线',
   `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
-  PRIMARY KEY (`id`)
+  `desc` varchar(255) NOT NULL DEFAULT '' COMMENT '任务说明，规则任务用',
+  `event` varchar(15) NOT NULL DEFAULT '' COMMENT '统计事件：publish/comment/like/liked/view/login，规则任务用',
+  `objtype` tinyint NOT NULL DEFAULT -1 COMMENT '对象类型，-1 表示不限，规则任务用',
+  `target` int unsigned NOT NULL DEFAULT 0 COMMENT '目标次数，规则任务用',
+  `period` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '统计周期：0-一次性；1-每天；2-每周；3-每月',
+  `per_object` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否按单个对象统计',
+  `continuous` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否要求连续天数',
+  `start_at` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '开始时间，0 表示不限',
+  `end_at` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '结束时间，0 表示不限',
+  PRIMARY KEY (`id`),
+  KEY `type` (`type`, `state`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '任务表';
 
+CREATE TABLE IF NOT EXISTS `user_mission` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '用户UID',
+  `mission_id` int unsigned NOT NULL DEFAULT 0 COMMENT '任务ID',
+  `period` int unsigned NOT NULL DEFAULT 0 COMMENT '周期标识：一次性为0，每天为Ymd，每周为年+周，每月为Ym',
+  `objid` int unsigned NOT NULL DEFAULT 0 COMMENT '按单个对象统计时的对象ID',
+  `progress` int unsigned NOT NULL DEFAULT 0 COMMENT '当前进度',
+  `last_date` int unsigned NOT NULL DEFAULT 0 COMMENT '最后一次计数的日期',
+  `state` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '状态：0-进行中；1-已完成',
+  `finished_at` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00' COMMENT '完成时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uid_mission` (`uid`, `mission_id`, `period`, `objid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '用户规则任务进度';
+
 CREATE TABLE IF NOT EXISTS `user_login_mission` (
   `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '用户UID',
   `date` int unsigned NOT NULL DEFAULT 0 COMMENT '最新领取日期',
@@ -586,6 +728,18 @@ CREATE TABLE IF NOT EXISTS `user_login_mission` (
   PRIMARY KEY (`uid`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '用户登录任务';
 
+CREATE TABLE IF NOT EXISTS `user_login_record` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '用户UID',
+  `date` int unsigned NOT NULL DEFAULT 0 COMMENT '签到日期',
+  `days` int unsigned NOT NULL DEFAULT 0 COMMENT '当天的连续登录天数',
+  `award` int unsigned NOT NULL DEFAULT 0 COMMENT '领取的奖励（铜币），补签为0',
+  `makeup` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否补签：0-否；1-是',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uid_date` (`uid`, `date`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '用户每日登录领取记录';
+
 CREATE TABLE IF NOT EXISTS `user_recharge` (
   `id` int unsigned NOT NULL AUTO_INCREMENT,
   `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '用户UID',
@@ -667,7 +821,8 @@ CREATE TABLE `gift_redeem` (
   `exchange` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否已兑换：0-否；1-是',
   `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '兑换者UID',
   `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '最后更新时间',
-  PRIMARY KEY (`id`)
+  PRIMARY KEY (`id`),
+  KEY `gift_exchange` (`gift_id`, `exchange`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '物品兑换码';
 
 CREATE TABLE `user_exchange_record` (
@@ -817,3 +972,219 @@ CREATE TABLE `wechat_user` (
   KEY `uid` (`uid`),
   KEY `updated_at` (`updated_at`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='微信用户绑定表';
+
+CREATE TABLE IF NOT EXISTS `badge` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(31) NOT NULL DEFAULT '' COMMENT '徽章名',
+  `desc` varchar(255) NOT NULL DEFAULT '' COMMENT '说明',
+  `icon` varchar(31) NOT NULL DEFAULT '' COMMENT 'font-awesome 图标',
+  `color` varchar(15) NOT NULL DEFAULT '' COMMENT '颜色',
+  `rule` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '授予规则：0-仅手动；1-主题数；2-文章数；3-回复数；4-活跃度；5-GCTT翻译数；6-注册天数',
+  `threshold` int unsigned NOT NULL DEFAULT 0 COMMENT '阈值',
+  `seq` int unsigned NOT NULL DEFAULT 0 COMMENT '排序，越大越靠前',
+  `state` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '状态：0-正常；1-下线',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '成就徽章';
+
+CREATE TABLE IF NOT EXISTS `user_badge` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '用户UID',
+  `badge_id` int unsigned NOT NULL DEFAULT 0 COMMENT '徽章ID',
+  `op_uid` int unsigned NOT NULL DEFAULT 0 COMMENT '授予人UID，0 表示按规则自动授予',
+  `state` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '状态：0-正常；1-已收回',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uid_badge` (`uid`, `badge_id`),
+  KEY `badge_id` (`badge_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '用户获得的徽章';
+
+CREATE TABLE IF NOT EXISTS `user_level` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `level` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '等级',
+  `name` varchar(15) NOT NULL DEFAULT '' COMMENT '等级名称',
+  `min_weight` int unsigned NOT NULL DEFAULT 0 COMMENT '活跃度门槛',
+  `min_content` int unsigned NOT NULL DEFAULT 0 COMMENT '发布且未被删除的内容数门槛',
+  `min_days` int unsigned NOT NULL DEFAULT 0 COMMENT '注册天数门槛',
+  `privileges` int unsigned NOT NULL DEFAULT 0 COMMENT '解锁的特权：1-发布外链；2-上传图片；4-置顶主题；8-编辑Wiki；16-免审核',
+  `publish_balance` int unsigned NOT NULL DEFAULT 20 COMMENT '发布内容需要的最低铜币',
+  `comment_balance` int unsigned NOT NULL DEFAULT 5 COMMENT '评论需要的最低铜币',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `level` (`level`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '声望等级';
+
+CREATE TABLE IF NOT EXISTS `draft` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '用户UID',
+  `objtype` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '类型：0-主题；1-文章',
+  `title` varchar(255) NOT NULL DEFAULT '' COMMENT '标题',
+  `content` longtext NOT NULL COMMENT '内容',
+  `extra` varchar(1024) NOT NULL DEFAULT '' COMMENT '其他表单字段，json 格式',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `uid` (`uid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '主题、文章草稿';
+
+CREATE TABLE IF NOT EXISTS `topic_mod_log` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `tid` int unsigned NOT NULL DEFAULT 0 COMMENT '主题 tid',
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '操作人 uid',
+  `action` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '操作：1-锁定；2-解锁；3-关闭；4-重新打开；5-移动；6-合并；7-删除；8-节点置顶；9-取消节点置顶',
+  `from_nid` int unsigned NOT NULL DEFAULT 0 COMMENT '移动：原节点',
+  `to_nid` int unsigned NOT NULL DEFAULT 0 COMMENT '移动：新节点',
+  `target_tid` int unsigned NOT NULL DEFAULT 0 COMMENT '合并：合并到的主题 tid',
+  `reason` varchar(255) NOT NULL DEFAULT '' COMMENT '原因',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `tid` (`tid`),
+  KEY `target_tid` (`target_tid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '主题管理记录';
+
+CREATE TABLE IF NOT EXISTS `node_moderator` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `nid` int unsigned NOT NULL DEFAULT 0 COMMENT '节点 nid，管理范围包括子节点',
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '版主 uid',
+  `privileges` int unsigned NOT NULL DEFAULT 0 COMMENT '权限：1-编辑；2-锁定/关闭；4-移动；8-删除；16-节点内置顶',
+  `op_user` varchar(20) NOT NULL DEFAULT '' COMMENT '任命人',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `nid_uid` (`nid`, `uid`),
+  KEY `uid` (`uid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '节点版主';
+
+CREATE TABLE IF NOT EXISTS `report` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `objtype` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '类型：0-主题；1-文章；2-资源；100-评论；102-用户',
+  `objid` int unsigned NOT NULL DEFAULT 0 COMMENT '被举报对象ID',
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '举报人 uid',
+  `reason` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '原因：1-广告、垃圾信息；2-辱骂、人身攻击；3-色情、低俗；4-违法违规；5-其他；100-命中过滤规则；101-疑似垃圾内容',
+  `content` varchar(255) NOT NULL DEFAULT '' COMMENT '补充说明',
+  `status` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '处理结果：0-待处理；1-已驳回；2-已删除；3-已删除并封禁',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `obj` (`objtype`, `objid`),
+  KEY `uid` (`uid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '用户举报';
+
+CREATE TABLE IF NOT EXISTS `report_object` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `objtype` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '类型：0-主题；1-文章；2-资源；100-评论；102-用户',
+  `objid` int unsigned NOT NULL DEFAULT 0 COMMENT '被举报对象ID',
+  `obj_uid` int unsigned NOT NULL DEFAULT 0 COMMENT '被举报内容的作者',
+  `title` varchar(255) NOT NULL DEFAULT '' COMMENT '标题，评论为内容摘要，用户为用户名',
+  `url` varchar(255) NOT NULL DEFAULT '' COMMENT '被举报对象的链接',
+  `num` int unsigned NOT NULL DEFAULT 0 COMMENT '待处理的举报数',
+  `hidden` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否已被隐藏',
+  `orig_state` int NOT NULL DEFAULT 0 COMMENT '隐藏前的状态，恢复时用',
+  `status` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '处理状态：0-待处理；1-已驳回；2-已删除；3-已删除并封禁',
+  `op_user` varchar(20) NOT NULL DEFAULT '' COMMENT '处理人',
+  `handled_at` datetime NOT NULL DEFAULT '2000-01-01 00:00:00' COMMENT '处理时间',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `obj` (`objtype`, `objid`),
+  KEY `status_num` (`status`, `num`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '举报处理队列，每个被举报对象一条';
+
+CREATE TABLE IF NOT EXISTS `filter_rule` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `type` tinyint unsigned NOT NULL DEFAULT 1 COMMENT '类型：1-关键词；2-正则；3-链接域名',
+  `pattern` varchar(255) NOT NULL DEFAULT '' COMMENT '规则内容',
+  `scope` tinyint unsigned NOT NULL DEFAULT 3 COMMENT '检查范围：1-标题；2-内容；3-标题和内容',
+  `action` tinyint unsigned NOT NULL DEFAULT 3 COMMENT '处理方式：1-替换为*；2-人工审核；3-拒绝发布；4-冻结账号',
+  `hits` int unsigned NOT NULL DEFAULT 0 COMMENT '累计命中次数',
+  `enabled` tinyint unsigned NOT NULL DEFAULT 1 COMMENT '是否启用',
+  `remark` varchar(255) NOT NULL DEFAULT '' COMMENT '备注',
+  `op_user` varchar(20) NOT NULL DEFAULT '' COMMENT '最后操作人',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `enabled` (`enabled`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '内容过滤规则';
+
+CREATE TABLE IF NOT EXISTS `filter_log` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `rule_id` int unsigned NOT NULL DEFAULT 0 COMMENT '命中的规则',
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '发布者',
+  `field` varchar(15) NOT NULL DEFAULT '' COMMENT '字段：title 或 content',
+  `matched` varchar(255) NOT NULL DEFAULT '' COMMENT '命中的内容',
+  `action` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '命中时规则的处理方式',
+  `uri` varchar(255) NOT NULL DEFAULT '' COMMENT '请求地址',
+  `excerpt` varchar(512) NOT NULL DEFAULT '' COMMENT '命中位置附近的内容',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `rule_id` (`rule_id`),
+  KEY `uid` (`uid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '内容过滤命中日志';
+
+CREATE TABLE IF NOT EXISTS `spam_sample` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `objtype` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '类型：0-主题；1-文章；2-资源；100-评论',
+  `objid` int unsigned NOT NULL DEFAULT 0 COMMENT '对象ID',
+  `text` text NOT NULL COMMENT '标题和内容',
+  `is_spam` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否垃圾内容',
+  `op_user` varchar(20) NOT NULL DEFAULT '' COMMENT '标注人',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `obj` (`objtype`, `objid`),
+  KEY `is_spam` (`is_spam`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '垃圾内容分类器训练样本';
+
+CREATE TABLE IF NOT EXISTS `spam_model` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `data` longtext NOT NULL COMMENT '分类器数据（JSON）',
+  `spam_num` int unsigned NOT NULL DEFAULT 0 COMMENT '垃圾样本数',
+  `ham_num` int unsigned NOT NULL DEFAULT 0 COMMENT '正常样本数',
+  `threshold` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '评估时的阈值（百分比）',
+  `precision` decimal(5,4) NOT NULL DEFAULT 0 COMMENT '准确率',
+  `recall` decimal(5,4) NOT NULL DEFAULT 0 COMMENT '召回率',
+  `op_user` varchar(20) NOT NULL DEFAULT '' COMMENT '操作人',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '垃圾内容分类器训练记录';
+
+CREATE TABLE IF NOT EXISTS `user_totp` (
+  `uid` int unsigned NOT NULL DEFAULT 0,
+  `secret` varchar(64) NOT NULL DEFAULT '' COMMENT 'base32 编码的密钥',
+  `enabled` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '是否已启用：扫码后输入动态码确认才启用',
+  `recovery_codes` varchar(1024) NOT NULL DEFAULT '' COMMENT '未使用的恢复码的 sha256，逗号分隔',
+  `last_step` bigint unsigned NOT NULL DEFAULT 0 COMMENT '最后一次使用的时间步',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`uid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '用户两步验证（TOTP）';
+
+CREATE TABLE IF NOT EXISTS `access_token` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `uid` int unsigned NOT NULL DEFAULT 0,
+  `name` varchar(63) NOT NULL DEFAULT '' COMMENT '用途',
+  `token_hash` char(64) NOT NULL DEFAULT '' COMMENT '令牌的 sha256',
+  `hint` varchar(15) NOT NULL DEFAULT '' COMMENT '令牌的前几位',
+  `scopes` varchar(63) NOT NULL DEFAULT '' COMMENT '权限，逗号分隔：read,publish,comment,message',
+  `expire` int unsigned NOT NULL DEFAULT 0 COMMENT '过期时间（unix 时间戳），0 表示永不过期',
+  `last_used` int unsigned NOT NULL DEFAULT 0 COMMENT '最后使用时间（unix 时间戳）',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `token_hash` (`token_hash`),
+  KEY `uid` (`uid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '个人访问令牌';
+
+CREATE TABLE IF NOT EXISTS `app_key` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(63) NOT NULL DEFAULT '' COMMENT '调用方名称',
+  `app_key` varchar(31) NOT NULL DEFAULT '' COMMENT '应用标识，请求参数中的 from',
+  `secret` varchar(127) NOT NULL DEFAULT '' COMMENT '签名密钥',
+  `uid` int unsigned NOT NULL DEFAULT 0 COMMENT '以哪个用户的身份调用，0 表示只能调用公开接口',
+  `scopes` varchar(63) NOT NULL DEFAULT '' COMMENT '权限，逗号分隔：read,publish,comment,message',
+  `enabled` tinyint unsigned NOT NULL DEFAULT 1 COMMENT '是否启用',
+  `last_used` int unsigned NOT NULL DEFAULT 0 COMMENT '最后调用时间（unix 时间戳）',
+  `remark` varchar(255) NOT NULL DEFAULT '' COMMENT '备注',
+  `op_user` varchar(20) NOT NULL DEFAULT '' COMMENT '操作人',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `app_key` (`app_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT '应用密钥';
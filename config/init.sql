@@ -53,7 +53,74 @@ VALUES
 	(40, '常规', 39, 0, '/admin/setting/genneral/modify', '', '2017-05-21 16:05:00', '2017-05-21 16:05:46'),
 	(41, '导航', 39, 0, '/admin/setting/nav/modify', '', '2017-05-21 18:01:00', '2017-05-21 18:01:16'),
 	(42, '节点管理', 15, 0, '/admin/community/node/list', 'polaris', '2017-09-01 22:23:08', '2017-09-01 23:10:38'),
-	(43, '编辑/新增节点', 15, 42, '/admin/community/node/modify', 'polaris', '2017-09-01 22:23:08', '2017-09-01 23:11:09');
+	(43, '编辑/新增节点', 15, 42, '/admin/community/node/modify', 'polaris', '2017-09-01 22:23:08', '2017-09-01 23:11:09'),
+	(44, '运营管理', 0, 0, '', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(45, '任务管理', 44, 0, '/admin/operation/mission/list', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(46, '任务查询', 44, 45, '/admin/operation/mission/query.html', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(47, '新建任务', 44, 45, '/admin/operation/mission/new', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(48, '修改任务', 44, 45, '/admin/operation/mission/modify', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(49, '任务上下线', 44, 45, '/admin/operation/mission/update_state', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(50, '徽章管理', 44, 0, '/admin/operation/badge/list', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(51, '徽章查询', 44, 50, '/admin/operation/badge/query.html', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(52, '新建徽章', 44, 50, '/admin/operation/badge/new', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(53, '修改徽章', 44, 50, '/admin/operation/badge/modify', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(54, '徽章拥有者', 44, 50, '/admin/operation/badge/holders', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(55, '授予徽章', 44, 50, '/admin/operation/badge/grant', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(56, '收回徽章', 44, 50, '/admin/operation/badge/revoke', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(57, '物品管理', 44, 0, '/admin/operation/gift/list', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(58, '物品查询', 44, 57, '/admin/operation/gift/query.html', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(59, '新建物品', 44, 57, '/admin/operation/gift/new', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(60, '修改物品', 44, 57, '/admin/operation/gift/modify', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(61, '物品上下线', 44, 57, '/admin/operation/gift/update_state', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(62, '物品补货', 44, 57, '/admin/operation/gift/restock', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(63, '兑换码管理', 44, 57, '/admin/operation/gift/redeem', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(64, '导入兑换码', 44, 57, '/admin/operation/gift/redeem/import', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(65, '兑换记录', 44, 57, '/admin/operation/gift/records', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(66, '兑换记录查询', 44, 57, '/admin/operation/gift/records/query.html', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(67, '导出兑换记录', 44, 57, '/admin/operation/gift/records/export', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(68, '等级', 39, 0, '/admin/setting/level/list', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(69, '新建等级', 39, 68, '/admin/setting/level/new', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(70, '修改等级', 39, 68, '/admin/setting/level/modify', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(71, '删除等级', 39, 68, '/admin/setting/level/del', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(72, '锁定帖子', 15, 16, '/admin/community/topic/lock', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(73, '关闭帖子', 15, 16, '/admin/community/topic/close', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(74, '移动帖子', 15, 16, '/admin/community/topic/move', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(75, '合并帖子', 15, 16, '/admin/community/topic/merge', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(76, '删除帖子', 15, 16, '/admin/community/topic/del', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(77, '节点内置顶帖子', 15, 16, '/admin/community/topic/node_top', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(78, '节点版主', 15, 42, '/admin/community/node/moderator/list', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(79, '任命/修改版主', 15, 42, '/admin/community/node/moderator/modify', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(80, '撤销版主', 15, 42, '/admin/community/node/moderator/del', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(81, '举报处理', 15, 0, '/admin/community/report/list', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(82, '举报查询', 15, 81, '/admin/community/report/query.html', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(83, '驳回举报', 15, 81, '/admin/community/report/approve', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(84, '删除被举报内容', 15, 81, '/admin/community/report/remove', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(85, '删除内容并封禁作者', 15, 81, '/admin/community/report/ban', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(86, '回收站', 15, 0, '/admin/community/trash/list', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(87, '回收站查询', 15, 86, '/admin/community/trash/query.html', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(88, '删除内容', 15, 86, '/admin/community/trash/del', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(89, '恢复内容', 15, 86, '/admin/community/trash/restore', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(90, '内容过滤', 15, 0, '/admin/community/filter/list', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(91, '过滤规则查询', 15, 90, '/admin/community/filter/query.html', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(92, '新建过滤规则', 15, 90, '/admin/community/filter/new', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(93, '修改过滤规则', 15, 90, '/admin/community/filter/modify', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(94, '删除过滤规则', 15, 90, '/admin/community/filter/del', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(95, '过滤命中日志', 15, 90, '/admin/community/filter/logs', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(96, '命中日志查询', 15, 90, '/admin/community/filter/logs/query.html', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(97, '频率限制', 1, 0, '/admin/user/ratelimit/list', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(98, '解除频率限制', 1, 97, '/admin/user/ratelimit/reset', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(99, '垃圾内容样本', 15, 0, '/admin/community/spam/samples', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(100, '样本查询', 15, 99, '/admin/community/spam/samples/query.html', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(101, '修正样本标注', 15, 99, '/admin/community/spam/label', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(102, '删除样本', 15, 99, '/admin/community/spam/del', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(103, '垃圾内容分类器', 15, 99, '/admin/community/spam/models', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(104, '重新训练分类器', 15, 99, '/admin/community/spam/retrain', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(105, '应用密钥', 39, 0, '/admin/setting/appkey/list', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(106, '应用密钥查询', 39, 105, '/admin/setting/appkey/query.html', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(107, '新建应用密钥', 39, 105, '/admin/setting/appkey/new', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(108, '修改应用密钥', 39, 105, '/admin/setting/appkey/modify', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(109, '重新生成应用密钥', 39, 105, '/admin/setting/appkey/reset_secret', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00'),
+	(110, '删除应用密钥', 39, 105, '/admin/setting/appkey/del', '', '2026-10-19 10:00:00', '2026-10-19 10:00:00');
 
 
 INSERT INTO `website_setting` (`id`, `name`, `domain`, `title_suffix`, `favicon`, `logo`, `start_year`, `blog_url`, `reading_menu`, `docs_menu`, `slogan`, `beian`, `friends_logo`, `footer_nav`, `project_df_logo`, `index_nav`, `created_at`, `updated_at`)
@@ -67,7 +134,24 @@ VALUES
 INSERT INTO `user_setting` (`id`, `key`, `value`, `remark`, `created_at`)
 VALUES
 	(1, 'new_user_wait', 0, '新用户注册多久能发布帖子，单位秒，0表示没限制', '2017-05-30 18:11:31'),
-	(2, 'can_edit_time', 300, '发布后多久内能够编辑，单位秒', '2017-05-30 18:12:53');
+	(2, 'can_edit_time', 300, '发布后多久内能够编辑，单位秒', '2017-05-30 18:12:53'),
+	(3, 'article_edit_time', 1296000, '文章发布后多久内作者能够编辑，单位秒，0表示没限制', '2026-10-19 10:00:00'),
+	(4, 'report_hide_num', 5, '内容被多少人举报后自动隐藏，0表示不自动隐藏', '2026-10-19 10:00:00'),
+	(5, 'spam_threshold', 90, '垃圾内容概率达到多少（百分比）时放入举报处理队列，0表示不检查', '2026-10-19 10:00:00'),
+	(6, 'probation_num', 3, '新用户的前多少条内容需要审核通过后才展示，0表示不需要', '2026-10-19 10:00:00'),
+	(7, 'require_admin_2fa', 0, '管理员是否必须启用两步验证，1表示必须，0表示不要求', '2026-10-19 10:00:00');
+
+INSERT INTO `filter_rule` (`id`, `type`, `pattern`, `scope`, `action`, `enabled`, `remark`)
+VALUES
+	(1, 1, '发票', 3, 4, 1, '原 env.ini [sensitive] 配置'),
+	(2, 1, '共产党', 2, 4, 1, '原 env.ini [sensitive] 配置');
+
+INSERT INTO `user_level` (`id`, `level`, `name`, `min_weight`, `min_content`, `min_days`, `privileges`, `publish_balance`, `comment_balance`, `created_at`)
+VALUES
+	(1, 0, '新手', 0, 0, 0, 2, 20, 5, '2026-10-19 10:00:00'),
+	(2, 1, '入门', 50, 3, 7, 1, 20, 5, '2026-10-19 10:00:00'),
+	(3, 2, '进阶', 200, 20, 30, 12, 10, 0, '2026-10-19 10:00:00'),
+	(4, 3, '资深', 500, 50, 365, 16, 0, 0, '2026-10-19 10:00:00');
 
 INSERT INTO `mission` (`id`, `name`, `type`, `fixed`, `min`, `max`, `incr`, `state`, `created_at`)
 VALUES
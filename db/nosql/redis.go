@@ -111,7 +111,7 @@ func (this *RedisClient) SET(key string, val interface{}, expireSeconds int) err
 
 	args := redis.Args{}.Add(key, val)
 	if expireSeconds != 0 {
-		args.Add("EX").Add(expireSeconds)
+		args = args.Add("EX", expireSeconds)
 	}
 	_, err := redis.String(this.Conn.Do("SET", args...))
 	return err
@@ -250,6 +250,49 @@ func (this *RedisClient) HSCAN(key string, cursor interface{}, optionArgs ...int
 	return newCursor, data, err
 }
 
+func (this *RedisClient) HMSET(key string, fields map[string]interface{}) error {
+	if this.err != nil {
+		return this.err
+	}
+
+	key = this.key(key)
+
+	_, err := redis.String(this.Conn.Do("HMSET", redis.Args{}.Add(key).AddFlat(fields)...))
+	return err
+}
+
+func (this *RedisClient) SADD(key string, members ...interface{}) error {
+	if this.err != nil {
+		return this.err
+	}
+
+	key = this.key(key)
+
+	_, err := redis.Int(this.Conn.Do("SADD", redis.Args{}.Add(key).Add(members...)...))
+	return err
+}
+
+func (this *RedisClient) SREM(key string, members ...interface{}) error {
+	if this.err != nil {
+		return this.err
+	}
+
+	key = this.key(key)
+
+	_, err := redis.Int(this.Conn.Do("SREM", redis.Args{}.Add(key).Add(members...)...))
+	return err
+}
+
+func (this *RedisClient) SMEMBERS(key string) ([]string, error) {
+	if this.err != nil {
+		return nil, this.err
+	}
+
+	key = this.key(key)
+
+	return redis.Strings(this.Conn.Do("SMEMBERS", key))
+}
+
 func (this *RedisClient) ZADD(key string, score, member interface{}, optionArgs ...interface{}) error {
 	if this.err != nil {
 		return this.err
@@ -379,6 +422,21 @@ func (this *RedisClient) zrank(command, key string, member interface{}) int {
 	return val + 1
 }
 
+// EVAL 执行 lua 脚本（先尝试 EVALSHA，脚本未缓存时再 EVAL），keys 会加上前缀
+func (this *RedisClient) EVAL(script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	if this.err != nil {
+		return nil, this.err
+	}
+
+	keysAndArgs := make([]interface{}, 0, len(keys)+len(args))
+	for _, key := range keys {
+		keysAndArgs = append(keysAndArgs, this.key(key))
+	}
+	keysAndArgs = append(keysAndArgs, args...)
+
+	return script.Do(this.Conn, keysAndArgs...)
+}
+
 func (this *RedisClient) Close() {
 	if this.Conn != nil {
 		this.Conn.Close()
@@ -17,3 +17,15 @@ var RoleAuthChan = make(chan struct{}, 1)
 
 // UserSettingChan .
 var UserSettingChan = make(chan struct{}, 1)
+
+// MissionChan .
+var MissionChan = make(chan struct{}, 1)
+
+// UserLevelChan .
+var UserLevelChan = make(chan struct{}, 1)
+
+// FilterRuleChan .
+var FilterRuleChan = make(chan struct{}, 1)
+
+// SpamModelChan .
+var SpamModelChan = make(chan struct{}, 1)
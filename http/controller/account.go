@@ -37,9 +37,18 @@ func (a AccountController) RegisterRoute(g *echo.Group) {
 	g.Post("/account/send_activate_email", a.SendActivateEmail)
 	g.Get("/account/activate", a.Activate)
 	g.Any("/account/login", a.Login)
+	g.Any("/account/login/2fa", a.Login2FA)
 	g.Any("/account/edit", a.Edit, middleware.NeedLogin())
 	g.Post("/account/change_avatar", a.ChangeAvatar, middleware.NeedLogin())
 	g.Post("/account/changepwd", a.ChangePwd, middleware.NeedLogin())
+	g.Post("/account/sessions/revoke", a.RevokeSession, middleware.NeedLogin())
+	g.Post("/account/sessions/revoke_all", a.RevokeAllSessions, middleware.NeedLogin())
+	g.Post("/account/tokens/new", a.CreateAccessToken, middleware.NeedLogin())
+	g.Post("/account/tokens/delete", a.DeleteAccessToken, middleware.NeedLogin())
+	g.Post("/account/2fa/enroll", a.Enroll2FA, middleware.NeedLogin())
+	g.Post("/account/2fa/enable", a.Enable2FA, middleware.NeedLogin())
+	g.Post("/account/2fa/disable", a.Disable2FA, middleware.NeedLogin())
+	g.Post("/account/2fa/recovery_codes", a.RegenRecoveryCodes, middleware.NeedLogin())
 	g.Any("/account/forgetpwd", a.ForgetPasswd)
 	g.Any("/account/resetpwd", a.ResetPasswd)
 	g.Get("/account/logout", a.Logout, middleware.NeedLogin())
@@ -89,6 +98,12 @@ func (a AccountController) Register(ctx echo.Context) error {
 		form.Set(field, ctx.FormValue(field))
 	}
 
+	// 同一 IP 注册太频繁
+	if wait := logic.DefaultRateLimit.Limit("register", nil, goutils.RemoteIp(xhttp.Request(ctx))); wait > 0 {
+		data["error"] = "注册太频繁，请 " + middleware.RetryAfterText(wait) + "后再试"
+		return render(ctx, registerTpl, data)
+	}
+
 	// 入库
 	errMsg, err := logic.DefaultUser.CreateUser(ctx, form)
 	if err != nil {
@@ -130,7 +145,10 @@ func (a AccountController) Register(ctx echo.Context) error {
 
 	// 不验证邮箱，注册完成直接登录
 	// 自动登录
-	xhttp.SetLoginCookie(ctx, username)
+	user := logic.DefaultUser.FindOne(ctx, "username", username)
+	if err = xhttp.SetLoginCookie(ctx, user.Uid); err != nil {
+		return render(ctx, "login.html", map[string]interface{}{"username": username, "error": "注册成功，但自动登录失败，请登录"})
+	}
 
 	return ctx.Redirect(http.StatusSeeOther, "/balance")
 }
@@ -197,7 +215,10 @@ func (AccountController) Activate(ctx echo.Context) error {
 	helper.RegActivateCode.DelUUID(uuid)
 
 	// 自动登录
-	xhttp.SetLoginCookie(ctx, user.Username)
+	if err = xhttp.SetLoginCookie(ctx, user.Uid); err != nil {
+		data["error"] = "账号已激活，但自动登录失败，请登录"
+		return render(ctx, contentTpl, data)
+	}
 
 	// return render(ctx, contentTpl, data)
 	return ctx.Redirect(http.StatusSeeOther, "/balance")
@@ -229,10 +250,24 @@ func (AccountController) Login(ctx echo.Context) error {
 		return render(ctx, contentTpl, data)
 	}
 
+	// 同一用户名或 IP 登录尝试太频繁
+	if wait := logic.DefaultRateLimit.LimitLogin(username, goutils.RemoteIp(xhttp.Request(ctx))); wait > 0 {
+		errMsg := "登录尝试太频繁，请 " + middleware.RetryAfterText(wait) + "后再试"
+		if util.IsAjax(ctx) {
+			return fail(ctx, 1, errMsg)
+		}
+
+		data["username"] = username
+		data["error"] = errMsg
+		return render(ctx, contentTpl, data)
+	}
+
 	// 处理用户登录
 	passwd := ctx.FormValue("passwd")
 	userLogin, err := logic.DefaultUser.Login(ctx, username, passwd)
 	if err != nil {
+		logic.DefaultRateLimit.LoginFailed(username)
+
 		data["username"] = username
 		data["error"] = err.Error()
 
@@ -243,8 +278,35 @@ func (AccountController) Login(ctx echo.Context) error {
 		return render(ctx, contentTpl, data)
 	}
 
+	// 启用了两步验证，还需要输入动态码
+	if logic.DefaultUserTotp.IsEnabled(userLogin.Uid) {
+		if err = startLogin2FA(ctx, userLogin.Uid); err != nil {
+			if util.IsAjax(ctx) {
+				return fail(ctx, 1, err.Error())
+			}
+
+			data["username"] = username
+			data["error"] = err.Error()
+			return render(ctx, contentTpl, data)
+		}
+
+		redirectURL := "/account/login/2fa?redirect_uri=" + url.QueryEscape(uri)
+		if util.IsAjax(ctx) {
+			return success(ctx, map[string]interface{}{"redirect": redirectURL})
+		}
+		return ctx.Redirect(http.StatusSeeOther, redirectURL)
+	}
+
 	// 登录成功，种cookie
-	xhttp.SetLoginCookie(ctx, userLogin.Username)
+	if err = xhttp.SetLoginCookie(ctx, userLogin.Uid); err != nil {
+		if util.IsAjax(ctx) {
+			return fail(ctx, 1, err.Error())
+		}
+
+		data["username"] = username
+		data["error"] = err.Error()
+		return render(ctx, contentTpl, data)
+	}
 
 	if util.IsAjax(ctx) {
 		return success(ctx, nil)
@@ -253,6 +315,55 @@ func (AccountController) Login(ctx echo.Context) error {
 	return ctx.Redirect(http.StatusSeeOther, uri)
 }
 
+// Login2FA 登录第二步：输入动态码或恢复码
+func (AccountController) Login2FA(ctx echo.Context) error {
+	if _, ok := ctx.Get("user").(*model.Me); ok {
+		return ctx.Redirect(http.StatusSeeOther, "/")
+	}
+
+	uri := ctx.FormValue("redirect_uri")
+	if uri == "" {
+		uri = "/"
+	}
+
+	token := xhttp.GetFromCookie(ctx, "login_2fa")
+	if token == "" {
+		return ctx.Redirect(http.StatusSeeOther, "/account/login")
+	}
+
+	contentTpl := "login_2fa.html"
+	data := map[string]interface{}{"redirect_uri": uri}
+
+	if ctx.Request().Method() != "POST" {
+		return render(ctx, contentTpl, data)
+	}
+
+	userLogin, err := logic.DefaultUserTotp.FinishLogin(ctx, token, ctx.FormValue("code"))
+	if err != nil {
+		data["error"] = err.Error()
+		return render(ctx, contentTpl, data)
+	}
+
+	// 临时凭证已经用过，登录成功，种cookie
+	xhttp.SetCookie(ctx, "login_2fa", "")
+	if err = xhttp.SetLoginCookie(ctx, userLogin.Uid); err != nil {
+		data["error"] = err.Error()
+		return render(ctx, contentTpl, data)
+	}
+
+	return ctx.Redirect(http.StatusSeeOther, uri)
+}
+
+// startLogin2FA 密码（或第三方账号）校验通过，临时凭证放入 cookie，等待输入动态码
+func startLogin2FA(ctx echo.Context, uid int) error {
+	token, err := logic.DefaultUserTotp.StartLogin(uid)
+	if err != nil {
+		return err
+	}
+	xhttp.SetCookie(ctx, "login_2fa", token)
+	return nil
+}
+
 // Edit 用户编辑个人信息
 func (a AccountController) Edit(ctx echo.Context) error {
 	me := ctx.Get("user").(*model.Me)
@@ -260,11 +371,27 @@ func (a AccountController) Edit(ctx echo.Context) error {
 	if ctx.Request().Method() != "POST" {
 		user := logic.DefaultUser.FindOne(ctx, "uid", me.Uid)
 		bindUsers := logic.DefaultUser.FindBindUsers(ctx, me.Uid)
+		userTotp := logic.DefaultUserTotp.Find(me.Uid)
+		sid, _ := ctx.Get("sid").(string)
+		// 绑定第三方账号失败的原因，只显示一次
+		oauthError := xhttp.GetFromCookie(ctx, "oauth_error")
+		if oauthError != "" {
+			xhttp.SetCookie(ctx, "oauth_error", "")
+		}
 		return render(ctx, "user/edit.html", map[string]interface{}{
 			"user":            user,
 			"default_avatars": logic.DefaultAvatars,
 			"has_passwd":      logic.DefaultUser.HasPasswd(ctx, me.Uid),
 			"bind_users":      bindUsers,
+			"totp_enabled":    userTotp != nil && userTotp.Enabled,
+			"totp_codes_left": logic.DefaultUserTotp.RecoveryCodesLeft(userTotp),
+			"need_2fa":        logic.DefaultUserTotp.NeedEnroll(me),
+			"oauth_error":     oauthError,
+			"sessions":        logic.DefaultSession.FindByUser(me.Uid, sid),
+			"access_tokens":   logic.DefaultAccessToken.FindByUser(ctx, me.Uid),
+			"scopes":          model.Scopes,
+			"scope_names":     model.ScopeMap,
+			"token_days":      logic.AccessTokenExpireDays,
 		})
 	}
 
@@ -310,6 +437,12 @@ func (AccountController) ChangePwd(ctx echo.Context) error {
 	if err != nil {
 		return fail(ctx, 1, errMsg)
 	}
+
+	// 修改密码后所有会话都已注销，当前设备重新登录
+	if err = xhttp.SetLoginCookie(ctx, curUser.Uid); err != nil {
+		return fail(ctx, 1, "密码已修改，但重新登录失败，请重新登录")
+	}
+
 	return success(ctx, nil)
 }
 
@@ -391,16 +524,13 @@ func (AccountController) ResetPasswd(ctx echo.Context) error {
 	data["code"] = uuid
 	// 提交修改密码
 	if passwd != "" && method == "POST" {
-		// 简单校验
-		if len(passwd) < 6 || len(passwd) > 32 {
-			data["error"] = "密码长度必须在6到32个字符之间"
-		} else if passwd != ctx.FormValue("pass2") {
+		if passwd != ctx.FormValue("pass2") {
 			data["error"] = "两次密码输入不一致"
 		} else {
-			// 更新密码
-			_, err := logic.DefaultUser.ResetPasswd(ctx, email, passwd)
+			// 更新密码，密码强度在 logic 中校验
+			errMsg, err := logic.DefaultUser.ResetPasswd(ctx, email, passwd)
 			if err != nil {
-				data["error"] = "对不起，服务器错误，请重试！"
+				data["error"] = errMsg
 			} else {
 				data["success"] = template.HTML(`密码重置成功，<p>将在<span id="jumpTo">3</span>秒后跳转到<a href="/account/login" id="jump_url">登录</a>页面</p>`)
 			}
@@ -411,6 +541,10 @@ func (AccountController) ResetPasswd(ctx echo.Context) error {
 
 // Logout 注销
 func (AccountController) Logout(ctx echo.Context) error {
+	if sid, ok := ctx.Get("sid").(string); ok {
+		logic.DefaultSession.Delete(sid)
+	}
+
 	// 删除cookie信息
 	session := xhttp.GetCookieSession(ctx)
 	session.Options = &sessions.Options{Path: "/", MaxAge: -1}
@@ -427,3 +561,104 @@ func (AccountController) Unbind(ctx echo.Context) error {
 
 	return ctx.Redirect(http.StatusSeeOther, "/account/edit#connection")
 }
+
+// RevokeSession 注销某个设备上的登录
+func (AccountController) RevokeSession(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	err := logic.DefaultSession.Revoke(me.Uid, ctx.FormValue("id"))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
+
+// RevokeAllSessions 在所有设备上退出，包括当前设备
+func (AccountController) RevokeAllSessions(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	err := logic.DefaultSession.RevokeAll(me.Uid)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
+
+// CreateAccessToken 生成个人访问令牌，令牌只在这时返回一次
+func (AccountController) CreateAccessToken(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	form := ctx.FormParams()
+	token, err := logic.DefaultAccessToken.Create(ctx, me.Uid, ctx.FormValue("name"), form["scopes"], goutils.MustInt(ctx.FormValue("days")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, map[string]interface{}{"token": token})
+}
+
+// DeleteAccessToken 删除个人访问令牌，使用该令牌的脚本立即失效
+func (AccountController) DeleteAccessToken(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	err := logic.DefaultAccessToken.Delete(ctx, me.Uid, goutils.MustInt(ctx.FormValue("id")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
+
+// Enroll2FA 生成两步验证的密钥，前端据此显示二维码
+func (AccountController) Enroll2FA(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	secret, uri, err := logic.DefaultUserTotp.Enroll(ctx, me)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, map[string]interface{}{"secret": secret, "uri": uri})
+}
+
+// Enable2FA 输入动态码确认后启用两步验证，返回恢复码
+func (AccountController) Enable2FA(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	codes, err := logic.DefaultUserTotp.Enable(ctx, me.Uid, ctx.FormValue("code"))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, map[string]interface{}{"recovery_codes": codes})
+}
+
+// Disable2FA 关闭两步验证
+func (AccountController) Disable2FA(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	if me.IsAdmin && logic.UserSetting[model.KeyRequireAdmin2FA] > 0 {
+		return fail(ctx, 2, "管理员必须启用两步验证")
+	}
+
+	err := logic.DefaultUserTotp.Disable(ctx, me.Uid, ctx.FormValue("code"))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
+
+// RegenRecoveryCodes 重新生成恢复码
+func (AccountController) RegenRecoveryCodes(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	codes, err := logic.DefaultUserTotp.RegenRecoveryCodes(ctx, me.Uid, ctx.FormValue("code"))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, map[string]interface{}{"recovery_codes": codes})
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"net/http"
+
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// AppKeyController 应用密钥：发放给其他服务，用于签名调用 app 接口
+type AppKeyController struct{}
+
+// RegisterRoute 注册路由
+func (a AppKeyController) RegisterRoute(g *echo.Group) {
+	g.GET("/setting/appkey/list", a.List)
+	g.POST("/setting/appkey/query.html", a.Query)
+	g.Match([]string{"GET", "POST"}, "/setting/appkey/new", a.New)
+	g.Match([]string{"GET", "POST"}, "/setting/appkey/modify", a.Modify)
+	g.POST("/setting/appkey/reset_secret", a.ResetSecret)
+	g.POST("/setting/appkey/del", a.Delete)
+}
+
+// List 所有应用密钥（分页）
+func (AppKeyController) List(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+
+	appKeys, total := logic.DefaultAppKey.FindByPage(ctx, nil, curPage, limit)
+	if appKeys == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   appKeys,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+	}
+
+	return render(ctx, "appkey/list.html,appkey/query.html", data)
+}
+
+// Query .
+func (AppKeyController) Query(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"enabled"})
+
+	appKeys, total := logic.DefaultAppKey.FindByPage(ctx, conds, curPage, limit)
+	if appKeys == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   appKeys,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+	}
+
+	return renderQuery(ctx, "appkey/query.html", data)
+}
+
+// New 新建应用密钥
+func (a AppKeyController) New(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		return a.save(ctx)
+	}
+
+	data := map[string]interface{}{
+		"app_key":     &model.AppKey{Scopes: model.ScopeRead, Enabled: true},
+		"scopes":      model.Scopes,
+		"scope_names": model.ScopeMap,
+	}
+
+	return render(ctx, "appkey/modify.html", data)
+}
+
+// Modify 编辑应用密钥，可以查看密钥
+func (a AppKeyController) Modify(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		return a.save(ctx)
+	}
+
+	appKey := logic.DefaultAppKey.FindById(ctx, goutils.MustInt(ctx.QueryParam("id")))
+	if appKey == nil {
+		return ctx.Redirect(http.StatusSeeOther, ctx.Echo().URI(echo.HandlerFunc(a.List)))
+	}
+
+	data := map[string]interface{}{
+		"app_key":     appKey,
+		"scopes":      model.Scopes,
+		"scope_names": model.ScopeMap,
+	}
+	if appKey.Uid > 0 {
+		data["user"] = logic.DefaultUser.FindOne(ctx, "uid", appKey.Uid)
+	}
+
+	return render(ctx, "appkey/modify.html", data)
+}
+
+func (AppKeyController) save(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	errMsg, err := logic.DefaultAppKey.Save(ctx, ctx.FormParams(), me.Username)
+	if err != nil {
+		return fail(ctx, 1, errMsg)
+	}
+	return success(ctx, nil)
+}
+
+// ResetSecret 重新生成密钥
+func (AppKeyController) ResetSecret(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	err := logic.DefaultAppKey.ResetSecret(ctx, goutils.MustInt(ctx.FormValue("id")), me.Username)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
+
+// Delete 删除应用密钥
+func (AppKeyController) Delete(ctx echo.Context) error {
+	err := logic.DefaultAppKey.Delete(ctx, goutils.MustInt(ctx.FormValue("id")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
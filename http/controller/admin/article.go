@@ -25,6 +25,7 @@ func (a ArticleController) RegisterRoute(g *echo.Group) {
 	g.GET("/crawl/article/list", a.ArticleList)
 	g.POST("/crawl/article/query.html", a.ArticleQuery)
 	g.POST("/crawl/article/move", a.MoveToTopic)
+	g.POST("/crawl/article/del", a.Delete)
 	g.Match([]string{"GET", "POST"}, "/crawl/article/new", a.CrawlArticle)
 	g.Match([]string{"GET", "POST"}, "/crawl/article/publish", a.Publish)
 	g.Match([]string{"GET", "POST"}, "/crawl/article/modify", a.Modify)
@@ -164,3 +165,13 @@ func (a ArticleController) MoveToTopic(ctx echo.Context) error {
 	}
 	return success(ctx, nil)
 }
+
+// Delete 删除文章（放入回收站）
+func (ArticleController) Delete(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	err := logic.DefaultTrash.Delete(ctx, model.TypeArticle, goutils.MustInt(ctx.QueryParam("id")), me.Uid, "")
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
@@ -0,0 +1,155 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"net/http"
+
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// BadgeController 徽章管理
+type BadgeController struct{}
+
+// RegisterRoute 注册路由
+func (b BadgeController) RegisterRoute(g *echo.Group) {
+	g.GET("/operation/badge/list", b.BadgeList)
+	g.POST("/operation/badge/query.html", b.Query)
+	g.Match([]string{"GET", "POST"}, "/operation/badge/new", b.New)
+	g.Match([]string{"GET", "POST"}, "/operation/badge/modify", b.Modify)
+	g.GET("/operation/badge/holders", b.Holders)
+	g.POST("/operation/badge/grant", b.Grant)
+	g.POST("/operation/badge/revoke", b.Revoke)
+}
+
+// BadgeList 所有徽章（分页）
+func (BadgeController) BadgeList(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+
+	badges, total := logic.DefaultBadge.FindBadgesByPage(ctx, nil, curPage, limit)
+	if badges == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   badges,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"rules":      model.BadgeRuleMap,
+	}
+
+	return render(ctx, "badge/list.html,badge/query.html", data)
+}
+
+// Query .
+func (BadgeController) Query(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"rule", "state"})
+
+	badges, total := logic.DefaultBadge.FindBadgesByPage(ctx, conds, curPage, limit)
+	if badges == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   badges,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"rules":      model.BadgeRuleMap,
+	}
+
+	return renderQuery(ctx, "badge/query.html", data)
+}
+
+// New 新建徽章
+func (BadgeController) New(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		errMsg, err := logic.DefaultBadge.Save(ctx, ctx.FormParams())
+		if err != nil {
+			return fail(ctx, 1, errMsg)
+		}
+		return success(ctx, nil)
+	}
+
+	data := map[string]interface{}{
+		"badge": &model.Badge{},
+		"rules": model.BadgeRuleMap,
+	}
+
+	return render(ctx, "badge/modify.html", data)
+}
+
+// Modify 编辑徽章
+func (b BadgeController) Modify(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		errMsg, err := logic.DefaultBadge.Save(ctx, ctx.FormParams())
+		if err != nil {
+			return fail(ctx, 1, errMsg)
+		}
+		return success(ctx, nil)
+	}
+
+	badge := logic.DefaultBadge.FindById(ctx, goutils.MustInt(ctx.QueryParam("id")))
+	if badge == nil {
+		return ctx.Redirect(http.StatusSeeOther, ctx.Echo().URI(echo.HandlerFunc(b.BadgeList)))
+	}
+
+	data := map[string]interface{}{
+		"badge": badge,
+		"rules": model.BadgeRuleMap,
+	}
+
+	return render(ctx, "badge/modify.html", data)
+}
+
+// Holders 徽章拥有者
+func (b BadgeController) Holders(ctx echo.Context) error {
+	badge := logic.DefaultBadge.FindById(ctx, goutils.MustInt(ctx.QueryParam("id")))
+	if badge == nil {
+		return ctx.Redirect(http.StatusSeeOther, ctx.Echo().URI(echo.HandlerFunc(b.BadgeList)))
+	}
+
+	data := map[string]interface{}{
+		"badge":    badge,
+		"datalist": logic.DefaultBadge.FindHolders(ctx, badge.Id),
+	}
+
+	return render(ctx, "badge/holders.html", data)
+}
+
+// Grant 手动授予徽章
+func (BadgeController) Grant(ctx echo.Context) error {
+	user := logic.DefaultUser.FindOne(ctx, "username", ctx.FormValue("username"))
+	if user == nil || user.Uid == 0 {
+		return fail(ctx, 1, "用户不存在")
+	}
+
+	me := ctx.Get("user").(*model.Me)
+	err := logic.DefaultBadge.Grant(ctx, goutils.MustInt(ctx.FormValue("badge_id")), user.Uid, me)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
+
+// Revoke 收回徽章，id 为用户 uid
+func (BadgeController) Revoke(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	err := logic.DefaultBadge.Revoke(ctx, goutils.MustInt(ctx.FormValue("badge_id")), goutils.MustInt(ctx.FormValue("id")), me)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
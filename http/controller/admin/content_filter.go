@@ -0,0 +1,172 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"net/http"
+
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// ContentFilterController 内容过滤规则及命中日志
+type ContentFilterController struct{}
+
+// RegisterRoute 注册路由
+func (f ContentFilterController) RegisterRoute(g *echo.Group) {
+	g.GET("/community/filter/list", f.RuleList)
+	g.POST("/community/filter/query.html", f.RuleQuery)
+	g.Match([]string{"GET", "POST"}, "/community/filter/new", f.New)
+	g.Match([]string{"GET", "POST"}, "/community/filter/modify", f.Modify)
+	g.POST("/community/filter/del", f.Delete)
+	g.GET("/community/filter/logs", f.LogList)
+	g.POST("/community/filter/logs/query.html", f.LogQuery)
+}
+
+// RuleList 所有过滤规则（分页）
+func (ContentFilterController) RuleList(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+
+	rules, total := logic.DefaultContentFilter.FindRulesByPage(ctx, nil, curPage, limit)
+	if rules == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   rules,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"types":      model.FilterTypeMap,
+		"actions":    model.FilterActionMap,
+	}
+
+	return render(ctx, "filter/list.html,filter/query.html", data)
+}
+
+// RuleQuery .
+func (ContentFilterController) RuleQuery(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"type", "action", "enabled"})
+
+	rules, total := logic.DefaultContentFilter.FindRulesByPage(ctx, conds, curPage, limit)
+	if rules == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   rules,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+	}
+
+	return renderQuery(ctx, "filter/query.html", data)
+}
+
+// New 新建规则
+func (f ContentFilterController) New(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		return f.save(ctx)
+	}
+
+	data := map[string]interface{}{
+		"rule":    &model.FilterRule{Scope: model.FilterScopeTitle | model.FilterScopeContent, Enabled: true},
+		"types":   model.FilterTypeMap,
+		"actions": model.FilterActionMap,
+		"scopes":  model.FilterScopeMap,
+	}
+
+	return render(ctx, "filter/modify.html", data)
+}
+
+// Modify 编辑规则
+func (f ContentFilterController) Modify(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		return f.save(ctx)
+	}
+
+	rule := logic.DefaultContentFilter.FindRuleById(ctx, goutils.MustInt(ctx.QueryParam("id")))
+	if rule == nil {
+		return ctx.Redirect(http.StatusSeeOther, ctx.Echo().URI(echo.HandlerFunc(f.RuleList)))
+	}
+
+	data := map[string]interface{}{
+		"rule":    rule,
+		"types":   model.FilterTypeMap,
+		"actions": model.FilterActionMap,
+		"scopes":  model.FilterScopeMap,
+	}
+
+	return render(ctx, "filter/modify.html", data)
+}
+
+func (ContentFilterController) save(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	errMsg, err := logic.DefaultContentFilter.SaveRule(ctx, ctx.FormParams(), me.Username)
+	if err != nil {
+		return fail(ctx, 1, errMsg)
+	}
+	return success(ctx, nil)
+}
+
+// Delete 删除规则
+func (ContentFilterController) Delete(ctx echo.Context) error {
+	err := logic.DefaultContentFilter.DeleteRule(ctx, goutils.MustInt(ctx.FormValue("id")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
+
+// LogList 命中日志（分页），可以按规则查看
+func (ContentFilterController) LogList(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"rule_id"})
+
+	filterLogs, total := logic.DefaultContentFilter.FindLogsByPage(ctx, conds, curPage, limit)
+	if filterLogs == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   filterLogs,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"rule_id":    ctx.QueryParam("rule_id"),
+	}
+
+	return render(ctx, "filter/logs.html,filter/log_query.html", data)
+}
+
+// LogQuery .
+func (ContentFilterController) LogQuery(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"rule_id"})
+
+	filterLogs, total := logic.DefaultContentFilter.FindLogsByPage(ctx, conds, curPage, limit)
+	if filterLogs == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   filterLogs,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+	}
+
+	return renderQuery(ctx, "filter/log_query.html", data)
+}
@@ -0,0 +1,251 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	xhttp "sander/http"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// 导入兑换码的 CSV 文件大小限制
+const maxRedeemFileSize = 2 << 20
+
+// GiftController 积分商城物品管理
+type GiftController struct{}
+
+// RegisterRoute 注册路由
+func (g GiftController) RegisterRoute(eg *echo.Group) {
+	eg.GET("/operation/gift/list", g.GiftList)
+	eg.POST("/operation/gift/query.html", g.Query)
+	eg.Match([]string{"GET", "POST"}, "/operation/gift/new", g.New)
+	eg.Match([]string{"GET", "POST"}, "/operation/gift/modify", g.Modify)
+	eg.POST("/operation/gift/update_state", g.UpdateState)
+	eg.POST("/operation/gift/restock", g.Restock)
+	eg.GET("/operation/gift/redeem", g.Redeem)
+	eg.POST("/operation/gift/redeem/import", g.ImportRedeem)
+	eg.GET("/operation/gift/records", g.Records)
+	eg.POST("/operation/gift/records/query.html", g.RecordsQuery)
+	eg.GET("/operation/gift/records/export", g.ExportRecords)
+}
+
+// GiftList 所有物品（分页）
+func (GiftController) GiftList(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+
+	gifts, total := logic.DefaultGift.FindGiftsByPage(ctx, nil, curPage, limit)
+	if gifts == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   gifts,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"types":      model.GiftTypeMap,
+		"states":     model.GiftStateMap,
+	}
+
+	return render(ctx, "gift/list.html,gift/query.html", data)
+}
+
+// Query .
+func (GiftController) Query(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"typ", "state"})
+
+	gifts, total := logic.DefaultGift.FindGiftsByPage(ctx, conds, curPage, limit)
+	if gifts == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   gifts,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"types":      model.GiftTypeMap,
+		"states":     model.GiftStateMap,
+	}
+
+	return renderQuery(ctx, "gift/query.html", data)
+}
+
+// New 新建物品
+func (GiftController) New(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		errMsg, err := logic.DefaultGift.Save(ctx, ctx.FormParams())
+		if err != nil {
+			return fail(ctx, 1, errMsg)
+		}
+		return success(ctx, nil)
+	}
+
+	data := map[string]interface{}{
+		"gift":  &model.Gift{BuyLimit: 1, ExpireTime: time.Now().AddDate(1, 0, 0)},
+		"types": model.GiftTypeMap,
+	}
+
+	return render(ctx, "gift/modify.html", data)
+}
+
+// Modify 编辑物品
+func (g GiftController) Modify(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		errMsg, err := logic.DefaultGift.Save(ctx, ctx.FormParams())
+		if err != nil {
+			return fail(ctx, 1, errMsg)
+		}
+		return success(ctx, nil)
+	}
+
+	gift := logic.DefaultGift.FindById(ctx, goutils.MustInt(ctx.QueryParam("id")))
+	if gift == nil {
+		return ctx.Redirect(http.StatusSeeOther, ctx.Echo().URI(echo.HandlerFunc(g.GiftList)))
+	}
+
+	data := map[string]interface{}{
+		"gift":  gift,
+		"types": model.GiftTypeMap,
+	}
+
+	return render(ctx, "gift/modify.html", data)
+}
+
+// UpdateState 上线或下线物品
+func (GiftController) UpdateState(ctx echo.Context) error {
+	id := goutils.MustInt(ctx.FormValue("id"))
+	state := goutils.MustInt(ctx.FormValue("state"))
+
+	err := logic.DefaultGift.ChangeState(ctx, id, state)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
+
+// Restock 折扣类物品补货
+func (GiftController) Restock(ctx echo.Context) error {
+	id := goutils.MustInt(ctx.FormValue("id"))
+	num := goutils.MustInt(ctx.FormValue("num"))
+
+	err := logic.DefaultGift.Restock(ctx, id, num)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
+
+// Redeem 兑换码管理
+func (g GiftController) Redeem(ctx echo.Context) error {
+	gift := logic.DefaultGift.FindById(ctx, goutils.MustInt(ctx.QueryParam("id")))
+	if gift == nil {
+		return ctx.Redirect(http.StatusSeeOther, ctx.Echo().URI(echo.HandlerFunc(g.GiftList)))
+	}
+
+	total, exchanged := logic.DefaultGift.RedeemStat(ctx, gift.Id)
+
+	data := map[string]interface{}{
+		"gift":      gift,
+		"total":     total,
+		"exchanged": exchanged,
+	}
+
+	return render(ctx, "gift/redeem.html", data)
+}
+
+// ImportRedeem 通过 CSV 文件批量导入兑换码
+func (GiftController) ImportRedeem(ctx echo.Context) error {
+	giftId := goutils.MustInt(ctx.FormValue("gift_id"))
+
+	file, fileHeader, err := xhttp.Request(ctx).FormFile("file")
+	if err != nil {
+		return fail(ctx, 1, "请选择要导入的 CSV 文件")
+	}
+	defer file.Close()
+
+	if fileHeader.Size > maxRedeemFileSize {
+		return fail(ctx, 1, "文件太大！")
+	}
+
+	num, err := logic.DefaultGift.ImportRedeems(ctx, giftId, file)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, map[string]interface{}{"num": num})
+}
+
+// Records 兑换记录
+func (GiftController) Records(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"gift_id", "uid"})
+
+	records, total := logic.DefaultGift.FindExchangeRecordsByPage(ctx, conds, curPage, limit)
+	if records == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   records,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"gift_id":    ctx.FormValue("gift_id"),
+	}
+
+	return render(ctx, "gift/records.html,gift/records_query.html", data)
+}
+
+// RecordsQuery .
+func (GiftController) RecordsQuery(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"gift_id", "uid"})
+
+	records, total := logic.DefaultGift.FindExchangeRecordsByPage(ctx, conds, curPage, limit)
+	if records == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   records,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+	}
+
+	return renderQuery(ctx, "gift/records_query.html", data)
+}
+
+// ExportRecords 导出兑换记录（CSV），用于发货
+func (GiftController) ExportRecords(ctx echo.Context) error {
+	giftId := goutils.MustInt(ctx.QueryParam("gift_id"))
+
+	filename := "exchange_records_" + time.Now().Format("20060102150405")
+	if giftId != 0 {
+		filename += "_" + strconv.Itoa(giftId)
+	}
+
+	response := ctx.Response()
+	response.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	response.Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`.csv"`)
+	response.WriteHeader(http.StatusOK)
+
+	return logic.DefaultGift.ExportExchangeRecords(ctx, giftId, response)
+}
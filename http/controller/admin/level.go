@@ -0,0 +1,87 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"net/http"
+
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// LevelController 声望等级设置
+type LevelController struct{}
+
+// RegisterRoute 注册路由
+func (l LevelController) RegisterRoute(g *echo.Group) {
+	g.GET("/setting/level/list", l.LevelList)
+	g.Match([]string{"GET", "POST"}, "/setting/level/new", l.New)
+	g.Match([]string{"GET", "POST"}, "/setting/level/modify", l.Modify)
+	g.POST("/setting/level/del", l.Delete)
+}
+
+// LevelList 所有等级
+func (LevelController) LevelList(ctx echo.Context) error {
+	data := map[string]interface{}{
+		"datalist": logic.DefaultUserLevel.FindAll(ctx),
+	}
+
+	return render(ctx, "level/list.html", data)
+}
+
+// New 新建等级
+func (LevelController) New(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		errMsg, err := logic.DefaultUserLevel.Save(ctx, ctx.FormParams())
+		if err != nil {
+			return fail(ctx, 1, errMsg)
+		}
+		return success(ctx, nil)
+	}
+
+	data := map[string]interface{}{
+		"level":      &model.UserLevel{},
+		"privileges": model.PrivilegeMap,
+	}
+
+	return render(ctx, "level/modify.html", data)
+}
+
+// Modify 编辑等级
+func (l LevelController) Modify(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		errMsg, err := logic.DefaultUserLevel.Save(ctx, ctx.FormParams())
+		if err != nil {
+			return fail(ctx, 1, errMsg)
+		}
+		return success(ctx, nil)
+	}
+
+	userLevel := logic.DefaultUserLevel.FindById(ctx, goutils.MustInt(ctx.QueryParam("id")))
+	if userLevel == nil {
+		return ctx.Redirect(http.StatusSeeOther, ctx.Echo().URI(echo.HandlerFunc(l.LevelList)))
+	}
+
+	data := map[string]interface{}{
+		"level":      userLevel,
+		"privileges": model.PrivilegeMap,
+	}
+
+	return render(ctx, "level/modify.html", data)
+}
+
+// Delete 删除等级
+func (LevelController) Delete(ctx echo.Context) error {
+	err := logic.DefaultUserLevel.Delete(ctx, goutils.MustInt(ctx.FormValue("id")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
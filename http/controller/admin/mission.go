@@ -0,0 +1,131 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"net/http"
+
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// MissionController 规则任务管理
+type MissionController struct{}
+
+// RegisterRoute 注册路由
+func (m MissionController) RegisterRoute(g *echo.Group) {
+	g.GET("/operation/mission/list", m.MissionList)
+	g.POST("/operation/mission/query.html", m.Query)
+	g.Match([]string{"GET", "POST"}, "/operation/mission/new", m.New)
+	g.Match([]string{"GET", "POST"}, "/operation/mission/modify", m.Modify)
+	g.POST("/operation/mission/update_state", m.UpdateState)
+}
+
+// MissionList 所有规则任务（分页）
+func (MissionController) MissionList(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+
+	missions, total := logic.DefaultMission.FindMissionsByPage(ctx, nil, curPage, limit)
+	if missions == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   missions,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"events":     model.MissionEventMap,
+		"periods":    model.MissionPeriodMap,
+	}
+
+	return render(ctx, "mission/list.html,mission/query.html", data)
+}
+
+// Query .
+func (MissionController) Query(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"event", "state"})
+
+	missions, total := logic.DefaultMission.FindMissionsByPage(ctx, conds, curPage, limit)
+	if missions == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   missions,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"events":     model.MissionEventMap,
+		"periods":    model.MissionPeriodMap,
+	}
+
+	return renderQuery(ctx, "mission/query.html", data)
+}
+
+// New 新建规则任务
+func (MissionController) New(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		errMsg, err := logic.DefaultMission.SaveMission(ctx, ctx.FormParams())
+		if err != nil {
+			return fail(ctx, 1, errMsg)
+		}
+		return success(ctx, nil)
+	}
+
+	data := map[string]interface{}{
+		"mission":   &model.Mission{Objtype: model.MissionObjtypeAll},
+		"events":    model.MissionEventMap,
+		"periods":   model.MissionPeriodMap,
+		"type_name": model.TypeNameMap,
+	}
+
+	return render(ctx, "mission/modify.html", data)
+}
+
+// Modify 编辑规则任务
+func (m MissionController) Modify(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		errMsg, err := logic.DefaultMission.SaveMission(ctx, ctx.FormParams())
+		if err != nil {
+			return fail(ctx, 1, errMsg)
+		}
+		return success(ctx, nil)
+	}
+
+	mission := logic.DefaultMission.FindMissionById(ctx, goutils.MustInt(ctx.QueryParam("id")))
+	if mission == nil {
+		return ctx.Redirect(http.StatusSeeOther, ctx.Echo().URI(echo.HandlerFunc(m.MissionList)))
+	}
+
+	data := map[string]interface{}{
+		"mission":   mission,
+		"events":    model.MissionEventMap,
+		"periods":   model.MissionPeriodMap,
+		"type_name": model.TypeNameMap,
+	}
+
+	return render(ctx, "mission/modify.html", data)
+}
+
+// UpdateState 上线或下线规则任务
+func (MissionController) UpdateState(ctx echo.Context) error {
+	id := goutils.MustInt(ctx.FormValue("id"))
+	state := goutils.MustInt(ctx.FormValue("state"))
+
+	err := logic.DefaultMission.ChangeMissionState(ctx, id, state)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
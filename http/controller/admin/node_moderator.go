@@ -0,0 +1,76 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"net/http"
+
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// NodeModeratorController 节点版主管理
+type NodeModeratorController struct{}
+
+// RegisterRoute 注册路由
+func (n NodeModeratorController) RegisterRoute(g *echo.Group) {
+	g.GET("/community/node/moderator/list", n.List)
+	g.Match([]string{"GET", "POST"}, "/community/node/moderator/modify", n.Modify)
+	g.POST("/community/node/moderator/del", n.Delete)
+}
+
+// List 版主列表，可以按节点筛选
+func (NodeModeratorController) List(ctx echo.Context) error {
+	nid := goutils.MustInt(ctx.QueryParam("nid"))
+
+	data := map[string]interface{}{
+		"datalist": logic.DefaultNodeModerator.FindAll(ctx, nid),
+		"node":     logic.GetNode(nid),
+	}
+
+	return render(ctx, "topic/moderator_list.html", data)
+}
+
+// Modify 任命版主或修改版主权限
+func (n NodeModeratorController) Modify(ctx echo.Context) error {
+	if ctx.FormValue("submit") == "1" {
+		me := ctx.Get("user").(*model.Me)
+		errMsg, err := logic.DefaultNodeModerator.Save(ctx, ctx.FormParams(), me.Username)
+		if err != nil {
+			return fail(ctx, 1, errMsg)
+		}
+		return success(ctx, nil)
+	}
+
+	moderator := &model.NodeModerator{Nid: goutils.MustInt(ctx.QueryParam("nid"))}
+	if id := goutils.MustInt(ctx.QueryParam("id")); id > 0 {
+		moderator = logic.DefaultNodeModerator.FindById(ctx, id)
+		if moderator == nil {
+			return ctx.Redirect(http.StatusSeeOther, ctx.Echo().URI(echo.HandlerFunc(n.List)))
+		}
+	}
+
+	data := map[string]interface{}{
+		"moderator":  moderator,
+		"nodes":      logic.DefaultNode.FindParallelTree(ctx),
+		"privileges": model.ModPermMap,
+	}
+
+	return render(ctx, "topic/moderator_modify.html", data)
+}
+
+// Delete 撤销版主
+func (NodeModeratorController) Delete(ctx echo.Context) error {
+	err := logic.DefaultNodeModerator.Delete(ctx, goutils.MustInt(ctx.FormValue("id")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"time"
+
+	"sander/logic"
+
+	"github.com/labstack/echo"
+)
+
+// RateLimitController 频率限制：查看被限制的用户和 IP
+type RateLimitController struct{}
+
+// RegisterRoute 注册路由
+func (r RateLimitController) RegisterRoute(g *echo.Group) {
+	g.GET("/user/ratelimit/list", r.List)
+	g.POST("/user/ratelimit/reset", r.Reset)
+}
+
+// List 某天被限制的用户和 IP，默认今天
+func (RateLimitController) List(ctx echo.Context) error {
+	day := ctx.QueryParam("day")
+	if _, err := time.Parse("20060102", day); err != nil {
+		day = time.Now().Format("20060102")
+	}
+
+	data := map[string]interface{}{
+		"day":      day,
+		"datalist": logic.DefaultRateLimit.FindThrottled(ctx, day),
+	}
+
+	return render(ctx, "ratelimit/list.html", data)
+}
+
+// Reset 解除限制，id 为 uid:1 或 ip:1.2.3.4 这样的限制对象
+func (RateLimitController) Reset(ctx echo.Context) error {
+	err := logic.DefaultRateLimit.Reset(ctx, ctx.FormValue("policy"), ctx.FormValue("id"))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
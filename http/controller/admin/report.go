@@ -0,0 +1,99 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"net/http"
+
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// ReportController 举报处理队列
+type ReportController struct{}
+
+// RegisterRoute 注册路由
+func (r ReportController) RegisterRoute(g *echo.Group) {
+	g.GET("/community/report/list", r.List)
+	g.POST("/community/report/query.html", r.Query)
+	g.POST("/community/report/approve", r.Approve)
+	g.POST("/community/report/remove", r.Remove)
+	g.POST("/community/report/ban", r.Ban)
+}
+
+// List 待处理的举报（分页）
+func (ReportController) List(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := map[string]string{"status": "0"}
+	reportObjects, total := logic.DefaultReport.FindByPage(ctx, conds, curPage, limit)
+
+	if reportObjects == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   reportObjects,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"types":      model.ReportTypeMap,
+		"statuses":   model.ReportStatusMap,
+	}
+
+	return render(ctx, "report/list.html,report/query.html", data)
+}
+
+// Query .
+func (ReportController) Query(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"objtype", "status"})
+
+	reportObjects, total := logic.DefaultReport.FindByPage(ctx, conds, curPage, limit)
+
+	if reportObjects == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   reportObjects,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+	}
+
+	return renderQuery(ctx, "report/query.html", data)
+}
+
+// Approve 驳回举报，恢复被自动隐藏的内容
+func (ReportController) Approve(ctx echo.Context) error {
+	return handleReport(ctx, model.ReportStatusApproved)
+}
+
+// Remove 删除被举报的内容
+func (ReportController) Remove(ctx echo.Context) error {
+	return handleReport(ctx, model.ReportStatusRemoved)
+}
+
+// Ban 删除被举报的内容并封禁作者
+func (ReportController) Ban(ctx echo.Context) error {
+	return handleReport(ctx, model.ReportStatusBanned)
+}
+
+func handleReport(ctx echo.Context, status int) error {
+	me := ctx.Get("user").(*model.Me)
+
+	err := logic.DefaultReport.Handle(ctx, goutils.MustInt(ctx.FormValue("id")), status, me)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
@@ -13,12 +13,23 @@ func RegisterRoutes(g *echo.Group) {
 	new(AuthorityController).RegisterRoute(g)
 	new(UserController).RegisterRoute(g)
 	new(TopicController).RegisterRoute(g)
+	new(ReportController).RegisterRoute(g)
+	new(TrashController).RegisterRoute(g)
+	new(ContentFilterController).RegisterRoute(g)
+	new(RateLimitController).RegisterRoute(g)
+	new(SpamController).RegisterRoute(g)
 	new(NodeController).RegisterRoute(g)
+	new(NodeModeratorController).RegisterRoute(g)
 	new(ArticleController).RegisterRoute(g)
 	new(ProjectController).RegisterRoute(g)
 	new(RuleController).RegisterRoute(g)
 	new(ReadingController).RegisterRoute(g)
 	new(ToolController).RegisterRoute(g)
 	new(SettingController).RegisterRoute(g)
+	new(AppKeyController).RegisterRoute(g)
 	new(MetricsController).RegisterRoute(g)
+	new(MissionController).RegisterRoute(g)
+	new(BadgeController).RegisterRoute(g)
+	new(GiftController).RegisterRoute(g)
+	new(LevelController).RegisterRoute(g)
 }
@@ -0,0 +1,112 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"net/http"
+
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// SpamController 垃圾内容分类器：训练样本和训练记录
+type SpamController struct{}
+
+// RegisterRoute 注册路由
+func (s SpamController) RegisterRoute(g *echo.Group) {
+	g.GET("/community/spam/samples", s.SampleList)
+	g.POST("/community/spam/samples/query.html", s.SampleQuery)
+	g.POST("/community/spam/label", s.Label)
+	g.POST("/community/spam/del", s.Delete)
+	g.GET("/community/spam/models", s.Models)
+	g.POST("/community/spam/retrain", s.Retrain)
+}
+
+// SampleList 训练样本（分页）
+func (SpamController) SampleList(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+
+	samples, total := logic.DefaultSpam.FindSamplesByPage(ctx, nil, curPage, limit)
+	if samples == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   samples,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"types":      model.SpamTypeMap,
+	}
+
+	return render(ctx, "spam/samples.html,spam/query.html", data)
+}
+
+// SampleQuery .
+func (SpamController) SampleQuery(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	conds := parseConds(ctx, []string{"objtype", "is_spam"})
+
+	samples, total := logic.DefaultSpam.FindSamplesByPage(ctx, conds, curPage, limit)
+	if samples == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   samples,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+	}
+
+	return renderQuery(ctx, "spam/query.html", data)
+}
+
+// Label 修正样本的标注，is_spam 为 1 表示垃圾内容
+func (SpamController) Label(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	isSpam := goutils.MustBool(ctx.FormValue("is_spam"))
+	err := logic.DefaultSpam.SetLabel(ctx, goutils.MustInt(ctx.FormValue("id")), isSpam, me.Username)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
+
+// Delete 删除样本
+func (SpamController) Delete(ctx echo.Context) error {
+	err := logic.DefaultSpam.DeleteSample(ctx, goutils.MustInt(ctx.FormValue("id")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
+
+// Models 最近的训练记录，包括准确率和召回率
+func (SpamController) Models(ctx echo.Context) error {
+	data := map[string]interface{}{
+		"datalist":  logic.DefaultSpam.FindModels(ctx, 20),
+		"threshold": logic.UserSetting[model.KeySpamThreshold],
+	}
+
+	return render(ctx, "spam/models.html", data)
+}
+
+// Retrain 用当前所有样本重新训练
+func (SpamController) Retrain(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	spamModel, err := logic.DefaultSpam.Retrain(ctx, me.Username)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, spamModel)
+}
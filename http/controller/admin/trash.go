@@ -0,0 +1,96 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package admin
+
+import (
+	"net/http"
+
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// TrashController 回收站
+type TrashController struct{}
+
+// RegisterRoute 注册路由
+func (t TrashController) RegisterRoute(g *echo.Group) {
+	g.GET("/community/trash/list", t.List)
+	g.POST("/community/trash/query.html", t.Query)
+	g.POST("/community/trash/del", t.Delete)
+	g.POST("/community/trash/restore", t.Restore)
+}
+
+// List 回收站中的内容（分页），默认展示主题
+func (TrashController) List(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	items, total := logic.DefaultTrash.FindByPage(ctx, model.TypeTopic, curPage, limit)
+
+	if items == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   items,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+		"types":      model.TrashTypeMap,
+	}
+
+	return render(ctx, "trash/list.html,trash/query.html", data)
+}
+
+// Query .
+func (TrashController) Query(ctx echo.Context) error {
+	curPage, limit := parsePage(ctx)
+	objtype := goutils.MustInt(ctx.FormValue("objtype"))
+
+	items, total := logic.DefaultTrash.FindByPage(ctx, objtype, curPage, limit)
+
+	if items == nil {
+		return ctx.HTML(http.StatusInternalServerError, "500")
+	}
+
+	data := map[string]interface{}{
+		"datalist":   items,
+		"total":      total,
+		"totalPages": (total + limit - 1) / limit,
+		"page":       curPage,
+		"limit":      limit,
+	}
+
+	return renderQuery(ctx, "trash/query.html", data)
+}
+
+// Delete 删除内容（放入回收站），objtype 通过 query string 传递
+func (TrashController) Delete(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	objtype := goutils.MustInt(ctx.FormValue("objtype"))
+	objid := goutils.MustInt(ctx.FormValue("id"))
+
+	err := logic.DefaultTrash.Delete(ctx, objtype, objid, me.Uid, ctx.FormValue("reason"))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
+
+// Restore 从回收站恢复
+func (TrashController) Restore(ctx echo.Context) error {
+	objtype := goutils.MustInt(ctx.FormValue("objtype"))
+	objid := goutils.MustInt(ctx.FormValue("id"))
+
+	err := logic.DefaultTrash.Restore(ctx, objtype, objid)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+	return success(ctx, nil)
+}
@@ -8,6 +8,7 @@ package admin
 
 import (
 	"sander/logic"
+	"sander/model"
 
 	"github.com/labstack/echo"
 	"github.com/polaris1119/goutils"
@@ -36,6 +37,7 @@ func (UserController) UserList(ctx echo.Context) error {
 		"totalPages": (total + limit - 1) / limit,
 		"page":       curPage,
 		"limit":      limit,
+		"sanctions":  model.UserSanctionMap,
 	}
 
 	return render(ctx, "user/list.html,user/query.html", data)
@@ -44,7 +46,7 @@ func (UserController) UserList(ctx echo.Context) error {
 // UserQuery .
 func (UserController) UserQuery(ctx echo.Context) error {
 	curPage, limit := parsePage(ctx)
-	conds := parseConds(ctx, []string{"uid", "username", "email"})
+	conds := parseConds(ctx, []string{"uid", "username", "email", "sanction"})
 
 	users, total := logic.DefaultUser.FindUserByPage(ctx, conds, curPage, limit)
 
@@ -64,7 +66,8 @@ func (UserController) Detail(ctx echo.Context) error {
 	user := logic.DefaultUser.FindOne(ctx, "uid", ctx.QueryParam("uid"))
 
 	data := map[string]interface{}{
-		"user": user,
+		"user":      user,
+		"sanctions": model.UserSanctionMap,
 	}
 
 	return render(ctx, "user/detail.html", data)
@@ -79,6 +82,11 @@ func (UserController) Modify(ctx echo.Context) error {
 		logic.DefaultUserRich.Recharge(ctx, uid, ctx.FormParams())
 	} else {
 		logic.DefaultUser.SetDauAuth(ctx, uid, ctx.FormParams())
+
+		err := logic.DefaultUserSanction.Set(ctx, goutils.MustInt(uid), goutils.MustInt(ctx.FormValue("sanction")), goutils.MustInt(ctx.FormValue("probation_left")))
+		if err != nil {
+			return fail(ctx, 1, err.Error())
+		}
 	}
 	return success(ctx, nil)
 }
@@ -30,11 +30,11 @@ func (ArticleController) ReadList(ctx echo.Context) error {
 	paginator := logic.NewPaginatorWithPerPage(curPage, perPage)
 
 	// 置顶的 article
-	topArticles := logic.DefaultArticle.FindAll(ctx, paginator, "id DESC", "top=1")
+	topArticles := logic.DefaultArticle.FindAll(ctx, paginator, "id DESC", "top=1 AND status IN(?,?)", model.ArticleStatusNew, model.ArticleStatusOnline)
 
-	articles := logic.DefaultArticle.FindAll(ctx, paginator, "id DESC", "")
+	articles := logic.DefaultArticle.FindAll(ctx, paginator, "id DESC", "status IN(?,?)", model.ArticleStatusNew, model.ArticleStatusOnline)
 
-	total := logic.DefaultArticle.Count(ctx, "")
+	total := logic.DefaultArticle.Count(ctx, "status IN(?,?)", model.ArticleStatusNew, model.ArticleStatusOnline)
 	hasMore := paginator.SetTotal(total).HasMorePage()
 
 	data := map[string]interface{}{
@@ -52,7 +52,7 @@ func (ArticleController) Detail(ctx echo.Context) error {
 		return fail(ctx, err.Error())
 	}
 
-	if article == nil || article.Id == 0 || article.Status == model.ArticleStatusOffline {
+	if article == nil || article.Id == 0 || article.Status == model.ArticleStatusOffline || article.Status == model.ArticleStatusScheduled || article.Status == model.ArticleStatusShadow {
 		return success(ctx, map[string]interface{}{"article": map[string]interface{}{"id": 0}})
 	}
 
@@ -62,7 +62,8 @@ func (ArticleController) Detail(ctx echo.Context) error {
 	article.Viewnum++
 
 	// 回复信息（评论）
-	replies, _, lastReplyUser := logic.DefaultComment.FindObjComments(ctx, article.Id, model.TypeArticle, 0, article.Lastreplyuid)
+	me, _ := ctx.Get("user").(*model.Me)
+	replies, _, lastReplyUser := logic.DefaultComment.FindObjComments(ctx, article.Id, model.TypeArticle, 0, article.Lastreplyuid, me)
 	// 有人回复
 	if article.Lastreplyuid != 0 {
 		article.LastReplyUser = lastReplyUser
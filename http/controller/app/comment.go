@@ -20,7 +20,7 @@ type CommentController struct{}
 
 // RegisterRoute .
 func (c CommentController) RegisterRoute(g *echo.Group) {
-	g.Post("/comment/:objid", c.Create, middleware.NeedLogin(), middleware.Sensivite(), middleware.PublishNotice())
+	g.Post("/comment/:objid", c.Create, middleware.NeedScope(model.ScopeComment), middleware.NeedLogin(), middleware.RateLimit("comment"), middleware.ContentFilter(), middleware.LinkCheck(), middleware.PublishNotice())
 }
 
 // Create 评论（或回复）
@@ -34,6 +34,9 @@ func (CommentController) Create(ctx echo.Context) error {
 	}
 	comment, err := logic.DefaultComment.Publish(ctx, user.Uid, objid, ctx.FormParams())
 	if err != nil {
+		if err == logic.TopicLockedErr {
+			return fail(ctx, err.Error(), 3)
+		}
 		return fail(ctx, "服务器内部错误", 2)
 	}
 
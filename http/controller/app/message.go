@@ -0,0 +1,75 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package app
+
+import (
+	"sander/http/middleware"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// MessageController .
+type MessageController struct{}
+
+// RegisterRoute 注册路由
+func (m MessageController) RegisterRoute(g *echo.Group) {
+	g.GET("/messages/:msgtype", m.ReadList, middleware.NeedScope(model.ScopeMessage), middleware.NeedLogin())
+	g.POST("/message/send", m.Send, middleware.NeedScope(model.ScopeMessage), middleware.NeedLogin(), middleware.RateLimit("message"))
+}
+
+// ReadList 消息列表，msgtype：system、inbox、outbox
+func (MessageController) ReadList(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	curPage := goutils.MustInt(ctx.QueryParam("p"), 1)
+	paginator := logic.NewPaginatorWithPerPage(curPage, perPage)
+
+	var (
+		messages []map[string]interface{}
+		total    int64
+	)
+	switch ctx.Param("msgtype") {
+	case "system":
+		messages = logic.DefaultMessage.FindSysMsgsByUid(ctx, me.Uid, paginator)
+		total = logic.DefaultMessage.SysMsgCount(ctx, me.Uid)
+	case "inbox":
+		messages = logic.DefaultMessage.FindToMsgsByUid(ctx, me.Uid, paginator)
+		total = logic.DefaultMessage.ToMsgCount(ctx, me.Uid)
+	case "outbox":
+		messages = logic.DefaultMessage.FindFromMsgsByUid(ctx, me.Uid, paginator)
+		total = logic.DefaultMessage.FromMsgCount(ctx, me.Uid)
+	default:
+		return fail(ctx, "消息类型不正确")
+	}
+
+	data := map[string]interface{}{
+		"messages": messages,
+		"has_more": paginator.SetTotal(total).HasMorePage(),
+	}
+
+	return success(ctx, data)
+}
+
+// Send 发短消息，to 为接收者的 uid
+func (MessageController) Send(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	content := ctx.FormValue("content")
+	if content == "" {
+		return fail(ctx, "内容不能为空")
+	}
+
+	to := goutils.MustInt(ctx.FormValue("to"))
+	if !logic.DefaultMessage.SendMessageTo(ctx, me.Uid, to, content) {
+		return fail(ctx, "对不起，发送失败，请稍候再试！")
+	}
+
+	return success(ctx, nil)
+}
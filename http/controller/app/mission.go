@@ -0,0 +1,62 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package app
+
+import (
+	"sander/http/middleware"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// MissionController .
+type MissionController struct{}
+
+// RegisterRoute 注册路由
+func (m MissionController) RegisterRoute(g *echo.Group) {
+	g.GET("/mission/login", m.Login, middleware.NeedLogin())
+	g.POST("/mission/login/redeem", m.Redeem, middleware.NeedLogin())
+	g.POST("/mission/login/makeup", m.Makeup, middleware.NeedLogin())
+}
+
+// Login 连续登录天数、下次奖励及签到日历，month 格式为 200601
+func (MissionController) Login(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	loginInfo := logic.DefaultMission.FindLoginInfo(ctx, me, goutils.MustInt(ctx.QueryParam("month")))
+	if loginInfo == nil {
+		return fail(ctx, "服务内部错误")
+	}
+
+	return success(ctx, loginInfo)
+}
+
+// Redeem 领取每日登录奖励
+func (MissionController) Redeem(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	err := logic.DefaultMission.RedeemLoginAward(ctx, me)
+	if err != nil {
+		return fail(ctx, err.Error())
+	}
+
+	return success(ctx, logic.DefaultMission.FindLoginInfo(ctx, me, 0))
+}
+
+// Makeup 购买补签卡补签昨天
+func (MissionController) Makeup(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	err := logic.DefaultMission.MakeupLogin(ctx, me)
+	if err != nil {
+		return fail(ctx, err.Error())
+	}
+
+	return success(ctx, logic.DefaultMission.FindLoginInfo(ctx, me, 0))
+}
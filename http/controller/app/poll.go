@@ -0,0 +1,42 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package app
+
+import (
+	"sander/http/middleware"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// PollController .
+type PollController struct{}
+
+// RegisterRoute 注册路由
+func (p PollController) RegisterRoute(g *echo.Group) {
+	g.POST("/poll/vote", p.Vote, middleware.NeedLogin())
+}
+
+// Vote 投票，多选时 option_id 传多个，返回投票最新情况
+func (PollController) Vote(ctx echo.Context) error {
+	id := goutils.MustInt(ctx.FormValue("id"))
+
+	optionIds := make([]int, 0)
+	for _, optionId := range ctx.FormParams()["option_id"] {
+		optionIds = append(optionIds, goutils.MustInt(optionId))
+	}
+
+	me := ctx.Get("user").(*model.Me)
+	err := logic.DefaultPoll.Vote(ctx, me, id, optionIds)
+	if err != nil {
+		return fail(ctx, err.Error())
+	}
+
+	return success(ctx, map[string]interface{}{"poll": logic.DefaultPoll.FindOne(ctx, id, me)})
+}
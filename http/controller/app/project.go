@@ -56,7 +56,8 @@ func (ProjectController) Detail(ctx echo.Context) error {
 	project.Viewnum++
 
 	// 回复信息（评论）
-	replies, _, lastReplyUser := logic.DefaultComment.FindObjComments(ctx, project.Id, model.TypeProject, 0, project.Lastreplyuid)
+	me, _ := ctx.Get("user").(*model.Me)
+	replies, _, lastReplyUser := logic.DefaultComment.FindObjComments(ctx, project.Id, model.TypeProject, 0, project.Lastreplyuid, me)
 	// 有人回复
 	if project.Lastreplyuid != 0 {
 		project.LastReplyUser = lastReplyUser
@@ -43,7 +43,8 @@ func (ResourceController) ReadList(ctx echo.Context) error {
 // Detail 某个资源详细页
 func (ResourceController) Detail(ctx echo.Context) error {
 	id := goutils.MustInt(ctx.QueryParam("id"))
-	resource, comments := logic.DefaultResource.FindById(ctx, id)
+	me, _ := ctx.Get("user").(*model.Me)
+	resource, comments := logic.DefaultResource.FindById(ctx, id, me)
 	if len(resource) == 0 {
 		return fail(ctx, "获取失败")
 	}
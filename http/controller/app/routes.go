@@ -18,4 +18,7 @@ func RegisterRoutes(g *echo.Group) {
 	new(UserController).RegisterRoute(g)
 	new(WechatController).RegisterRoute(g)
 	new(CommentController).RegisterRoute(g)
+	new(MissionController).RegisterRoute(g)
+	new(PollController).RegisterRoute(g)
+	new(MessageController).RegisterRoute(g)
 }
@@ -30,8 +30,8 @@ func (t TopicController) RegisterRoute(g *echo.Group) {
 	g.GET("/topic/detail", t.Detail)
 	g.GET("/topics/node/:nid", t.NodeTopics)
 
-	g.Match([]string{"GET", "POST"}, "/topics/new", t.Create, middleware.NeedLogin(), middleware.Sensivite(), middleware.PublishNotice())
-	g.Match([]string{"GET", "POST"}, "/topics/modify", t.Modify, middleware.NeedLogin(), middleware.Sensivite())
+	g.Match([]string{"GET", "POST"}, "/topics/new", t.Create, middleware.NeedScope(model.ScopePublish), middleware.NeedLogin(), middleware.RateLimit("topic"), middleware.ContentFilter(), middleware.LinkCheck(), middleware.PublishNotice())
+	g.Match([]string{"GET", "POST"}, "/topics/modify", t.Modify, middleware.NeedScope(model.ScopePublish), middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck())
 }
 
 // TopicList .
@@ -91,7 +91,7 @@ func (TopicController) NodeTopics(ctx echo.Context) error {
 	paginator := logic.NewPaginator(curPage)
 
 	querystring, nid := "nid=?", goutils.MustInt(ctx.Param("nid"))
-	topics := logic.DefaultTopic.FindAll(ctx, paginator, "topics.mtime DESC", querystring, nid)
+	topics := logic.DefaultTopic.FindAll(ctx, paginator, "topics.node_top DESC, topics.mtime DESC", querystring, nid)
 	total := logic.DefaultTopic.Count(ctx, querystring, nid)
 	page := paginator.SetTotal(total).GetPageHtml(ctx.Request().URL().Path())
 
@@ -108,7 +108,9 @@ func (TopicController) Detail(ctx echo.Context) error {
 		return fail(ctx, "tid 非法")
 	}
 
-	topic, replies, err := logic.DefaultTopic.FindByTid(ctx, tid)
+	me, _ := ctx.Get("user").(*model.Me)
+
+	topic, replies, err := logic.DefaultTopic.FindByTid(ctx, tid, me)
 	if err != nil {
 		return fail(ctx, "服务器异常")
 	}
@@ -118,6 +120,7 @@ func (TopicController) Detail(ctx echo.Context) error {
 	data := map[string]interface{}{
 		"topic":   topic,
 		"replies": replies,
+		"poll":    logic.DefaultPoll.FindByTid(ctx, tid, me),
 	}
 
 	return success(ctx, data)
@@ -166,6 +169,9 @@ func (TopicController) Modify(ctx echo.Context) error {
 		if err == logic.NotModifyAuthorityErr {
 			return fail(ctx, "没有权限操作", 1)
 		}
+		if err == logic.ConflictErr {
+			return fail(ctx, err.Error(), xhttp.ConflictCode)
+		}
 
 		return fail(ctx, "服务错误，请稍后重试！", 2)
 	}
@@ -8,11 +8,13 @@ package app
 
 import (
 	xhttp "sander/http"
+	"sander/http/middleware"
 	"sander/http/internal/helper"
 	"sander/logic"
 	"sander/model"
 
 	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
 )
 
 // UserController .
@@ -20,17 +22,19 @@ type UserController struct{}
 
 // RegisterRoute 注册路由
 func (u UserController) RegisterRoute(g *echo.Group) {
-	g.GET("/user/center", u.Center)
-	g.GET("/user/me", u.Me)
+	g.GET("/user/center", u.Center, middleware.NeedScope(model.ScopeRead))
+	g.GET("/user/me", u.Me, middleware.NeedScope(model.ScopeRead))
 	g.POST("/user/modify", u.Modify)
 	g.POST("/user/login", u.Login)
+	g.POST("/user/login/2fa", u.Login2FA)
 }
 
 // Center 用户自己个人中心
 func (UserController) Center(ctx echo.Context) error {
 	if user, ok := ctx.Get("user").(*model.Me); ok {
 		data := map[string]interface{}{
-			"user": user,
+			"user":   user,
+			"badges": logic.DefaultBadge.FindUserBadges(ctx, user.Uid),
 		}
 		return success(ctx, data)
 	}
@@ -62,15 +66,62 @@ func (UserController) Login(ctx echo.Context) error {
 		return fail(ctx, "用户名为空")
 	}
 
+	if wait := logic.DefaultRateLimit.LimitLogin(username, goutils.RemoteIp(xhttp.Request(ctx))); wait > 0 {
+		return middleware.RateLimited(ctx, wait)
+	}
+
 	// 处理用户登录
 	passwd := ctx.FormValue("passwd")
 	userLogin, err := logic.DefaultUser.Login(ctx, username, passwd)
+	if err != nil {
+		logic.DefaultRateLimit.LoginFailed(username)
+		return fail(ctx, err.Error())
+	}
+
+	// 启用了两步验证，需要带上 tfa_token 和动态码再请求 /user/login/2fa
+	if logic.DefaultUserTotp.IsEnabled(userLogin.Uid) {
+		return login2FA(ctx, userLogin.Uid)
+	}
+
+	token, err := xhttp.GenAppLoginToken(ctx, userLogin.Uid)
+	if err != nil {
+		return fail(ctx, err.Error())
+	}
+
+	data := map[string]interface{}{
+		"token":    token,
+		"uid":      userLogin.Uid,
+		"username": userLogin.Username,
+	}
+	return success(ctx, data)
+}
+
+// login2FA 登录第一步通过，但启用了两步验证，返回临时凭证 tfa_token
+func login2FA(ctx echo.Context, uid int) error {
+	tfaToken, err := logic.DefaultUserTotp.StartLogin(uid)
+	if err != nil {
+		return fail(ctx, err.Error())
+	}
+	return success(ctx, map[string]interface{}{
+		"need_2fa":  true,
+		"tfa_token": tfaToken,
+	})
+}
+
+// Login2FA 登录第二步：校验动态码或恢复码
+func (UserController) Login2FA(ctx echo.Context) error {
+	userLogin, err := logic.DefaultUserTotp.FinishLogin(ctx, ctx.FormValue("tfa_token"), ctx.FormValue("code"))
+	if err != nil {
+		return fail(ctx, err.Error())
+	}
+
+	token, err := xhttp.GenAppLoginToken(ctx, userLogin.Uid)
 	if err != nil {
 		return fail(ctx, err.Error())
 	}
 
 	data := map[string]interface{}{
-		"token":    xhttp.GenToken(userLogin.Uid),
+		"token":    token,
 		"uid":      userLogin.Uid,
 		"username": userLogin.Username,
 	}
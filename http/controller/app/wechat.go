@@ -13,7 +13,10 @@ import (
 	"github.com/labstack/echo"
 
 	xhttp "sander/http"
+	"sander/http/middleware"
 	"sander/logic"
+
+	"github.com/polaris1119/goutils"
 )
 
 // WechatController .
@@ -36,8 +39,18 @@ func (WechatController) CheckSession(ctx echo.Context) error {
 	}
 
 	if wechatUser.Uid > 0 {
+		// 启用了两步验证，需要带上 tfa_token 和动态码再请求 /user/login/2fa
+		if logic.DefaultUserTotp.IsEnabled(wechatUser.Uid) {
+			return login2FA(ctx, wechatUser.Uid)
+		}
+
+		token, err := xhttp.GenAppLoginToken(ctx, wechatUser.Uid)
+		if err != nil {
+			return fail(ctx, err.Error())
+		}
+
 		data := map[string]interface{}{
-			"token":    xhttp.GenToken(wechatUser.Uid),
+			"token":    token,
 			"uid":      wechatUser.Uid,
 			"nickname": wechatUser.Nickname,
 			"avatar":   wechatUser.Avatar,
@@ -66,10 +79,15 @@ func (WechatController) Login(ctx echo.Context) error {
 		return fail(ctx, "用户名为空")
 	}
 
+	if wait := logic.DefaultRateLimit.LimitLogin(username, goutils.RemoteIp(xhttp.Request(ctx))); wait > 0 {
+		return middleware.RateLimited(ctx, wait)
+	}
+
 	// 处理用户登录
 	passwd := ctx.FormValue("passwd")
 	userLogin, err := logic.DefaultUser.Login(ctx, username, passwd)
 	if err != nil {
+		logic.DefaultRateLimit.LoginFailed(username)
 		return fail(ctx, err.Error())
 	}
 
@@ -80,8 +98,18 @@ func (WechatController) Login(ctx echo.Context) error {
 		return fail(ctx, err.Error())
 	}
 
+	// 密码正确就绑定，但登录还要通过两步验证
+	if logic.DefaultUserTotp.IsEnabled(wechatUser.Uid) {
+		return login2FA(ctx, wechatUser.Uid)
+	}
+
+	token, err := xhttp.GenAppLoginToken(ctx, wechatUser.Uid)
+	if err != nil {
+		return fail(ctx, err.Error())
+	}
+
 	data := map[string]interface{}{
-		"token":    xhttp.GenToken(wechatUser.Uid),
+		"token":    token,
 		"uid":      wechatUser.Uid,
 		"nickname": wechatUser.Nickname,
 		"avatar":   wechatUser.Avatar,
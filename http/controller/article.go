@@ -38,8 +38,8 @@ func (a ArticleController) RegisterRoute(g *echo.Group) {
 
 	g.Get("/articles/:id", a.Detail)
 
-	g.Match([]string{"GET", "POST"}, "/articles/new", a.Create, middleware.NeedLogin(), middleware.Sensivite(), middleware.BalanceCheck(), middleware.PublishNotice())
-	g.Match([]string{"GET", "POST"}, "/articles/modify", a.Modify, middleware.NeedLogin(), middleware.Sensivite())
+	g.Match([]string{"GET", "POST"}, "/articles/new", a.Create, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck(), middleware.BalanceCheck(), middleware.PublishNotice())
+	g.Match([]string{"GET", "POST"}, "/articles/modify", a.Modify, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck())
 }
 
 // ReadList 网友文章列表页
@@ -49,13 +49,13 @@ func (ArticleController) ReadList(ctx echo.Context) error {
 	curPage := goutils.MustInt(ctx.QueryParam("p"), 1)
 	paginator := logic.NewPaginator(curPage)
 	paginator.SetPerPage(limit)
-	total := logic.DefaultArticle.Count(ctx, "")
+	total := logic.DefaultArticle.Count(ctx, "status IN(?,?)", model.ArticleStatusNew, model.ArticleStatusOnline)
 	pageHtml := paginator.SetTotal(total).GetPageHtml(ctx.Request().URL().Path())
 	pageInfo := template.HTML(pageHtml)
 
 	// TODO: 参考的 topics 的处理方式，但是感觉不应该这样做
-	topArticles := logic.DefaultArticle.FindAll(ctx, paginator, "id DESC", "top=1")
-	unTopArticles := logic.DefaultArticle.FindAll(ctx, paginator, "id DESC", "top!=1")
+	topArticles := logic.DefaultArticle.FindAll(ctx, paginator, "id DESC", "top=1 AND status IN(?,?)", model.ArticleStatusNew, model.ArticleStatusOnline)
+	unTopArticles := logic.DefaultArticle.FindAll(ctx, paginator, "id DESC", "top!=1 AND status IN(?,?)", model.ArticleStatusNew, model.ArticleStatusOnline)
 	articles := append(topArticles, unTopArticles...)
 	if articles == nil {
 		logger.Error("article controller: find article error")
@@ -108,6 +108,15 @@ func (ArticleController) Detail(ctx echo.Context) error {
 		return ctx.Redirect(http.StatusSeeOther, "/articles")
 	}
 
+	me, ok := ctx.Get("user").(*model.Me)
+
+	// 定时发布的文章发布前、影子封禁的文章，只有作者和管理员能看到
+	if article.Status == model.ArticleStatusScheduled || article.Status == model.ArticleStatusShadow {
+		if !ok || (me.Username != article.AuthorTxt && !me.IsAdmin) {
+			return ctx.Redirect(http.StatusSeeOther, "/articles")
+		}
+	}
+
 	articleGCTT := logic.DefaultArticle.FindArticleGCTT(ctx, article)
 	data := map[string]interface{}{
 		"activeArticles": "active",
@@ -117,7 +126,6 @@ func (ArticleController) Detail(ctx echo.Context) error {
 		"next":           prevNext[1],
 	}
 
-	me, ok := ctx.Get("user").(*model.Me)
 	if ok {
 		data["likeflag"] = logic.DefaultLike.HadLike(ctx, me.Uid, article.Id, model.TypeArticle)
 		data["hadcollect"] = logic.DefaultFavorite.HadFavorite(ctx, me.Uid, article.Id, model.TypeArticle)
@@ -141,6 +149,10 @@ func (ArticleController) Detail(ctx echo.Context) error {
 
 	data["subjects"] = logic.DefaultSubject.FindArticleSubjects(ctx, article.Id)
 
+	if editedAt, ok := logic.DefaultContentRevision.FindEditedTimes(model.TypeArticle, []int{article.Id})[article.Id]; ok {
+		data["edited_at"] = editedAt
+	}
+
 	return render(ctx, "articles/detail.html,common/comment.html", data)
 }
 
@@ -148,7 +160,15 @@ func (ArticleController) Detail(ctx echo.Context) error {
 func (ArticleController) Create(ctx echo.Context) error {
 	title := ctx.FormValue("title")
 	if title == "" || ctx.Request().Method() != "POST" {
-		return render(ctx, "articles/new.html", map[string]interface{}{"activeArticles": "active"})
+		data := map[string]interface{}{"activeArticles": "active"}
+
+		// 从草稿继续编辑
+		if draftId := goutils.MustInt(ctx.QueryParam("draft")); draftId > 0 {
+			me := ctx.Get("user").(*model.Me)
+			data["draft"] = logic.DefaultDraft.FindOne(ctx, me, draftId)
+		}
+
+		return render(ctx, "articles/new.html", data)
 	}
 
 	if ctx.FormValue("content") == "" {
@@ -195,6 +215,10 @@ func (ArticleController) Modify(ctx echo.Context) error {
 
 	errMsg, err := logic.DefaultArticle.Modify(echoutils.WrapEchoContext(ctx), me, ctx.FormParams())
 	if err != nil {
+		if err == logic.ConflictErr {
+			article, _ = logic.DefaultArticle.FindById(ctx, id)
+			return conflict(ctx, article.Version, article.Title, article.Content, ctx.FormValue("content"))
+		}
 		return fail(ctx, 4, errMsg)
 	}
 
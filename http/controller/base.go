@@ -14,6 +14,7 @@ import (
 	"sander/db/nosql"
 	xhttp "sander/http"
 	"sander/logger"
+	"sander/util"
 
 	"github.com/labstack/echo"
 	"github.com/polaris1119/goutils"
@@ -74,3 +75,23 @@ func fail(ctx echo.Context, code int, msg string) error {
 
 	return ctx.JSON(http.StatusOK, result)
 }
+
+// conflict 编辑冲突：返回最新的内容以及和提交内容的对比，供用户合并后重新提交
+func conflict(ctx echo.Context, version int, title, content, mine string) error {
+	if ctx.Response().Committed() {
+		return nil
+	}
+
+	result := map[string]interface{}{
+		"ok":    0,
+		"error": "内容已被他人修改，请合并后重新提交",
+		"conflict": map[string]interface{}{
+			"version": version,
+			"title":   title,
+			"content": content,
+			"diff":    util.DiffLines(content, mine),
+		},
+	}
+
+	return ctx.JSON(http.StatusOK, result)
+}
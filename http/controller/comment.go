@@ -26,9 +26,9 @@ type CommentController struct{}
 
 func (c CommentController) RegisterRoute(g *echo.Group) {
 	g.Get("/at/users", c.AtUsers)
-	g.Post("/comment/:objid", c.Create, middleware.NeedLogin(), middleware.Sensivite(), middleware.BalanceCheck(), middleware.PublishNotice())
+	g.Post("/comment/:objid", c.Create, middleware.NeedLogin(), middleware.RateLimit("comment"), middleware.ContentFilter(), middleware.LinkCheck(), middleware.BalanceCheck(), middleware.PublishNotice())
 	g.Get("/object/comments", c.CommentList)
-	g.Post("/object/comments/:cid", c.Modify, middleware.NeedLogin(), middleware.Sensivite())
+	g.Post("/object/comments/:cid", c.Modify, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck())
 
 	g.Get("/topics/:objid/comment/:cid", c.TopicDetail)
 	g.Get("/articles/:objid/comment/:cid", c.ArticleDetail)
@@ -53,6 +53,9 @@ func (CommentController) Create(ctx echo.Context) error {
 	}
 	comment, err := logic.DefaultComment.Publish(ctx, user.Uid, objid, ctx.FormParams())
 	if err != nil {
+		if err == logic.TopicLockedErr {
+			return fail(ctx, 3, err.Error())
+		}
 		return fail(ctx, 2, "服务器内部错误")
 	}
 
@@ -79,7 +82,7 @@ func (CommentController) Modify(ctx echo.Context) error {
 		return fail(ctx, 3, "没有修改权限")
 	}
 
-	errMsg, err := logic.DefaultComment.Modify(echoutils.WrapEchoContext(ctx), cid, content)
+	errMsg, err := logic.DefaultComment.Modify(echoutils.WrapEchoContext(ctx), me, cid, content)
 	if err != nil {
 		return fail(ctx, 4, errMsg)
 	}
@@ -92,13 +95,15 @@ func (CommentController) CommentList(ctx echo.Context) error {
 	objid := goutils.MustInt(ctx.QueryParam("objid"))
 	objtype := goutils.MustInt(ctx.QueryParam("objtype"))
 
-	commentList, err := logic.DefaultComment.FindObjectComments(ctx, objid, objtype)
+	me, _ := ctx.Get("user").(*model.Me)
+	commentList, err := logic.DefaultComment.FindObjectComments(ctx, objid, objtype, me)
 	if err != nil {
 		return fail(ctx, 1, "服务器内部错误")
 	}
 
 	uids := slices.StructsIntSlice(commentList, "Uid")
 	users := logic.DefaultUser.FindUserInfos(ctx, uids)
+	logic.DefaultBadge.FillUsersBadges(ctx, users)
 
 	result := map[string]interface{}{
 		"comments": commentList,
@@ -0,0 +1,62 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package controller
+
+import (
+	"sander/http/middleware"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// DraftController 主题、文章草稿
+type DraftController struct{}
+
+// RegisterRoute 注册路由
+func (d DraftController) RegisterRoute(g *echo.Group) {
+	g.GET("/drafts", d.ReadList, middleware.NeedLogin())
+	g.POST("/drafts/save", d.Save, middleware.NeedLogin())
+	g.POST("/drafts/:id/delete", d.Delete, middleware.NeedLogin())
+}
+
+// ReadList 我的草稿
+func (DraftController) ReadList(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	drafts := logic.DefaultDraft.FindAll(ctx, me)
+
+	return render(ctx, "user/drafts.html", map[string]interface{}{"drafts": drafts})
+}
+
+// Save 编辑器自动保存草稿
+func (DraftController) Save(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	id, err := logic.DefaultDraft.Save(ctx, me,
+		goutils.MustInt(ctx.FormValue("id")),
+		goutils.MustInt(ctx.FormValue("objtype")),
+		ctx.FormValue("title"),
+		ctx.FormValue("content"),
+		ctx.FormValue("extra"),
+	)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, map[string]interface{}{"id": id})
+}
+
+// Delete 删除草稿
+func (DraftController) Delete(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	if err := logic.DefaultDraft.Delete(ctx, me.Uid, goutils.MustInt(ctx.Param("id"))); err != nil {
+		return fail(ctx, 1, "删除失败")
+	}
+
+	return success(ctx, nil)
+}
@@ -18,6 +18,7 @@ import (
 	xhttp "sander/http"
 	"sander/logger"
 	"sander/logic"
+	"sander/model"
 
 	"github.com/labstack/echo"
 	"github.com/polaris1119/goutils"
@@ -37,6 +38,9 @@ func (i ImageController) RegisterRoute(eg *echo.Group) {
 
 // PasteUpload jquery 粘贴上传图片
 func (i ImageController) PasteUpload(ctx echo.Context) error {
+	if !i.canUpload(ctx) {
+		return i.pasteUploadFail(ctx, "等级不够，还不能上传图片！")
+	}
 
 	file, fileHeader, err := xhttp.Request(ctx).FormFile("imageFile")
 	if err != nil {
@@ -81,6 +85,9 @@ func (i ImageController) PasteUpload(ctx echo.Context) error {
 
 // QuickUpload CKEditor 编辑器，上传图片，支持粘贴方式上传
 func (i ImageController) QuickUpload(ctx echo.Context) error {
+	if !i.canUpload(ctx) {
+		return i.quickUploadFail(ctx, "等级不够，还不能上传图片！")
+	}
 
 	file, fileHeader, err := xhttp.Request(ctx).FormFile("upload")
 	if err != nil {
@@ -166,7 +173,11 @@ func (ImageController) Upload(ctx echo.Context) error {
 }
 
 // Transfer 转换图片：通过 url 从远程下载图片然后转存到七牛
-func (ImageController) Transfer(ctx echo.Context) error {
+func (i ImageController) Transfer(ctx echo.Context) error {
+	if !i.canUpload(ctx) {
+		return fail(ctx, 3, "等级不够，还不能上传图片！")
+	}
+
 	origURL := ctx.FormValue("url")
 	if origURL == "" {
 		return fail(ctx, 1, "url不能为空！")
@@ -182,6 +193,12 @@ func (ImageController) Transfer(ctx echo.Context) error {
 	return success(ctx, map[string]interface{}{"url": cdnDomain + path})
 }
 
+// canUpload 内容中的图片需要解锁了“上传图片”特权才能上传
+func (ImageController) canUpload(ctx echo.Context) bool {
+	me, ok := ctx.Get("user").(*model.Me)
+	return ok && logic.HasPrivilege(me, model.PrivUploadImage)
+}
+
 func (ImageController) quickUploadFail(ctx echo.Context, message string) error {
 	data := map[string]interface{}{
 		"uploaded": 0,
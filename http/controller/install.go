@@ -254,12 +254,6 @@ func (InstallController) genConfig(ctx echo.Context) error {
 	config.ConfigFile.SetKeyComments("security", "activate_sign_salt", "注册激活邮件使用的 sign salt")
 	config.ConfigFile.SetValue("security", "activate_sign_salt", goutils.RandString(18))
 
-	config.ConfigFile.SetSectionComments("sensitive", "过滤广告")
-	config.ConfigFile.SetKeyComments("sensitive", "title", "标题关键词")
-	config.ConfigFile.SetValue("sensitive", "title", "")
-	config.ConfigFile.SetKeyComments("sensitive", "content", "内容关键词")
-	config.ConfigFile.SetValue("sensitive", "content", "")
-
 	config.ConfigFile.SetSectionComments("search", "搜索配置")
 	config.ConfigFile.SetValue("search", "engine_url", "")
 
@@ -0,0 +1,29 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package controller
+
+import (
+	"sander/http/middleware"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+)
+
+type LevelController struct{}
+
+// 注册路由
+func (l LevelController) RegisterRoute(g *echo.Group) {
+	g.Get("/level", l.Level, middleware.NeedLogin())
+}
+
+// Level 我的等级：当前等级、特权以及如何升级
+func (LevelController) Level(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	return render(ctx, "user/level.html", logic.DefaultUserLevel.FindLevelInfo(ctx, me))
+}
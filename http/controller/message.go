@@ -27,7 +27,7 @@ func (m MessageController) RegisterRoute(g *echo.Group) {
 
 	messageG.GET(":msgtype", m.ReadList)
 	messageG.GET("system", m.ReadList)
-	messageG.Match([]string{"GET", "POST"}, "send", m.Send)
+	messageG.Match([]string{"GET", "POST"}, "send", m.Send, middleware.RateLimit("message"))
 	messageG.POST("delete", m.Delete)
 
 	// g.GET("/message/:msgtype", m.ReadList, middleware.NeedLogin())
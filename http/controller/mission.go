@@ -8,14 +8,14 @@ package controller
 
 import (
 	"net/http"
-	"strconv"
+	"net/url"
 
 	"sander/http/middleware"
 	"sander/logic"
 	"sander/model"
 
 	"github.com/labstack/echo"
-	"github.com/polaris1119/times"
+	"github.com/polaris1119/goutils"
 )
 
 type MissionController struct{}
@@ -24,25 +24,25 @@ type MissionController struct{}
 func (m MissionController) RegisterRoute(g *echo.Group) {
 	g.Get("/mission/daily", m.Daily, middleware.NeedLogin())
 	g.Get("/mission/daily/redeem", m.DailyRedeem, middleware.NeedLogin())
+	g.Post("/mission/daily/makeup", m.DailyMakeup, middleware.NeedLogin())
 	g.Get("/mission/complete/:id", m.Complete, middleware.NeedLogin())
 }
 
 func (MissionController) Daily(ctx echo.Context) error {
 	me := ctx.Get("user").(*model.Me)
-	userLoginMission := logic.DefaultMission.FindLoginMission(ctx, me)
-	userLoginMission.Uid = me.Uid
+	loginInfo := logic.DefaultMission.FindLoginInfo(ctx, me, goutils.MustInt(ctx.QueryParam("month")))
 
-	data := map[string]interface{}{"login_mission": userLoginMission}
-
-	if userLoginMission != nil && times.Format("Ymd") == strconv.Itoa(userLoginMission.Date) {
-		data["had_redeem"] = true
-	} else {
-		data["had_redeem"] = false
+	data := map[string]interface{}{
+		"login_info":    loginInfo,
+		"rule_missions": logic.DefaultMission.FindRuleMissions(ctx, me),
+		"had_redeem":    loginInfo != nil && loginInfo["had_redeem"].(bool),
 	}
 
-	fr := ctx.QueryParam("fr")
-	if fr == "redeem" {
+	switch ctx.QueryParam("fr") {
+	case "redeem":
 		data["show_msg"] = true
+	case "makeup":
+		data["makeup_msg"] = ctx.QueryParam("msg")
 	}
 	return render(ctx, "mission/daily.html", data)
 }
@@ -54,6 +54,19 @@ func (MissionController) DailyRedeem(ctx echo.Context) error {
 	return ctx.Redirect(http.StatusSeeOther, "/mission/daily?fr=redeem")
 }
 
+// DailyMakeup 购买补签卡补签昨天
+func (MissionController) DailyMakeup(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	msg := "补签成功"
+	err := logic.DefaultMission.MakeupLogin(ctx, me)
+	if err != nil {
+		msg = err.Error()
+	}
+
+	return ctx.Redirect(http.StatusSeeOther, "/mission/daily?fr=makeup&msg="+url.QueryEscape(msg))
+}
+
 func (MissionController) Complete(ctx echo.Context) error {
 	me := ctx.Get("user").(*model.Me)
 	id := ctx.Param("id")
@@ -8,44 +8,69 @@ package controller
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 
 	xhttp "sander/http"
 	"sander/logic"
 	"sander/model"
 
 	"github.com/labstack/echo"
+	guuid "github.com/twinj/uuid"
 )
 
 type OAuthController struct{}
 
-// 注册路由
+// 注册路由，name 为 logic 中注册的登录方式：github、gitee、gitlab、oidc
 func (o OAuthController) RegisterRoute(g *echo.Group) {
-	g.Get("/oauth/github/callback", o.GithubCallback)
-	g.Get("/oauth/github/login", o.GithubLogin)
+	g.Get("/oauth/:name/callback", o.Callback)
+	g.Get("/oauth/:name/login", o.Login)
 }
 
-func (OAuthController) GithubLogin(ctx echo.Context) error {
+// Login 跳转到第三方授权页。uri 为授权后要回到的本站页面
+func (OAuthController) Login(ctx echo.Context) error {
 	uri := ctx.QueryParam("uri")
-	url := logic.DefaultThirdUser.GithubAuthCodeUrl(ctx, uri)
-	return ctx.Redirect(http.StatusSeeOther, url)
+	if !strings.HasPrefix(uri, "/") || strings.HasPrefix(uri, "//") {
+		uri = ""
+	}
+
+	// state 防止 CSRF，和 uri 一起放在 cookie 中，回调时校验
+	state := guuid.NewV4().String()
+	authURL, err := logic.DefaultThirdUser.AuthCodeUrl(ctx, ctx.Param("name"), state, oauthCallbackURL(ctx))
+	if err != nil {
+		return render(ctx, "login.html", map[string]interface{}{"error": err.Error()})
+	}
+	xhttp.SetCookie(ctx, "oauth_state", state+","+uri)
+
+	return ctx.Redirect(http.StatusSeeOther, authURL)
 }
 
-func (OAuthController) GithubCallback(ctx echo.Context) error {
+func (OAuthController) Callback(ctx echo.Context) error {
+	stateAndURI := strings.SplitN(xhttp.GetFromCookie(ctx, "oauth_state"), ",", 2)
+	if len(stateAndURI) != 2 || stateAndURI[0] == "" || stateAndURI[0] != ctx.FormValue("state") {
+		return render(ctx, "login.html", map[string]interface{}{"error": "授权已失效，请重试"})
+	}
+	uri := stateAndURI[1]
+
+	name := ctx.Param("name")
 	code := ctx.FormValue("code")
 
 	me, ok := ctx.Get("user").(*model.Me)
 	if ok {
-		// 已登录用户，绑定 github
-		logic.DefaultThirdUser.BindGithub(ctx, code, me)
+		// 已登录用户，绑定第三方账号
+		err := logic.DefaultThirdUser.Bind(ctx, name, code, oauthCallbackURL(ctx), me)
+		if err != nil {
+			// 在账号关联中显示
+			xhttp.SetCookie(ctx, "oauth_error", err.Error())
+		}
 
-		redirectURL := ctx.QueryParam("redirect_url")
-		if redirectURL == "" {
-			redirectURL = "/account/edit#connection"
+		if uri == "" {
+			uri = "/account/edit#connection"
 		}
-		return ctx.Redirect(http.StatusSeeOther, redirectURL)
+		return ctx.Redirect(http.StatusSeeOther, uri)
 	}
 
-	user, err := logic.DefaultThirdUser.LoginFromGithub(ctx, code)
+	user, err := logic.DefaultThirdUser.Login(ctx, name, code, oauthCallbackURL(ctx))
 	if err != nil || user.Uid == 0 {
 		var errMsg = ""
 		if err != nil {
@@ -57,12 +82,38 @@ func (OAuthController) GithubCallback(ctx echo.Context) error {
 		return render(ctx, "login.html", map[string]interface{}{"error": errMsg})
 	}
 
+	// 启用了两步验证，还需要输入动态码
+	if logic.DefaultUserTotp.IsEnabled(user.Uid) {
+		if err = startLogin2FA(ctx, user.Uid); err != nil {
+			return render(ctx, "login.html", map[string]interface{}{"error": err.Error()})
+		}
+		// 和下面一样，没有铜币的用户验证通过后先去领取
+		if user.Balance == 0 {
+			uri = "/balance"
+		}
+		return ctx.Redirect(http.StatusSeeOther, "/account/login/2fa?redirect_uri="+url.QueryEscape(uri))
+	}
+
 	// 登录成功，种cookie
-	xhttp.SetLoginCookie(ctx, user.Username)
+	if err = xhttp.SetLoginCookie(ctx, user.Uid); err != nil {
+		return render(ctx, "login.html", map[string]interface{}{"error": err.Error()})
+	}
 
 	if user.Balance == 0 {
 		return ctx.Redirect(http.StatusSeeOther, "/balance")
 	}
 
-	return ctx.Redirect(http.StatusSeeOther, "/")
+	if uri == "" {
+		uri = "/"
+	}
+	return ctx.Redirect(http.StatusSeeOther, uri)
+}
+
+// oauthCallbackURL 授权后第三方回调的地址，需要和在第三方登记的一致
+func oauthCallbackURL(ctx echo.Context) string {
+	scheme := "http://"
+	if xhttp.CheckIsHttps(ctx) {
+		scheme = "https://"
+	}
+	return scheme + ctx.Request().Host() + "/oauth/" + ctx.Param("name") + "/callback"
 }
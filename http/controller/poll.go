@@ -0,0 +1,66 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package controller
+
+import (
+	"sander/http/middleware"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// PollController 主题中的投票
+type PollController struct{}
+
+// RegisterRoute 注册路由
+func (p PollController) RegisterRoute(g *echo.Group) {
+	g.GET("/poll/:id", p.Detail)
+	g.POST("/poll/:id/vote", p.Vote, middleware.NeedLogin())
+	g.POST("/poll/:id/close", p.Close, middleware.NeedLogin())
+}
+
+// Detail 投票当前的情况，用于实时刷新结果
+func (PollController) Detail(ctx echo.Context) error {
+	me, _ := ctx.Get("user").(*model.Me)
+	poll := logic.DefaultPoll.FindOne(ctx, goutils.MustInt(ctx.Param("id")), me)
+	if poll == nil {
+		return fail(ctx, 1, "投票不存在")
+	}
+
+	return success(ctx, poll)
+}
+
+// Vote 投票，多选时 option_id 传多个
+func (PollController) Vote(ctx echo.Context) error {
+	id := goutils.MustInt(ctx.Param("id"))
+
+	optionIds := make([]int, 0)
+	for _, optionId := range ctx.FormParams()["option_id"] {
+		optionIds = append(optionIds, goutils.MustInt(optionId))
+	}
+
+	me := ctx.Get("user").(*model.Me)
+	err := logic.DefaultPoll.Vote(ctx, me, id, optionIds)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, logic.DefaultPoll.FindOne(ctx, id, me))
+}
+
+// Close 提前结束投票
+func (PollController) Close(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	err := logic.DefaultPoll.Close(ctx, me, goutils.MustInt(ctx.Param("id")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
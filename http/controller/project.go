@@ -31,8 +31,8 @@ type ProjectController struct{}
 // 注册路由
 func (p ProjectController) RegisterRoute(g *echo.Group) {
 	g.GET("/projects", p.ReadList)
-	g.Match([]string{"GET", "POST"}, "/project/new", p.Create, middleware.NeedLogin(), middleware.Sensivite(), middleware.BalanceCheck(), middleware.PublishNotice())
-	g.Match([]string{"GET", "POST"}, "/project/modify", p.Modify, middleware.NeedLogin(), middleware.Sensivite())
+	g.Match([]string{"GET", "POST"}, "/project/new", p.Create, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck(), middleware.BalanceCheck(), middleware.PublishNotice())
+	g.Match([]string{"GET", "POST"}, "/project/modify", p.Modify, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck())
 	g.GET("/p/:uri", p.Detail)
 	g.GET("/project/uri", p.CheckExist)
 }
@@ -105,6 +105,11 @@ func (ProjectController) Modify(ctx echo.Context) error {
 		if err == logic.NotModifyAuthorityErr {
 			return ctx.String(http.StatusForbidden, "没有权限")
 		}
+		if err == logic.ConflictErr {
+			if project := logic.DefaultProject.FindOne(ctx, id); project != nil {
+				return conflict(ctx, project.Version, project.Name, project.Desc, ctx.FormValue("desc"))
+			}
+		}
 		return fail(ctx, 1, "内部服务错误！")
 	}
 	return success(ctx, nil)
@@ -0,0 +1,35 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package controller
+
+import (
+	"sander/http/middleware"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+)
+
+// ReportController 举报
+type ReportController struct{}
+
+// RegisterRoute 注册路由
+func (r ReportController) RegisterRoute(g *echo.Group) {
+	g.POST("/report", r.Report, middleware.NeedLogin())
+}
+
+// Report 举报主题、文章、资源、评论或用户
+func (ReportController) Report(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+
+	err := logic.DefaultReport.Report(ctx, me, ctx.FormParams())
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
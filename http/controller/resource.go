@@ -33,8 +33,8 @@ func (r ResourceController) RegisterRoute(g *echo.Group) {
 	g.GET("/resources", r.ReadList)
 	g.GET("/resources/cat/:catid", r.ReadCatResources)
 	g.GET("/resources/:id", r.Detail)
-	g.Match([]string{"GET", "POST"}, "/resources/new", r.Create, middleware.NeedLogin(), middleware.Sensivite(), middleware.BalanceCheck(), middleware.PublishNotice())
-	g.Match([]string{"GET", "POST"}, "/resources/modify", r.Modify, middleware.NeedLogin(), middleware.Sensivite())
+	g.Match([]string{"GET", "POST"}, "/resources/new", r.Create, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck(), middleware.BalanceCheck(), middleware.PublishNotice())
+	g.Match([]string{"GET", "POST"}, "/resources/modify", r.Modify, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck())
 }
 
 // ReadList 资源索引页
@@ -60,7 +60,10 @@ func (ResourceController) Detail(ctx echo.Context) error {
 	if id == 0 {
 		return ctx.Redirect(http.StatusSeeOther, "/resources/cat/1")
 	}
-	resource, comments := logic.DefaultResource.FindById(ctx, id)
+
+	me, ok := ctx.Get("user").(*model.Me)
+
+	resource, comments := logic.DefaultResource.FindById(ctx, id, me)
 	if len(resource) == 0 {
 		return ctx.Redirect(http.StatusSeeOther, "/resources/cat/1")
 	}
@@ -71,7 +74,6 @@ func (ResourceController) Detail(ctx echo.Context) error {
 		"comments":        comments,
 	}
 
-	me, ok := ctx.Get("user").(*model.Me)
 	if ok {
 		id := resource["id"].(int)
 		data["likeflag"] = logic.DefaultLike.HadLike(ctx, me.Uid, id, model.TypeResource)
@@ -145,6 +147,10 @@ func (ResourceController) Modify(ctx echo.Context) error {
 		if err == logic.NotModifyAuthorityErr {
 			return ctx.String(http.StatusForbidden, "没有权限修改")
 		}
+		if err == logic.ConflictErr {
+			resource := logic.DefaultResource.FindResource(ctx, id)
+			return conflict(ctx, resource.Version, resource.Title, resource.Content, ctx.FormValue("content"))
+		}
 		return fail(ctx, 2, "内部服务错误，请稍候再试！")
 	}
 
@@ -0,0 +1,135 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package controller
+
+import (
+	"net/http"
+
+	"sander/http/middleware"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// RevisionController 主题、文章、评论的修改记录
+type RevisionController struct{}
+
+// RegisterRoute 注册路由
+func (r RevisionController) RegisterRoute(g *echo.Group) {
+	g.GET("/revisions/:objtype/:objid", r.History)
+	g.GET("/revisions/:objtype/:objid/diff", r.Diff, middleware.NeedLogin())
+	g.GET("/revisions/:objtype/:objid/:version", r.Revision, middleware.NeedLogin())
+	g.POST("/revisions/:objtype/:objid/restore", r.Restore, middleware.NeedLogin())
+}
+
+// History 修改记录列表
+func (RevisionController) History(ctx echo.Context) error {
+	objtype, objid := goutils.MustInt(ctx.Param("objtype")), goutils.MustInt(ctx.Param("objid"))
+
+	object := logic.DefaultContentRevision.FindObject(ctx, objtype, objid)
+	if object == nil {
+		return render(ctx, "notfound.html", nil)
+	}
+
+	data := map[string]interface{}{
+		"objtype":   objtype,
+		"objid":     objid,
+		"object":    object,
+		"revisions": logic.DefaultContentRevision.FindRevisions(ctx, objtype, objid),
+	}
+
+	me, ok := ctx.Get("user").(*model.Me)
+	data["can_restore"] = ok && logic.DefaultContentRevision.CanRestore(me, objtype)
+
+	return render(ctx, "revision/history.html", data)
+}
+
+// Diff 左右对照比较两个版本，默认比较最新版本和上一个版本：管理员用
+func (RevisionController) Diff(ctx echo.Context) error {
+	objtype, objid := goutils.MustInt(ctx.Param("objtype")), goutils.MustInt(ctx.Param("objid"))
+
+	me := ctx.Get("user").(*model.Me)
+	if !logic.DefaultContentRevision.CanRestore(me, objtype) {
+		return ctx.HTML(http.StatusForbidden, `403 Forbidden`)
+	}
+
+	object := logic.DefaultContentRevision.FindObject(ctx, objtype, objid)
+	if object == nil {
+		return render(ctx, "notfound.html", nil)
+	}
+
+	historyURL := "/revisions/" + ctx.Param("objtype") + "/" + ctx.Param("objid")
+
+	to := logic.DefaultContentRevision.FindRevision(ctx, objtype, objid, goutils.MustInt(ctx.QueryParam("to")))
+	if to == nil {
+		return ctx.Redirect(http.StatusSeeOther, historyURL)
+	}
+
+	fromVersion := goutils.MustInt(ctx.QueryParam("from"), to.Version-1)
+	if fromVersion < 1 {
+		return ctx.Redirect(http.StatusSeeOther, historyURL)
+	}
+	from := logic.DefaultContentRevision.FindRevision(ctx, objtype, objid, fromVersion)
+	if from == nil {
+		return ctx.Redirect(http.StatusSeeOther, historyURL)
+	}
+
+	data := map[string]interface{}{
+		"objtype": objtype,
+		"objid":   objid,
+		"object":  object,
+		"from":    from,
+		"to":      to,
+		"rows":    logic.DefaultContentRevision.Diff(from, to),
+	}
+
+	return render(ctx, "revision/diff.html", data)
+}
+
+// Revision 查看某个历史版本的完整内容：管理员用
+func (RevisionController) Revision(ctx echo.Context) error {
+	objtype, objid := goutils.MustInt(ctx.Param("objtype")), goutils.MustInt(ctx.Param("objid"))
+
+	me := ctx.Get("user").(*model.Me)
+	if !logic.DefaultContentRevision.CanRestore(me, objtype) {
+		return ctx.HTML(http.StatusForbidden, `403 Forbidden`)
+	}
+
+	object := logic.DefaultContentRevision.FindObject(ctx, objtype, objid)
+	if object == nil {
+		return render(ctx, "notfound.html", nil)
+	}
+
+	revision := logic.DefaultContentRevision.FindRevision(ctx, objtype, objid, goutils.MustInt(ctx.Param("version")))
+	if revision == nil {
+		return render(ctx, "notfound.html", nil)
+	}
+
+	data := map[string]interface{}{
+		"objtype":  objtype,
+		"objid":    objid,
+		"object":   object,
+		"revision": revision,
+	}
+
+	return render(ctx, "revision/revision.html", data)
+}
+
+// Restore 恢复到某个历史版本：管理员用
+func (RevisionController) Restore(ctx echo.Context) error {
+	objtype, objid := goutils.MustInt(ctx.Param("objtype")), goutils.MustInt(ctx.Param("objid"))
+
+	me := ctx.Get("user").(*model.Me)
+	err := logic.DefaultContentRevision.Restore(ctx, me, objtype, objid, goutils.MustInt(ctx.FormValue("version")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
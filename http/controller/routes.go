@@ -23,12 +23,17 @@ func RegisterRoutes(g *echo.Group) {
 	new(MessageController).RegisterRoute(g)
 	new(SidebarController).RegisterRoute(g)
 	new(CommentController).RegisterRoute(g)
+	new(RevisionController).RegisterRoute(g)
+	new(PollController).RegisterRoute(g)
+	new(DraftController).RegisterRoute(g)
+	new(ReportController).RegisterRoute(g)
 	new(SearchController).RegisterRoute(g)
 	new(WideController).RegisterRoute(g)
 	new(ImageController).RegisterRoute(g)
 	new(CaptchaController).RegisterRoute(g)
 	new(BookController).RegisterRoute(g)
 	new(MissionController).RegisterRoute(g)
+	new(LevelController).RegisterRoute(g)
 	new(UserRichController).RegisterRoute(g)
 	new(TopController).RegisterRoute(g)
 	new(GiftController).RegisterRoute(g)
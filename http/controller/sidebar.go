@@ -91,7 +91,7 @@ func (SidebarController) RecentDynamic(ctx echo.Context) error {
 // RecentTopic 最新帖子
 func (SidebarController) RecentTopic(ctx echo.Context) error {
 	limit := goutils.MustInt(ctx.QueryParam("limit"), 10)
-	topicList := logic.DefaultTopic.FindRecent(limit)
+	topicList := logic.DefaultTopic.FindRecent(ctx, limit)
 	return success(ctx, topicList)
 }
 
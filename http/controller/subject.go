@@ -31,8 +31,8 @@ func (s SubjectController) RegisterRoute(g *echo.Group) {
 	g.Post("/subject/remove_contribute", s.RemoveContribute, middleware.NeedLogin())
 	g.Get("/subject/mine", s.Mine, middleware.NeedLogin())
 
-	g.Match([]string{"GET", "POST"}, "/subject/new", s.Create, middleware.NeedLogin(), middleware.Sensivite(), middleware.BalanceCheck(), middleware.PublishNotice())
-	g.Match([]string{"GET", "POST"}, "/subject/modify", s.Modify, middleware.NeedLogin(), middleware.Sensivite())
+	g.Match([]string{"GET", "POST"}, "/subject/new", s.Create, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck(), middleware.BalanceCheck(), middleware.PublishNotice())
+	g.Match([]string{"GET", "POST"}, "/subject/modify", s.Modify, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck())
 }
 
 func (SubjectController) Index(ctx echo.Context) error {
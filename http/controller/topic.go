@@ -39,12 +39,18 @@ func (t TopicController) RegisterRoute(g *echo.Group) {
 	g.GET("/go/:node", t.GoNodeTopics)
 	g.GET("/nodes", t.Nodes)
 
-	g.Match([]string{"GET", "POST"}, "/topics/new", t.Create, middleware.NeedLogin(), middleware.Sensivite(), middleware.BalanceCheck(), middleware.PublishNotice())
-	g.Match([]string{"GET", "POST"}, "/topics/modify", t.Modify, middleware.NeedLogin(), middleware.Sensivite())
+	g.Match([]string{"GET", "POST"}, "/topics/new", t.Create, middleware.NeedLogin(), middleware.RateLimit("topic"), middleware.ContentFilter(), middleware.LinkCheck(), middleware.BalanceCheck(), middleware.PublishNotice())
+	g.Match([]string{"GET", "POST"}, "/topics/modify", t.Modify, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck())
 
 	g.POST("/topics/set_top", t.SetTop, middleware.NeedLogin())
-
-	g.Match([]string{"GET", "POST"}, "/append/topic/:tid", t.Append, middleware.NeedLogin(), middleware.Sensivite(), middleware.BalanceCheck())
+	g.POST("/topics/lock", t.Lock, middleware.NeedLogin())
+	g.POST("/topics/close", t.Close, middleware.NeedLogin())
+	g.POST("/topics/move", t.Move, middleware.NeedLogin())
+	g.POST("/topics/merge", t.Merge, middleware.NeedLogin())
+	g.POST("/topics/delete", t.Delete, middleware.NeedLogin())
+	g.POST("/topics/node_top", t.NodeTop, middleware.NeedLogin())
+
+	g.Match([]string{"GET", "POST"}, "/append/topic/:tid", t.Append, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck(), middleware.BalanceCheck())
 }
 
 func (t TopicController) TopicList(ctx echo.Context) error {
@@ -108,14 +114,15 @@ func (TopicController) NodeTopics(ctx echo.Context) error {
 	paginator := logic.NewPaginator(curPage)
 
 	querystring, nid := "nid=?", goutils.MustInt(ctx.Param("nid"))
-	topics := logic.DefaultTopic.FindAll(ctx, paginator, "topics.mtime DESC", querystring, nid)
+	topics := logic.DefaultTopic.FindAll(ctx, paginator, "topics.node_top DESC, topics.mtime DESC", querystring, nid)
 	total := logic.DefaultTopic.Count(ctx, querystring, nid)
 	pageHtml := paginator.SetTotal(total).GetPageHtml(ctx.Request().URL().Path())
 
 	// 当前节点信息
 	node := logic.GetNode(nid)
+	moderators := logic.DefaultNodeModerator.FindByNid(ctx, nid)
 
-	return render(ctx, "topics/node.html", map[string]interface{}{"activeTopics": "active", "topics": topics, "page": template.HTML(pageHtml), "total": total, "node": node})
+	return render(ctx, "topics/node.html", map[string]interface{}{"activeTopics": "active", "topics": topics, "page": template.HTML(pageHtml), "total": total, "node": node, "moderators": moderators})
 }
 
 // GoNodeTopics 某节点下的主题列表，uri: /go/golang
@@ -130,11 +137,12 @@ func (TopicController) GoNodeTopics(ctx echo.Context) error {
 	}
 
 	querystring, nid := "nid=?", node["nid"].(int)
-	topics := logic.DefaultTopic.FindAll(ctx, paginator, "topics.mtime DESC", querystring, nid)
+	topics := logic.DefaultTopic.FindAll(ctx, paginator, "topics.node_top DESC, topics.mtime DESC", querystring, nid)
 	total := logic.DefaultTopic.Count(ctx, querystring, nid)
 	pageHtml := paginator.SetTotal(total).GetPageHtml(ctx.Request().URL().Path())
+	moderators := logic.DefaultNodeModerator.FindByNid(ctx, nid)
 
-	return render(ctx, "topics/node.html", map[string]interface{}{"activeTopics": "active", "topics": topics, "page": template.HTML(pageHtml), "total": total, "node": node})
+	return render(ctx, "topics/node.html", map[string]interface{}{"activeTopics": "active", "topics": topics, "page": template.HTML(pageHtml), "total": total, "node": node, "moderators": moderators})
 }
 
 // Detail 社区主题详细页
@@ -144,18 +152,24 @@ func (TopicController) Detail(ctx echo.Context) error {
 		return render(ctx, "notfound.html", nil)
 	}
 
-	topic, replies, err := logic.DefaultTopic.FindByTid(ctx, tid)
+	me, ok := ctx.Get("user").(*model.Me)
+
+	topic, replies, err := logic.DefaultTopic.FindByTid(ctx, tid, me)
 	if err != nil {
 		return render(ctx, "notfound.html", nil)
 	}
 
+	// 被合并的主题跳转到合并后的主题
+	if mergedTo := topic["merged_to"].(int); mergedTo > 0 {
+		return ctx.Redirect(http.StatusMovedPermanently, "/topics/"+strconv.Itoa(mergedTo))
+	}
+
 	data := map[string]interface{}{
 		"activeTopics": "active",
 		"topic":        topic,
 		"replies":      replies,
 	}
 
-	me, ok := ctx.Get("user").(*model.Me)
 	if ok {
 		tid := topic["tid"].(int)
 		data["likeflag"] = logic.DefaultLike.HadLike(ctx, me.Uid, tid, model.TypeTopic)
@@ -176,6 +190,18 @@ func (TopicController) Detail(ctx echo.Context) error {
 	}
 
 	data["appends"] = logic.DefaultTopic.FindAppend(ctx, tid)
+	data["poll"] = logic.DefaultPoll.FindByTid(ctx, tid, me)
+	data["mod_logs"] = logic.DefaultTopic.FindModLogs(ctx, tid)
+	if canModerate := logic.DefaultTopic.CanModerate(me, topic["nid"].(int)); canModerate != nil {
+		data["can_moderate"] = canModerate
+		if canModerate["move"] {
+			data["move_nodes"] = logic.GenNodes()
+		}
+	}
+
+	if editedAt, ok := logic.DefaultContentRevision.FindEditedTimes(model.TypeTopic, []int{tid})[tid]; ok {
+		data["edited_at"] = editedAt
+	}
 
 	return render(ctx, "topics/detail.html,common/comment.html", data)
 }
@@ -205,6 +231,13 @@ func (TopicController) Create(ctx echo.Context) error {
 
 		data["had_recommend"] = hadRecommend
 
+		// 从草稿继续编辑
+		if draftId := goutils.MustInt(ctx.QueryParam("draft")); draftId > 0 {
+			if me, ok := ctx.Get("user").(*model.Me); ok {
+				data["draft"] = logic.DefaultDraft.FindOne(ctx, me, draftId)
+			}
+		}
+
 		return render(ctx, "topics/new.html", data)
 	}
 
@@ -262,6 +295,11 @@ func (TopicController) Modify(ctx echo.Context) error {
 		if err == logic.NotModifyAuthorityErr {
 			return fail(ctx, 1, "没有权限操作")
 		}
+		if err == logic.ConflictErr {
+			if topics := logic.DefaultTopic.FindByTids([]int{tid}); len(topics) > 0 {
+				return conflict(ctx, topics[0].Version, topics[0].Title, topics[0].Content, ctx.FormValue("content"))
+			}
+		}
 
 		return fail(ctx, 2, "服务错误，请稍后重试！")
 	}
@@ -335,3 +373,82 @@ func (TopicController) SetTop(ctx echo.Context) error {
 
 	return success(ctx, nil)
 }
+
+// Lock 锁定或解除锁定主题
+func (TopicController) Lock(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	tid := goutils.MustInt(ctx.FormValue("tid"))
+
+	err := logic.DefaultTopic.Lock(ctx, me, tid, ctx.FormValue("lock") == "1")
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
+
+// Close 关闭或重新打开主题
+func (TopicController) Close(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	tid := goutils.MustInt(ctx.FormValue("tid"))
+
+	err := logic.DefaultTopic.Close(ctx, me, tid, ctx.FormValue("close") == "1", ctx.FormValue("reason"))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
+
+// Move 移动主题到其他节点
+func (TopicController) Move(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	tid := goutils.MustInt(ctx.FormValue("tid"))
+
+	err := logic.DefaultTopic.Move(ctx, me, tid, goutils.MustInt(ctx.FormValue("nid")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
+
+// Merge 将重复的主题合并到另一个主题
+func (TopicController) Merge(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	tid := goutils.MustInt(ctx.FormValue("tid"))
+	targetTid := goutils.MustInt(ctx.FormValue("target_tid"))
+
+	err := logic.DefaultTopic.Merge(ctx, me, tid, targetTid)
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, map[string]interface{}{"tid": targetTid})
+}
+
+// Delete 删除主题
+func (TopicController) Delete(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	tid := goutils.MustInt(ctx.FormValue("tid"))
+
+	err := logic.DefaultTopic.Delete(ctx, me, tid, ctx.FormValue("reason"))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
+
+// NodeTop 节点内置顶或取消置顶
+func (TopicController) NodeTop(ctx echo.Context) error {
+	me := ctx.Get("user").(*model.Me)
+	tid := goutils.MustInt(ctx.FormValue("tid"))
+
+	err := logic.DefaultTopic.NodeTop(ctx, me, tid, ctx.FormValue("top") == "1")
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
@@ -41,7 +41,7 @@ func (UserController) Home(ctx echo.Context) error {
 
 	user.Weight = logic.DefaultRank.UserDAURank(ctx, user.Uid)
 
-	topics := logic.DefaultTopic.FindRecent(5, user.Uid)
+	topics := logic.DefaultTopic.FindRecent(ctx, 5, user.Uid)
 
 	articles := logic.DefaultArticle.FindByUser(ctx, user.Username, 5)
 
@@ -54,6 +54,7 @@ func (UserController) Home(ctx echo.Context) error {
 	comments := logic.DefaultComment.FindRecent(ctx, user.Uid, -1, 5)
 
 	user.IsOnline = logic.Book.RegUserIsOnline(user.Uid)
+	user.Badges = logic.DefaultBadge.FindUserBadges(ctx, user.Uid)
 
 	return render(ctx, "user/profile.html", map[string]interface{}{
 		"activeUsers": "active",
@@ -8,6 +8,7 @@ package controller
 
 import (
 	"net/http"
+	"strings"
 
 	xhttp "sander/http"
 	"sander/http/middleware"
@@ -30,24 +31,85 @@ type WikiController struct{}
 
 // 注册路由
 func (w WikiController) RegisterRoute(g *echo.Group) {
-	g.Match([]string{"GET", "POST"}, "/wiki/new", w.Create, middleware.NeedLogin(), middleware.Sensivite(), middleware.BalanceCheck())
-	g.Match([]string{"GET", "POST"}, "/wiki/modify", w.Modify, middleware.NeedLogin(), middleware.Sensivite())
+	g.Match([]string{"GET", "POST"}, "/wiki/new", w.Create, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck(), middleware.BalanceCheck())
+	g.Match([]string{"GET", "POST"}, "/wiki/modify", w.Modify, middleware.NeedLogin(), middleware.ContentFilter(), middleware.LinkCheck())
 	g.GET("/wiki", w.ReadList)
-	g.GET("/wiki/:uri", w.Detail)
+	g.GET("/wiki/uri", w.CheckExist)
+	// wiki 地址可以有多级（如 go/spec），页面的操作放在地址后面
+	g.GET("/wiki/*", w.Page)
+	g.POST("/wiki/*", w.Operate, middleware.NeedLogin())
+}
+
+// Page 根据地址分发：{uri}、{uri}/history、{uri}/revision/{version}、{uri}/diff
+func (w WikiController) Page(ctx echo.Context) error {
+	uri, action, version := splitWikiPath(ctx.Param("_*"))
+	if uri == "" {
+		return ctx.Redirect(http.StatusSeeOther, "/wiki")
+	}
+
+	switch action {
+	case "history":
+		return w.history(ctx, uri)
+	case "revision":
+		return w.revision(ctx, uri, version)
+	case "diff":
+		return w.diff(ctx, uri)
+	case "":
+		return w.detail(ctx, uri)
+	}
+
+	return echo.ErrNotFound
+}
+
+// Operate 根据地址分发：{uri}/rollback、{uri}/state
+func (w WikiController) Operate(ctx echo.Context) error {
+	uri, action, _ := splitWikiPath(ctx.Param("_*"))
+
+	switch action {
+	case "rollback":
+		return w.rollback(ctx, uri)
+	case "state":
+		return middleware.AdminAuth()(func(ctx echo.Context) error {
+			return w.setState(ctx, uri)
+		})(ctx)
+	}
+
+	return echo.ErrNotFound
+}
+
+// splitWikiPath 从路径中拆分出 wiki 地址和操作
+func splitWikiPath(path string) (uri, action string, version int) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	n := len(segments)
+
+	switch {
+	case n > 2 && segments[n-2] == "revision":
+		version = goutils.MustInt(segments[n-1])
+		return strings.Join(segments[:n-2], "/"), "revision", version
+	case n > 1 && (segments[n-1] == "history" || segments[n-1] == "diff" ||
+		segments[n-1] == "rollback" || segments[n-1] == "state"):
+		return strings.Join(segments[:n-1], "/"), segments[n-1], 0
+	}
+
+	return strings.Join(segments, "/"), "", 0
 }
 
 // Create 创建wiki页
 func (WikiController) Create(ctx echo.Context) error {
 	title := ctx.FormValue("title")
-	// 请求新建 wiki 页面
+	// 请求新建 wiki 页面，可以通过 uri 和 title 预填（如从不存在页面的链接过来）
 	if title == "" || ctx.Request().Method() != "POST" {
-		return render(ctx, "wiki/new.html", map[string]interface{}{"activeWiki": "active"})
+		wiki := &model.Wiki{
+			Title: title,
+			Uri:   logic.NormalizeWikiUri(ctx.QueryParam("uri")),
+		}
+		return render(ctx, "wiki/new.html", map[string]interface{}{"activeWiki": "active", "wiki": wiki})
 	}
 
 	me := ctx.Get("user").(*model.Me)
 	err := logic.DefaultWiki.Create(ctx, me, ctx.FormParams())
 	if err != nil {
-		return fail(ctx, 1, "内部服务错误")
+		return fail(ctx, 1, err.Error())
 	}
 
 	return success(ctx, nil)
@@ -72,17 +134,47 @@ func (WikiController) Modify(ctx echo.Context) error {
 	me := ctx.Get("user").(*model.Me)
 	err := logic.DefaultWiki.Modify(ctx, me, ctx.FormParams())
 	if err != nil {
+		if err == logic.ConflictErr {
+			wiki := logic.DefaultWiki.FindById(ctx, id)
+			return conflict(ctx, wiki.Version, wiki.Title, wiki.Content, ctx.FormValue("content"))
+		}
 		return fail(ctx, 1, "内部服务错误")
 	}
 
 	return success(ctx, nil)
 }
 
-// Detail 展示wiki页
-func (WikiController) Detail(ctx echo.Context) error {
-	wiki := logic.DefaultWiki.FindOne(ctx, ctx.Param("uri"))
+// CheckExist 检测 uri 对应的 wiki 页是否可用(验证，true表示可用；false表示不合法或已存在)
+func (WikiController) CheckExist(ctx echo.Context) error {
+	uri := logic.NormalizeWikiUri(ctx.QueryParam("uri"))
+	if uri == "" {
+		return ctx.JSON(http.StatusOK, `true`)
+	}
+
+	if !logic.ValidWikiUri(uri) || logic.DefaultWiki.UriExists(ctx, uri) {
+		return ctx.JSON(http.StatusOK, `false`)
+	}
+	return ctx.JSON(http.StatusOK, `true`)
+}
+
+// detail 展示wiki页。页面不存在但有下级页面时，展示目录页
+func (WikiController) detail(ctx echo.Context, uri string) error {
+	wiki := logic.DefaultWiki.FindOne(ctx, uri)
 	if wiki == nil {
-		return ctx.Redirect(http.StatusSeeOther, "/wiki")
+		children := logic.DefaultWiki.FindChildren(ctx, uri)
+		if len(children) == 0 {
+			return ctx.Redirect(http.StatusSeeOther, "/wiki")
+		}
+
+		segments := strings.Split(uri, "/")
+		data := map[string]interface{}{
+			"activeWiki": "active",
+			"uri":        uri,
+			"title":      segments[len(segments)-1],
+			"crumbs":     logic.DefaultWiki.FindBreadcrumbs(ctx, uri),
+			"children":   children,
+		}
+		return render(ctx, "wiki/toc.html", data)
 	}
 
 	// likeFlag := 0
@@ -97,7 +189,107 @@ func (WikiController) Detail(ctx echo.Context) error {
 	// 为了阅读数即时看到
 	wiki.Viewnum++
 
-	return render(ctx, "wiki/content.html", map[string]interface{}{"activeWiki": "active", "wiki": wiki})
+	data := map[string]interface{}{
+		"activeWiki": "active",
+		"wiki":       wiki,
+		"content":    logic.DefaultWiki.RenderLinks(ctx, wiki.Content),
+		"crumbs":     logic.DefaultWiki.FindBreadcrumbs(ctx, wiki.Uri),
+		"children":   logic.DefaultWiki.FindChildren(ctx, wiki.Uri),
+		"backlinks":  logic.DefaultWiki.FindBacklinks(ctx, wiki.Uri),
+	}
+
+	return render(ctx, "wiki/content.html", data)
+}
+
+// history 历史版本列表
+func (WikiController) history(ctx echo.Context, uri string) error {
+	wiki := logic.DefaultWiki.FindOne(ctx, uri)
+	if wiki == nil {
+		return ctx.Redirect(http.StatusSeeOther, "/wiki")
+	}
+
+	revisions := logic.DefaultWiki.FindRevisions(ctx, wiki.Id)
+
+	return render(ctx, "wiki/history.html", map[string]interface{}{"activeWiki": "active", "wiki": wiki, "revisions": revisions})
+}
+
+// revision 查看某个历史版本
+func (WikiController) revision(ctx echo.Context, uri string, version int) error {
+	wiki := logic.DefaultWiki.FindOne(ctx, uri)
+	if wiki == nil {
+		return ctx.Redirect(http.StatusSeeOther, "/wiki")
+	}
+
+	revision := logic.DefaultWiki.FindRevision(ctx, wiki.Id, version)
+	if revision == nil {
+		return ctx.Redirect(http.StatusSeeOther, "/wiki/"+wiki.Uri+"/history")
+	}
+
+	return render(ctx, "wiki/revision.html", map[string]interface{}{"activeWiki": "active", "wiki": wiki, "revision": revision})
+}
+
+// diff 左右对照比较两个版本，默认比较最新版本和上一个版本
+func (WikiController) diff(ctx echo.Context, uri string) error {
+	wiki := logic.DefaultWiki.FindOne(ctx, uri)
+	if wiki == nil {
+		return ctx.Redirect(http.StatusSeeOther, "/wiki")
+	}
+
+	to := logic.DefaultWiki.FindRevision(ctx, wiki.Id, goutils.MustInt(ctx.QueryParam("to")))
+	if to == nil {
+		return ctx.Redirect(http.StatusSeeOther, "/wiki/"+wiki.Uri+"/history")
+	}
+
+	fromVersion := goutils.MustInt(ctx.QueryParam("from"), to.Version-1)
+	if fromVersion < 1 {
+		return ctx.Redirect(http.StatusSeeOther, "/wiki/"+wiki.Uri+"/history")
+	}
+	from := logic.DefaultWiki.FindRevision(ctx, wiki.Id, fromVersion)
+	if from == nil {
+		return ctx.Redirect(http.StatusSeeOther, "/wiki/"+wiki.Uri+"/history")
+	}
+
+	data := map[string]interface{}{
+		"activeWiki": "active",
+		"wiki":       wiki,
+		"from":       from,
+		"to":         to,
+		"rows":       logic.DefaultWiki.Diff(from, to),
+	}
+
+	return render(ctx, "wiki/diff.html", data)
+}
+
+// rollback 回滚到某个历史版本
+func (WikiController) rollback(ctx echo.Context, uri string) error {
+	wiki := logic.DefaultWiki.FindOne(ctx, uri)
+	if wiki == nil {
+		return fail(ctx, 1, "wiki 不存在")
+	}
+
+	me := ctx.Get("user").(*model.Me)
+	err := logic.DefaultWiki.Rollback(ctx, me, wiki, goutils.MustInt(ctx.FormValue("version")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
+}
+
+// setState 锁定或保护 wiki 页面
+func (WikiController) setState(ctx echo.Context, uri string) error {
+	wiki := logic.DefaultWiki.FindOne(ctx, uri)
+	if wiki == nil {
+		return fail(ctx, 1, "wiki 不存在")
+	}
+
+	me := ctx.Get("user").(*model.Me)
+	err := logic.DefaultWiki.SetState(ctx, me, wiki.Id, goutils.MustInt(ctx.FormValue("state")))
+	if err != nil {
+		return fail(ctx, 1, err.Error())
+	}
+
+	return success(ctx, nil)
 }
 
 // ReadList 获得wiki列表
@@ -8,6 +8,7 @@ package http
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/json"
 	"html/template"
 	"math/rand"
@@ -33,10 +34,16 @@ import (
 // Store .
 var Store = sessions.NewCookieStore([]byte(config.ConfigFile.MustValue("global", "cookie_secret")))
 
-// SetLoginCookie .
-func SetLoginCookie(ctx echo.Context, username string) {
+// SetLoginCookie 创建登录会话并种 cookie，会话创建失败时返回错误，此时并没有登录
+func SetLoginCookie(ctx echo.Context, uid int) error {
 	Store.Options.HttpOnly = true
 
+	// 会话保存在服务端，cookie 中只有会话标识，可以随时注销
+	sid, err := logic.DefaultSession.Create(uid, model.SessionTypeWeb, goutils.RemoteIp(Request(ctx)), ctx.Request().UserAgent())
+	if err != nil {
+		return err
+	}
+
 	session := GetCookieSession(ctx)
 	if ctx.FormValue("remember_me") != "1" {
 		// 浏览器关闭，cookie删除，否则保存30天(github.com/gorilla/sessions 包的默认值)
@@ -45,10 +52,12 @@ func SetLoginCookie(ctx echo.Context, username string) {
 			HttpOnly: true,
 		}
 	}
-	session.Values["username"] = username
+	// 旧版本直接保存的用户名，不再使用
+	delete(session.Values, "username")
+	session.Values["sid"] = sid
 	req := Request(ctx)
 	resp := ResponseWriter(ctx)
-	session.Save(req, resp)
+	return session.Save(req, resp)
 }
 
 // SetCookie .
@@ -92,6 +101,8 @@ func ResponseWriter(ctx echo.Context) http.ResponseWriter {
 var funcMap = template.FuncMap{
 	// 获取gravatar头像
 	"gravatar": util.Gravatar,
+	// 启用了的第三方登录方式
+	"oauthProviders": logic.DefaultThirdUser.Providers,
 	// 转为前端显示需要的时间格式
 	"formatTime": func(i interface{}) string {
 		ctime, ok := i.(string)
@@ -166,8 +177,12 @@ var funcMap = template.FuncMap{
 
 		return int(time.Now().Sub(t).Hours() / 24)
 	},
-	"canEdit":    logic.CanEdit,
-	"canPublish": logic.CanPublish,
+	"canEdit":      logic.CanEdit,
+	"canPublish":   logic.CanPublish,
+	"hasPrivilege": logic.HasPrivilege,
+	"reportReasons": func() map[int]string {
+		return model.ReportReasonMap
+	},
 	"parseJSON": func(str string) map[string]interface{} {
 		result := make(map[string]interface{})
 		json.Unmarshal([]byte(str), &result)
@@ -413,36 +428,39 @@ const (
 	TokenSalt = "b3%JFOykZx_golang_polaris"
 	// NeedReLoginCode .
 	NeedReLoginCode = 600
+	// ConflictCode 编辑冲突，内容已被他人修改
+	ConflictCode = 409
 )
 
-// ParseToken .
+// ParseToken 校验 GenToken 生成的凭证（签名和有效期），返回其中的 id
 func ParseToken(token string) (int, bool) {
-	if len(token) < 32 {
+	if len(token) < 45 {
 		return 0, false
 	}
 
 	pos := strings.LastIndex(token, "uid")
-	if pos == -1 {
+	if pos != 42 {
 		return 0, false
 	}
-	return goutils.MustInt(token[pos+3:]), true
-}
+	expireTime, uid := goutils.MustInt64(token[:10]), goutils.MustInt(token[pos+3:])
 
-// ValidateToken .
-func ValidateToken(token string) bool {
-	_, ok := ParseToken(token)
-	if !ok {
-		return false
+	buffer := goutils.NewBuffer().Append(expireTime).Append(uid).Append(TokenSalt)
+	if subtle.ConstantTimeCompare([]byte(goutils.Md5(buffer.String())), []byte(token[10:42])) != 1 {
+		return 0, false
 	}
-
-	expireTime := time.Unix(goutils.MustInt64(token[:10]), 0)
-	if time.Now().Before(expireTime) {
-		return true
+	if time.Now().Unix() > expireTime {
+		return 0, false
 	}
-	return false
+
+	return uid, true
+}
+
+// GenAppLoginToken App 登录成功后的 token，即服务端会话的标识
+func GenAppLoginToken(ctx echo.Context, uid int) (string, error) {
+	return logic.DefaultSession.Create(uid, model.SessionTypeApp, goutils.RemoteIp(Request(ctx)), ctx.Request().UserAgent())
 }
 
-// GenToken .
+// GenToken 签名的临时凭证，不能用于登录
 func GenToken(uid int) string {
 	expireTime := time.Now().Add(30 * 24 * time.Hour).Unix()
 
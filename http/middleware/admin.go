@@ -9,6 +9,7 @@ package middleware
 import (
 	"net/http"
 
+	"sander/logic"
 	"sander/model"
 
 	"github.com/labstack/echo"
@@ -23,6 +24,11 @@ func AdminAuth() echo.MiddlewareFunc {
 				return ctx.HTML(http.StatusForbidden, `403 Forbidden`)
 			}
 
+			// 要求管理员启用两步验证时，先去启用
+			if logic.DefaultUserTotp.NeedEnroll(user) {
+				return ctx.Redirect(http.StatusSeeOther, "/account/edit#2fa")
+			}
+
 			if err := next(ctx); err != nil {
 				return err
 			}
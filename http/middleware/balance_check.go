@@ -9,13 +9,14 @@ package middleware
 import (
 	"net/http"
 
+	"sander/logic"
 	"sander/model"
 	"sander/util"
 
 	"github.com/labstack/echo"
 )
 
-// BalanceCheck 用于 echo 框架，用户发布内容校验余额是否足够
+// BalanceCheck 用于 echo 框架，用户发布内容校验余额是否足够。需要的最低余额按声望等级配置
 func BalanceCheck() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
@@ -27,14 +28,13 @@ func BalanceCheck() echo.MiddlewareFunc {
 				title := ctx.FormValue("title")
 				content := ctx.FormValue("content")
 				if ctx.Request().Method() == "POST" && (title != "" || content != "") {
+					publishBalance, commentBalance := logic.LevelBalanceFloor(curUser.Level)
+					floor := publishBalance
 					if ctx.Path() == "/comment/:objid" {
-						if curUser.Balance < 5 {
-							return ctx.String(http.StatusOK, `{"ok":0,"error":"对不起，您的账号余额不足，可以领取初始资本！"}`)
-						}
-					} else {
-						if curUser.Balance < 20 {
-							return ctx.String(http.StatusOK, `{"ok":0,"error":"对不起，您的账号余额不足，可以领取初始资本！"}`)
-						}
+						floor = commentBalance
+					}
+					if curUser.Balance < floor {
+						return ctx.String(http.StatusOK, `{"ok":0,"error":"对不起，您的账号余额不足，可以领取初始资本！"}`)
 					}
 				}
 			}
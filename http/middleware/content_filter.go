@@ -0,0 +1,65 @@
+// Copyright 2016 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"sander/logger"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+)
+
+// ContentFilter 用于 echo 框架，按后台配置的过滤规则检查发布的标题和内容（广告、敏感词等）。
+// 命中后根据规则拒绝发布、冻结账号或把命中的内容替换为 *；需要人工审核的在发布后由 logic.ContentReviewObserver 处理
+func ContentFilter() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if ctx.Request().Method() != "POST" {
+				return next(ctx)
+			}
+
+			title := ctx.FormValue("title")
+			content := ctx.FormValue("content")
+			if title == "" && content == "" {
+				return next(ctx)
+			}
+
+			user := ctx.Get("user").(*model.Me)
+
+			result := logic.DefaultContentFilter.Filter(ctx, user.Uid, ctx.Request().URI(), title, content)
+			switch result.Action {
+			case model.FilterActionFreeze:
+				// 把账号冻结
+				logic.DefaultUser.UpdateUserStatus(ctx, user.Uid, model.UserStatusFreeze)
+				logger.Info("user=", user.Uid, "publish ad, matched=", result.Matched, ". freeze")
+				return ctx.String(http.StatusOK, `{"ok":0,"error":"对不起，您的账号已被冻结！"}`)
+			case model.FilterActionReject:
+				return ctx.JSON(http.StatusOK, map[string]interface{}{
+					"ok":    0,
+					"error": "对不起，内容中包含不允许发布的内容：" + strings.Join(result.Matched, "、"),
+				})
+			}
+
+			if result.Title != title || result.Content != content {
+				// FormParams 返回的就是请求的 Form，修改后 handler 取到的是替换后的内容
+				form := ctx.FormParams()
+				if result.Title != title {
+					form["title"] = []string{result.Title}
+				}
+				if result.Content != content {
+					form["content"] = []string{result.Content}
+				}
+			}
+
+			return next(ctx)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/gorilla/context"
 	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
 )
 
 // AutoLogin 用于 echo 框架的自动登录和通过 cookie 获取用户信息
@@ -46,14 +47,16 @@ func AutoLogin() echo.MiddlewareFunc {
 				}
 			}
 
+			// 网页登录的会话标识在 cookie 中，App（手机）登录的是 token
 			session := xhttp.GetCookieSession(ctx)
-			username, ok := session.Values["username"]
-			if ok {
-				getCurrentUser(username)
-			} else {
-				// App（手机） 登录
-				uid, ok := xhttp.ParseToken(ctx.FormValue("token"))
-				if ok {
+			sid, _ := session.Values["sid"].(string)
+			if sid == "" {
+				sid = ctx.FormValue("token")
+			}
+			if sid != "" && db.MasterDB != nil {
+				uid := logic.DefaultSession.Find(sid, goutils.RemoteIp(xhttp.Request(ctx)))
+				if uid > 0 {
+					ctx.Set("sid", sid)
 					getCurrentUser(uid)
 				}
 			}
@@ -112,13 +115,9 @@ func NeedLogin() echo.MiddlewareFunc {
 func AppNeedLogin() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
+			// token 无效、过期或已注销时，AutoLogin 不会设置 user
 			user, ok := ctx.Get("user").(*model.Me)
 			if ok {
-				// 校验 token 是否有效
-				if !xhttp.ValidateToken(ctx.QueryParam("token")) {
-					return outputAppJSON(ctx, xhttp.NeedReLoginCode, "token无效，请重新登录！")
-				}
-
 				if user.Status != model.UserStatusAudit {
 					return outputAppJSON(ctx, 1, "账号未审核通过、被冻结或被停号，请联系我们")
 				}
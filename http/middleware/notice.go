@@ -24,7 +24,7 @@ func PublishNotice() echo.MiddlewareFunc {
 			}
 
 			curUser := ctx.Get("user").(*model.Me)
-			if curUser.IsRoot {
+			if logic.HasPrivilege(curUser, model.PrivSkipAudit) {
 				return nil
 			}
 
@@ -0,0 +1,64 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+)
+
+var linkReg = regexp.MustCompile(`(?i)(?:https?://|www\.)([^\s/"'<>\)\]]+)`)
+
+// LinkCheck 用于 echo 框架，未解锁“发布外链”特权的用户不能在内容中带站外链接
+func LinkCheck() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if ctx.Request().Method() == "POST" {
+				curUser := ctx.Get("user").(*model.Me)
+				if !logic.HasPrivilege(curUser, model.PrivPostLink) &&
+					(hasOuterLink(ctx.FormValue("title")) || hasOuterLink(ctx.FormValue("content"))) {
+					return ctx.String(http.StatusOK, `{"ok":0,"error":"对不起，您的等级还不能发布站外链接，<a href=\"/level\">了解如何升级</a>"}`)
+				}
+			}
+
+			if err := next(ctx); err != nil {
+				return err
+			}
+
+			return nil
+		}
+	}
+}
+
+// hasOuterLink 是否包含站外链接
+func hasOuterLink(content string) bool {
+	if content == "" {
+		return false
+	}
+
+	for _, matches := range linkReg.FindAllStringSubmatch(content, -1) {
+		host := strings.ToLower(matches[1])
+		if strings.HasPrefix(host, "www.") {
+			host = host[4:]
+		}
+		domain := strings.ToLower(logic.WebsiteSetting.Domain)
+		if strings.HasPrefix(domain, "www.") {
+			domain = domain[4:]
+		}
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	xhttp "sander/http"
+	"sander/logic"
+	"sander/model"
+
+	"github.com/labstack/echo"
+	"github.com/polaris1119/goutils"
+)
+
+// RateLimit 用于 echo 框架，按 env.ini [rate_limit] 中 policy 对应的策略限制写操作（POST）的频率。
+// app 接口被限制时返回 429 和 Retry-After
+func RateLimit(policy string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if ctx.Request().Method() != "POST" {
+				return next(ctx)
+			}
+
+			me, _ := ctx.Get("user").(*model.Me)
+			wait := logic.DefaultRateLimit.Limit(policy, me, goutils.RemoteIp(xhttp.Request(ctx)))
+			if wait > 0 {
+				return RateLimited(ctx, wait)
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// RateLimited 输出被限制频率的响应
+func RateLimited(ctx echo.Context, wait time.Duration) error {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	msg := "操作太频繁，请 " + RetryAfterText(wait) + "后再试"
+
+	if strings.HasPrefix(ctx.Path(), "/app/") {
+		xhttp.AccessControl(ctx)
+		ctx.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+		return ctx.JSON(http.StatusTooManyRequests, map[string]interface{}{
+			"code": http.StatusTooManyRequests,
+			"msg":  msg,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"ok":    0,
+		"error": msg,
+	})
+}
+
+// RetryAfterText 等待时间的友好显示，如“30 秒”、“5 分钟”
+func RetryAfterText(wait time.Duration) string {
+	if wait < time.Minute {
+		return strconv.Itoa(int((wait+time.Second-1)/time.Second)) + " 秒"
+	}
+	return strconv.Itoa(int((wait+time.Minute-1)/time.Minute)) + " 分钟"
+}
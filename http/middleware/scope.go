@@ -0,0 +1,113 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	xhttp "sander/http"
+	"sander/logic"
+	thirdmw "sander/middleware"
+	"sander/model"
+
+	"github.com/labstack/echo"
+)
+
+// appKeyAuthConfig 应用密钥的签名校验，密钥由管理员在后台发放
+var appKeyAuthConfig = &thirdmw.AuthConfig{
+	SecretKey: func(from string) string {
+		if appKey := logic.DefaultAppKey.FindEnabled(from); appKey != nil {
+			return appKey.Secret
+		}
+		return ""
+	},
+	CheckNonce: logic.DefaultAppKey.CheckNonce,
+}
+
+// AppKeyAuth 用于 echo 框架，服务端调用 app 接口时用应用密钥签名（带 sign 参数），
+// 校验通过后 ctx 中的 app_key 为对应的 *model.AppKey。没有 sign 的请求不处理
+func AppKeyAuth() echo.MiddlewareFunc {
+	signCheck := thirdmw.EchoAuthWithConfig(appKeyAuthConfig)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withAppKey := signCheck(func(ctx echo.Context) error {
+			from, _ := ctx.Get("auth_from").(string)
+			appKey := logic.DefaultAppKey.FindEnabled(from)
+			if appKey == nil {
+				return outputAppJSON(ctx, 1, "应用密钥无效或已停用")
+			}
+			ctx.Set("app_key", appKey)
+
+			return next(ctx)
+		})
+
+		return func(ctx echo.Context) error {
+			if ctx.FormValue("sign") == "" {
+				return next(ctx)
+			}
+			return withAppKey(ctx)
+		}
+	}
+}
+
+// NeedScope 用于 echo 框架，允许个人访问令牌或应用密钥以用户身份调用该接口，需要有 scope 权限。
+// 要放在 NeedLogin 之前；没有加这个中间件的接口，令牌和应用密钥都不能以用户身份调用
+func NeedScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			// 网页或 App 登录的，不受限制
+			if _, ok := ctx.Get("user").(*model.Me); ok {
+				return next(ctx)
+			}
+
+			uid := 0
+			if appKey, ok := ctx.Get("app_key").(*model.AppKey); ok {
+				if !appKey.HasScope(scope) {
+					return scopeForbidden(ctx, scope)
+				}
+				uid = appKey.Uid
+			} else if token := accessTokenFromRequest(ctx); token != "" {
+				accessToken := logic.DefaultAccessToken.Find(ctx, token)
+				if accessToken == nil {
+					return outputAppJSON(ctx, xhttp.NeedReLoginCode, "访问令牌无效或已过期")
+				}
+				if !accessToken.HasScope(scope) {
+					return scopeForbidden(ctx, scope)
+				}
+				uid = accessToken.Uid
+			}
+
+			if uid > 0 {
+				// 账号被冻结、停号的查不到
+				user := logic.DefaultUser.FindCurrentUser(ctx, uid)
+				if user.Uid != 0 {
+					ctx.Set("user", user)
+				}
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// accessTokenFromRequest 个人访问令牌放在 Authorization: Bearer 头中，或 access_token 参数中
+func accessTokenFromRequest(ctx echo.Context) string {
+	auth := ctx.Request().Header().Get(echo.HeaderAuthorization)
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return ctx.FormValue("access_token")
+}
+
+func scopeForbidden(ctx echo.Context, scope string) error {
+	xhttp.AccessControl(ctx)
+	return ctx.JSON(http.StatusForbidden, map[string]interface{}{
+		"code": http.StatusForbidden,
+		"msg":  "没有“" + model.ScopeMap[scope] + "”的权限",
+	})
+}
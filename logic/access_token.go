@@ -0,0 +1,163 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// 每个用户最多多少个令牌
+	accessTokenMaxNum = 20
+	// 最后使用时间最多多久更新一次
+	accessTokenTouchInterval = 60
+)
+
+// AccessTokenExpireDays 令牌有效期可选的天数，0 表示永不过期
+var AccessTokenExpireDays = []int{7, 30, 90, 365, 0}
+
+type AccessTokenLogic struct{}
+
+var DefaultAccessToken = AccessTokenLogic{}
+
+// Create 生成个人访问令牌，返回令牌明文，只在生成时显示这一次
+func (AccessTokenLogic) Create(ctx context.Context, uid int, name string, scopes []string, days int) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 50 {
+		return "", errors.New("名称不能为空，且不能超过50个字")
+	}
+
+	accessToken := &model.AccessToken{
+		Uid:    uid,
+		Name:   name,
+		Scopes: cleanScopes(scopes),
+	}
+	if accessToken.Scopes == "" {
+		return "", errors.New("请至少选择一项权限")
+	}
+
+	validDays := false
+	for _, d := range AccessTokenExpireDays {
+		if d == days {
+			validDays = true
+			break
+		}
+	}
+	if !validDays {
+		return "", errors.New("有效期不正确")
+	}
+	if days > 0 {
+		accessToken.Expire = int(time.Now().AddDate(0, 0, days).Unix())
+	}
+
+	total, err := db.MasterDB.Where("uid=?", uid).Count(new(model.AccessToken))
+	if err != nil {
+		logger.Error("AccessTokenLogic Create count error:", err)
+		return "", errors.New("服务内部错误")
+	}
+	if total >= accessTokenMaxNum {
+		return "", errors.New("令牌太多了，请先删除不用的")
+	}
+
+	buf := make([]byte, 20)
+	if _, err = rand.Read(buf); err != nil {
+		logger.Error("AccessTokenLogic Create rand error:", err)
+		return "", errors.New("服务内部错误")
+	}
+	token := model.AccessTokenPrefix + hex.EncodeToString(buf)
+
+	accessToken.TokenHash = hashAccessToken(token)
+	accessToken.Hint = token[:len(model.AccessTokenPrefix)+4]
+	if _, err = db.MasterDB.Insert(accessToken); err != nil {
+		logger.Error("AccessTokenLogic Create insert error:", err)
+		return "", errors.New("服务内部错误")
+	}
+
+	return token, nil
+}
+
+// FindByUser 用户所有的令牌，包括已过期的
+func (AccessTokenLogic) FindByUser(ctx context.Context, uid int) []*model.AccessToken {
+	accessTokens := make([]*model.AccessToken, 0)
+	err := db.MasterDB.Where("uid=?", uid).Desc("id").Find(&accessTokens)
+	if err != nil {
+		logger.Error("AccessTokenLogic FindByUser error:", err)
+	}
+	return accessTokens
+}
+
+// Delete 用户删除（吊销）自己的令牌
+func (AccessTokenLogic) Delete(ctx context.Context, uid, id int) error {
+	affected, err := db.MasterDB.Where("id=? AND uid=?", id, uid).Delete(new(model.AccessToken))
+	if err != nil {
+		logger.Error("AccessTokenLogic Delete error:", err)
+		return errors.New("服务内部错误")
+	}
+	if affected == 0 {
+		return errors.New("令牌不存在")
+	}
+	return nil
+}
+
+// Find 令牌明文对应的有效令牌，不存在或已过期时返回 nil。同时记录最后使用时间
+func (AccessTokenLogic) Find(ctx context.Context, token string) *model.AccessToken {
+	if !strings.HasPrefix(token, model.AccessTokenPrefix) {
+		return nil
+	}
+
+	accessToken := &model.AccessToken{}
+	_, err := db.MasterDB.Where("token_hash=?", hashAccessToken(token)).Get(accessToken)
+	if err != nil {
+		logger.Error("AccessTokenLogic Find error:", err)
+		return nil
+	}
+	if accessToken.Id == 0 || accessToken.Expired() {
+		return nil
+	}
+
+	now := int(time.Now().Unix())
+	if now-accessToken.LastUsed >= accessTokenTouchInterval {
+		accessToken.LastUsed = now
+		_, err = db.MasterDB.Id(accessToken.Id).Cols("last_used").Update(accessToken)
+		if err != nil {
+			logger.Error("AccessTokenLogic Find update last_used error:", err)
+		}
+	}
+
+	return accessToken
+}
+
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// cleanScopes 去掉不认识和重复的权限，按 model.Scopes 的顺序用逗号连接
+func cleanScopes(scopes []string) string {
+	cleaned := make([]string, 0, len(model.Scopes))
+	for _, scope := range model.Scopes {
+		for _, s := range scopes {
+			if s == scope {
+				cleaned = append(cleaned, scope)
+				break
+			}
+		}
+	}
+	return strings.Join(cleaned, ",")
+}
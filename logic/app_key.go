@@ -0,0 +1,201 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"sander/db"
+	"sander/db/nosql"
+	"sander/logger"
+	"sander/model"
+
+	"golang.org/x/net/context"
+)
+
+type AppKeyLogic struct{}
+
+var DefaultAppKey = AppKeyLogic{}
+
+// FindByPage 应用密钥列表（分页）：后台用
+func (AppKeyLogic) FindByPage(ctx context.Context, conds map[string]string, curPage, limit int) ([]*model.AppKey, int) {
+	session := db.MasterDB.NewSession()
+
+	for k, v := range conds {
+		session.And(k+"=?", v)
+	}
+
+	totalSession := session.Clone()
+
+	offset := (curPage - 1) * limit
+	appKeys := make([]*model.AppKey, 0)
+	err := session.Desc("id").Limit(limit, offset).Find(&appKeys)
+	if err != nil {
+		logger.Error("AppKeyLogic FindByPage error:", err)
+		return nil, 0
+	}
+
+	total, err := totalSession.Count(new(model.AppKey))
+	if err != nil {
+		logger.Error("AppKeyLogic FindByPage count error:", err)
+		return nil, 0
+	}
+
+	return appKeys, int(total)
+}
+
+// FindById 获取一个应用密钥
+func (AppKeyLogic) FindById(ctx context.Context, id int) *model.AppKey {
+	appKey := &model.AppKey{}
+	_, err := db.MasterDB.Id(id).Get(appKey)
+	if err != nil {
+		logger.Error("AppKeyLogic FindById error:", err)
+		return nil
+	}
+
+	if appKey.Id == 0 {
+		return nil
+	}
+
+	return appKey
+}
+
+// FindEnabled 签名校验时根据 from 找到启用的应用密钥，不存在或已停用时返回 nil
+func (AppKeyLogic) FindEnabled(from string) *model.AppKey {
+	if from == "" {
+		return nil
+	}
+
+	appKey := &model.AppKey{}
+	_, err := db.MasterDB.Where("app_key=? AND enabled=1", from).Get(appKey)
+	if err != nil {
+		logger.Error("AppKeyLogic FindEnabled error:", err)
+		return nil
+	}
+
+	if appKey.Id == 0 {
+		return nil
+	}
+
+	now := int(time.Now().Unix())
+	if now-appKey.LastUsed >= accessTokenTouchInterval {
+		appKey.LastUsed = now
+		_, err = db.MasterDB.Id(appKey.Id).Cols("last_used").Update(appKey)
+		if err != nil {
+			logger.Error("AppKeyLogic FindEnabled update last_used error:", err)
+		}
+	}
+
+	return appKey
+}
+
+// CheckNonce 签名请求的 nonce 在 expire 内只能用一次，防止重放。返回 false 表示已经用过
+func (AppKeyLogic) CheckNonce(from, nonce string, expire time.Duration) bool {
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	key := "app_key:nonce:" + from + ":" + nonce
+	num, err := redisClient.INCR(key)
+	if err != nil {
+		logger.Error("AppKeyLogic CheckNonce error:", err)
+		return false
+	}
+	if num == 1 {
+		redisClient.EXPIRE(key, int(expire/time.Second))
+	}
+
+	return num == 1
+}
+
+// Save 新建或修改应用密钥。新建时生成 app_key 和 secret
+func (AppKeyLogic) Save(ctx context.Context, form url.Values, opUser string) (errMsg string, err error) {
+	appKey := &model.AppKey{}
+	err = schemaDecoder.Decode(appKey, form)
+	if err != nil {
+		logger.Error("AppKeyLogic Save decode error:", err)
+		errMsg = err.Error()
+		return
+	}
+
+	appKey.Name = strings.TrimSpace(appKey.Name)
+	if appKey.Name == "" {
+		errMsg = "名称不能为空"
+		err = errors.New(errMsg)
+		return
+	}
+
+	appKey.Scopes = cleanScopes(form["scopes"])
+
+	appKey.Uid = 0
+	if username := strings.TrimSpace(form.Get("username")); username != "" {
+		user := DefaultUser.FindOne(ctx, "username", username)
+		if user.Uid == 0 {
+			errMsg = "用户 " + username + " 不存在"
+			err = errors.New(errMsg)
+			return
+		}
+		appKey.Uid = user.Uid
+	}
+
+	appKey.OpUser = opUser
+	if appKey.Id != 0 {
+		_, err = db.MasterDB.Id(appKey.Id).Cols("name", "uid", "scopes", "enabled", "remark", "op_user").Update(appKey)
+	} else {
+		appKey.AppKey, appKey.Secret, err = genAppKeyAndSecret()
+		if err == nil {
+			_, err = db.MasterDB.Insert(appKey)
+		}
+	}
+
+	if err != nil {
+		errMsg = "内部服务器错误"
+		logger.Error("AppKeyLogic Save error:", err)
+		return
+	}
+
+	return
+}
+
+// ResetSecret 重新生成密钥，旧的密钥立即失效
+func (AppKeyLogic) ResetSecret(ctx context.Context, id int, opUser string) error {
+	_, secret, err := genAppKeyAndSecret()
+	if err != nil {
+		logger.Error("AppKeyLogic ResetSecret gen error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	appKey := &model.AppKey{Secret: secret, OpUser: opUser}
+	_, err = db.MasterDB.Id(id).Cols("secret", "op_user").Update(appKey)
+	if err != nil {
+		logger.Error("AppKeyLogic ResetSecret error:", err)
+		return errors.New("服务内部错误")
+	}
+	return nil
+}
+
+// Delete 删除应用密钥
+func (AppKeyLogic) Delete(ctx context.Context, id int) error {
+	_, err := db.MasterDB.Id(id).Delete(new(model.AppKey))
+	if err != nil {
+		logger.Error("AppKeyLogic Delete error:", err)
+		return errors.New("服务内部错误")
+	}
+	return nil
+}
+
+func genAppKeyAndSecret() (string, string, error) {
+	buf := make([]byte, 40)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	return "sg" + hex.EncodeToString(buf[:8]), hex.EncodeToString(buf[8:]), nil
+}
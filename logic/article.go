@@ -309,6 +309,28 @@ func (self ArticleLogic) Publish(ctx context.Context, me *model.Me, form url.Val
 		article.Txt = article.Content
 	}
 
+	// 定时发布
+	if publishAt := form.Get("publish_at"); publishAt != "" {
+		article.PublishAt = parseMissionTime(publishAt)
+		if article.PublishAt.IsZero() {
+			article.PublishAt, _ = time.ParseInLocation("2006-01-02 15:04", publishAt, time.Local)
+		}
+		if article.PublishAt.IsZero() {
+			return 0, errors.New("定时发布时间格式不正确")
+		}
+		if article.PublishAt.Before(time.Now()) {
+			return 0, errors.New("定时发布时间必须晚于现在")
+		}
+		article.Status = model.ArticleStatusScheduled
+		article.PubDate = article.PublishAt.Format("2006-01-02 15:04:05")
+	}
+
+	// 影子封禁的用户发布的文章只有自己能看到
+	sanction := DefaultUserSanction.Find(me.Uid)
+	if sanction == model.UserSanctionShadowBan {
+		article.Status = model.ArticleStatusShadow
+	}
+
 	requestIdInter := ctx.Value("request_id")
 	if requestIdInter != nil {
 		if requestId, ok := requestIdInter.(string); ok {
@@ -378,11 +400,60 @@ func (self ArticleLogic) Publish(ctx context.Context, me *model.Me, form url.Val
 
 	session.Commit()
 
-	go publishObservable.NotifyObservers(uid, model.TypeArticle, article.Id)
+	// 发布成功，删除草稿
+	DefaultDraft.Delete(ctx, me.Uid, goutils.MustInt(form.Get("draft_id")))
+
+	// 观察期用户的文章审核通过前不展示（定时发布的审核通过后恢复为定时发布）
+	if sanction == model.UserSanctionProbation {
+		DefaultUserSanction.Hold(ctx, sanction, model.TypeArticle, article.Id)
+	}
+
+	// 定时发布的文章，到发布时间后再通知；影子封禁的文章不通知
+	if article.Status != model.ArticleStatusScheduled && article.Status != model.ArticleStatusShadow {
+		go publishObservable.NotifyObservers(uid, model.TypeArticle, article.Id)
+	}
 
 	return article.Id, nil
 }
 
+// PublishScheduled 发布到了发布时间的定时文章，并通知发布的观察者
+func (ArticleLogic) PublishScheduled() {
+	articles := make([]*model.Article, 0)
+	err := db.MasterDB.Where("status=? AND publish_at<=?", model.ArticleStatusScheduled, time.Now()).Find(&articles)
+	if err != nil {
+		logger.Error("ArticleLogic PublishScheduled find error:%+v", err)
+		return
+	}
+
+	for _, article := range articles {
+		change := map[string]interface{}{
+			"status": model.ArticleStatusNew,
+			"ctime":  article.PublishAt,
+		}
+		affected, err := db.MasterDB.Table(new(model.Article)).
+			Where("id=? AND status=?", article.Id, model.ArticleStatusScheduled).Update(change)
+		if err != nil {
+			logger.Error("ArticleLogic PublishScheduled update error:%+v", err)
+			continue
+		}
+		if affected == 0 {
+			continue
+		}
+
+		article.Status = model.ArticleStatusNew
+		article.Ctime = model.OftenTime(article.PublishAt)
+		article.Mtime = article.Ctime
+		model.PublishFeed(article, nil)
+
+		uid := 0
+		user := DefaultUser.FindOne(context.Background(), "username", article.AuthorTxt)
+		if user != nil {
+			uid = user.Uid
+		}
+		go publishObservable.NotifyObservers(uid, model.TypeArticle, article.Id)
+	}
+}
+
 func (self ArticleLogic) PublishFromAdmin(ctx context.Context, me *model.Me, form url.Values) error {
 	articleUrl := form.Get("url")
 	netUrl, err := url.Parse(articleUrl)
@@ -467,7 +538,7 @@ func (ArticleLogic) convertByExt(extMap map[string]string, article *model.Articl
 
 func (ArticleLogic) FindLastList(beginTime string, limit int) ([]*model.Article, error) {
 	articles := make([]*model.Article, 0)
-	err := db.MasterDB.Where("ctime>? AND status!=?", beginTime, model.ArticleStatusOffline).
+	err := db.MasterDB.Where("ctime>? AND status IN(?,?)", beginTime, model.ArticleStatusNew, model.ArticleStatusOnline).
 		OrderBy("cmtnum DESC, likenum DESC, viewnum DESC").Limit(limit).Find(&articles)
 
 	return articles, err
@@ -544,8 +615,19 @@ func (self ArticleLogic) FindTaGCTTArticles(ctx context.Context, translator stri
 }
 
 func (self ArticleLogic) FindByUser(ctx context.Context, username string, limit int) []*model.Article {
+	// 影子封禁的文章只有作者自己能看到
+	showShadow := false
+	if me := currentMe(ctx); me != nil && me.Username == username {
+		showShadow = true
+	}
+
+	dbSession := db.MasterDB.Where("author_txt=? AND status!=?", username, model.ArticleStatusScheduled)
+	if !showShadow {
+		dbSession.And("status!=?", model.ArticleStatusShadow)
+	}
+
 	articles := make([]*model.Article, 0)
-	err := db.MasterDB.Where("author_txt=?", username).OrderBy("id DESC").Limit(limit).Find(&articles)
+	err := dbSession.OrderBy("id DESC").Limit(limit).Find(&articles)
 	if err != nil {
 		logger.Error("ArticleLogic FindByUser Error:%+v", err)
 		return nil
@@ -908,6 +990,11 @@ func (ArticleLogic) FindByIdAndPreNext(ctx context.Context, id int) (curArticle
 	prevNext = make([]*model.Article, 2)
 	prevId, nextId := articles[0].Id, articles[len(articles)-1].Id
 	for _, article := range articles {
+		// 定时发布和影子封禁的文章只有作者能看到，不作为上一篇、下一篇
+		if article.Id != id && (article.Status == model.ArticleStatusScheduled || article.Status == model.ArticleStatusShadow) {
+			continue
+		}
+
 		if article.Id < id && article.Id > prevId {
 			prevId = article.Id
 			prevNext[0] = article
@@ -991,13 +1078,44 @@ func (ArticleLogic) Modify(ctx context.Context, user *model.Me, form url.Values)
 		}
 	}
 
-	_, err = db.MasterDB.Table(new(model.Article)).Id(id).Update(change)
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	_, err = updateWithVersion(session.Table(new(model.Article)).Id(id), form.Get("version"), change)
 	if err != nil {
+		session.Rollback()
+		if err == ConflictErr {
+			errMsg = err.Error()
+			return
+		}
 		logger.Error("更新文章 【%s】 信息失败：%s\n", id, err)
 		errMsg = "对不起，服务器内部错误，请稍后再试！"
 		return
 	}
 
+	origin := &model.ContentRevision{
+		Uid:       DefaultArticle.getOwner(article.Id),
+		Title:     article.Title,
+		Content:   article.Content,
+		CreatedAt: time.Time(article.Ctime),
+	}
+	title, content := article.Title, article.Content
+	if val, ok := change["title"]; ok {
+		title = val
+	}
+	if val, ok := change["content"]; ok {
+		content = val
+	}
+	err = DefaultContentRevision.record(session, model.TypeArticle, article.Id, origin, user.Uid, title, content)
+	if err != nil {
+		session.Rollback()
+		errMsg = "对不起，服务器内部错误，请稍后再试！"
+		return
+	}
+
+	session.Commit()
+
 	go modifyObservable.NotifyObservers(user.Uid, model.TypeArticle, goutils.MustInt(id))
 
 	return
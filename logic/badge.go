@@ -0,0 +1,379 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author:polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+
+	"github.com/polaris1119/goutils"
+	"golang.org/x/net/context"
+)
+
+type BadgeLogic struct{}
+
+var DefaultBadge = BadgeLogic{}
+
+// badgeRuleSql 可批量计算的规则：查询达到阈值的用户 uid
+var badgeRuleSql = map[int]string{
+	model.BadgeRuleWeight:     "SELECT uid FROM user_active WHERE weight>=?",
+	model.BadgeRuleGCTTNum:    "SELECT uid FROM gctt_user WHERE uid>0 AND num>=?",
+	model.BadgeRuleMemberDays: "SELECT uid FROM user_info WHERE ctime<=?",
+}
+
+// Evaluate 按规则检查某个用户是否获得了新徽章，rules 为空表示检查所有规则
+func (self BadgeLogic) Evaluate(uid int, rules ...int) {
+	if uid == 0 {
+		return
+	}
+
+	badges := self.findOnlineBadges(rules...)
+	if len(badges) == 0 {
+		return
+	}
+
+	owned := self.findOwnedBadgeIds(uid)
+	var user *model.User
+	for _, badge := range badges {
+		if _, ok := owned[badge.Id]; ok {
+			continue
+		}
+
+		if user == nil {
+			user = DefaultUser.FindOne(nil, "uid", uid)
+			if user.Uid == 0 {
+				return
+			}
+		}
+
+		if self.reached(user, badge) {
+			self.grant(badge, uid, 0)
+		}
+	}
+}
+
+// AwardAll 对所有用户按规则批量发放徽章（每天执行一次）
+func (self BadgeLogic) AwardAll() {
+	for _, badge := range self.findOnlineBadges() {
+		strSql, ok := badgeRuleSql[badge.Rule]
+		if !ok {
+			continue
+		}
+
+		var arg interface{} = badge.Threshold
+		if badge.Rule == model.BadgeRuleMemberDays {
+			arg = time.Now().AddDate(0, 0, -badge.Threshold).Format("2006-01-02 15:04:05")
+		}
+
+		results, err := db.MasterDB.Query(strSql+" AND uid NOT IN (SELECT uid FROM user_badge WHERE badge_id=?)", arg, badge.Id)
+		if err != nil {
+			logger.Error("BadgeLogic AwardAll badge(%d) error:%+v", badge.Id, err)
+			continue
+		}
+
+		for _, result := range results {
+			self.grant(badge, goutils.MustInt(string(result["uid"])), 0)
+		}
+	}
+}
+
+// Grant 管理员手动授予徽章
+func (self BadgeLogic) Grant(ctx context.Context, badgeId, uid int, me *model.Me) error {
+	badge := self.FindById(ctx, badgeId)
+	if badge == nil {
+		return NotFoundErr
+	}
+
+	userBadge := &model.UserBadge{}
+	_, err := db.MasterDB.Where("uid=? AND badge_id=?", uid, badgeId).Get(userBadge)
+	if err != nil {
+		logger.Error("BadgeLogic Grant error:", err)
+		return err
+	}
+
+	if userBadge.Id == 0 {
+		return self.grant(badge, uid, me.Uid)
+	}
+
+	if userBadge.State == model.UserBadgeStateGranted {
+		return errors.New("该用户已拥有此徽章")
+	}
+
+	_, err = db.MasterDB.Table(new(model.UserBadge)).Id(userBadge.Id).Update(map[string]interface{}{
+		"state":  model.UserBadgeStateGranted,
+		"op_uid": me.Uid,
+	})
+	if err != nil {
+		logger.Error("BadgeLogic Grant update error:", err)
+	}
+	return err
+}
+
+// Revoke 管理员收回徽章（保留记录，避免被规则再次自动授予）
+func (BadgeLogic) Revoke(ctx context.Context, badgeId, uid int, me *model.Me) error {
+	_, err := db.MasterDB.Table(new(model.UserBadge)).Where("uid=? AND badge_id=?", uid, badgeId).
+		Update(map[string]interface{}{
+			"state":  model.UserBadgeStateRevoked,
+			"op_uid": me.Uid,
+		})
+	if err != nil {
+		logger.Error("BadgeLogic Revoke error:", err)
+	}
+	return err
+}
+
+// FindUserBadges 获取用户拥有的徽章
+func (self BadgeLogic) FindUserBadges(ctx context.Context, uid int) []*model.Badge {
+	return self.FindUsersBadges(ctx, []int{uid})[uid]
+}
+
+// FindUsersBadges 批量获取用户拥有的徽章
+func (BadgeLogic) FindUsersBadges(ctx context.Context, uids []int) map[int][]*model.Badge {
+	usersBadges := make(map[int][]*model.Badge)
+	if len(uids) == 0 {
+		return usersBadges
+	}
+
+	userBadgeInfos := make([]*model.UserBadgeInfo, 0)
+	err := db.MasterDB.Join("INNER", "badge", "user_badge.badge_id=badge.id").
+		In("user_badge.uid", uids).And("user_badge.state=? AND badge.state=?", model.UserBadgeStateGranted, model.BadgeStateOnline).
+		OrderBy("badge.seq DESC, user_badge.id ASC").Find(&userBadgeInfos)
+	if err != nil {
+		logger.Error("BadgeLogic FindUsersBadges error:", err)
+		return usersBadges
+	}
+
+	for _, userBadgeInfo := range userBadgeInfos {
+		badge := userBadgeInfo.Badge
+		uid := userBadgeInfo.UserBadge.Uid
+		usersBadges[uid] = append(usersBadges[uid], &badge)
+	}
+
+	return usersBadges
+}
+
+// FillUsersBadges 为用户填充徽章信息
+func (self BadgeLogic) FillUsersBadges(ctx context.Context, users map[int]*model.User) {
+	uids := make([]int, 0, len(users))
+	for uid := range users {
+		uids = append(uids, uid)
+	}
+
+	usersBadges := self.FindUsersBadges(ctx, uids)
+	for uid, user := range users {
+		user.Badges = usersBadges[uid]
+	}
+}
+
+// FindHolders 获取某个徽章的拥有者记录：后台用
+func (BadgeLogic) FindHolders(ctx context.Context, badgeId int) []map[string]interface{} {
+	userBadges := make([]*model.UserBadge, 0)
+	err := db.MasterDB.Where("badge_id=?", badgeId).OrderBy("id DESC").Limit(200).Find(&userBadges)
+	if err != nil {
+		logger.Error("BadgeLogic FindHolders error:", err)
+		return nil
+	}
+
+	uids := make([]int, 0, len(userBadges)*2)
+	for _, userBadge := range userBadges {
+		uids = append(uids, userBadge.Uid)
+		if userBadge.OpUid != 0 {
+			uids = append(uids, userBadge.OpUid)
+		}
+	}
+	usersMap := DefaultUser.FindUserInfos(ctx, uids)
+
+	holders := make([]map[string]interface{}, len(userBadges))
+	for i, userBadge := range userBadges {
+		holders[i] = map[string]interface{}{
+			"user_badge": userBadge,
+			"user":       usersMap[userBadge.Uid],
+			"op_user":    usersMap[userBadge.OpUid],
+		}
+	}
+
+	return holders
+}
+
+// FindBadgesByPage 获取徽章列表（分页）：后台用
+func (BadgeLogic) FindBadgesByPage(ctx context.Context, conds map[string]string, curPage, limit int) ([]*model.Badge, int) {
+	session := db.MasterDB.NewSession()
+
+	for k, v := range conds {
+		session.And(k+"=?", v)
+	}
+
+	totalSession := session.Clone()
+
+	offset := (curPage - 1) * limit
+	badgeList := make([]*model.Badge, 0)
+	err := session.OrderBy("seq DESC, id DESC").Limit(limit, offset).Find(&badgeList)
+	if err != nil {
+		logger.Error("BadgeLogic FindBadgesByPage error:", err)
+		return nil, 0
+	}
+
+	total, err := totalSession.Count(new(model.Badge))
+	if err != nil {
+		logger.Error("BadgeLogic FindBadgesByPage count error:", err)
+		return nil, 0
+	}
+
+	return badgeList, int(total)
+}
+
+// FindById 获取单个徽章
+func (BadgeLogic) FindById(ctx context.Context, id int) *model.Badge {
+	badge := &model.Badge{}
+	_, err := db.MasterDB.Id(id).Get(badge)
+	if err != nil {
+		logger.Error("BadgeLogic FindById error:", err)
+		return nil
+	}
+
+	if badge.Id == 0 {
+		return nil
+	}
+
+	return badge
+}
+
+// Save 新建或修改徽章：后台用
+func (BadgeLogic) Save(ctx context.Context, form url.Values) (errMsg string, err error) {
+	badge := &model.Badge{}
+	err = schemaDecoder.Decode(badge, form)
+	if err != nil {
+		logger.Error("BadgeLogic Save decode error:", err)
+		errMsg = err.Error()
+		return
+	}
+
+	if _, ok := model.BadgeRuleMap[badge.Rule]; !ok {
+		errMsg = "授予规则不正确"
+		err = errors.New(errMsg)
+		return
+	}
+
+	if badge.Id != 0 {
+		_, err = db.MasterDB.Id(badge.Id).AllCols().Omit("created_at").Update(badge)
+	} else {
+		_, err = db.MasterDB.Insert(badge)
+	}
+
+	if err != nil {
+		errMsg = "内部服务器错误"
+		logger.Error("BadgeLogic Save error:", err)
+		return
+	}
+
+	return
+}
+
+func (BadgeLogic) findByIds(ids []int) map[int]*model.Badge {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	badges := make(map[int]*model.Badge)
+	err := db.MasterDB.In("id", ids).Find(&badges)
+	if err != nil {
+		return nil
+	}
+
+	return badges
+}
+
+func (BadgeLogic) findOnlineBadges(rules ...int) []*model.Badge {
+	session := db.MasterDB.Where("state=? AND rule!=?", model.BadgeStateOnline, model.BadgeRuleManual)
+	if len(rules) > 0 {
+		session.In("rule", rules)
+	}
+
+	badges := make([]*model.Badge, 0)
+	if err := session.Find(&badges); err != nil {
+		logger.Error("BadgeLogic findOnlineBadges error:", err)
+	}
+	return badges
+}
+
+// findOwnedBadgeIds 用户已有（包括被收回）的徽章
+func (BadgeLogic) findOwnedBadgeIds(uid int) map[int]struct{} {
+	userBadges := make([]*model.UserBadge, 0)
+	if err := db.MasterDB.Where("uid=?", uid).Find(&userBadges); err != nil {
+		logger.Error("BadgeLogic findOwnedBadgeIds error:", err)
+	}
+
+	owned := make(map[int]struct{}, len(userBadges))
+	for _, userBadge := range userBadges {
+		owned[userBadge.BadgeId] = struct{}{}
+	}
+	return owned
+}
+
+// reached 用户是否达到了徽章的授予条件
+func (BadgeLogic) reached(user *model.User, badge *model.Badge) bool {
+	var (
+		num int64
+		err error
+	)
+
+	switch badge.Rule {
+	case model.BadgeRuleTopicNum:
+		num, err = db.MasterDB.Where("uid=? AND flag IN(?,?)", user.Uid, model.FlagNoAudit, model.FlagNormal).Count(new(model.Topic))
+	case model.BadgeRuleArticleNum:
+		num, err = db.MasterDB.Where("author_txt=? AND domain=? AND status IN(?,?)", user.Username, WebsiteSetting.Domain, model.ArticleStatusNew, model.ArticleStatusOnline).Count(new(model.Article))
+	case model.BadgeRuleCommentNum:
+		// 已删除的（软删除）自动排除；影子、被删除的评论不算
+		num, err = db.MasterDB.Where("uid=? AND flag IN(?,?)", user.Uid, model.FlagNoAudit, model.FlagNormal).Count(new(model.Comment))
+	case model.BadgeRuleWeight:
+		userActive := &model.UserActive{}
+		_, err = db.MasterDB.Where("uid=?", user.Uid).Get(userActive)
+		num = int64(userActive.Weight)
+	case model.BadgeRuleGCTTNum:
+		gcttUser := &model.GCTTUser{}
+		_, err = db.MasterDB.Where("uid=?", user.Uid).Get(gcttUser)
+		num = int64(gcttUser.Num)
+	case model.BadgeRuleMemberDays:
+		num = int64(time.Since(time.Time(user.Ctime)).Hours() / 24)
+	default:
+		return false
+	}
+
+	if err != nil {
+		logger.Error("BadgeLogic reached badge(%d) uid(%d) error:%+v", badge.Id, user.Uid, err)
+		return false
+	}
+
+	return num >= int64(badge.Threshold)
+}
+
+func (BadgeLogic) grant(badge *model.Badge, uid, opUid int) error {
+	userBadge := &model.UserBadge{
+		Uid:     uid,
+		BadgeId: badge.Id,
+		OpUid:   opUid,
+	}
+	_, err := db.MasterDB.Insert(userBadge)
+	if err != nil {
+		logger.Error("BadgeLogic grant badge(%d) uid(%d) error:%+v", badge.Id, uid, err)
+		return err
+	}
+
+	// 给用户发系统消息（ext 中不能带 uid，否则会被当作自己的动作而不发送）
+	ext := map[string]interface{}{
+		"objid":   badge.Id,
+		"content": badge.Desc,
+	}
+	DefaultMessage.SendSystemMsgTo(nil, uid, model.MsgtypeBadge, ext)
+
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"sander/model"
 
 	"github.com/fatih/structs"
+	"github.com/go-xorm/xorm"
 	"github.com/polaris1119/goutils"
 	"github.com/polaris1119/set"
 	"github.com/polaris1119/slices"
@@ -30,13 +31,17 @@ type CommentLogic struct{}
 
 var DefaultComment = CommentLogic{}
 
+// hiddenCmtContent 被举报隐藏或删除的评论展示的内容（评论的楼层需要保留）
+const hiddenCmtContent = "该回复因违规已被隐藏"
+
 // FindObjComments 获得某个对象的所有评论
 // owner: 被评论对象属主
 // TODO:分页暂不做
-func (self CommentLogic) FindObjComments(ctx context.Context, objid, objtype int, owner, lastCommentUid int /*, page, pageNum int*/) (comments []map[string]interface{}, ownerUser, lastReplyUser *model.User) {
+// me 为当前用户（可以为 nil），影子封禁的评论只有作者自己能看到
+func (self CommentLogic) FindObjComments(ctx context.Context, objid, objtype int, owner, lastCommentUid int, me *model.Me /*, page, pageNum int*/) (comments []map[string]interface{}, ownerUser, lastReplyUser *model.User) {
 
 	commentList := make([]*model.Comment, 0)
-	err := db.MasterDB.Where("objid=? AND objtype=?", objid, objtype).Find(&commentList)
+	err := self.visibleSession(me).And("objid=? AND objtype=?", objid, objtype).Find(&commentList)
 	if err != nil {
 		logger.Error("CommentLogic FindObjComments Error:", err)
 		return
@@ -65,21 +70,39 @@ func (self CommentLogic) FindObjComments(ctx context.Context, objid, objtype int
 
 // FindObjectComments 获得某个对象的所有评论（新版）
 // TODO:分页暂不做
-func (self CommentLogic) FindObjectComments(ctx context.Context, objid, objtype int) (commentList []*model.Comment, err error) {
+// me 为当前用户（可以为 nil），影子封禁的评论只有作者自己能看到
+func (self CommentLogic) FindObjectComments(ctx context.Context, objid, objtype int, me *model.Me) (commentList []*model.Comment, err error) {
 
 	commentList = make([]*model.Comment, 0)
-	err = db.MasterDB.Where("objid=? AND objtype=?", objid, objtype).Asc("cid").Find(&commentList)
+	err = self.visibleSession(me).And("objid=? AND objtype=?", objid, objtype).Asc("cid").Find(&commentList)
 	if err != nil {
 		logger.Error("comment logic FindObjectComments Error:", err)
 	}
 
-	for _, comment := range commentList {
+	cids := make([]int, len(commentList))
+	for i, comment := range commentList {
 		self.decodeCmtContentForShow(ctx, comment, true)
+		cids[i] = comment.Cid
+	}
+
+	editedTimes := DefaultContentRevision.FindEditedTimes(model.TypeComment, cids)
+	for _, comment := range commentList {
+		if editedAt, ok := editedTimes[comment.Cid]; ok {
+			comment.EditedAt = editedAt.Format("2006-01-02 15:04:05")
+		}
 	}
 
 	return
 }
 
+// visibleSession 过滤掉其他人被影子封禁的评论
+func (CommentLogic) visibleSession(me *model.Me) *xorm.Session {
+	if me == nil {
+		return db.MasterDB.Where("flag!=?", model.FlagShadow)
+	}
+	return db.MasterDB.Where("(flag!=? OR uid=?)", model.FlagShadow, me.Uid)
+}
+
 // FindComment 获得评论和额外两个评论
 func (self CommentLogic) FindComment(ctx context.Context, cid, objid, objtype int) (*model.Comment, []*model.Comment) {
 
@@ -92,7 +115,7 @@ func (self CommentLogic) FindComment(ctx context.Context, cid, objid, objtype in
 	self.decodeCmtContentForShow(ctx, comment, false)
 
 	comments := make([]*model.Comment, 0)
-	err = db.MasterDB.Where("objid=? AND objtype=? AND cid!=?", objid, objtype, cid).
+	err = db.MasterDB.Where("objid=? AND objtype=? AND cid!=? AND flag!=?", objid, objtype, cid, model.FlagShadow).
 		Limit(2).Find(&comments)
 	if err != nil {
 		logger.Error("CommentLogic FindComment Find more error:", err)
@@ -126,7 +149,7 @@ func (CommentLogic) Total(objtypes ...int) int64 {
 // 如果 uid!=0，表示获取某人的评论；
 // 如果 objtype!=-1，表示获取某类型的评论；
 func (self CommentLogic) FindRecent(ctx context.Context, uid, objtype, limit int) []*model.Comment {
-	dbSession := db.MasterDB.OrderBy("cid DESC").Limit(limit)
+	dbSession := db.MasterDB.Where("(flag!=? OR uid=?)", model.FlagShadow, currentUid(ctx)).OrderBy("cid DESC").Limit(limit)
 
 	if uid != 0 {
 		dbSession.And("uid=?", uid)
@@ -181,11 +204,27 @@ func (self CommentLogic) Publish(ctx context.Context, uid, objid int, form url.V
 		Content: form.Get("content"),
 	}
 
+	sanction := DefaultUserSanction.Find(uid)
+	if sanction == model.UserSanctionShadowBan {
+		comment.Flag = model.FlagShadow
+	}
+
+	// 锁定、关闭或被合并的主题不能回复
+	if objtype == model.TypeTopic {
+		topic := DefaultTopic.findByTid(objid)
+		if topic.Tid == 0 {
+			return nil, NotFoundErr
+		}
+		if topic.Locked || topic.Closed || topic.MergedTo > 0 {
+			return nil, TopicLockedErr
+		}
+	}
+
 	// TODO:评论楼层怎么处理，避免冲突？最后的楼层信息保存在内存中？
 
-	// 暂时只是从数据库中取出最后的评论楼层
+	// 暂时只是从数据库中取出最后的评论楼层（已删除的评论也占楼层）
 	tmpCmt := &model.Comment{}
-	_, err := db.MasterDB.Where("objid=? AND objtype=?", objid, objtype).OrderBy("floor DESC").Get(tmpCmt)
+	_, err := db.MasterDB.Unscoped().Where("objid=? AND objtype=?", objid, objtype).OrderBy("floor DESC").Get(tmpCmt)
 	if err != nil {
 		logger.Error("post comment find last floor error:", err)
 		return nil, err
@@ -193,7 +232,7 @@ func (self CommentLogic) Publish(ctx context.Context, uid, objid int, form url.V
 
 	comment.Floor = tmpCmt.Floor + 1
 
-	if tmpCmt.Uid == comment.Uid && tmpCmt.Content == comment.Content {
+	if tmpCmt.Uid == comment.Uid && tmpCmt.Content == comment.Content && tmpCmt.DeletedAt.IsZero() {
 		logger.Info("had post comment: %+v", *comment)
 		return tmpCmt, nil
 	}
@@ -206,6 +245,12 @@ func (self CommentLogic) Publish(ctx context.Context, uid, objid int, form url.V
 	}
 	self.decodeCmtContentForShow(ctx, comment, true)
 
+	// 影子封禁的评论只有作者能看到，不更新被评论对象，也不发通知（UserRichObserver 不给被评论者收益）
+	if comment.Flag == model.FlagShadow {
+		go commentObservable.NotifyObservers(uid, objtype, comment.Cid)
+		return comment, nil
+	}
+
 	// 回调，不关心处理结果（有些对象可能不需要回调）
 	if commenter, ok := commenters[objtype]; ok {
 		now := time.Now()
@@ -218,6 +263,11 @@ func (self CommentLogic) Publish(ctx context.Context, uid, objid int, form url.V
 
 	go commentObservable.NotifyObservers(uid, objtype, comment.Cid)
 
+	// 观察期的评论审核通过前隐藏，不发通知
+	if sanction == model.UserSanctionProbation && DefaultUserSanction.Hold(ctx, sanction, model.TypeComment, comment.Cid) {
+		return comment, nil
+	}
+
 	go self.sendSystemMsg(ctx, uid, objid, objtype, comment.Cid, form)
 
 	return comment, nil
@@ -253,16 +303,37 @@ func (CommentLogic) sendSystemMsg(ctx context.Context, uid, objid, objtype, cid
 	DefaultMessage.SendSysMsgAtUsernames(ctx, form.Get("usernames"), ext, to)
 }
 
-// Modify 修改评论信息
-func (CommentLogic) Modify(ctx context.Context, cid int, content string) (errMsg string, err error) {
+// Modify 修改评论信息，保留修改前的版本
+func (CommentLogic) Modify(ctx context.Context, me *model.Me, cid int, content string) (errMsg string, err error) {
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	origin, err := DefaultContentRevision.origin(session, model.TypeComment, cid)
+	if err != nil {
+		session.Rollback()
+		errMsg = "对不起，服务器内部错误，请稍后再试！"
+		return
+	}
 
-	_, err = db.MasterDB.Table(new(model.Comment)).Id(cid).Update(map[string]interface{}{"content": content})
+	_, err = session.Table(new(model.Comment)).Id(cid).Update(map[string]interface{}{"content": content})
 	if err != nil {
+		session.Rollback()
 		logger.Error("更新评论内容 【%d】 失败：%s", cid, err)
 		errMsg = "对不起，服务器内部错误，请稍后再试！"
 		return
 	}
 
+	err = DefaultContentRevision.record(session, model.TypeComment, cid, origin, me.Uid, "", content)
+	if err != nil {
+		session.Rollback()
+		errMsg = "对不起，服务器内部错误，请稍后再试！"
+		return
+	}
+
+	session.Commit()
+
 	return
 }
 
@@ -310,6 +381,11 @@ func (CommentLogic) FindById(cid int) (*model.Comment, error) {
 }
 
 func (CommentLogic) decodeCmtContent(ctx context.Context, comment *model.Comment) string {
+	if comment.Flag == model.FlagAuditDelete {
+		comment.Content = hiddenCmtContent
+		return comment.Content
+	}
+
 	// 安全过滤
 	content := template.HTMLEscapeString(comment.Content)
 	// @别人
@@ -327,6 +403,11 @@ func (CommentLogic) decodeCmtContent(ctx context.Context, comment *model.Comment
 
 // decodeCmtContentForShow 采用引用的方式显示对其他楼层的回复
 func (CommentLogic) decodeCmtContentForShow(ctx context.Context, comment *model.Comment, isEscape bool) {
+	if comment.Flag == model.FlagAuditDelete {
+		comment.Content = hiddenCmtContent
+		return
+	}
+
 	// 安全过滤
 	content := template.HTMLEscapeString(comment.Content)
 
@@ -356,9 +437,9 @@ type CommentObjecter interface {
 func (self CommentLogic) FindAll(ctx context.Context, paginator *Paginator, orderBy string, querystring string, args ...interface{}) []*model.Comment {
 
 	comments := make([]*model.Comment, 0)
-	session := db.MasterDB.OrderBy(orderBy)
+	session := db.MasterDB.Where("flag!=?", model.FlagShadow).OrderBy(orderBy)
 	if querystring != "" {
-		session.Where(querystring, args...)
+		session.And(querystring, args...)
 	}
 	err := session.Limit(paginator.PerPage(), paginator.Offset()).Find(&comments)
 	if err != nil {
@@ -398,9 +479,9 @@ func (CommentLogic) Count(ctx context.Context, querystring string, args ...inter
 		err   error
 	)
 	if querystring == "" {
-		total, err = db.MasterDB.Count(new(model.Comment))
+		total, err = db.MasterDB.Where("flag!=?", model.FlagShadow).Count(new(model.Comment))
 	} else {
-		total, err = db.MasterDB.Where(querystring, args...).Count(new(model.Comment))
+		total, err = db.MasterDB.Where("flag!=?", model.FlagShadow).And(querystring, args...).Count(new(model.Comment))
 	}
 
 	if err != nil {
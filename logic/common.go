@@ -15,7 +15,9 @@ import (
 	"sander/model"
 	"sander/util"
 
+	"github.com/go-xorm/xorm"
 	"github.com/gorilla/schema"
+	"github.com/polaris1119/goutils"
 	"golang.org/x/net/context"
 )
 
@@ -29,8 +31,42 @@ func init() {
 var (
 	NotModifyAuthorityErr = errors.New("没有修改权限")
 	NotFoundErr           = errors.New("Not Found")
+	ConflictErr           = errors.New("内容已被他人修改，请合并后重新提交")
 )
 
+// updateWithVersion 更新内容时版本号加 1。version 不为空时启用乐观锁：
+// 只有数据库中的版本和 version 一致才更新，否则返回 ConflictErr
+func updateWithVersion(session *xorm.Session, version string, bean interface{}) (int64, error) {
+	session.Omit("version").Incr("version", 1)
+	if version != "" {
+		session.And("version=?", goutils.MustInt(version))
+	}
+
+	affected, err := session.Update(bean)
+	if err == nil && version != "" && affected == 0 {
+		err = ConflictErr
+	}
+	return affected, err
+}
+
+// currentMe 当前登录用户（登录中间件放在 ctx 的 user 中），未登录时为 nil。
+// 用于影子封禁的内容只让作者自己看到
+func currentMe(ctx context.Context) *model.Me {
+	if ctx == nil {
+		return nil
+	}
+	me, _ := ctx.Value("user").(*model.Me)
+	return me
+}
+
+// currentUid 当前登录用户的 uid，未登录时为 0
+func currentUid(ctx context.Context) int {
+	if me := currentMe(ctx); me != nil {
+		return me.Uid
+	}
+	return 0
+}
+
 // parseAtUser 解析 @某人
 func parseAtUser(ctx context.Context, content string) string {
 	reg := regexp.MustCompile(`@([^\s@]{4,20})`)
@@ -59,6 +95,11 @@ func CanEdit(me *model.Me, curModel interface{}) bool {
 			return true
 		}
 
+		// 节点版主可以编辑所管理节点下的主题
+		if DefaultNodeModerator.HasPerm(me.Uid, entity.Nid, model.ModPermEdit) {
+			return true
+		}
+
 		if time.Now().Sub(time.Time(entity.Ctime)) > canEditTime {
 			return false
 		}
@@ -71,8 +112,9 @@ func CanEdit(me *model.Me, curModel interface{}) bool {
 			return true
 		}
 
-		// 文章的能编辑时间是15天
-		if time.Now().Sub(time.Time(entity.Ctime)) > 15*86400*time.Second {
+		// 文章的能编辑时间可配置，0 表示没限制
+		articleEditTime := time.Duration(UserSetting[model.KeyArticleEditTime]) * time.Second
+		if articleEditTime > 0 && time.Now().Sub(time.Time(entity.Ctime)) > articleEditTime {
 			return false
 		}
 
@@ -104,6 +146,15 @@ func CanEdit(me *model.Me, curModel interface{}) bool {
 		if me.IsAdmin && roleCanEdit(model.Administrator, me) {
 			return true
 		}
+		if entity.State == model.WikiStateLocked {
+			return false
+		}
+		if HasPrivilege(me, model.PrivEditWiki) {
+			return true
+		}
+		if entity.State == model.WikiStateProtected {
+			return false
+		}
 		if time.Now().Sub(time.Time(entity.Ctime)) > canEditTime {
 			return false
 		}
@@ -137,6 +188,13 @@ func CanEdit(me *model.Me, curModel interface{}) bool {
 		if adminCanEdit(entity, me) {
 			return true
 		}
+
+		// 主题详情页传入的是 map，节点版主可以编辑所管理节点下的主题
+		if _, ok := entity["tid"]; ok {
+			if nid, ok := entity["nid"].(int); ok && DefaultNodeModerator.HasPerm(me.Uid, nid, model.ModPermEdit) {
+				return true
+			}
+		}
 		if ctime, ok := entity["ctime"]; ok {
 			if time.Now().Sub(time.Time(ctime.(model.OftenTime))) > canEditTime {
 				return false
@@ -0,0 +1,497 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"sander/db"
+	"sander/global"
+	"sander/logger"
+	"sander/model"
+	"sander/util"
+
+	"github.com/polaris1119/set"
+	"golang.org/x/net/context"
+)
+
+var filterLinkReg = regexp.MustCompile(`(?i)(?:https?://|www\.)([^\s/"'<>\)\]]+)`)
+
+// FilterHit 规则的一次命中，Start、End 为命中内容在字段中的字节位置
+type FilterHit struct {
+	Rule  *model.FilterRule
+	Field string // title 或 content
+	Start int
+	End   int
+}
+
+// text 命中的内容
+func (this *FilterHit) text(title, content string) string {
+	if this.Field == "title" {
+		return title[this.Start:this.End]
+	}
+	return content[this.Start:this.End]
+}
+
+// FilterResult 内容过滤的结果
+type FilterResult struct {
+	Action  int      // 命中规则中最严重的处理方式，0 表示没有命中
+	Matched []string // 命中最严重规则的内容（去重），用于提示用户
+	Title   string   // 按“替换为 *”的规则处理后的标题
+	Content string   // 按“替换为 *”的规则处理后的内容
+}
+
+// contentFilter 编译好的过滤规则：关键词用 Aho-Corasick 自动机一次扫描，正则逐条匹配，域名只匹配链接
+type contentFilter struct {
+	words    []*model.FilterRule
+	matcher  *util.ACMatcher
+	regexps  []*regexp.Regexp
+	regRules []*model.FilterRule
+	domains  []*model.FilterRule
+}
+
+// newContentFilter 编译启用的规则，正则不合法的规则会被忽略
+func newContentFilter(rules []*model.FilterRule) *contentFilter {
+	filter := &contentFilter{}
+
+	patterns := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		switch rule.Type {
+		case model.FilterTypeWord:
+			filter.words = append(filter.words, rule)
+			patterns = append(patterns, rule.Pattern)
+		case model.FilterTypeRegexp:
+			reg, err := regexp.Compile("(?i)" + rule.Pattern)
+			if err != nil {
+				logger.Error("content filter rule", rule.Id, "compile error:", err)
+				continue
+			}
+			filter.regexps = append(filter.regexps, reg)
+			filter.regRules = append(filter.regRules, rule)
+		case model.FilterTypeDomain:
+			filter.domains = append(filter.domains, rule)
+		}
+	}
+	filter.matcher = util.NewACMatcher(patterns)
+
+	return filter
+}
+
+// match 检查一个字段，scope 为字段对应的 FilterScopeXxx
+func (this *contentFilter) match(field string, scope int, text string) []*FilterHit {
+	if text == "" {
+		return nil
+	}
+
+	hits := make([]*FilterHit, 0)
+	for _, m := range this.matcher.FindAll(text) {
+		if rule := this.words[m.Index]; rule.Scope&scope != 0 {
+			hits = append(hits, &FilterHit{Rule: rule, Field: field, Start: m.Start, End: m.End})
+		}
+	}
+
+	for i, reg := range this.regexps {
+		rule := this.regRules[i]
+		if rule.Scope&scope == 0 {
+			continue
+		}
+		for _, loc := range reg.FindAllStringIndex(text, -1) {
+			if loc[1] > loc[0] {
+				hits = append(hits, &FilterHit{Rule: rule, Field: field, Start: loc[0], End: loc[1]})
+			}
+		}
+	}
+
+	if len(this.domains) > 0 {
+		for _, loc := range filterLinkReg.FindAllStringSubmatchIndex(text, -1) {
+			host := strings.ToLower(text[loc[2]:loc[3]])
+			if pos := strings.IndexByte(host, ':'); pos != -1 {
+				host = host[:pos]
+			}
+			for _, rule := range this.domains {
+				if rule.Scope&scope != 0 && (host == rule.Pattern || strings.HasSuffix(host, "."+rule.Pattern)) {
+					hits = append(hits, &FilterHit{Rule: rule, Field: field, Start: loc[2], End: loc[3]})
+				}
+			}
+		}
+	}
+
+	return hits
+}
+
+type ContentFilterLogic struct{}
+
+var DefaultContentFilter = ContentFilterLogic{}
+
+// Check 用启用的规则检查标题和内容，返回所有命中
+func (ContentFilterLogic) Check(title, content string) []*FilterHit {
+	filterLocker.RLock()
+	filter := curContentFilter
+	filterLocker.RUnlock()
+
+	if filter == nil {
+		return nil
+	}
+
+	hits := filter.match("title", model.FilterScopeTitle, title)
+	return append(hits, filter.match("content", model.FilterScopeContent, content)...)
+}
+
+// Filter 检查用户提交的标题和内容：记录所有命中，按“替换为 *”的规则处理内容，并返回最严重的处理方式
+func (self ContentFilterLogic) Filter(ctx context.Context, uid int, uri, title, content string) *FilterResult {
+	result := &FilterResult{Title: title, Content: content}
+
+	hits := self.Check(title, content)
+	if len(hits) == 0 {
+		return result
+	}
+
+	for _, hit := range hits {
+		if hit.Rule.Action > result.Action {
+			result.Action = hit.Rule.Action
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, hit := range hits {
+		matched := hit.text(title, content)
+		if hit.Rule.Action == result.Action && !seen[matched] {
+			seen[matched] = true
+			result.Matched = append(result.Matched, matched)
+		}
+	}
+
+	self.logHits(uid, uri, title, content, hits)
+
+	result.Title = maskHits(title, "title", hits)
+	result.Content = maskHits(content, "content", hits)
+
+	return result
+}
+
+// NeedReview 内容是否命中了“人工审核”的规则，返回第一个命中的内容
+func (self ContentFilterLogic) NeedReview(title, content string) (string, bool) {
+	for _, hit := range self.Check(title, content) {
+		if hit.Rule.Action == model.FilterActionReview {
+			return hit.text(title, content), true
+		}
+	}
+	return "", false
+}
+
+// logHits 记录命中日志并累加规则的命中次数。同一规则在同一字段命中相同的内容只记一次
+func (ContentFilterLogic) logHits(uid int, uri, title, content string, hits []*FilterHit) {
+	type logKey struct {
+		ruleId         int
+		field, matched string
+	}
+
+	logged := make(map[logKey]bool)
+	ruleHits := make(map[int]int)
+	for _, hit := range hits {
+		text := content
+		if hit.Field == "title" {
+			text = title
+		}
+		matched := text[hit.Start:hit.End]
+
+		key := logKey{hit.Rule.Id, hit.Field, matched}
+		if logged[key] {
+			continue
+		}
+		logged[key] = true
+		ruleHits[hit.Rule.Id]++
+
+		filterLog := &model.FilterLog{
+			RuleId:  hit.Rule.Id,
+			Uid:     uid,
+			Field:   hit.Field,
+			Matched: matched,
+			Action:  hit.Rule.Action,
+			Uri:     uri,
+			Excerpt: excerpt(text, hit.Start, hit.End),
+		}
+		if _, err := db.MasterDB.Insert(filterLog); err != nil {
+			logger.Error("ContentFilterLogic logHits insert error:", err)
+		}
+	}
+
+	for ruleId, num := range ruleHits {
+		_, err := db.MasterDB.Exec("UPDATE filter_rule SET hits=hits+?, updated_at=updated_at WHERE id=?", num, ruleId)
+		if err != nil {
+			logger.Error("ContentFilterLogic logHits update hits error:", err)
+		}
+	}
+}
+
+// FindRulesByPage 过滤规则（分页）：后台用
+func (ContentFilterLogic) FindRulesByPage(ctx context.Context, conds map[string]string, curPage, limit int) ([]*model.FilterRule, int) {
+	session := db.MasterDB.NewSession()
+
+	for k, v := range conds {
+		session.And(k+"=?", v)
+	}
+
+	totalSession := session.Clone()
+
+	offset := (curPage - 1) * limit
+	rules := make([]*model.FilterRule, 0)
+	err := session.Desc("id").Limit(limit, offset).Find(&rules)
+	if err != nil {
+		logger.Error("ContentFilterLogic FindRulesByPage error:", err)
+		return nil, 0
+	}
+
+	total, err := totalSession.Count(new(model.FilterRule))
+	if err != nil {
+		logger.Error("ContentFilterLogic FindRulesByPage count error:", err)
+		return nil, 0
+	}
+
+	return rules, int(total)
+}
+
+// FindRuleById 获取一条规则
+func (ContentFilterLogic) FindRuleById(ctx context.Context, id int) *model.FilterRule {
+	rule := &model.FilterRule{}
+	_, err := db.MasterDB.Id(id).Get(rule)
+	if err != nil {
+		logger.Error("ContentFilterLogic FindRuleById error:", err)
+		return nil
+	}
+
+	if rule.Id == 0 {
+		return nil
+	}
+
+	return rule
+}
+
+// SaveRule 新建或修改规则，保存后重新加载规则
+func (ContentFilterLogic) SaveRule(ctx context.Context, form url.Values, username string) (errMsg string, err error) {
+	rule := &model.FilterRule{}
+	err = schemaDecoder.Decode(rule, form)
+	if err != nil {
+		logger.Error("ContentFilterLogic SaveRule decode error:", err)
+		errMsg = err.Error()
+		return
+	}
+
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	switch rule.Type {
+	case model.FilterTypeWord:
+	case model.FilterTypeRegexp:
+		if _, err = regexp.Compile(rule.Pattern); err != nil {
+			errMsg = "正则表达式不合法：" + err.Error()
+			return
+		}
+	case model.FilterTypeDomain:
+		rule.Pattern = strings.ToLower(rule.Pattern)
+		rule.Pattern = strings.TrimPrefix(strings.TrimPrefix(rule.Pattern, "http://"), "https://")
+		rule.Pattern = strings.TrimPrefix(strings.TrimSuffix(rule.Pattern, "/"), "www.")
+	default:
+		errMsg = "规则类型不正确"
+		err = errors.New(errMsg)
+		return
+	}
+
+	if rule.Pattern == "" {
+		errMsg = "规则内容不能为空"
+		err = errors.New(errMsg)
+		return
+	}
+	if _, ok := model.FilterActionMap[rule.Action]; !ok {
+		errMsg = "处理方式不正确"
+		err = errors.New(errMsg)
+		return
+	}
+	if _, ok := model.FilterScopeMap[rule.Scope]; !ok {
+		errMsg = "检查范围不正确"
+		err = errors.New(errMsg)
+		return
+	}
+
+	rule.OpUser = username
+	if rule.Id != 0 {
+		_, err = db.MasterDB.Id(rule.Id).Cols("type", "pattern", "scope", "action", "enabled", "remark", "op_user").Update(rule)
+	} else {
+		_, err = db.MasterDB.Insert(rule)
+	}
+
+	if err != nil {
+		errMsg = "内部服务器错误"
+		logger.Error("ContentFilterLogic SaveRule error:", err)
+		return
+	}
+
+	global.FilterRuleChan <- struct{}{}
+
+	return
+}
+
+// DeleteRule 删除规则，命中日志保留
+func (ContentFilterLogic) DeleteRule(ctx context.Context, id int) error {
+	_, err := db.MasterDB.Id(id).Delete(new(model.FilterRule))
+	if err != nil {
+		logger.Error("ContentFilterLogic DeleteRule error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	global.FilterRuleChan <- struct{}{}
+
+	return nil
+}
+
+// FindLogsByPage 命中日志（分页）：后台用
+func (ContentFilterLogic) FindLogsByPage(ctx context.Context, conds map[string]string, curPage, limit int) ([]*model.FilterLog, int) {
+	session := db.MasterDB.NewSession()
+
+	for k, v := range conds {
+		session.And(k+"=?", v)
+	}
+
+	totalSession := session.Clone()
+
+	offset := (curPage - 1) * limit
+	filterLogs := make([]*model.FilterLog, 0)
+	err := session.Desc("id").Limit(limit, offset).Find(&filterLogs)
+	if err != nil {
+		logger.Error("ContentFilterLogic FindLogsByPage error:", err)
+		return nil, 0
+	}
+
+	total, err := totalSession.Count(new(model.FilterLog))
+	if err != nil {
+		logger.Error("ContentFilterLogic FindLogsByPage count error:", err)
+		return nil, 0
+	}
+
+	uidSet := set.New(set.NonThreadSafe)
+	ruleIds := make([]int, 0, len(filterLogs))
+	for _, filterLog := range filterLogs {
+		uidSet.Add(filterLog.Uid)
+		ruleIds = append(ruleIds, filterLog.RuleId)
+	}
+
+	rules := make(map[int]*model.FilterRule)
+	if len(ruleIds) > 0 {
+		if err = db.MasterDB.In("id", ruleIds).Find(&rules); err != nil {
+			logger.Error("ContentFilterLogic FindLogsByPage find rules error:", err)
+		}
+	}
+	usersMap := DefaultUser.FindUserInfos(ctx, set.IntSlice(uidSet))
+	for _, filterLog := range filterLogs {
+		filterLog.Rule = rules[filterLog.RuleId]
+		filterLog.User = usersMap[filterLog.Uid]
+	}
+
+	return filterLogs, int(total)
+}
+
+// ContentReviewObserver 发布或修改的内容命中“人工审核”的规则时，隐藏内容并放入举报处理队列，
+// 管理员驳回（即审核通过）后恢复。只支持能被举报的主题、文章、资源和评论
+type ContentReviewObserver struct{}
+
+func (ContentReviewObserver) Update(action string, uid, objtype, objid int) {
+	if action == actionComment {
+		objtype = model.TypeComment
+	}
+
+	title, content, ok := findObjectText(objtype, objid)
+	if !ok {
+		return
+	}
+
+	matched, ok := DefaultContentFilter.NeedReview(title, content)
+	if !ok {
+		return
+	}
+
+	err := DefaultReport.Review(context.Background(), objtype, objid, model.ReportReasonFilter, "命中过滤规则："+matched)
+	if err != nil {
+		logger.Error("ContentReviewObserver review error:", err)
+	}
+}
+
+// findObjectText 主题、文章、资源、评论的标题和内容（评论没有标题），其他类型返回 false
+func findObjectText(objtype, objid int) (title, content string, ok bool) {
+	switch objtype {
+	case model.TypeTopic:
+		topic := DefaultTopic.findByTid(objid)
+		return topic.Title, topic.Content, topic.Tid > 0
+	case model.TypeArticle:
+		article, err := DefaultArticle.FindById(context.Background(), objid)
+		if err != nil {
+			return
+		}
+		return article.Title, article.Content, article.Id > 0
+	case model.TypeResource:
+		resource := DefaultResource.findById(objid)
+		return resource.Title, resource.Content, resource.Id > 0
+	case model.TypeComment:
+		comment, err := DefaultComment.FindById(objid)
+		if err != nil {
+			return
+		}
+		return "", comment.Content, comment.Cid > 0
+	}
+
+	return
+}
+
+// maskHits 把字段中命中“替换为 *”规则的内容替换为 *，每个字符一个 *
+func maskHits(text, field string, hits []*FilterHit) string {
+	ranges := make([][2]int, 0)
+	for _, hit := range hits {
+		if hit.Field == field && hit.Rule.Action == model.FilterActionMask {
+			ranges = append(ranges, [2]int{hit.Start, hit.End})
+		}
+	}
+	if len(ranges) == 0 {
+		return text
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	buf := make([]byte, 0, len(text))
+	pos := 0
+	for _, r := range ranges {
+		if r[1] <= pos {
+			continue
+		}
+		if r[0] < pos {
+			r[0] = pos
+		}
+		buf = append(buf, text[pos:r[0]]...)
+		buf = append(buf, strings.Repeat("*", utf8.RuneCountInString(text[r[0]:r[1]]))...)
+		pos = r[1]
+	}
+	buf = append(buf, text[pos:]...)
+
+	return string(buf)
+}
+
+// excerpt 命中位置前后各取一些字符，便于后台判断是否误判
+func excerpt(text string, start, end int) string {
+	const around = 30
+
+	prefix := []rune(text[:start])
+	if len(prefix) > around {
+		prefix = append([]rune("..."), prefix[len(prefix)-around:]...)
+	}
+	suffix := []rune(text[end:])
+	if len(suffix) > around {
+		suffix = append(suffix[:around], []rune("...")...)
+	}
+
+	return string(prefix) + text[start:end] + string(suffix)
+}
@@ -0,0 +1,299 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author:polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+	"sander/util"
+
+	"github.com/go-xorm/xorm"
+	"github.com/polaris1119/set"
+	"golang.org/x/net/context"
+)
+
+type ContentRevisionLogic struct{}
+
+var DefaultContentRevision = ContentRevisionLogic{}
+
+// revisionObjtypeRoles 各类对象的历史版本由哪类管理员恢复
+var revisionObjtypeRoles = map[int]int{
+	model.TypeTopic:   model.TopicAdmin,
+	model.TypeArticle: model.ArticleAdmin,
+	model.TypeComment: model.Administrator,
+}
+
+// CanRestore 是否能查看并恢复某类对象的历史版本
+func (ContentRevisionLogic) CanRestore(me *model.Me, objtype int) bool {
+	if me == nil || !me.IsAdmin {
+		return false
+	}
+
+	roleId, ok := revisionObjtypeRoles[objtype]
+	if !ok {
+		return false
+	}
+
+	return roleCanEdit(roleId, me)
+}
+
+// FindObject 被编辑对象的标题和访问地址，对象不存在或当前用户看不到时返回 nil，可见性和详情页一致
+func (self ContentRevisionLogic) FindObject(ctx context.Context, objtype, objid int) map[string]interface{} {
+	me := currentMe(ctx)
+
+	switch objtype {
+	case model.TypeTopic:
+		topic := DefaultTopic.findByTid(objid)
+		if topic.Tid == 0 {
+			return nil
+		}
+		// 删除、审核删除的主题都看不到，影子封禁的只有作者自己能看到
+		if topic.Flag > model.FlagNormal && !(topic.Flag == model.FlagShadow && me != nil && topic.Uid == me.Uid) {
+			return nil
+		}
+		return map[string]interface{}{"title": topic.Title, "url": model.PathUrlMap[objtype] + strconv.Itoa(objid), "type_name": model.TypeNameMap[objtype]}
+	case model.TypeArticle:
+		article, err := DefaultArticle.FindById(ctx, objid)
+		if err != nil || article.Id == 0 || article.Status == model.ArticleStatusOffline {
+			return nil
+		}
+		// 定时发布的文章发布前、影子封禁的文章，只有作者和管理员能看到
+		if article.Status == model.ArticleStatusScheduled || article.Status == model.ArticleStatusShadow {
+			if me == nil || (me.Username != article.AuthorTxt && !me.IsAdmin) {
+				return nil
+			}
+		}
+		return map[string]interface{}{"title": article.Title, "url": model.PathUrlMap[objtype] + strconv.Itoa(objid), "type_name": model.TypeNameMap[objtype]}
+	case model.TypeComment:
+		comment, err := DefaultComment.FindById(objid)
+		if err != nil || comment.Cid == 0 || comment.Flag == model.FlagAuditDelete {
+			return nil
+		}
+		if comment.Flag == model.FlagShadow && (me == nil || comment.Uid != me.Uid) {
+			return nil
+		}
+		// 评论所在的主题、文章看不到时，评论也看不到
+		if _, ok := revisionObjtypeRoles[comment.Objtype]; ok && self.FindObject(ctx, comment.Objtype, comment.Objid) == nil {
+			return nil
+		}
+		return map[string]interface{}{
+			"title":     fmt.Sprintf("%s的第 %d 楼评论", model.TypeNameMap[comment.Objtype], comment.Floor),
+			"url":       fmt.Sprintf("%s%d#reply-%d", model.PathUrlMap[comment.Objtype], comment.Objid, comment.Floor),
+			"type_name": "评论",
+		}
+	}
+
+	return nil
+}
+
+// FindRevisions 某个对象的所有历史版本（不含内容），新版本在前
+func (ContentRevisionLogic) FindRevisions(ctx context.Context, objtype, objid int) []*model.ContentRevision {
+	revisions := make([]*model.ContentRevision, 0)
+	err := db.MasterDB.Where("objtype=? AND objid=?", objtype, objid).Omit("content").Desc("version").Find(&revisions)
+	if err != nil {
+		logger.Error("ContentRevisionLogic FindRevisions error:", err)
+		return nil
+	}
+
+	uidSet := set.New(set.NonThreadSafe)
+	for _, revision := range revisions {
+		uidSet.Add(revision.Uid)
+	}
+	usersMap := DefaultUser.FindUserInfos(ctx, set.IntSlice(uidSet))
+	for _, revision := range revisions {
+		revision.User = usersMap[revision.Uid]
+	}
+
+	return revisions
+}
+
+// FindRevision 获取某个历史版本，version 为 0 表示最新版本
+func (ContentRevisionLogic) FindRevision(ctx context.Context, objtype, objid, version int) *model.ContentRevision {
+	revision := &model.ContentRevision{}
+
+	session := db.MasterDB.Where("objtype=? AND objid=?", objtype, objid)
+	if version > 0 {
+		session.And("version=?", version)
+	} else {
+		session.Desc("version")
+	}
+
+	_, err := session.Get(revision)
+	if err != nil {
+		logger.Error("ContentRevisionLogic FindRevision error:", err)
+		return nil
+	}
+
+	if revision.Id == 0 {
+		return nil
+	}
+
+	revision.User = DefaultUser.FindOne(ctx, "uid", revision.Uid)
+
+	return revision
+}
+
+// FindEditedTimes 多个对象最后一次被编辑的时间，没编辑过的不在结果中
+func (ContentRevisionLogic) FindEditedTimes(objtype int, objids []int) map[int]time.Time {
+	editedTimes := make(map[int]time.Time)
+	if len(objids) == 0 {
+		return editedTimes
+	}
+
+	revisions := make([]*model.ContentRevision, 0)
+	err := db.MasterDB.Select("objid, MAX(created_at) AS created_at").
+		Where("objtype=? AND version>1", objtype).In("objid", objids).
+		GroupBy("objid").Find(&revisions)
+	if err != nil {
+		logger.Error("ContentRevisionLogic FindEditedTimes error:", err)
+		return editedTimes
+	}
+
+	for _, revision := range revisions {
+		editedTimes[revision.Objid] = revision.CreatedAt
+	}
+
+	return editedTimes
+}
+
+// Diff 对比两个版本，按行左右对照
+func (ContentRevisionLogic) Diff(from, to *model.ContentRevision) []*util.DiffRow {
+	return util.DiffLines(from.Content, to.Content)
+}
+
+// Restore 将对象恢复到某个历史版本，恢复本身也会保存为一个新版本：管理员用
+func (self ContentRevisionLogic) Restore(ctx context.Context, me *model.Me, objtype, objid, version int) error {
+	if !self.CanRestore(me, objtype) {
+		return errors.New("没有权限")
+	}
+
+	revision := self.FindRevision(ctx, objtype, objid, version)
+	if revision == nil {
+		return errors.New("该版本不存在")
+	}
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	origin, err := self.origin(session, objtype, objid)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	// 主题、文章恢复时版本号也要加 1，让恢复前打开的编辑页提交时能检测到冲突
+	var change map[string]interface{}
+	switch objtype {
+	case model.TypeTopic:
+		change = map[string]interface{}{"title": revision.Title, "content": revision.Content, "editor_uid": me.Uid}
+		_, err = updateWithVersion(session.Table(new(model.Topic)).Id(objid), "", change)
+	case model.TypeArticle:
+		change = map[string]interface{}{"title": revision.Title, "content": revision.Content, "op_user": me.Username}
+		_, err = updateWithVersion(session.Table(new(model.Article)).Id(objid), "", change)
+	case model.TypeComment:
+		change = map[string]interface{}{"content": revision.Content}
+		_, err = session.Table(new(model.Comment)).Id(objid).Update(change)
+	}
+	if err != nil {
+		session.Rollback()
+		logger.Error("ContentRevisionLogic Restore update error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	err = self.record(session, objtype, objid, origin, me.Uid, revision.Title, revision.Content)
+	if err != nil {
+		session.Rollback()
+		return errors.New("服务内部错误")
+	}
+
+	session.Commit()
+
+	if objtype != model.TypeComment {
+		go modifyObservable.NotifyObservers(me.Uid, objtype, objid)
+	}
+
+	return nil
+}
+
+// origin 获取对象编辑前的信息，用于第一次编辑时保存原始版本
+func (ContentRevisionLogic) origin(session *xorm.Session, objtype, objid int) (*model.ContentRevision, error) {
+	origin := &model.ContentRevision{}
+
+	var err error
+	switch objtype {
+	case model.TypeTopic:
+		topic := &model.Topic{}
+		_, err = session.Id(objid).Get(topic)
+		origin.Uid, origin.Title, origin.Content, origin.CreatedAt = topic.Uid, topic.Title, topic.Content, time.Time(topic.Ctime)
+	case model.TypeArticle:
+		article := &model.Article{}
+		_, err = session.Id(objid).Get(article)
+		if article.IsSelf {
+			origin.Uid = DefaultUser.FindOne(nil, "username", article.Author).Uid
+		}
+		origin.Title, origin.Content, origin.CreatedAt = article.Title, article.Content, time.Time(article.Ctime)
+	case model.TypeComment:
+		comment := &model.Comment{}
+		_, err = session.Id(objid).Get(comment)
+		origin.Uid, origin.Content, origin.CreatedAt = comment.Uid, comment.Content, time.Time(comment.Ctime)
+	default:
+		err = errors.New("不支持的类型")
+	}
+
+	if err != nil {
+		logger.Error("ContentRevisionLogic origin objtype(%d) objid(%d) error:%+v", objtype, objid, err)
+		return nil, err
+	}
+
+	return origin, nil
+}
+
+// record 在编辑对象的事务中保存新版本。没有任何版本时先把编辑前的内容保存为版本 1；内容没变化时不保存
+func (ContentRevisionLogic) record(session *xorm.Session, objtype, objid int, origin *model.ContentRevision, uid int, title, content string) error {
+	if origin.Title == title && origin.Content == content {
+		return nil
+	}
+
+	lastRevision := &model.ContentRevision{}
+	_, err := session.Where("objtype=? AND objid=?", objtype, objid).Desc("version").Get(lastRevision)
+	if err != nil {
+		logger.Error("ContentRevisionLogic record find last revision error:", err)
+		return err
+	}
+
+	if lastRevision.Id == 0 {
+		origin.Objtype, origin.Objid, origin.Version = objtype, objid, 1
+		if _, err = session.Insert(origin); err != nil {
+			logger.Error("ContentRevisionLogic record insert origin error:", err)
+			return err
+		}
+		lastRevision = origin
+	}
+
+	revision := &model.ContentRevision{
+		Objtype:   objtype,
+		Objid:     objid,
+		Version:   lastRevision.Version + 1,
+		Title:     title,
+		Content:   content,
+		Uid:       uid,
+		CreatedAt: time.Now(),
+	}
+	if _, err = session.Insert(revision); err != nil {
+		logger.Error("ContentRevisionLogic record insert error:", err)
+		return err
+	}
+
+	return nil
+}
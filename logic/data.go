@@ -7,12 +7,14 @@
 package logic
 
 import (
+	"encoding/json"
 	"errors"
 	"sync"
 
 	"sander/db"
 	"sander/logger"
 	"sander/model"
+	"sander/util"
 )
 
 // 常驻内存数据（多实例部署时，数据同步会有问题）
@@ -43,6 +45,18 @@ var (
 
 	userSettingLocker sync.RWMutex
 	UserSetting       map[string]int
+
+	userLevelLocker sync.RWMutex
+	// 声望等级，按等级从低到高排序
+	UserLevels []*model.UserLevel
+
+	filterLocker sync.RWMutex
+	// 编译好的内容过滤规则
+	curContentFilter *contentFilter
+
+	spamLocker sync.RWMutex
+	// 最近一次训练的垃圾内容分类器
+	curSpamClassifier *util.NaiveBayes
 )
 
 // 将所有 权限 加载到内存中；后台修改权限时，重新加载一次
@@ -466,3 +480,72 @@ func loadRecommendNodes() bool {
 
 	return true
 }
+
+// LoadUserLevels 将声望等级加载到内存中；后台修改等级时，重新加载一次
+func LoadUserLevels() error {
+	userLevels := make([]*model.UserLevel, 0)
+	err := db.MasterDB.Asc("level").Find(&userLevels)
+	if err != nil {
+		logger.Error("LoadUserLevels Find fail:%+v", err)
+		return err
+	}
+
+	userLevelLocker.Lock()
+	defer userLevelLocker.Unlock()
+
+	UserLevels = userLevels
+
+	logger.Info("LoadUserLevels successfully!")
+
+	return nil
+}
+
+// LoadFilterRules 将启用的内容过滤规则编译后加载到内存中；后台修改规则时，重新加载一次
+func LoadFilterRules() error {
+	rules := make([]*model.FilterRule, 0)
+	err := db.MasterDB.Where("enabled=1").Find(&rules)
+	if err != nil {
+		logger.Error("LoadFilterRules Find fail:%+v", err)
+		return err
+	}
+
+	filter := newContentFilter(rules)
+
+	filterLocker.Lock()
+	defer filterLocker.Unlock()
+
+	curContentFilter = filter
+
+	logger.Info("LoadFilterRules successfully!")
+
+	return nil
+}
+
+// LoadSpamModel 加载最近一次训练的垃圾内容分类器；后台重新训练后，重新加载一次
+func LoadSpamModel() error {
+	spamModel := &model.SpamModel{}
+	_, err := db.MasterDB.Desc("id").Get(spamModel)
+	if err != nil {
+		logger.Error("LoadSpamModel Get fail:%+v", err)
+		return err
+	}
+
+	if spamModel.Id == 0 {
+		return nil
+	}
+
+	classifier := util.NewNaiveBayes()
+	if err = json.Unmarshal([]byte(spamModel.Data), classifier); err != nil {
+		logger.Error("LoadSpamModel Unmarshal fail:%+v", err)
+		return err
+	}
+
+	spamLocker.Lock()
+	defer spamLocker.Unlock()
+
+	curSpamClassifier = classifier
+
+	logger.Info("LoadSpamModel successfully!")
+
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+
+	"golang.org/x/net/context"
+)
+
+type DraftLogic struct{}
+
+var DefaultDraft = DraftLogic{}
+
+// 支持草稿的类型
+var draftObjtypes = map[int]bool{
+	model.TypeTopic:   true,
+	model.TypeArticle: true,
+}
+
+// Save 自动保存草稿。id 为 0 时新建，返回草稿 id
+func (DraftLogic) Save(ctx context.Context, me *model.Me, id, objtype int, title, content, extra string) (int, error) {
+	if !draftObjtypes[objtype] {
+		return 0, errors.New("不支持该类型的草稿")
+	}
+	if utf8.RuneCountInString(title) > 255 {
+		return 0, errors.New("草稿标题太长")
+	}
+	if len(content) > model.MaxDraftSize || len(extra) > 1024 {
+		return 0, errors.New("草稿内容太长，不能自动保存")
+	}
+
+	draft := &model.Draft{
+		Uid:     me.Uid,
+		Objtype: objtype,
+		Title:   title,
+		Content: content,
+		Extra:   extra,
+	}
+
+	if id > 0 {
+		affected, err := db.MasterDB.Where("id=? AND uid=?", id, me.Uid).Cols("title", "content", "extra").Update(draft)
+		if err != nil {
+			logger.Error("DraftLogic Save update error:", err)
+			return 0, errors.New("服务内部错误")
+		}
+		if affected > 0 {
+			return id, nil
+		}
+
+		// 内容没变化时 affected 也为 0，确认草稿是否还在（可能已发布或被删除）
+		exists, err := db.MasterDB.Where("id=? AND uid=?", id, me.Uid).Exist(new(model.Draft))
+		if err != nil {
+			logger.Error("DraftLogic Save exist error:", err)
+			return 0, errors.New("服务内部错误")
+		}
+		if exists {
+			return id, nil
+		}
+		// 已发布或被删除的草稿不再重新创建，避免发布后编辑页还开着时又生成一份草稿
+		return 0, errors.New("草稿不存在，可能已发布或被删除")
+	}
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	// 锁住用户，保证并发保存时草稿数不会超过上限
+	_, err := session.Where("uid=?", me.Uid).Cols("uid").ForUpdate().Get(new(model.User))
+	if err != nil {
+		session.Rollback()
+		logger.Error("DraftLogic Save lock user error:", err)
+		return 0, errors.New("服务内部错误")
+	}
+
+	total, err := session.Where("uid=?", me.Uid).Count(new(model.Draft))
+	if err != nil {
+		session.Rollback()
+		logger.Error("DraftLogic Save count error:", err)
+		return 0, errors.New("服务内部错误")
+	}
+	if total >= model.MaxDraftNum {
+		session.Rollback()
+		return 0, errors.New("草稿太多了，请先清理一些草稿")
+	}
+
+	if _, err = session.Insert(draft); err != nil {
+		session.Rollback()
+		logger.Error("DraftLogic Save insert error:", err)
+		return 0, errors.New("服务内部错误")
+	}
+
+	session.Commit()
+
+	return draft.Id, nil
+}
+
+// FindOne 获取自己的一个草稿
+func (DraftLogic) FindOne(ctx context.Context, me *model.Me, id int) *model.Draft {
+	draft := &model.Draft{}
+	_, err := db.MasterDB.Where("id=? AND uid=?", id, me.Uid).Get(draft)
+	if err != nil {
+		logger.Error("DraftLogic FindOne error:", err)
+		return nil
+	}
+	if draft.Id == 0 {
+		return nil
+	}
+	return draft
+}
+
+// FindAll 我的草稿，最近编辑的在前
+func (DraftLogic) FindAll(ctx context.Context, me *model.Me) []*model.Draft {
+	drafts := make([]*model.Draft, 0)
+	err := db.MasterDB.Where("uid=?", me.Uid).Desc("updated_at").Find(&drafts)
+	if err != nil {
+		logger.Error("DraftLogic FindAll error:", err)
+		return nil
+	}
+	return drafts
+}
+
+// Delete 删除自己的草稿
+func (DraftLogic) Delete(ctx context.Context, uid, id int) error {
+	if id == 0 {
+		return nil
+	}
+
+	_, err := db.MasterDB.Where("id=? AND uid=?", id, uid).Delete(new(model.Draft))
+	if err != nil {
+		logger.Error("DraftLogic Delete error:", err)
+	}
+	return err
+}
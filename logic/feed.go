@@ -162,3 +162,41 @@ func (self FeedLogic) modifyTopicNode(tid, nid int) {
 			Update(change)
 	}()
 }
+
+// setState 上线或下线某个对象的动态，state 为 0 表示上线
+func (FeedLogic) setState(objid, objtype, state int) {
+	_, err := db.MasterDB.Table(new(model.Feed)).Where("objid=? AND objtype=?", objid, objtype).
+		Update(map[string]interface{}{"state": state})
+	if err != nil {
+		logger.Error("FeedLogic setState error:", err)
+	}
+}
+
+// online 上线对象的动态，不在首页展示的节点下的主题，动态本来就是下线的。
+// 受处罚用户的内容审核通过前没有发布动态，这时补发
+func (self FeedLogic) online(objid, objtype int) {
+	total, err := db.MasterDB.Where("objid=? AND objtype=?", objid, objtype).Count(new(model.Feed))
+	if err != nil {
+		logger.Error("FeedLogic online count error:", err)
+		return
+	}
+	if total == 0 {
+		switch objtype {
+		case model.TypeTopic:
+			self.publish(DefaultTopic.findByTid(objid), nil)
+		case model.TypeResource:
+			self.publish(DefaultResource.findById(objid), nil)
+		}
+		return
+	}
+
+	state := 0
+	if objtype == model.TypeTopic {
+		topic := DefaultTopic.findByTid(objid)
+		if !DefaultNode.FindOne(topic.Nid).ShowIndex {
+			state = model.FeedOffline
+		}
+	}
+
+	self.setState(objid, objtype, state)
+}
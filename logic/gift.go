@@ -8,8 +8,13 @@ package logic
 
 import (
 	"context"
+	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"sander/db"
@@ -111,66 +116,108 @@ func (self GiftLogic) UserCanExchange(ctx context.Context, me *model.Me, gifts [
 }
 
 func (self GiftLogic) exchangeRedeem(gift *model.Gift, me *model.Me) error {
-	giftRedeem := &model.GiftRedeem{}
-	_, err := db.MasterDB.Where("gift_id=? AND exchange=0", gift.Id).Get(giftRedeem)
-	if err != nil {
-		return err
-	}
+	return self.doExchange(gift, me, func(session *xorm.Session) (string, error) {
+		// gift 行已被锁，这里取到的兑换码不会被其他请求同时取到
+		giftRedeem := &model.GiftRedeem{}
+		_, err := session.Where("gift_id=? AND exchange=0", gift.Id).ForUpdate().Get(giftRedeem)
+		if err != nil {
+			return "", err
+		}
 
-	if giftRedeem.Id == 0 {
-		return errors.New("no more gift redeem")
-	}
+		if giftRedeem.Id == 0 {
+			return "", errors.New("已兑完")
+		}
 
-	return self.doExchange(gift, me, "兑换码："+giftRedeem.Code, func(session *xorm.Session) error {
-		_, err := session.Table(giftRedeem).Where("id=? AND exchange=0", giftRedeem.Id).
+		_, err = session.Table(giftRedeem).Where("id=? AND exchange=0", giftRedeem.Id).
 			Update(map[string]interface{}{"exchange": 1, "uid": me.Uid})
 
-		return err
+		return "兑换码：" + giftRedeem.Code, err
 	})
 }
 
 func (self GiftLogic) exchangeDiscount(gift *model.Gift, me *model.Me) error {
-	return self.doExchange(gift, me, "已兑换，我们会尽快联系合作方处理", nil)
+	return self.doExchange(gift, me, func(session *xorm.Session) (string, error) {
+		return "已兑换，我们会尽快联系合作方处理", nil
+	})
 }
 
-func (self GiftLogic) doExchange(gift *model.Gift, me *model.Me, remark string, moreOp func(session *xorm.Session) error) error {
-	if me.Balance < gift.Price {
-		return errors.New("兑换失败：铜币不够！")
-	}
-
+// doExchange 在事务中完成兑换：锁住物品行后再检查库存和限购，保证并发时不超卖、不超限
+func (self GiftLogic) doExchange(gift *model.Gift, me *model.Me, moreOp func(session *xorm.Session) (string, error)) error {
 	session := db.MasterDB.NewSession()
 	defer session.Close()
 
 	session.Begin()
 
+	lockedGift := &model.Gift{}
+	_, err := session.Id(gift.Id).ForUpdate().Get(lockedGift)
+	if err != nil {
+		session.Rollback()
+		logger.Error("GiftLogic doExchange lock gift error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	if lockedGift.State != model.GiftStateOnline || lockedGift.ExpireTime.Before(time.Now()) {
+		session.Rollback()
+		return errors.New("该物品已下线")
+	}
+
+	if lockedGift.RemainNum <= 0 {
+		session.Rollback()
+		return errors.New("已兑完")
+	}
+
+	total, err := session.Where("gift_id=? AND uid=?", gift.Id, me.Uid).Count(new(model.UserExchangeRecord))
+	if err != nil {
+		session.Rollback()
+		logger.Error("GiftLogic doExchange count UserExchangeRecord error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	if lockedGift.BuyLimit <= int(total) {
+		session.Rollback()
+		return errors.New("已兑换过")
+	}
+
+	user := &model.User{}
+	_, err = session.Where("uid=?", me.Uid).ForUpdate().Get(user)
+	if err != nil {
+		session.Rollback()
+		logger.Error("GiftLogic doExchange lock user error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	if user.Balance < lockedGift.Price {
+		session.Rollback()
+		return errors.New("兑换失败：铜币不够！")
+	}
+	me.Balance = user.Balance
+
+	remark, err := moreOp(session)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
 	exchangeRecord := &model.UserExchangeRecord{
 		GiftId:     gift.Id,
 		Uid:        me.Uid,
 		Remark:     remark,
-		ExpireTime: gift.ExpireTime,
+		ExpireTime: lockedGift.ExpireTime,
 	}
-	_, err := db.MasterDB.Insert(exchangeRecord)
+	_, err = session.Insert(exchangeRecord)
 	if err != nil {
 		session.Rollback()
 		return err
 	}
 
-	if moreOp != nil {
-		err = moreOp(session)
-		if err != nil {
-			session.Rollback()
-			return err
-		}
-	}
-
 	_, err = session.Id(gift.Id).Decr("remain_num", 1).Update(new(model.Gift))
 	if err != nil {
 		session.Rollback()
 		return err
 	}
 
-	desc := fmt.Sprintf("兑换 %s 消费 %d 铜币", gift.Name, gift.Price)
-	err = DefaultMission.changeUserBalance(session, me, model.MissionTypeGift, -gift.Price, desc)
+	desc := fmt.Sprintf("兑换 %s 消费 %d 铜币", lockedGift.Name, lockedGift.Price)
+	err = DefaultMission.changeUserBalance(session, me, model.MissionTypeGift, -lockedGift.Price, desc)
 	if err != nil {
 		session.Rollback()
 		return err
@@ -182,3 +229,337 @@ func (self GiftLogic) doExchange(gift *model.Gift, me *model.Me, remark string,
 func (self GiftLogic) doExpire(gift *model.Gift) {
 	db.MasterDB.Table(gift).Where("id=?", gift.Id).Update(map[string]interface{}{"state": gift.State})
 }
+
+// FindGiftsByPage 获取物品列表（分页）：后台用
+func (GiftLogic) FindGiftsByPage(ctx context.Context, conds map[string]string, curPage, limit int) ([]*model.Gift, int) {
+	session := db.MasterDB.NewSession()
+
+	for k, v := range conds {
+		session.And(k+"=?", v)
+	}
+
+	totalSession := session.Clone()
+
+	offset := (curPage - 1) * limit
+	giftList := make([]*model.Gift, 0)
+	err := session.OrderBy("id DESC").Limit(limit, offset).Find(&giftList)
+	if err != nil {
+		logger.Error("GiftLogic FindGiftsByPage error:", err)
+		return nil, 0
+	}
+
+	total, err := totalSession.Count(new(model.Gift))
+	if err != nil {
+		logger.Error("GiftLogic FindGiftsByPage count error:", err)
+		return nil, 0
+	}
+
+	return giftList, int(total)
+}
+
+// FindById 获取单个物品
+func (GiftLogic) FindById(ctx context.Context, id int) *model.Gift {
+	gift := &model.Gift{}
+	_, err := db.MasterDB.Id(id).Get(gift)
+	if err != nil {
+		logger.Error("GiftLogic FindById error:", err)
+		return nil
+	}
+
+	if gift.Id == 0 {
+		return nil
+	}
+
+	return gift
+}
+
+// Save 新建或修改物品：后台用。库存通过补货或导入兑换码修改，这里不允许直接改
+func (GiftLogic) Save(ctx context.Context, form url.Values) (errMsg string, err error) {
+	expireTime := form.Get("expire_time")
+	form.Del("expire_time")
+
+	gift := &model.Gift{}
+	err = schemaDecoder.Decode(gift, form)
+	if err != nil {
+		logger.Error("GiftLogic Save decode error:", err)
+		errMsg = err.Error()
+		return
+	}
+
+	if _, ok := model.GiftTypeMap[gift.Typ]; !ok {
+		errMsg = "物品类型不正确"
+		err = errors.New(errMsg)
+		return
+	}
+
+	gift.ExpireTime = parseMissionTime(expireTime)
+	if gift.ExpireTime.IsZero() {
+		errMsg = "有效期不正确"
+		err = errors.New(errMsg)
+		return
+	}
+
+	if gift.Id != 0 {
+		_, err = db.MasterDB.Id(gift.Id).AllCols().Omit("total_num", "remain_num", "state", "created_at").Update(gift)
+	} else {
+		// 兑换码类物品的库存由导入的兑换码决定
+		if gift.Typ == model.GiftTypRedeem {
+			gift.TotalNum = 0
+		}
+		gift.RemainNum = gift.TotalNum
+		gift.State = model.GiftStateNew
+		_, err = db.MasterDB.Insert(gift)
+	}
+
+	if err != nil {
+		errMsg = "内部服务器错误"
+		logger.Error("GiftLogic Save error:", err)
+		return
+	}
+
+	return
+}
+
+// ChangeState 上下线物品：后台用
+func (GiftLogic) ChangeState(ctx context.Context, id, state int) error {
+	if _, ok := model.GiftStateMap[state]; !ok {
+		return errors.New("状态不正确")
+	}
+
+	_, err := db.MasterDB.Table(new(model.Gift)).Id(id).Update(map[string]interface{}{"state": state})
+	if err != nil {
+		logger.Error("GiftLogic ChangeState error:", err)
+	}
+	return err
+}
+
+// Restock 折扣类物品补货：后台用
+func (self GiftLogic) Restock(ctx context.Context, id, num int) error {
+	gift := self.FindById(ctx, id)
+	if gift == nil {
+		return NotFoundErr
+	}
+
+	if gift.Typ == model.GiftTypRedeem {
+		return errors.New("兑换码类物品请通过导入兑换码补货")
+	}
+
+	if num <= 0 {
+		return errors.New("补货数量必须大于 0")
+	}
+
+	_, err := db.MasterDB.Id(id).Incr("total_num", num).Incr("remain_num", num).Update(new(model.Gift))
+	if err != nil {
+		logger.Error("GiftLogic Restock error:", err)
+	}
+	return err
+}
+
+// ImportRedeems 从 CSV 批量导入兑换码（每行第一列为兑换码），同时增加库存：后台用
+func (GiftLogic) ImportRedeems(ctx context.Context, giftId int, reader io.Reader) (int, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return 0, errors.New("CSV 文件格式不正确：" + err.Error())
+	}
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+
+	session.Begin()
+
+	gift := &model.Gift{}
+	_, err = session.Id(giftId).ForUpdate().Get(gift)
+	if err != nil || gift.Id == 0 {
+		session.Rollback()
+		return 0, NotFoundErr
+	}
+
+	if gift.Typ != model.GiftTypRedeem {
+		session.Rollback()
+		return 0, errors.New("该物品不是兑换码类型")
+	}
+
+	existRedeems := make([]*model.GiftRedeem, 0)
+	err = session.Where("gift_id=?", giftId).Cols("code").Find(&existRedeems)
+	if err != nil {
+		session.Rollback()
+		logger.Error("GiftLogic ImportRedeems find exist error:", err)
+		return 0, err
+	}
+
+	codeSet := make(map[string]struct{}, len(existRedeems)+len(records))
+	for _, redeem := range existRedeems {
+		codeSet[redeem.Code] = struct{}{}
+	}
+
+	giftRedeems := make([]*model.GiftRedeem, 0, len(records))
+	for i, record := range records {
+		if len(record) == 0 {
+			continue
+		}
+
+		code := strings.TrimSpace(strings.TrimPrefix(record[0], "\xef\xbb\xbf"))
+		if code == "" || (i == 0 && (strings.EqualFold(code, "code") || code == "兑换码")) {
+			continue
+		}
+
+		if len(code) > 15 {
+			session.Rollback()
+			return 0, fmt.Errorf("第 %d 行兑换码过长（最多 15 个字符）", i+1)
+		}
+
+		if _, ok := codeSet[code]; ok {
+			continue
+		}
+		codeSet[code] = struct{}{}
+
+		giftRedeems = append(giftRedeems, &model.GiftRedeem{GiftId: giftId, Code: code})
+	}
+
+	num := len(giftRedeems)
+	if num == 0 {
+		session.Rollback()
+		return 0, errors.New("没有可导入的新兑换码")
+	}
+
+	_, err = session.Insert(&giftRedeems)
+	if err != nil {
+		session.Rollback()
+		logger.Error("GiftLogic ImportRedeems insert error:", err)
+		return 0, err
+	}
+
+	_, err = session.Id(giftId).Incr("total_num", num).Incr("remain_num", num).Update(new(model.Gift))
+	if err != nil {
+		session.Rollback()
+		logger.Error("GiftLogic ImportRedeems incr stock error:", err)
+		return 0, err
+	}
+
+	return num, session.Commit()
+}
+
+// RedeemStat 兑换码的总数和已兑换数：后台用
+func (GiftLogic) RedeemStat(ctx context.Context, giftId int) (total, exchanged int64) {
+	var err error
+	total, err = db.MasterDB.Where("gift_id=?", giftId).Count(new(model.GiftRedeem))
+	if err != nil {
+		logger.Error("GiftLogic RedeemStat error:", err)
+	}
+	exchanged, err = db.MasterDB.Where("gift_id=? AND exchange=1", giftId).Count(new(model.GiftRedeem))
+	if err != nil {
+		logger.Error("GiftLogic RedeemStat error:", err)
+	}
+	return
+}
+
+// FindExchangeRecordsByPage 获取兑换记录（分页），同时返回相关的物品和用户：后台用
+func (self GiftLogic) FindExchangeRecordsByPage(ctx context.Context, conds map[string]string, curPage, limit int) ([]map[string]interface{}, int) {
+	session := db.MasterDB.NewSession()
+
+	for k, v := range conds {
+		session.And(k+"=?", v)
+	}
+
+	totalSession := session.Clone()
+
+	offset := (curPage - 1) * limit
+	records := make([]*model.UserExchangeRecord, 0)
+	err := session.OrderBy("id DESC").Limit(limit, offset).Find(&records)
+	if err != nil {
+		logger.Error("GiftLogic FindExchangeRecordsByPage error:", err)
+		return nil, 0
+	}
+
+	total, err := totalSession.Count(new(model.UserExchangeRecord))
+	if err != nil {
+		logger.Error("GiftLogic FindExchangeRecordsByPage count error:", err)
+		return nil, 0
+	}
+
+	return self.fillExchangeRecords(ctx, records), int(total)
+}
+
+// ExportExchangeRecords 导出兑换记录为 CSV，供发货、对账用；giftId 为 0 表示导出全部
+func (self GiftLogic) ExportExchangeRecords(ctx context.Context, giftId int, writer io.Writer) error {
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+
+	if giftId != 0 {
+		session.Where("gift_id=?", giftId)
+	}
+
+	records := make([]*model.UserExchangeRecord, 0)
+	err := session.OrderBy("id ASC").Find(&records)
+	if err != nil {
+		logger.Error("GiftLogic ExportExchangeRecords error:", err)
+		return err
+	}
+
+	// 加 BOM，避免 Excel 打开中文乱码
+	io.WriteString(writer, "\xef\xbb\xbf")
+
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Write([]string{"ID", "物品", "供应商", "UID", "用户名", "Email", "兑换说明", "兑换时间", "过期时间"})
+	for _, data := range self.fillExchangeRecords(ctx, records) {
+		record := data["record"].(*model.UserExchangeRecord)
+		gift := data["gift"].(*model.Gift)
+		user := data["user"].(*model.User)
+		csvWriter.Write([]string{
+			strconv.Itoa(record.Id),
+			gift.Name,
+			gift.Supplier,
+			strconv.Itoa(record.Uid),
+			user.Username,
+			user.Email,
+			record.Remark,
+			record.CreatedAt.String(),
+			record.ExpireTime.Format("2006-01-02 15:04:05"),
+		})
+	}
+	csvWriter.Flush()
+
+	return csvWriter.Error()
+}
+
+func (GiftLogic) fillExchangeRecords(ctx context.Context, records []*model.UserExchangeRecord) []map[string]interface{} {
+	giftIds := make([]int, 0, len(records))
+	uids := make([]int, 0, len(records))
+	for _, record := range records {
+		giftIds = append(giftIds, record.GiftId)
+		uids = append(uids, record.Uid)
+	}
+
+	giftMap := make(map[int]*model.Gift)
+	if len(giftIds) > 0 {
+		err := db.MasterDB.In("id", giftIds).Find(&giftMap)
+		if err != nil {
+			logger.Error("GiftLogic fillExchangeRecords find gifts error:", err)
+		}
+	}
+	userMap := DefaultUser.FindUserInfos(ctx, uids)
+
+	result := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		gift, ok := giftMap[record.GiftId]
+		if !ok {
+			gift = &model.Gift{}
+		}
+		user, ok := userMap[record.Uid]
+		if !ok {
+			user = &model.User{}
+		}
+
+		result[i] = map[string]interface{}{
+			"record": record,
+			"gift":   gift,
+			"user":   user,
+		}
+	}
+
+	return result
+}
@@ -77,33 +77,37 @@ func (LikeLogic) LikeObject(ctx context.Context, uid, objid, objtype, likeFlag i
 		return err
 	}
 
-	// 之前喜欢过
+	// 之前喜欢过（或喜欢后又取消了）。取消时保留记录只改 flag，
+	// 这样反复喜欢、取消不会重复通知观察者（任务进度等）
 	if like.Uid != 0 {
-		// 再喜欢直接返回
-		if likeFlag == model.FlagLike {
+		if like.Flag == likeFlag || (likeFlag != model.FlagLike && likeFlag != model.FlagCancel) {
 			return nil
 		}
 
-		// 取消喜欢
-		if likeFlag == model.FlagCancel {
-			// db.MasterDB.Where("uid=? AND objid=? AND objtype=?", uid, objid,objtype).Delete(like)
-			_, err = db.MasterDB.Delete(like)
-			if err != nil {
-				logger.Error("LikeLogic LikeObject delete error:", err)
-				return err
-			}
+		like.Flag = likeFlag
+		_, err = db.MasterDB.Where("uid=? AND objid=? AND objtype=?", uid, objid, objtype).Cols("flag").Update(like)
+		if err != nil {
+			logger.Error("LikeLogic LikeObject update flag error:", err)
+			return err
+		}
 
-			// 取消喜欢成功，更新对象的喜欢数
-			if liker, ok := likers[objtype]; ok {
+		// 更新对象的喜欢数
+		if liker, ok := likers[objtype]; ok {
+			if likeFlag == model.FlagLike {
+				go liker.UpdateLike(objid, 1)
+			} else {
 				go liker.UpdateLike(objid, -1)
 			}
-
-			return nil
 		}
 
 		return nil
 	}
 
+	// 没喜欢过，取消无意义
+	if likeFlag != model.FlagLike {
+		return nil
+	}
+
 	like.Uid = uid
 	like.Objid = objid
 	like.Objtype = objtype
@@ -120,6 +124,8 @@ func (LikeLogic) LikeObject(ctx context.Context, uid, objid, objtype, likeFlag i
 		if liker, ok := likers[objtype]; ok {
 			go liker.UpdateLike(objid, 1)
 		}
+
+		go likeObservable.NotifyObservers(uid, objtype, objid)
 	}
 
 	// TODO: 给被喜欢对象所有者发系统消息
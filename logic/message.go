@@ -197,6 +197,7 @@ func (self MessageLogic) FindSysMsgsByUid(ctx context.Context, uid int, paginato
 	uidSet := set.New(set.NonThreadSafe)
 	// subject id
 	sidSet := set.New(set.NonThreadSafe)
+	badgeIdSet := set.New(set.NonThreadSafe)
 
 	ids := make([]int, 0, len(messages))
 	for _, message := range messages {
@@ -238,6 +239,12 @@ func (self MessageLogic) FindSysMsgsByUid(ctx context.Context, uid int, paginato
 		case model.MsgtypeSubjectContribute:
 			articleIdSet.Add(objid)
 			sidSet.Add(int(ext["sid"].(float64)))
+		case model.MsgtypeBadge:
+			badgeIdSet.Add(objid)
+			// 徽章消息展示的是用户自己
+			uidSet.Add(uid)
+		case model.MsgtypeReport:
+			uidSet.Add(uid)
 		}
 		if val, ok := ext["cid"]; ok {
 			cidSet.Add(int(val.(float64)))
@@ -258,6 +265,7 @@ func (self MessageLogic) FindSysMsgsByUid(ctx context.Context, uid int, paginato
 	projectMap := DefaultProject.findByIds(set.IntSlice(pidSet))
 	bookMap := DefaultGoBook.findByIds(set.IntSlice(bookIdSet))
 	subjectMap := DefaultSubject.findByIds(set.IntSlice(sidSet))
+	badgeMap := DefaultBadge.findByIds(set.IntSlice(badgeIdSet))
 
 	result := make([]map[string]interface{}, len(messages))
 	for i, message := range messages {
@@ -383,6 +391,18 @@ func (self MessageLogic) FindSysMsgsByUid(ctx context.Context, uid int, paginato
 				tmpMap["sprefix"] = "的专栏"
 				tmpMap["surl"] = "/subject/" + strconv.Itoa(subject.Id)
 				tmpMap["stitle"] = subject.Name
+			case model.MsgtypeBadge:
+				if badge, ok := badgeMap[objid]; ok {
+					objTitle = badge.Name
+				}
+				if user, ok := userMap[uid]; ok {
+					objUrl = "/user/" + user.Username
+				}
+				title = "获得了徽章："
+			case model.MsgtypeReport:
+				objTitle, _ = ext["objtitle"].(string)
+				objUrl, _ = ext["objurl"].(string)
+				title = "举报的内容已处理："
 			}
 			tmpMap["objtitle"] = objTitle
 			tmpMap["objurl"] = objUrl
@@ -394,6 +414,8 @@ func (self MessageLogic) FindSysMsgsByUid(ctx context.Context, uid int, paginato
 		tmpMap["hasread"] = message.Hasread
 		if val, ok := ext["uid"]; ok {
 			tmpMap["user"] = userMap[int(val.(float64))]
+		} else if message.Msgtype == model.MsgtypeBadge || message.Msgtype == model.MsgtypeReport {
+			tmpMap["user"] = userMap[uid]
 		}
 		// content 和 cid不会同时存在
 		if val, ok := ext["content"]; ok {
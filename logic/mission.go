@@ -9,12 +9,14 @@ package logic
 import (
 	"errors"
 	"fmt"
-	"math"
-	"math/rand"
+	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	"sander/db"
+	"sander/db/nosql"
+	"sander/global"
 	"sander/logger"
 	"sander/model"
 
@@ -73,11 +75,12 @@ func (self MissionLogic) RedeemLoginAward(ctx context.Context, me *model.Me) err
 	defer session.Close()
 	session.Begin()
 
+	now := time.Now()
 	if userLoginMission.Uid == 0 {
-		userLoginMission.Date = goutils.MustInt(time.Now().Format("20060102"))
+		userLoginMission.Date = today(now)
 		userLoginMission.Days = 1
 		userLoginMission.TotalDays = 1
-		userLoginMission.Award = mission.Min
+		userLoginMission.Award = loginAward(mission, 1)
 		userLoginMission.Uid = me.Uid
 
 		_, err := session.Insert(userLoginMission)
@@ -88,29 +91,21 @@ func (self MissionLogic) RedeemLoginAward(ctx context.Context, me *model.Me) err
 		}
 
 	} else {
-		today := goutils.MustInt(times.Format("Ymd"))
-		if today == userLoginMission.Date {
+		if today(now) == userLoginMission.Date {
 			session.Rollback()
 			return errors.New("今日已领取")
 		}
-		// 昨日是否领取了
-		yesterday := goutils.MustInt(times.Format("Ymd", time.Now().Add(-86400*time.Second)))
-		if yesterday != userLoginMission.Date {
-			userLoginMission.Award = mission.Min
+		// 昨日是否领取了，断签后连续天数重新计算
+		if yesterday(now) != userLoginMission.Date {
 			userLoginMission.Days = 1
 		} else {
 			userLoginMission.Days++
-			if userLoginMission.Award == mission.Max {
-				userLoginMission.Award = mission.Min
-			} else {
-				award := userLoginMission.Award + rand.Intn(mission.Incr) + 1
-				userLoginMission.Award = int(math.Min(float64(award), float64(mission.Max)))
-			}
 		}
 
-		userLoginMission.Date = today
+		userLoginMission.Award = loginAward(mission, userLoginMission.Days)
+		userLoginMission.Date = today(now)
 		userLoginMission.TotalDays++
-		userLoginMission.UpdatedAt = time.Now()
+		userLoginMission.UpdatedAt = now
 
 		_, err := session.Where("uid=?", userLoginMission.Uid).Update(userLoginMission)
 		if err != nil {
@@ -120,8 +115,130 @@ func (self MissionLogic) RedeemLoginAward(ctx context.Context, me *model.Me) err
 		}
 	}
 
-	desc := times.Format("Ymd") + " 的每日登录奖励 " + strconv.Itoa(userLoginMission.Award) + " 铜币"
-	err := self.changeUserBalance(session, me, model.MissionTypeLogin, userLoginMission.Award, desc)
+	loginRecord := &model.UserLoginRecord{
+		Uid:   me.Uid,
+		Date:  userLoginMission.Date,
+		Days:  userLoginMission.Days,
+		Award: userLoginMission.Award,
+	}
+	_, err := session.Insert(loginRecord)
+	if err != nil {
+		session.Rollback()
+		logger.Error("insert user_login_record error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	desc := fmt.Sprintf("%s 的每日登录奖励 %d 铜币（连续 %d 天）", times.Format("Ymd"), userLoginMission.Award, userLoginMission.Days)
+	err = self.changeUserBalance(session, me, model.MissionTypeLogin, userLoginMission.Award, desc)
+	if err != nil {
+		session.Rollback()
+		logger.Error("changeUserBalance error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	session.Commit()
+
+	return nil
+}
+
+// MakeupLogin 花费铜币购买补签卡，补签昨天漏签的一天，使连续天数得以延续。
+// 只有前天签到过、昨天漏签时才能补签
+func (self MissionLogic) MakeupLogin(ctx context.Context, me *model.Me) error {
+	now := time.Now()
+	missDate := yesterday(now)
+	prevDate := goutils.MustInt(now.AddDate(0, 0, -2).Format("20060102"))
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	userLoginMission := &model.UserLoginMission{}
+	_, err := session.Where("uid=?", me.Uid).ForUpdate().Get(userLoginMission)
+	if err != nil {
+		session.Rollback()
+		logger.Error("MissionLogic MakeupLogin lock user_login_mission error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	records := make([]*model.UserLoginRecord, 0)
+	err = session.Where("uid=?", me.Uid).In("date", []int{prevDate, missDate, today(now)}).Find(&records)
+	if err != nil {
+		session.Rollback()
+		logger.Error("MissionLogic MakeupLogin find user_login_record error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	var prevRecord, todayRecord *model.UserLoginRecord
+	for _, record := range records {
+		switch record.Date {
+		case missDate:
+			session.Rollback()
+			return errors.New("昨天已签到，无需补签")
+		case prevDate:
+			prevRecord = record
+		default:
+			todayRecord = record
+		}
+	}
+
+	if prevRecord == nil {
+		session.Rollback()
+		return errors.New("只能补签连续登录中漏掉的昨天一天")
+	}
+
+	user := &model.User{}
+	_, err = session.Where("uid=?", me.Uid).ForUpdate().Get(user)
+	if err != nil {
+		session.Rollback()
+		logger.Error("MissionLogic MakeupLogin lock user error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	if user.Balance < model.LoginMakeupPrice {
+		session.Rollback()
+		return errors.New("补签失败：铜币不够！")
+	}
+	me.Balance = user.Balance
+
+	makeupRecord := &model.UserLoginRecord{
+		Uid:    me.Uid,
+		Date:   missDate,
+		Days:   prevRecord.Days + 1,
+		Makeup: true,
+	}
+	_, err = session.Insert(makeupRecord)
+	if err != nil {
+		session.Rollback()
+		logger.Error("MissionLogic MakeupLogin insert user_login_record error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	// 今天已领取时，今天的连续天数一并接上
+	if todayRecord != nil {
+		todayRecord.Days = makeupRecord.Days + 1
+		_, err = session.Id(todayRecord.Id).Cols("days").Update(todayRecord)
+		if err != nil {
+			session.Rollback()
+			logger.Error("MissionLogic MakeupLogin update user_login_record error:", err)
+			return errors.New("服务内部错误")
+		}
+
+		userLoginMission.Days = todayRecord.Days
+	} else {
+		userLoginMission.Date = missDate
+		userLoginMission.Days = makeupRecord.Days
+	}
+	userLoginMission.TotalDays++
+
+	_, err = session.Where("uid=?", me.Uid).Cols("date", "days", "total_days").Update(userLoginMission)
+	if err != nil {
+		session.Rollback()
+		logger.Error("MissionLogic MakeupLogin update user_login_mission error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	desc := fmt.Sprintf("补签 %d，花费 %d 铜币", missDate, model.LoginMakeupPrice)
+	err = self.changeUserBalance(session, me, model.MissionTypeMakeup, -model.LoginMakeupPrice, desc)
 	if err != nil {
 		session.Rollback()
 		logger.Error("changeUserBalance error:", err)
@@ -133,6 +250,112 @@ func (self MissionLogic) RedeemLoginAward(ctx context.Context, me *model.Me) err
 	return nil
 }
 
+// FindLoginInfo 每日登录领取的连续天数、下次奖励、能否补签及某月的签到日历。
+// month 格式为 200601，为 0 表示当月
+func (self MissionLogic) FindLoginInfo(ctx context.Context, me *model.Me, month int) map[string]interface{} {
+	now := time.Now()
+
+	userLoginMission := self.FindLoginMission(ctx, me)
+	if userLoginMission == nil {
+		return nil
+	}
+
+	hadRedeem := userLoginMission.Date == today(now)
+
+	// 今天和昨天都没有领取，连续天数已中断
+	streak := userLoginMission.Days
+	if !hadRedeem && userLoginMission.Date != yesterday(now) {
+		streak = 0
+	}
+
+	nextAward := 0
+	if !hadRedeem {
+		nextAward = loginAward(self.findMission(ctx, model.MissionTypeLogin), streak+1)
+	}
+
+	data := map[string]interface{}{
+		"streak":       streak,
+		"total_days":   userLoginMission.TotalDays,
+		"had_redeem":   hadRedeem,
+		"next_award":   nextAward,
+		"makeup_price": model.LoginMakeupPrice,
+		"can_makeup":   self.canMakeup(me, now),
+	}
+
+	monthTime, err := time.ParseInLocation("200601", strconv.Itoa(month), time.Local)
+	if err != nil {
+		monthTime = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	for k, v := range self.findLoginCalendar(me, monthTime, now) {
+		data[k] = v
+	}
+
+	return data
+}
+
+// canMakeup 昨天漏签且前天签到过
+func (MissionLogic) canMakeup(me *model.Me, now time.Time) bool {
+	prevDate := goutils.MustInt(now.AddDate(0, 0, -2).Format("20060102"))
+
+	records := make([]*model.UserLoginRecord, 0)
+	err := db.MasterDB.Where("uid=?", me.Uid).In("date", []int{prevDate, yesterday(now)}).Find(&records)
+	if err != nil {
+		logger.Error("MissionLogic canMakeup error:", err)
+		return false
+	}
+
+	return len(records) == 1 && records[0].Date == prevDate
+}
+
+// findLoginCalendar 某月的签到日历，blanks 为月初第一天前需要空出的格数（周日开始）
+func (MissionLogic) findLoginCalendar(me *model.Me, monthTime, now time.Time) map[string]interface{} {
+	start := today(monthTime)
+	end := today(monthTime.AddDate(0, 1, -1))
+
+	records := make([]*model.UserLoginRecord, 0)
+	err := db.MasterDB.Where("uid=? AND date BETWEEN ? AND ?", me.Uid, start, end).Find(&records)
+	if err != nil {
+		logger.Error("MissionLogic findLoginCalendar error:", err)
+	}
+
+	recordMap := make(map[int]*model.UserLoginRecord, len(records))
+	for _, record := range records {
+		recordMap[record.Date] = record
+	}
+
+	days := make([]map[string]interface{}, 0, 31)
+	for date := start; date <= end; date++ {
+		record, signed := recordMap[date]
+		days = append(days, map[string]interface{}{
+			"day":    date - start + 1,
+			"signed": signed,
+			"makeup": signed && record.Makeup,
+			"today":  date == today(now),
+		})
+	}
+
+	return map[string]interface{}{
+		"month":      monthTime.Format("200601"),
+		"prev_month": monthTime.AddDate(0, -1, 0).Format("200601"),
+		"next_month": monthTime.AddDate(0, 1, 0).Format("200601"),
+		"blanks":     make([]struct{}, int(monthTime.Weekday())),
+		"days":       days,
+	}
+}
+
+// loginAward 连续登录天数越多奖励越高：第一天为 Min，之后每天多 Incr，最多 Max
+func loginAward(mission *model.Mission, days int) int {
+	if days < 1 {
+		days = 1
+	}
+
+	award := mission.Min + (days-1)*mission.Incr
+	if mission.Max > 0 && award > mission.Max {
+		award = mission.Max
+	}
+	return award
+}
+
 func (MissionLogic) FindLoginMission(ctx context.Context, me *model.Me) *model.UserLoginMission {
 
 	userLoginMission := &model.UserLoginMission{}
@@ -159,6 +382,11 @@ func (MissionLogic) Complete(ctx context.Context, me *model.Me, id string) error
 		return errors.New("任务不存在或已过期")
 	}
 
+	// 规则任务的奖励只能通过完成进度发放
+	if mission.Type == model.MissionTypeRule {
+		return errors.New("任务不存在或已过期")
+	}
+
 	user := DefaultUser.FindOne(ctx, "uid", me.Uid)
 
 	// 初始任务，不允许重复提交
@@ -201,3 +429,435 @@ func (self MissionLogic) changeUserBalance(session *xorm.Session, me *model.Me,
 	}
 	return DefaultUserRich.add(session, balanceDetail)
 }
+
+// FindRuleMissions 获取进行中的规则任务，并填充用户的当前进度
+func (self MissionLogic) FindRuleMissions(ctx context.Context, me *model.Me) []map[string]interface{} {
+	missions := activeRuleMissions("", 0)
+	if len(missions) == 0 {
+		return nil
+	}
+
+	missionIds := make([]int, len(missions))
+	for i, mission := range missions {
+		missionIds[i] = mission.Id
+	}
+
+	userMissions := make([]*model.UserMission, 0)
+	err := db.MasterDB.Where("uid=?", me.Uid).In("mission_id", missionIds).Find(&userMissions)
+	if err != nil {
+		logger.Error("MissionLogic FindRuleMissions error:", err)
+		return nil
+	}
+
+	now := time.Now()
+	data := make([]map[string]interface{}, len(missions))
+	for i, mission := range missions {
+		period := missionPeriod(mission.Period, now)
+
+		var progress, state int
+		for _, userMission := range userMissions {
+			if userMission.MissionId != mission.Id || userMission.Period != period {
+				continue
+			}
+
+			if userMission.State == model.UserMissionStateFinished {
+				progress, state = mission.Target, userMission.State
+				break
+			}
+
+			// 连续型任务，断签后进度作废
+			if mission.Continuous && userMission.LastDate != today(now) && userMission.LastDate != yesterday(now) {
+				continue
+			}
+
+			// 按对象统计时，显示进度最高的那个
+			if userMission.Progress > progress {
+				progress = userMission.Progress
+			}
+		}
+
+		data[i] = map[string]interface{}{
+			"mission":  mission,
+			"progress": progress,
+			"percent":  progress * 100 / mission.Target,
+			"finished": state == model.UserMissionStateFinished,
+		}
+	}
+
+	return data
+}
+
+// Accomplish 用户触发了某个事件，推进相关规则任务的进度，达成时自动发放奖励
+func (self MissionLogic) Accomplish(event string, uid, objtype, objid int) {
+	if uid == 0 {
+		return
+	}
+
+	missions := activeRuleMissions(event, objtype)
+	if len(missions) == 0 {
+		return
+	}
+	// 登录任务每个页面都会触发，每天只推进一次，先过滤掉当天已经处理过的，避免每次都开事务
+	if event == model.MissionEventLogin && !self.firstLoginToday(uid) {
+		return
+	}
+
+	for _, mission := range missions {
+		if err := self.advance(mission, uid, objid); err != nil {
+			logger.Error("MissionLogic Accomplish mission(%d) uid(%d) error:%+v", mission.Id, uid, err)
+		}
+	}
+}
+
+// firstLoginToday 用户今天是否第一次触发登录任务。redis 出错时返回 true，由 advance 中的日期判断兜底
+func (MissionLogic) firstLoginToday(uid int) bool {
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	key := "mission:login:" + strconv.Itoa(today(time.Now())) + ":" + strconv.Itoa(uid)
+	num, err := redisClient.INCR(key)
+	if err != nil {
+		logger.Error("MissionLogic firstLoginToday error:", err)
+		return true
+	}
+	if num == 1 {
+		redisClient.EXPIRE(key, 86400)
+	}
+
+	return num == 1
+}
+
+func (self MissionLogic) advance(mission *model.Mission, uid, objid int) error {
+	now := time.Now()
+	period := missionPeriod(mission.Period, now)
+	if !mission.PerObject {
+		objid = 0
+	}
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	// 本周期已完成（按对象统计时，任一对象完成即算完成）
+	finished, err := session.Where("uid=? AND mission_id=? AND period=? AND state=?",
+		uid, mission.Id, period, model.UserMissionStateFinished).ForUpdate().Exist(new(model.UserMission))
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+	if finished {
+		session.Rollback()
+		return nil
+	}
+
+	userMission := &model.UserMission{}
+	_, err = session.Where("uid=? AND mission_id=? AND period=? AND objid=?", uid, mission.Id, period, objid).
+		ForUpdate().Get(userMission)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	curDate := today(now)
+	if mission.Event == model.MissionEventLogin || mission.Continuous {
+		// 每天只计一次
+		if userMission.LastDate == curDate {
+			session.Rollback()
+			return nil
+		}
+	}
+
+	if mission.Continuous && userMission.LastDate != yesterday(now) {
+		userMission.Progress = 1
+	} else {
+		userMission.Progress++
+	}
+	userMission.LastDate = curDate
+
+	if userMission.Progress >= mission.Target {
+		userMission.State = model.UserMissionStateFinished
+		userMission.FinishedAt = now
+	}
+
+	if userMission.Id == 0 {
+		userMission.Uid = uid
+		userMission.MissionId = mission.Id
+		userMission.Period = period
+		userMission.Objid = objid
+		_, err = session.Insert(userMission)
+	} else {
+		_, err = session.Id(userMission.Id).UseBool().Update(userMission)
+	}
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	session.Commit()
+
+	if userMission.State == model.UserMissionStateFinished && mission.Fixed > 0 {
+		user := DefaultUser.FindOne(nil, "uid", uid)
+		desc := fmt.Sprintf(`完成任务 › <a href="/mission/daily">%s</a>，获得 %d 铜币`, mission.Name, mission.Fixed)
+		DefaultUserRich.IncrUserRich(user, model.MissionTypeRule, mission.Fixed, desc)
+	}
+
+	return nil
+}
+
+// objOwner 获取对象的所有者 uid，未知返回 0
+func (MissionLogic) objOwner(objtype, objid int) int {
+	switch objtype {
+	case model.TypeTopic:
+		return DefaultTopic.getOwner(objid)
+	case model.TypeArticle:
+		article, err := DefaultArticle.FindById(nil, objid)
+		if err != nil || article.Id == 0 || article.Domain != WebsiteSetting.Domain {
+			return 0
+		}
+		return DefaultUser.FindOne(nil, "username", article.Author).Uid
+	case model.TypeResource:
+		return DefaultResource.findById(objid).Uid
+	case model.TypeWiki:
+		if wiki := DefaultWiki.FindById(nil, objid); wiki != nil {
+			return wiki.Uid
+		}
+	case model.TypeProject:
+		if project := DefaultProject.FindOne(nil, objid); project != nil && project.Username != "" {
+			return DefaultUser.FindOne(nil, "username", project.Username).Uid
+		}
+	case model.TypeBook:
+		book, err := DefaultGoBook.FindById(nil, objid)
+		if err == nil {
+			return book.Uid
+		}
+	}
+
+	return 0
+}
+
+// FindMissionsByPage 获取规则任务列表（分页）：后台用
+func (MissionLogic) FindMissionsByPage(ctx context.Context, conds map[string]string, curPage, limit int) ([]*model.Mission, int) {
+	session := db.MasterDB.Where("type=?", model.MissionTypeRule)
+
+	for k, v := range conds {
+		session.And(k+"=?", v)
+	}
+
+	totalSession := session.Clone()
+
+	offset := (curPage - 1) * limit
+	missionList := make([]*model.Mission, 0)
+	err := session.OrderBy("id DESC").Limit(limit, offset).Find(&missionList)
+	if err != nil {
+		logger.Error("MissionLogic FindMissionsByPage error:", err)
+		return nil, 0
+	}
+
+	total, err := totalSession.Count(new(model.Mission))
+	if err != nil {
+		logger.Error("MissionLogic FindMissionsByPage count error:", err)
+		return nil, 0
+	}
+
+	return missionList, int(total)
+}
+
+// FindMissionById 获取单个规则任务：后台用
+func (MissionLogic) FindMissionById(ctx context.Context, id int) *model.Mission {
+	mission := &model.Mission{}
+	_, err := db.MasterDB.Id(id).Get(mission)
+	if err != nil {
+		logger.Error("MissionLogic FindMissionById error:", err)
+		return nil
+	}
+
+	if mission.Id == 0 || mission.Type != model.MissionTypeRule {
+		return nil
+	}
+
+	return mission
+}
+
+// SaveMission 新建或修改规则任务：后台用
+func (MissionLogic) SaveMission(ctx context.Context, form url.Values) (errMsg string, err error) {
+	startAt, endAt := form.Get("start_at"), form.Get("end_at")
+	form.Del("start_at")
+	form.Del("end_at")
+
+	mission := &model.Mission{}
+	err = schemaDecoder.Decode(mission, form)
+	if err != nil {
+		logger.Error("MissionLogic SaveMission decode error:", err)
+		errMsg = err.Error()
+		return
+	}
+
+	if _, ok := model.MissionEventMap[mission.Event]; !ok {
+		errMsg = "事件类型不正确"
+		err = errors.New(errMsg)
+		return
+	}
+
+	if mission.Target <= 0 {
+		errMsg = "目标次数必须大于 0"
+		err = errors.New(errMsg)
+		return
+	}
+
+	mission.Type = model.MissionTypeRule
+	mission.StartAt = parseMissionTime(startAt)
+	mission.EndAt = parseMissionTime(endAt)
+
+	if mission.Id != 0 {
+		// 只能修改规则任务，不能覆盖内置任务
+		if err = isRuleMission(mission.Id); err != nil {
+			errMsg = err.Error()
+			return
+		}
+		_, err = db.MasterDB.Id(mission.Id).Where("type=?", model.MissionTypeRule).
+			UseBool().AllCols().Omit("state", "created_at").Update(mission)
+	} else {
+		_, err = db.MasterDB.Insert(mission)
+	}
+
+	if err != nil {
+		errMsg = "内部服务器错误"
+		logger.Error("MissionLogic SaveMission error:", err)
+		return
+	}
+
+	global.MissionChan <- struct{}{}
+
+	return
+}
+
+// ChangeMissionState 上下线规则任务：后台用
+func (MissionLogic) ChangeMissionState(ctx context.Context, id, state int) error {
+	if err := isRuleMission(id); err != nil {
+		return err
+	}
+
+	_, err := db.MasterDB.Table(new(model.Mission)).Id(id).Where("type=?", model.MissionTypeRule).
+		Update(map[string]interface{}{"state": state})
+	if err != nil {
+		logger.Error("MissionLogic ChangeMissionState error:", err)
+		return err
+	}
+
+	global.MissionChan <- struct{}{}
+
+	return nil
+}
+
+// isRuleMission 后台只能修改规则任务，内置任务（登录、初始资金等）不允许修改
+func isRuleMission(id int) error {
+	total, err := db.MasterDB.Where("id=? AND type=?", id, model.MissionTypeRule).Count(new(model.Mission))
+	if err != nil {
+		logger.Error("MissionLogic isRuleMission error:", err)
+		return errors.New("服务内部错误")
+	}
+	if total == 0 {
+		return errors.New("规则任务不存在")
+	}
+	return nil
+}
+
+// ExpireRuleMissions 将已过结束时间的规则任务置为过期
+func (MissionLogic) ExpireRuleMissions() {
+	affected, err := db.MasterDB.Table(new(model.Mission)).
+		Where("type=? AND state=? AND end_at>? AND end_at<?", model.MissionTypeRule, model.MissionStateNormal, "2000-01-01", time.Now()).
+		Update(map[string]interface{}{"state": model.MissionStateExpired})
+	if err != nil {
+		logger.Error("MissionLogic ExpireRuleMissions error:", err)
+		return
+	}
+
+	if affected > 0 {
+		global.MissionChan <- struct{}{}
+	}
+}
+
+var (
+	ruleMissionLocker sync.RWMutex
+	ruleMissions      []*model.Mission
+)
+
+// LoadRuleMissions 将进行中的规则任务加载到内存中；后台修改时，重新加载一次
+func LoadRuleMissions() error {
+	missions := make([]*model.Mission, 0)
+	err := db.MasterDB.Where("type=? AND state=?", model.MissionTypeRule, model.MissionStateNormal).Find(&missions)
+	if err != nil {
+		logger.Error("LoadRuleMissions mission read fail:%+v", err)
+		return err
+	}
+
+	ruleMissionLocker.Lock()
+	defer ruleMissionLocker.Unlock()
+
+	ruleMissions = missions
+
+	logger.Info("LoadRuleMissions successfully!")
+
+	return nil
+}
+
+// activeRuleMissions 获取当前进行中的规则任务，event 为空表示所有事件
+func activeRuleMissions(event string, objtype int) []*model.Mission {
+	ruleMissionLocker.RLock()
+	defer ruleMissionLocker.RUnlock()
+
+	missions := make([]*model.Mission, 0, len(ruleMissions))
+	for _, mission := range ruleMissions {
+		if !mission.IsActive() {
+			continue
+		}
+
+		if event != "" {
+			if mission.Event != event {
+				continue
+			}
+			if mission.Objtype != model.MissionObjtypeAll && mission.Objtype != objtype {
+				continue
+			}
+		}
+
+		missions = append(missions, mission)
+	}
+
+	return missions
+}
+
+// missionPeriod 计算某个时刻所属的任务周期标识
+func missionPeriod(period int, t time.Time) int {
+	switch period {
+	case model.MissionPeriodDaily:
+		return today(t)
+	case model.MissionPeriodWeekly:
+		year, week := t.ISOWeek()
+		return year*100 + week
+	case model.MissionPeriodMonthly:
+		return t.Year()*100 + int(t.Month())
+	default:
+		return 0
+	}
+}
+
+func today(t time.Time) int {
+	return goutils.MustInt(t.Format("20060102"))
+}
+
+func yesterday(t time.Time) int {
+	return goutils.MustInt(t.AddDate(0, 0, -1).Format("20060102"))
+}
+
+func parseMissionTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	if err != nil {
+		t, _ = time.ParseInLocation("2006-01-02", value, time.Local)
+	}
+	return t
+}
@@ -0,0 +1,207 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author:polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+
+	"github.com/polaris1119/goutils"
+	"github.com/polaris1119/set"
+	"golang.org/x/net/context"
+)
+
+type NodeModeratorLogic struct{}
+
+var DefaultNodeModerator = NodeModeratorLogic{}
+
+// nodeAncestors 节点及其所有上级节点
+func nodeAncestors(nid int) []int {
+	nids := make([]int, 0, 3)
+	// 节点层级不会太深，限制循环次数避免数据异常时死循环
+	for i := 0; nid > 0 && i < 10; i++ {
+		nids = append(nids, nid)
+
+		node := GetNode(nid)
+		if node == nil {
+			break
+		}
+		nid, _ = node["pid"].(int)
+	}
+	return nids
+}
+
+// HasPerm 用户是否是节点 nid（或其上级节点）的版主，且拥有 perm 权限
+func (NodeModeratorLogic) HasPerm(uid, nid, perm int) bool {
+	if uid == 0 || nid == 0 {
+		return false
+	}
+
+	moderators := make([]*model.NodeModerator, 0)
+	err := db.MasterDB.Where("uid=?", uid).In("nid", nodeAncestors(nid)).Find(&moderators)
+	if err != nil {
+		logger.Error("NodeModeratorLogic HasPerm error:", err)
+		return false
+	}
+
+	for _, moderator := range moderators {
+		if moderator.HasPrivilege(perm) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindByNid 节点的版主，包括上级节点的版主（同一用户只出现一次）
+func (self NodeModeratorLogic) FindByNid(ctx context.Context, nid int) []*model.NodeModerator {
+	moderators := make([]*model.NodeModerator, 0)
+	err := db.MasterDB.In("nid", nodeAncestors(nid)).Asc("id").Find(&moderators)
+	if err != nil {
+		logger.Error("NodeModeratorLogic FindByNid error:", err)
+		return nil
+	}
+
+	uidSet := set.New(set.NonThreadSafe)
+	uniqModerators := make([]*model.NodeModerator, 0, len(moderators))
+	for _, moderator := range moderators {
+		if uidSet.Has(moderator.Uid) {
+			continue
+		}
+		uidSet.Add(moderator.Uid)
+		uniqModerators = append(uniqModerators, moderator)
+	}
+
+	return self.fillUser(ctx, uniqModerators)
+}
+
+// FindAll 获取版主列表，nid 为 0 时获取所有：后台用
+func (self NodeModeratorLogic) FindAll(ctx context.Context, nid int) []*model.NodeModerator {
+	moderators := make([]*model.NodeModerator, 0)
+	session := db.MasterDB.Asc("nid", "id")
+	if nid > 0 {
+		session.Where("nid=?", nid)
+	}
+	err := session.Find(&moderators)
+	if err != nil {
+		logger.Error("NodeModeratorLogic FindAll error:", err)
+		return nil
+	}
+
+	for _, moderator := range moderators {
+		moderator.Node = GetNodeName(moderator.Nid)
+	}
+
+	return self.fillUser(ctx, moderators)
+}
+
+// FindById 获取单个版主
+func (self NodeModeratorLogic) FindById(ctx context.Context, id int) *model.NodeModerator {
+	moderator := &model.NodeModerator{}
+	_, err := db.MasterDB.Id(id).Get(moderator)
+	if err != nil {
+		logger.Error("NodeModeratorLogic FindById error:", err)
+		return nil
+	}
+
+	if moderator.Id == 0 {
+		return nil
+	}
+
+	moderator.User = DefaultUser.FindOne(ctx, "uid", moderator.Uid)
+
+	return moderator
+}
+
+// Save 任命版主或修改版主权限：后台用
+func (NodeModeratorLogic) Save(ctx context.Context, form url.Values, opUser string) (errMsg string, err error) {
+	id := goutils.MustInt(form.Get("id"))
+	nid := goutils.MustInt(form.Get("nid"))
+	if DefaultNode.FindOne(nid).Nid == 0 {
+		errMsg = "节点不存在"
+		err = errors.New(errMsg)
+		return
+	}
+
+	user := DefaultUser.FindOne(ctx, "username", strings.TrimSpace(form.Get("username")))
+	if user.Uid == 0 {
+		errMsg = "用户不存在"
+		err = errors.New(errMsg)
+		return
+	}
+
+	moderator := &model.NodeModerator{
+		Id:     id,
+		Nid:    nid,
+		Uid:    user.Uid,
+		OpUser: opUser,
+	}
+	for _, priv := range form["privilege"] {
+		moderator.Privileges |= goutils.MustInt(priv)
+	}
+	if moderator.Privileges == 0 {
+		errMsg = "请至少选择一项权限"
+		err = errors.New(errMsg)
+		return
+	}
+
+	exists := &model.NodeModerator{}
+	_, err = db.MasterDB.Where("nid=? AND uid=?", nid, user.Uid).Get(exists)
+	if err != nil {
+		errMsg = "内部服务器错误"
+		logger.Error("NodeModeratorLogic Save find error:", err)
+		return
+	}
+	if exists.Id != 0 && exists.Id != id {
+		errMsg = "该用户已经是此节点的版主"
+		err = errors.New(errMsg)
+		return
+	}
+
+	if id != 0 {
+		_, err = db.MasterDB.Id(id).AllCols().Omit("created_at").Update(moderator)
+	} else {
+		_, err = db.MasterDB.Insert(moderator)
+	}
+
+	if err != nil {
+		errMsg = "内部服务器错误"
+		logger.Error("NodeModeratorLogic Save error:", err)
+		return
+	}
+
+	return
+}
+
+// Delete 撤销版主：后台用
+func (NodeModeratorLogic) Delete(ctx context.Context, id int) error {
+	_, err := db.MasterDB.Id(id).Delete(new(model.NodeModerator))
+	if err != nil {
+		logger.Error("NodeModeratorLogic Delete error:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (NodeModeratorLogic) fillUser(ctx context.Context, moderators []*model.NodeModerator) []*model.NodeModerator {
+	uidSet := set.New(set.NonThreadSafe)
+	for _, moderator := range moderators {
+		uidSet.Add(moderator.Uid)
+	}
+	usersMap := DefaultUser.FindUserInfos(ctx, set.IntSlice(uidSet))
+	for _, moderator := range moderators {
+		moderator.User = usersMap[moderator.Uid]
+	}
+
+	return moderators
+}
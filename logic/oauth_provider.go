@@ -0,0 +1,400 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sander/config"
+	"sander/logger"
+	"sander/model"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+)
+
+// OAuthUser 各个登录方式返回的第三方用户信息，统一成一种格式
+type OAuthUser struct {
+	Key           string // 唯一标识，保存在 bind_user.username：GitHub 为登录名，Gitee、GitLab 为数字 id（登录名可以改），OpenID 为 sub
+	Tuid          int
+	Login         string // 建本站账号时用的用户名
+	Name          string
+	Email         string
+	EmailVerified bool // 第三方确认过邮箱属于该用户，只有这时才按邮箱关联本站已有账号
+	Avatar        string
+	Company       string
+	Location      string
+	Website       string
+	Github        string
+}
+
+// OAuthProvider 一种第三方登录方式。在 env.ini 中配置了 client_id 的才启用
+type OAuthProvider struct {
+	Name  string // 路由和配置中用的名字：/oauth/{Name}/login
+	Title string
+	Icon  string // font-awesome 图标
+	Type  int    // model.BindTypeXXX
+
+	scopes []string
+	// 获取 AuthURL、TokenURL 以及用户信息地址（OpenID 需要先从 issuer 发现）
+	endpoint func(ctx context.Context) (oauth2.Endpoint, error)
+	fetch    func(ctx context.Context, client *http.Client) (*OAuthUser, error)
+
+	clientID     string
+	clientSecret string
+}
+
+func (p *OAuthProvider) config(ctx context.Context, redirectURL string) (*oauth2.Config, error) {
+	endpoint, err := p.endpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.clientID,
+		ClientSecret: p.clientSecret,
+		Endpoint:     endpoint,
+		RedirectURL:  redirectURL,
+		Scopes:       p.scopes,
+	}, nil
+}
+
+// 按注册顺序，登录页按这个顺序显示
+var oauthProviders []*OAuthProvider
+
+func registerOAuthProvider(provider *OAuthProvider) {
+	if provider.clientID == "" {
+		return
+	}
+	oauthProviders = append(oauthProviders, provider)
+}
+
+func findOAuthProvider(name string) (*OAuthProvider, error) {
+	for _, provider := range oauthProviders {
+		if provider.Name == name {
+			return provider, nil
+		}
+	}
+	return nil, errors.New("不支持该登录方式")
+}
+
+func init() {
+	registerOAuthProvider(newGithubProvider())
+	registerOAuthProvider(newGiteeProvider())
+	registerOAuthProvider(newGitlabProvider())
+	registerOAuthProvider(newOIDCProvider())
+}
+
+func staticEndpoint(authURL, tokenURL string) func(context.Context) (oauth2.Endpoint, error) {
+	endpoint := oauth2.Endpoint{AuthURL: authURL, TokenURL: tokenURL}
+	return func(context.Context) (oauth2.Endpoint, error) {
+		return endpoint, nil
+	}
+}
+
+// getOAuthJSON 用授权后的 client 请求第三方接口
+func getOAuthJSON(client *http.Client, url string, result interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s error: %s %s", url, resp.Status, respBytes)
+	}
+
+	return json.Unmarshal(respBytes, result)
+}
+
+const GithubAPIBaseUrl = "https://api.github.com"
+
+// GitHub 的配置沿用 [github] 段
+func newGithubProvider() *OAuthProvider {
+	return &OAuthProvider{
+		Name:  "github",
+		Title: "GitHub",
+		Icon:  "fa-github",
+		Type:  model.BindTypeGithub,
+
+		scopes:   []string{"user:email"},
+		endpoint: staticEndpoint("https://github.com/login/oauth/authorize", "https://github.com/login/oauth/access_token"),
+		fetch: func(ctx context.Context, client *http.Client) (*OAuthUser, error) {
+			githubUser := &model.GithubUser{}
+			if err := getOAuthJSON(client, GithubAPIBaseUrl+"/user", githubUser); err != nil {
+				return nil, err
+			}
+			if githubUser.Id == 0 {
+				return nil, errors.New("get github user info error")
+			}
+
+			oauthUser := &OAuthUser{
+				Key:      githubUser.Login,
+				Tuid:     githubUser.Id,
+				Login:    githubUser.Login,
+				Name:     githubUser.Name,
+				Email:    githubUser.Email,
+				Avatar:   githubUser.AvatarUrl,
+				Company:  githubUser.Company,
+				Location: githubUser.Location,
+				Website:  githubUser.Blog,
+				Github:   githubUser.Login,
+			}
+
+			// /user 中的是公开邮箱，是否验证过要看 /user/emails
+			emails := make([]struct {
+				Email    string `json:"email"`
+				Primary  bool   `json:"primary"`
+				Verified bool   `json:"verified"`
+			}, 0)
+			if err := getOAuthJSON(client, GithubAPIBaseUrl+"/user/emails", &emails); err != nil {
+				logger.Error("github get emails error:", err)
+				return oauthUser, nil
+			}
+			for _, email := range emails {
+				if email.Primary && email.Verified {
+					oauthUser.Email = email.Email
+					oauthUser.EmailVerified = true
+				}
+			}
+			return oauthUser, nil
+		},
+
+		clientID:     config.ConfigFile.MustValue("github", "client_id"),
+		clientSecret: config.ConfigFile.MustValue("github", "client_secret"),
+	}
+}
+
+func newGiteeProvider() *OAuthProvider {
+	const apiBaseUrl = "https://gitee.com/api/v5"
+
+	tokenURL := "https://gitee.com/oauth/token"
+	// Gitee 只接受放在请求体中的 client_secret
+	oauth2.RegisterBrokenAuthHeaderProvider(tokenURL)
+
+	return &OAuthProvider{
+		Name:  "gitee",
+		Title: "Gitee",
+		Icon:  "fa-git",
+		Type:  model.BindTypeGitee,
+
+		scopes:   []string{"user_info", "emails"},
+		endpoint: staticEndpoint("https://gitee.com/oauth/authorize", tokenURL),
+		fetch: func(ctx context.Context, client *http.Client) (*OAuthUser, error) {
+			giteeUser := &struct {
+				Id        int    `json:"id"`
+				Login     string `json:"login"`
+				Name      string `json:"name"`
+				AvatarUrl string `json:"avatar_url"`
+				Blog      string `json:"blog"`
+				Email     string `json:"email"`
+			}{}
+			if err := getOAuthJSON(client, apiBaseUrl+"/user", giteeUser); err != nil {
+				return nil, err
+			}
+			if giteeUser.Id == 0 {
+				return nil, errors.New("get gitee user info error")
+			}
+
+			oauthUser := &OAuthUser{
+				Key:     strconv.Itoa(giteeUser.Id),
+				Tuid:    giteeUser.Id,
+				Login:   giteeUser.Login,
+				Name:    giteeUser.Name,
+				Email:   giteeUser.Email,
+				Avatar:  giteeUser.AvatarUrl,
+				Website: giteeUser.Blog,
+			}
+
+			emails := make([]struct {
+				Email string   `json:"email"`
+				State string   `json:"state"`
+				Scope []string `json:"scope"`
+			}, 0)
+			if err := getOAuthJSON(client, apiBaseUrl+"/emails", &emails); err != nil {
+				logger.Error("gitee get emails error:", err)
+				return oauthUser, nil
+			}
+			for _, email := range emails {
+				if email.State != "confirmed" {
+					continue
+				}
+				for _, scope := range email.Scope {
+					if scope == "primary" {
+						oauthUser.Email = email.Email
+						oauthUser.EmailVerified = true
+					}
+				}
+			}
+			return oauthUser, nil
+		},
+
+		clientID:     config.ConfigFile.MustValue("oauth_gitee", "client_id"),
+		clientSecret: config.ConfigFile.MustValue("oauth_gitee", "client_secret"),
+	}
+}
+
+// GitLab 可以是自建的，base_url 为站点地址
+func newGitlabProvider() *OAuthProvider {
+	baseUrl := strings.TrimRight(config.ConfigFile.MustValue("oauth_gitlab", "base_url", "https://gitlab.com"), "/")
+	// 自建的 GitLab 可能关闭了邮箱确认，这时不应该按邮箱关联账号，所以需要明确配置才信任
+	emailVerified := config.ConfigFile.MustBool("oauth_gitlab", "email_verified", false)
+
+	return &OAuthProvider{
+		Name:  "gitlab",
+		Title: config.ConfigFile.MustValue("oauth_gitlab", "title", "GitLab"),
+		Icon:  "fa-gitlab",
+		Type:  model.BindTypeGitlab,
+
+		scopes:   []string{"read_user"},
+		endpoint: staticEndpoint(baseUrl+"/oauth/authorize", baseUrl+"/oauth/token"),
+		fetch: func(ctx context.Context, client *http.Client) (*OAuthUser, error) {
+			gitlabUser := &struct {
+				Id           int    `json:"id"`
+				Username     string `json:"username"`
+				Name         string `json:"name"`
+				Email        string `json:"email"`
+				AvatarUrl    string `json:"avatar_url"`
+				WebsiteUrl   string `json:"website_url"`
+				Organization string `json:"organization"`
+				Location     string `json:"location"`
+			}{}
+			if err := getOAuthJSON(client, baseUrl+"/api/v4/user", gitlabUser); err != nil {
+				return nil, err
+			}
+			if gitlabUser.Id == 0 {
+				return nil, errors.New("get gitlab user info error")
+			}
+
+			return &OAuthUser{
+				Key:           strconv.Itoa(gitlabUser.Id),
+				Tuid:          gitlabUser.Id,
+				Login:         gitlabUser.Username,
+				Name:          gitlabUser.Name,
+				Email:         gitlabUser.Email,
+				EmailVerified: emailVerified && gitlabUser.Email != "",
+				Avatar:        gitlabUser.AvatarUrl,
+				Company:       gitlabUser.Organization,
+				Location:      gitlabUser.Location,
+				Website:       gitlabUser.WebsiteUrl,
+			}, nil
+		},
+
+		clientID:     config.ConfigFile.MustValue("oauth_gitlab", "client_id"),
+		clientSecret: config.ConfigFile.MustValue("oauth_gitlab", "client_secret"),
+	}
+}
+
+// 任意 OpenID Connect 身份提供方，issuer 为其地址，各个端点通过 /.well-known/openid-configuration 发现
+func newOIDCProvider() *OAuthProvider {
+	issuer := strings.TrimRight(config.ConfigFile.MustValue("oauth_oidc", "issuer"), "/")
+
+	var (
+		locker    sync.Mutex
+		discovery *struct {
+			Issuer                string `json:"issuer"`
+			AuthorizationEndpoint string `json:"authorization_endpoint"`
+			TokenEndpoint         string `json:"token_endpoint"`
+			UserinfoEndpoint      string `json:"userinfo_endpoint"`
+		}
+	)
+	// 发现成功后缓存，失败时下次再试
+	discover := func() error {
+		locker.Lock()
+		defer locker.Unlock()
+
+		if discovery != nil {
+			return nil
+		}
+
+		result := discovery
+		if err := getOAuthJSON(http.DefaultClient, issuer+"/.well-known/openid-configuration", &result); err != nil {
+			logger.Error("oidc discovery error:", err)
+			return errors.New("获取 OpenID 配置失败，请稍后再试")
+		}
+		if strings.TrimRight(result.Issuer, "/") != issuer || result.AuthorizationEndpoint == "" ||
+			result.TokenEndpoint == "" || result.UserinfoEndpoint == "" {
+			logger.Error("oidc discovery invalid:", result)
+			return errors.New("OpenID 配置不正确")
+		}
+
+		discovery = result
+		return nil
+	}
+
+	return &OAuthProvider{
+		Name:  "oidc",
+		Title: config.ConfigFile.MustValue("oauth_oidc", "title", "OpenID"),
+		Icon:  "fa-openid",
+		Type:  model.BindTypeOIDC,
+
+		scopes: []string{"openid", "profile", "email"},
+		endpoint: func(ctx context.Context) (oauth2.Endpoint, error) {
+			if err := discover(); err != nil {
+				return oauth2.Endpoint{}, err
+			}
+			return oauth2.Endpoint{AuthURL: discovery.AuthorizationEndpoint, TokenURL: discovery.TokenEndpoint}, nil
+		},
+		fetch: func(ctx context.Context, client *http.Client) (*OAuthUser, error) {
+			if err := discover(); err != nil {
+				return nil, err
+			}
+
+			claims := &struct {
+				Sub               string      `json:"sub"`
+				PreferredUsername string      `json:"preferred_username"`
+				Nickname          string      `json:"nickname"`
+				Name              string      `json:"name"`
+				Email             string      `json:"email"`
+				EmailVerified     interface{} `json:"email_verified"` // 有的提供方返回字符串 "true"
+				Picture           string      `json:"picture"`
+				Website           string      `json:"website"`
+			}{}
+			if err := getOAuthJSON(client, discovery.UserinfoEndpoint, claims); err != nil {
+				return nil, err
+			}
+			if claims.Sub == "" {
+				return nil, errors.New("get oidc userinfo error")
+			}
+
+			login := claims.PreferredUsername
+			if login == "" {
+				login = claims.Nickname
+			}
+			if login == "" {
+				if pos := strings.Index(claims.Email, "@"); pos > 0 {
+					login = claims.Email[:pos]
+				}
+			}
+			verified, _ := strconv.ParseBool(fmt.Sprint(claims.EmailVerified))
+
+			return &OAuthUser{
+				Key:           claims.Sub,
+				Login:         login,
+				Name:          claims.Name,
+				Email:         claims.Email,
+				EmailVerified: verified,
+				Avatar:        claims.Picture,
+				Website:       claims.Website,
+			}, nil
+		},
+
+		clientID:     config.ConfigFile.MustValue("oauth_oidc", "client_id"),
+		clientSecret: config.ConfigFile.MustValue("oauth_oidc", "client_secret"),
+	}
+}
@@ -20,6 +20,7 @@ var (
 	ViewObservable    Observable
 	appendObservable  Observable
 	topObservable     Observable
+	likeObservable    Observable
 )
 
 func init() {
@@ -27,30 +28,47 @@ func init() {
 	publishObservable.AddObserver(&UserWeightObserver{})
 	publishObservable.AddObserver(&TodayActiveObserver{})
 	publishObservable.AddObserver(&UserRichObserver{})
+	publishObservable.AddObserver(&MissionObserver{})
+	publishObservable.AddObserver(&BadgeObserver{})
+	publishObservable.AddObserver(&UserLevelObserver{})
+	publishObservable.AddObserver(&ContentReviewObserver{})
+	publishObservable.AddObserver(&SpamObserver{})
 
 	modifyObservable = NewConcreteObservable(actionModify)
 	modifyObservable.AddObserver(&UserWeightObserver{})
 	modifyObservable.AddObserver(&TodayActiveObserver{})
 	modifyObservable.AddObserver(&UserRichObserver{})
+	modifyObservable.AddObserver(&ContentReviewObserver{})
 
 	commentObservable = NewConcreteObservable(actionComment)
 	commentObservable.AddObserver(&UserWeightObserver{})
 	commentObservable.AddObserver(&TodayActiveObserver{})
 	commentObservable.AddObserver(&UserRichObserver{})
+	commentObservable.AddObserver(&MissionObserver{})
+	commentObservable.AddObserver(&BadgeObserver{})
+	commentObservable.AddObserver(&UserLevelObserver{})
+	commentObservable.AddObserver(&ContentReviewObserver{})
+	commentObservable.AddObserver(&SpamObserver{})
 
 	ViewObservable = NewConcreteObservable(actionView)
 	ViewObservable.AddObserver(&UserWeightObserver{})
 	ViewObservable.AddObserver(&TodayActiveObserver{})
+	ViewObservable.AddObserver(&MissionObserver{})
 
 	appendObservable = NewConcreteObservable(actionAppend)
 	appendObservable.AddObserver(&UserWeightObserver{})
 	appendObservable.AddObserver(&TodayActiveObserver{})
 	appendObservable.AddObserver(&UserRichObserver{})
+	appendObservable.AddObserver(&BadgeObserver{})
 
 	topObservable = NewConcreteObservable(actionTop)
 	topObservable.AddObserver(&UserWeightObserver{})
 	topObservable.AddObserver(&TodayActiveObserver{})
 	topObservable.AddObserver(&UserRichObserver{})
+	topObservable.AddObserver(&BadgeObserver{})
+
+	likeObservable = NewConcreteObservable(actionLike)
+	likeObservable.AddObserver(&MissionObserver{})
 }
 
 type Observer interface {
@@ -73,6 +91,7 @@ const (
 	actionView    = "view"
 	actionAppend  = "append"
 	actionTop     = "top" // 置顶
+	actionLike    = "like"
 )
 
 type ConcreteObservable struct {
@@ -183,7 +202,10 @@ func (UserRichObserver) Update(action string, uid, objtype, objid int) {
 	)
 
 	if action == actionPublish || action == actionComment {
-		var comment *model.Comment
+		var (
+			comment  *model.Comment
+			payOwner bool
+		)
 		if action == actionComment {
 			comment, _ = DefaultComment.FindById(objid)
 			if comment.Cid != objid {
@@ -191,6 +213,8 @@ func (UserRichObserver) Update(action string, uid, objtype, objid int) {
 			}
 
 			objid = comment.Objid
+			// 影子封禁的回复只有作者能看到，被回复者不能获得收益，否则明细中会暴露这条回复
+			payOwner = comment.Flag != model.FlagShadow
 
 			award = -5
 			typ = model.MissionTypeReply
@@ -211,7 +235,7 @@ func (UserRichObserver) Update(action string, uid, objtype, objid int) {
 					objid,
 					topic.Title)
 
-				if uid != topic.Uid {
+				if payOwner && uid != topic.Uid {
 					// 主题发起人获得收益
 					replyDesc := fmt.Sprintf(`收到 <a href="/user/%s">%s</a> 的回复 › <a href="/topics/%d">%s</a>`,
 						user.Username,
@@ -238,7 +262,7 @@ func (UserRichObserver) Update(action string, uid, objtype, objid int) {
 					utf8.RuneCountInString(comment.Content),
 					objid,
 					article.Title)
-				if article.Domain == WebsiteSetting.Domain && user.Username != article.Author {
+				if payOwner && article.Domain == WebsiteSetting.Domain && user.Username != article.Author {
 					// 文章发起人获得收益
 					replyDesc := fmt.Sprintf(`收到 <a href="/user/%s">%s</a> 的回复 › <a href="/articles/%d">%s</a>`,
 						user.Username,
@@ -265,7 +289,7 @@ func (UserRichObserver) Update(action string, uid, objtype, objid int) {
 					objid,
 					resource.Title)
 
-				if uid != resource.Uid {
+				if payOwner && uid != resource.Uid {
 					// 资源发起人获得收益
 					replyDesc := fmt.Sprintf(`收到 <a href="/user/%s">%s</a> 的回复 › <a href="/resources/%d">%s</a>`,
 						user.Username,
@@ -292,7 +316,7 @@ func (UserRichObserver) Update(action string, uid, objtype, objid int) {
 					objid,
 					project.Category+project.Name)
 
-				if user.Username != project.Username {
+				if payOwner && user.Username != project.Username {
 					// 项目发起人获得收益
 					replyDesc := fmt.Sprintf(`收到 <a href="/user/%s">%s</a> 的回复 › <a href="/p/%d">%s</a>`,
 						user.Username,
@@ -318,7 +342,7 @@ func (UserRichObserver) Update(action string, uid, objtype, objid int) {
 					wiki.Uri,
 					wiki.Title)
 
-				if uid != wiki.Uid {
+				if payOwner && uid != wiki.Uid {
 					// WIKI发起人获得收益
 					replyDesc := fmt.Sprintf(`收到 <a href="/user/%s">%s</a> 的回复 › <a href="/wiki/%d">%s</a>`,
 						user.Username,
@@ -383,3 +407,46 @@ func (UserRichObserver) Update(action string, uid, objtype, objid int) {
 
 	DefaultUserRich.IncrUserRich(user, typ, award, desc)
 }
+
+type MissionObserver struct{}
+
+// Update 推进规则任务进度。如果是回复，则 objid 是 cid；如果是浏览且 objid 为 0，表示登录用户访问了网站
+func (MissionObserver) Update(action string, uid, objtype, objid int) {
+	switch action {
+	case actionPublish:
+		DefaultMission.Accomplish(model.MissionEventPublish, uid, objtype, objid)
+	case actionComment:
+		comment, err := DefaultComment.FindById(objid)
+		if err != nil || comment.Cid != objid {
+			return
+		}
+		DefaultMission.Accomplish(model.MissionEventComment, uid, objtype, comment.Objid)
+	case actionView:
+		if objid == 0 {
+			DefaultMission.Accomplish(model.MissionEventLogin, uid, objtype, objid)
+		} else {
+			DefaultMission.Accomplish(model.MissionEventView, uid, objtype, objid)
+		}
+	case actionLike:
+		DefaultMission.Accomplish(model.MissionEventLike, uid, objtype, objid)
+
+		owner := DefaultMission.objOwner(objtype, objid)
+		if owner != 0 && owner != uid {
+			DefaultMission.Accomplish(model.MissionEventLiked, owner, objtype, objid)
+		}
+	}
+}
+
+type BadgeObserver struct{}
+
+// Update 发布、回复等行为后，检查用户是否达到了徽章的授予条件
+func (BadgeObserver) Update(action string, uid, objtype, objid int) {
+	DefaultBadge.Evaluate(uid, model.BadgeRuleTopicNum, model.BadgeRuleArticleNum, model.BadgeRuleCommentNum, model.BadgeRuleWeight)
+}
+
+type UserLevelObserver struct{}
+
+// Update 发布、回复后活跃度和内容数会变化，重新计算用户的声望等级
+func (UserLevelObserver) Update(action string, uid, objtype, objid int) {
+	DefaultUserLevel.Refresh(uid)
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"sander/config"
+)
+
+// 密码最多 64 个字节（按字节计算，不是字符），小于 bcrypt 只使用的前 72 个字节，保证整个密码都参与计算
+const passwdMaxLen = 64
+
+// 最常见的弱密码，长度和字符种类都满足要求也不允许使用
+var weakPasswds = map[string]bool{
+	"password1":   true,
+	"password123": true,
+	"12345678a":   true,
+	"a12345678":   true,
+	"abc12345":    true,
+	"abcd1234":    true,
+	"aa123456":    true,
+	"a1234567":    true,
+	"qwerty123":   true,
+	"1qaz2wsx":    true,
+	"1q2w3e4r":    true,
+	"admin123":    true,
+	"iloveyou1":   true,
+	"qwe123456":   true,
+}
+
+// checkPasswdStrength 密码强度策略：长度至少 [account] passwd_min_len 位，
+// 至少包含字母、数字、符号中的 passwd_min_classes 类，不能包含用户名和邮箱名，不能是常见弱密码
+func checkPasswdStrength(passwd, username, email string) error {
+	minLen := config.ConfigFile.MustInt("account", "passwd_min_len", 8)
+	if utf8.RuneCountInString(passwd) < minLen || len(passwd) > passwdMaxLen {
+		return fmt.Errorf("密码长度必须在%d到%d个字符之间", minLen, passwdMaxLen)
+	}
+
+	var hasLetter, hasDigit, hasSymbol bool
+	for _, r := range passwd {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsSpace(r):
+			return errors.New("密码不能包含空白字符")
+		default:
+			hasSymbol = true
+		}
+	}
+	classes := 0
+	for _, has := range []bool{hasLetter, hasDigit, hasSymbol} {
+		if has {
+			classes++
+		}
+	}
+	if minClasses := config.ConfigFile.MustInt("account", "passwd_min_classes", 2); classes < minClasses {
+		return fmt.Errorf("密码至少需要包含字母、数字、符号中的%d类", minClasses)
+	}
+
+	lowerPasswd := strings.ToLower(passwd)
+	if username != "" && strings.Contains(lowerPasswd, strings.ToLower(username)) {
+		return errors.New("密码不能包含用户名")
+	}
+	if pos := strings.Index(email, "@"); pos >= 4 && strings.Contains(lowerPasswd, strings.ToLower(email[:pos])) {
+		return errors.New("密码不能包含邮箱名")
+	}
+	if weakPasswds[lowerPasswd] {
+		return errors.New("密码太常见，请换一个")
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"strings"
+	"testing"
+
+	"sander/model"
+
+	"github.com/polaris1119/goutils"
+)
+
+func TestCheckPasswdStrength(t *testing.T) {
+	type args struct {
+		passwd   string
+		username string
+		email    string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"字母加数字", args{"golang2017", "polaris", "polaris@studygolang.com"}, false},
+		{"字母加符号", args{"go-lang-rocks", "polaris", ""}, false},
+		{"中文算字母，只有一类", args{"学习golang", "polaris", ""}, true},
+		{"中文加数字", args{"学习golang2017", "polaris", ""}, false},
+		{"太短", args{"go2017", "polaris", ""}, true},
+		{"正好 64 个字节", args{strings.Repeat("a1", 32), "polaris", ""}, false},
+		{"超过 64 个字节", args{strings.Repeat("a1", 32) + "b", "polaris", ""}, true},
+		{"中文按字节算长度", args{strings.Repeat("中", 21) + "1a", "polaris", ""}, true},
+		{"只有一类字符", args{"golanggolang", "polaris", ""}, true},
+		{"包含空白", args{"golang 2017", "polaris", ""}, true},
+		{"包含用户名（不区分大小写）", args{"POLARIS2017", "polaris", ""}, true},
+		{"包含邮箱名", args{"xuxinhua2017", "polaris", "xuxinhua@studygolang.com"}, true},
+		{"邮箱名太短不检查", args{"xyz-2017", "polaris", "xyz@studygolang.com"}, false},
+		{"常见弱密码", args{"Password123", "polaris", ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkPasswdStrength(tt.args.passwd, tt.args.username, tt.args.email); (err != nil) != tt.wantErr {
+				t.Errorf("checkPasswdStrength() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckPasswdUpgrade(t *testing.T) {
+	// 旧的 md5(passwd+passcode)，密码正确时需要升级
+	userLogin := &model.UserLogin{Passcode: "abcd", Passwd: goutils.Md5("golang2017" + "abcd")}
+	if ok, needUpgrade := userLogin.CheckPasswd("golang2017"); !ok || !needUpgrade {
+		t.Fatalf("md5 CheckPasswd() = %v, %v, want true, true", ok, needUpgrade)
+	}
+	if ok, needUpgrade := userLogin.CheckPasswd("golang2018"); ok || needUpgrade {
+		t.Fatalf("md5 wrong CheckPasswd() = %v, %v, want false, false", ok, needUpgrade)
+	}
+
+	// 升级为 bcrypt 后不再需要 passcode
+	userLogin.Passwd = "golang2017"
+	if err := userLogin.GenPasswd(); err != nil {
+		t.Fatal(err)
+	}
+	if userLogin.Passcode != "" || !strings.HasPrefix(userLogin.Passwd, model.PasswdPrefixBcrypt) {
+		t.Fatalf("GenPasswd() passcode = %q, passwd = %q", userLogin.Passcode, userLogin.Passwd)
+	}
+	if ok, needUpgrade := userLogin.CheckPasswd("golang2017"); !ok || needUpgrade {
+		t.Fatalf("bcrypt CheckPasswd() = %v, %v, want true, false", ok, needUpgrade)
+	}
+	if ok, _ := userLogin.CheckPasswd("golang2018"); ok {
+		t.Fatal("bcrypt wrong CheckPasswd() = true, want false")
+	}
+	if ok, _ := userLogin.CheckPasswd(""); ok {
+		t.Fatal("empty CheckPasswd() = true, want false")
+	}
+}
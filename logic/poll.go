@@ -0,0 +1,340 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+
+	"github.com/go-xorm/xorm"
+	"github.com/polaris1119/goutils"
+	"github.com/polaris1119/set"
+	"golang.org/x/net/context"
+)
+
+type PollLogic struct{}
+
+var DefaultPoll = PollLogic{}
+
+// parseForm 从发布主题的表单中解析投票。没有填写选项时返回 nil
+func (PollLogic) parseForm(form url.Values) (*model.Poll, error) {
+	contents := make([]string, 0, len(form["poll_option"]))
+	contentSet := make(map[string]bool)
+	for _, content := range form["poll_option"] {
+		content = strings.TrimSpace(content)
+		if content == "" || contentSet[content] {
+			continue
+		}
+		if utf8.RuneCountInString(content) > 100 {
+			return nil, errors.New("投票选项不能超过 100 个字")
+		}
+		contentSet[content] = true
+		contents = append(contents, content)
+	}
+
+	if len(contents) == 0 {
+		return nil, nil
+	}
+	if len(contents) < model.PollMinOptions {
+		return nil, errors.New("投票至少需要 " + strconv.Itoa(model.PollMinOptions) + " 个不同的选项")
+	}
+	if len(contents) > model.PollMaxOptions {
+		return nil, errors.New("投票最多 " + strconv.Itoa(model.PollMaxOptions) + " 个选项")
+	}
+
+	poll := &model.Poll{
+		Multiple:  form.Get("poll_multiple") == "1",
+		Anonymous: form.Get("poll_anonymous") == "1",
+		Options:   make([]*model.PollOption, len(contents)),
+	}
+	for i, content := range contents {
+		poll.Options[i] = &model.PollOption{Content: content}
+	}
+
+	if deadline := strings.TrimSpace(form.Get("poll_deadline")); deadline != "" {
+		poll.Deadline = parseMissionTime(deadline)
+		if poll.Deadline.IsZero() {
+			poll.Deadline, _ = time.ParseInLocation("2006-01-02 15:04", deadline, time.Local)
+		}
+		if poll.Deadline.IsZero() {
+			return nil, errors.New("投票截止时间格式不正确")
+		}
+		if poll.Deadline.Before(time.Now()) {
+			return nil, errors.New("投票截止时间必须晚于现在")
+		}
+	}
+
+	return poll, nil
+}
+
+// create 保存主题的投票，和主题在同一个事务中
+func (PollLogic) create(session *xorm.Session, tid int, poll *model.Poll) error {
+	poll.Tid = tid
+	if _, err := session.Insert(poll); err != nil {
+		logger.Error("PollLogic create poll error:", err)
+		return err
+	}
+
+	for _, option := range poll.Options {
+		option.PollId = poll.Id
+	}
+	if _, err := session.Insert(&poll.Options); err != nil {
+		logger.Error("PollLogic create options error:", err)
+		return err
+	}
+
+	return nil
+}
+
+// FindByTid 获取主题的投票，没有投票时返回 nil
+func (self PollLogic) FindByTid(ctx context.Context, tid int, me *model.Me) *model.Poll {
+	poll := &model.Poll{}
+	_, err := db.MasterDB.Where("tid=?", tid).Get(poll)
+	if err != nil {
+		logger.Error("PollLogic FindByTid error:", err)
+		return nil
+	}
+	if poll.Id == 0 {
+		return nil
+	}
+
+	return self.fill(ctx, poll, me)
+}
+
+// FindOne 获取一个投票
+func (self PollLogic) FindOne(ctx context.Context, id int, me *model.Me) *model.Poll {
+	poll := &model.Poll{}
+	_, err := db.MasterDB.Id(id).Get(poll)
+	if err != nil {
+		logger.Error("PollLogic FindOne error:", err)
+		return nil
+	}
+	if poll.Id == 0 {
+		return nil
+	}
+
+	return self.fill(ctx, poll, me)
+}
+
+// Vote 投票，每人只能投一次
+func (PollLogic) Vote(ctx context.Context, me *model.Me, id int, optionIds []int) error {
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	poll := &model.Poll{}
+	_, err := session.Id(id).ForUpdate().Get(poll)
+	if err != nil {
+		session.Rollback()
+		logger.Error("PollLogic Vote find poll error:", err)
+		return errors.New("服务内部错误")
+	}
+	if poll.Id == 0 {
+		session.Rollback()
+		return errors.New("投票不存在")
+	}
+	if poll.IsEnded() {
+		session.Rollback()
+		return errors.New("投票已结束")
+	}
+
+	// 删除、影子封禁的主题不能投票，锁定、关闭或被合并的主题和回复一样不能再投票
+	topic := DefaultTopic.findByTid(poll.Tid)
+	if topic.Tid == 0 || topic.Flag > model.FlagNormal {
+		session.Rollback()
+		return errors.New("投票不存在")
+	}
+	if topic.Locked || topic.Closed || topic.MergedTo > 0 {
+		session.Rollback()
+		return errors.New("主题已锁定，不能投票")
+	}
+
+	total, err := session.Where("poll_id=? AND uid=?", id, me.Uid).Count(new(model.PollVote))
+	if err != nil {
+		session.Rollback()
+		logger.Error("PollLogic Vote count error:", err)
+		return errors.New("服务内部错误")
+	}
+	if total > 0 {
+		session.Rollback()
+		return errors.New("你已经投过票了")
+	}
+
+	optionSet := set.New(set.NonThreadSafe)
+	for _, optionId := range optionIds {
+		optionSet.Add(optionId)
+	}
+	optionIds = set.IntSlice(optionSet)
+	if len(optionIds) == 0 {
+		session.Rollback()
+		return errors.New("请选择投票选项")
+	}
+	if !poll.Multiple && len(optionIds) > 1 {
+		session.Rollback()
+		return errors.New("该投票只能选择一项")
+	}
+
+	num, err := session.Where("poll_id=?", id).In("id", optionIds).Count(new(model.PollOption))
+	if err != nil || int(num) != len(optionIds) {
+		session.Rollback()
+		return errors.New("投票选项不存在")
+	}
+
+	strIds := make([]string, len(optionIds))
+	for i, optionId := range optionIds {
+		strIds[i] = strconv.Itoa(optionId)
+	}
+	vote := &model.PollVote{
+		PollId:    id,
+		Uid:       me.Uid,
+		OptionIds: strings.Join(strIds, ","),
+	}
+	// poll_id 和 uid 有唯一索引，保证一人一票
+	if _, err = session.Insert(vote); err != nil {
+		session.Rollback()
+		logger.Error("PollLogic Vote insert error:", err)
+		return errors.New("你已经投过票了")
+	}
+
+	_, err = session.In("id", optionIds).Incr("votes", 1).Update(new(model.PollOption))
+	if err != nil {
+		session.Rollback()
+		logger.Error("PollLogic Vote incr votes error:", err)
+		return errors.New("服务内部错误")
+	}
+	_, err = session.Id(id).Incr("voters", 1).Update(new(model.Poll))
+	if err != nil {
+		session.Rollback()
+		logger.Error("PollLogic Vote incr voters error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	session.Commit()
+
+	go Book.BroadcastAllUsersMessage(NewMessage(WsMsgPoll, map[string]int{"poll_id": id, "tid": poll.Tid}))
+
+	return nil
+}
+
+// Close 提前结束投票：主题作者或管理员
+func (PollLogic) Close(ctx context.Context, me *model.Me, id int) error {
+	poll := &model.Poll{}
+	_, err := db.MasterDB.Id(id).Get(poll)
+	if err != nil || poll.Id == 0 {
+		return errors.New("投票不存在")
+	}
+
+	topic := &model.Topic{}
+	_, err = db.MasterDB.Id(poll.Tid).Get(topic)
+	if err != nil {
+		logger.Error("PollLogic Close find topic error:", err)
+		return errors.New("服务内部错误")
+	}
+	if topic.Uid != me.Uid && !me.IsAdmin {
+		return NotModifyAuthorityErr
+	}
+
+	_, err = db.MasterDB.Table(new(model.Poll)).Id(id).Update(map[string]interface{}{"closed": 1})
+	if err != nil {
+		logger.Error("PollLogic Close error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	go Book.BroadcastAllUsersMessage(NewMessage(WsMsgPoll, map[string]int{"poll_id": id, "tid": poll.Tid}))
+
+	return nil
+}
+
+// fill 填充选项、当前用户的投票情况。结果在投票或结束前不可见，此时票数清零
+func (PollLogic) fill(ctx context.Context, poll *model.Poll, me *model.Me) *model.Poll {
+	poll.Options = make([]*model.PollOption, 0)
+	err := db.MasterDB.Where("poll_id=?", poll.Id).Asc("id").Find(&poll.Options)
+	if err != nil {
+		logger.Error("PollLogic fill options error:", err)
+	}
+
+	poll.Ended = poll.IsEnded()
+
+	chosen := make(map[int]bool)
+	if me != nil {
+		vote := &model.PollVote{}
+		_, err = db.MasterDB.Where("poll_id=? AND uid=?", poll.Id, me.Uid).Get(vote)
+		if err != nil {
+			logger.Error("PollLogic fill find vote error:", err)
+		}
+		if vote.Id > 0 {
+			poll.Voted = true
+			for _, optionId := range strings.Split(vote.OptionIds, ",") {
+				chosen[goutils.MustInt(optionId)] = true
+			}
+		}
+	}
+
+	poll.ShowResult = poll.Voted || poll.Ended
+
+	for _, option := range poll.Options {
+		option.Chosen = chosen[option.Id]
+		if !poll.ShowResult {
+			option.Votes = 0
+			continue
+		}
+		if poll.Voters > 0 {
+			option.Percent = option.Votes * 100 / poll.Voters
+		}
+	}
+	// 看不到结果时，参与人数也不展示
+	if !poll.ShowResult {
+		poll.Voters = 0
+	}
+
+	if poll.ShowResult && !poll.Anonymous {
+		fillPollUsernames(ctx, poll)
+	}
+
+	return poll
+}
+
+// fillPollUsernames 非匿名投票时，填充每个选项的投票人
+func fillPollUsernames(ctx context.Context, poll *model.Poll) {
+	votes := make([]*model.PollVote, 0)
+	err := db.MasterDB.Where("poll_id=?", poll.Id).Asc("id").Find(&votes)
+	if err != nil {
+		logger.Error("fillPollUsernames error:", err)
+		return
+	}
+
+	uids := make([]int, len(votes))
+	for i, vote := range votes {
+		uids[i] = vote.Uid
+	}
+	usersMap := DefaultUser.FindUserInfos(ctx, uids)
+
+	optionMap := make(map[int]*model.PollOption, len(poll.Options))
+	for _, option := range poll.Options {
+		optionMap[option.Id] = option
+	}
+
+	for _, vote := range votes {
+		user, ok := usersMap[vote.Uid]
+		if !ok {
+			continue
+		}
+		for _, optionId := range strings.Split(vote.OptionIds, ",") {
+			if option, ok := optionMap[goutils.MustInt(optionId)]; ok {
+				option.Usernames = append(option.Usernames, user.Username)
+			}
+		}
+	}
+}
@@ -83,9 +83,12 @@ func (self ProjectLogic) Publish(ctx context.Context, user *model.Me, form url.V
 	if !isModify {
 		affected, err = db.MasterDB.Insert(project)
 	} else {
-		affected, err = db.MasterDB.Id(id).Update(project)
+		affected, err = updateWithVersion(db.MasterDB.Id(id), form.Get("version"), project)
 	}
 
+	if err == ConflictErr {
+		return
+	}
 	if err != nil {
 		logger.Error("Publish Project error:", err)
 		return
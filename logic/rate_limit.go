@@ -0,0 +1,256 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"sander/config"
+	"sander/db/nosql"
+	"sander/logger"
+	"sander/model"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/polaris1119/goutils"
+	"github.com/polaris1119/set"
+	"golang.org/x/net/context"
+)
+
+// 令牌桶：桶里最多 capacity 个令牌，每 interval 毫秒补充一个，每次请求取 cost 个（0 表示只检查不取），
+// 取不到时返回还要等待的毫秒数
+// KEYS[1] 桶；ARGV：capacity、interval、当前时间（毫秒）、cost
+var tokenBucketScript = redis.NewScript(1, `
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+local delta = math.floor((now - ts) / interval)
+if delta > 0 then
+	tokens = math.min(capacity, tokens + delta)
+	ts = ts + delta * interval
+end
+if tokens >= capacity then
+	ts = now
+end
+
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - cost
+else
+	wait = interval - (now - ts)
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tokens, 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], capacity * interval)
+
+return wait
+`)
+
+// rateSpec 一条策略：最多连续 burst 次，之后每 interval 恢复一次
+type rateSpec struct {
+	burst    int
+	interval time.Duration
+}
+
+// ThrottleRecord 某天被限制的用户或 IP：后台用
+type ThrottleRecord struct {
+	Policy  string // 配置项名，IP 维度的以 _ip 结尾
+	Subject string // uid:1、ip:1.2.3.4 或 name:xxx（登录时的用户名）
+	Num     int
+	LastAt  time.Time
+	User    *model.User
+}
+
+type RateLimitLogic struct{}
+
+var DefaultRateLimit = RateLimitLogic{}
+
+// Limit 按 [rate_limit] 中的策略对用户和 IP 分别取令牌，任一个被限制就返回需要等待的时间，0 表示放行。
+// 用户维度的配置项为 policy，IP 维度的为 policy_ip；未登录（me 为 nil）时只限制 IP，管理员不限制
+func (self RateLimitLogic) Limit(policy string, me *model.Me, ip string) time.Duration {
+	if me != nil && me.IsAdmin {
+		return 0
+	}
+
+	if me != nil {
+		if spec, ok := self.spec(policy, me); ok {
+			if wait := self.take(policy, "uid:"+strconv.Itoa(me.Uid), spec); wait > 0 {
+				return wait
+			}
+		}
+	}
+
+	if spec, ok := self.spec(policy+"_ip", nil); ok {
+		return self.take(policy+"_ip", "ip:"+ip, spec)
+	}
+
+	return 0
+}
+
+// LimitLogin 登录按尝试的用户名和 IP 分别限制，防止暴力破解密码。
+// 用户名维度这里只检查不扣减，登录失败时再调用 LoginFailed 扣减，这样正常登录成功不占次数。
+// 注意这不能防止别人故意输错：失败次数用完后，该用户名在令牌恢复前（包括本人）都不能登录。
+// 这是为了限制从多个 IP 分散猜同一个账号的密码，有意不按 IP 区分
+func (self RateLimitLogic) LimitLogin(username, ip string) time.Duration {
+	if spec, ok := self.spec("login", nil); ok {
+		if wait := self.acquire("login", "name:"+strings.ToLower(username), spec, 0); wait > 0 {
+			return wait
+		}
+	}
+
+	if spec, ok := self.spec("login_ip", nil); ok {
+		return self.take("login_ip", "ip:"+ip, spec)
+	}
+
+	return 0
+}
+
+// LoginFailed 用户名或密码错误时，扣减该用户名的登录次数
+func (self RateLimitLogic) LoginFailed(username string) {
+	if spec, ok := self.spec("login", nil); ok {
+		self.take("login", "name:"+strings.ToLower(username), spec)
+	}
+}
+
+// FindThrottled 某天（格式 20060102）被限制的记录，按被限制次数从多到少
+func (RateLimitLogic) FindThrottled(ctx context.Context, day string) []*ThrottleRecord {
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	resultSlice, err := redisClient.ZREVRANGE("ratelimit:throttled:"+day, 0, 199, true)
+	if err != nil {
+		logger.Error("RateLimitLogic FindThrottled ZREVRANGE error:", err)
+		return nil
+	}
+	lastTimes, err := redisClient.HGETALL("ratelimit:throttled_at:" + day)
+	if err != nil {
+		logger.Error("RateLimitLogic FindThrottled HGETALL error:", err)
+	}
+
+	records := make([]*ThrottleRecord, 0, len(resultSlice)/2)
+	uidSet := set.New(set.NonThreadSafe)
+	for len(resultSlice) > 0 {
+		var (
+			member string
+			num    int
+		)
+		resultSlice, err = redis.Scan(resultSlice, &member, &num)
+		if err != nil {
+			logger.Error("RateLimitLogic FindThrottled redis Scan error:", err)
+			return nil
+		}
+
+		pos := strings.Index(member, "|")
+		if pos == -1 {
+			continue
+		}
+		record := &ThrottleRecord{
+			Policy:  member[:pos],
+			Subject: member[pos+1:],
+			Num:     num,
+			LastAt:  time.Unix(int64(goutils.MustInt(lastTimes[member])), 0),
+		}
+		if strings.HasPrefix(record.Subject, "uid:") {
+			uidSet.Add(goutils.MustInt(record.Subject[4:]))
+		}
+		records = append(records, record)
+	}
+
+	usersMap := DefaultUser.FindUserInfos(ctx, set.IntSlice(uidSet))
+	for _, record := range records {
+		if strings.HasPrefix(record.Subject, "uid:") {
+			record.User = usersMap[goutils.MustInt(record.Subject[4:])]
+		}
+	}
+
+	return records
+}
+
+// Reset 清空某个用户或 IP 的令牌桶，立即解除限制
+func (RateLimitLogic) Reset(ctx context.Context, policy, subject string) error {
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	return redisClient.DEL("ratelimit:bucket:" + policy + ":" + subject)
+}
+
+// spec 读取策略配置，格式为“容量/秒数”，如 5/300 表示最多连续 5 次，之后每 300 秒恢复 1 次。
+// 没有配置或未开启时不限制；新手（声望等级低于 newbie_level）的容量和速度按 newbie_ratio 打折
+func (RateLimitLogic) spec(policy string, me *model.Me) (rateSpec, bool) {
+	if !config.ConfigFile.MustBool("rate_limit", "enable", false) {
+		return rateSpec{}, false
+	}
+
+	value := config.ConfigFile.MustValue("rate_limit", policy)
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return rateSpec{}, false
+	}
+	spec := rateSpec{
+		burst:    goutils.MustInt(strings.TrimSpace(parts[0])),
+		interval: time.Duration(goutils.MustInt(strings.TrimSpace(parts[1]))) * time.Second,
+	}
+	if spec.burst <= 0 || spec.interval <= 0 {
+		return rateSpec{}, false
+	}
+
+	if me != nil && me.Level < config.ConfigFile.MustInt("rate_limit", "newbie_level", 0) {
+		ratio := config.ConfigFile.MustFloat64("rate_limit", "newbie_ratio", 1)
+		if ratio > 0 && ratio < 1 {
+			spec.burst = int(math.Max(1, math.Floor(float64(spec.burst)*ratio)))
+			spec.interval = time.Duration(float64(spec.interval) / ratio)
+		}
+	}
+
+	return spec, true
+}
+
+// take 从 subject 的令牌桶中取一个令牌
+func (self RateLimitLogic) take(policy, subject string, spec rateSpec) time.Duration {
+	return self.acquire(policy, subject, spec, 1)
+}
+
+// acquire 从 subject 的令牌桶中取 cost 个令牌（0 表示只检查），被限制时记录下来供后台查看。redis 出错时放行
+func (RateLimitLogic) acquire(policy, subject string, spec rateSpec, cost int) time.Duration {
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	now := time.Now()
+	interval := int64(spec.interval / time.Millisecond)
+	wait, err := redis.Int64(redisClient.EVAL(tokenBucketScript, []string{"ratelimit:bucket:" + policy + ":" + subject},
+		spec.burst, interval, now.UnixNano()/int64(time.Millisecond), cost))
+	if err != nil {
+		logger.Error("RateLimitLogic take redis error:", err)
+		return 0
+	}
+	if wait <= 0 {
+		return 0
+	}
+
+	day := now.Format("20060102")
+	member := policy + "|" + subject
+	redisClient.ZINCRBY("ratelimit:throttled:"+day, 1, member)
+	redisClient.EXPIRE("ratelimit:throttled:"+day, 7*86400)
+	redisClient.HSET("ratelimit:throttled_at:"+day, member, strconv.FormatInt(now.Unix(), 10))
+	redisClient.EXPIRE("ratelimit:throttled_at:"+day, 7*86400)
+
+	logger.Info("rate limit:", member, "wait", wait, "ms")
+
+	return time.Duration(wait) * time.Millisecond
+}
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"sander/db"
 	"sander/logger"
@@ -25,7 +26,7 @@ var DefaultReading = ReadingLogic{}
 
 func (ReadingLogic) FindLastList(beginTime string) ([]*model.MorningReading, error) {
 	readings := make([]*model.MorningReading, 0)
-	err := db.MasterDB.Where("ctime>? AND rtype=0", beginTime).OrderBy("id DESC").Find(&readings)
+	err := db.MasterDB.Where("ctime>? AND rtype=0 AND status=?", beginTime, model.ReadingStatusOnline).OrderBy("id DESC").Find(&readings)
 
 	return readings, err
 }
@@ -33,7 +34,7 @@ func (ReadingLogic) FindLastList(beginTime string) ([]*model.MorningReading, err
 // 获取晨读列表（分页）
 func (ReadingLogic) FindBy(ctx context.Context, limit, rtype int, lastIds ...int) []*model.MorningReading {
 
-	dbSession := db.MasterDB.Where("rtype=?", rtype)
+	dbSession := db.MasterDB.Where("rtype=? AND status=?", rtype, model.ReadingStatusOnline)
 	if len(lastIds) > 0 && lastIds[0] > 0 {
 		dbSession.And("id<?", lastIds[0])
 	}
@@ -58,7 +59,7 @@ func (ReadingLogic) IReading(ctx context.Context, id int) string {
 		return "/readings"
 	}
 
-	if reading.Id == 0 {
+	if reading.Id == 0 || reading.Status == model.ReadingStatusScheduled {
 		return "/readings"
 	}
 
@@ -100,6 +101,9 @@ func (ReadingLogic) FindReadingByPage(ctx context.Context, conds map[string]stri
 
 // SaveReading 保存晨读
 func (ReadingLogic) SaveReading(ctx context.Context, form url.Values, username string) (errMsg string, err error) {
+	publishAt := form.Get("publish_at")
+	form.Del("publish_at")
+
 	reading := &model.MorningReading{}
 	err = schemaDecoder.Decode(reading, form)
 	if err != nil {
@@ -128,9 +132,20 @@ func (ReadingLogic) SaveReading(ctx context.Context, form url.Values, username s
 
 	reading.Username = username
 
+	// 填写了发布时间且晚于现在，定时发布
+	reading.Status = model.ReadingStatusOnline
+	reading.PublishAt = parseMissionTime(publishAt)
+	if publishAt != "" && reading.PublishAt.IsZero() {
+		errMsg, err = "发布时间格式不正确", errors.New("发布时间格式不正确")
+		return
+	}
+	if reading.PublishAt.After(time.Now()) {
+		reading.Status = model.ReadingStatusScheduled
+	}
+
 	logger.Debug("typ:%+v,id:%+v", reading.Rtype, reading.Id)
 	if reading.Id != 0 {
-		_, err = db.MasterDB.Id(reading.Id).Update(reading)
+		_, err = db.MasterDB.Id(reading.Id).MustCols("status", "publish_at").Update(reading)
 	} else {
 		if len(readings) > 0 {
 			logger.Error("reading report:%+v", reading)
@@ -164,3 +179,25 @@ func (ReadingLogic) FindById(ctx context.Context, id int) *model.MorningReading
 
 	return reading
 }
+
+// PublishScheduled 发布到了发布时间的定时晨读，晨读日期改为发布日期
+func (ReadingLogic) PublishScheduled() {
+	readings := make([]*model.MorningReading, 0)
+	err := db.MasterDB.Where("status=? AND publish_at<=?", model.ReadingStatusScheduled, time.Now()).Find(&readings)
+	if err != nil {
+		logger.Error("ReadingLogic PublishScheduled find error:", err)
+		return
+	}
+
+	for _, reading := range readings {
+		change := map[string]interface{}{
+			"status": model.ReadingStatusOnline,
+			"ctime":  reading.PublishAt,
+		}
+		_, err = db.MasterDB.Table(new(model.MorningReading)).
+			Where("id=? AND status=?", reading.Id, model.ReadingStatusScheduled).Update(change)
+		if err != nil {
+			logger.Error("ReadingLogic PublishScheduled update error:", err)
+		}
+	}
+}
@@ -0,0 +1,533 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author:polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+
+	"github.com/go-xorm/xorm"
+	"github.com/polaris1119/goutils"
+	"golang.org/x/net/context"
+)
+
+type ReportLogic struct{}
+
+var DefaultReport = ReportLogic{}
+
+var ReportHandledErr = errors.New("该举报已经处理过了")
+
+// Report 举报主题、文章、资源、评论或用户。同一对象的举报汇总到举报队列，举报人数达到阈值时自动隐藏内容
+func (self ReportLogic) Report(ctx context.Context, me *model.Me, form url.Values) error {
+	objtype := goutils.MustInt(form.Get("objtype"))
+	objid := goutils.MustInt(form.Get("objid"))
+	reason := goutils.MustInt(form.Get("reason"))
+	content := strings.TrimSpace(form.Get("content"))
+
+	if _, ok := model.ReportReasonMap[reason]; !ok {
+		return errors.New("请选择举报原因")
+	}
+	if reason == model.ReportReasonOther && content == "" {
+		return errors.New("请填写举报说明")
+	}
+	if utf8.RuneCountInString(content) > 200 {
+		return errors.New("举报说明不能超过 200 个字")
+	}
+
+	reportObject := self.findTarget(ctx, objtype, objid)
+	if reportObject == nil {
+		return errors.New("举报的内容不存在")
+	}
+	if reportObject.ObjUid == me.Uid {
+		return errors.New("不能举报自己")
+	}
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	exists := &model.ReportObject{}
+	_, err := session.Where("objtype=? AND objid=?", objtype, objid).ForUpdate().Get(exists)
+	if err != nil {
+		session.Rollback()
+		logger.Error("ReportLogic Report find object error:", err)
+		return errors.New("服务内部错误")
+	}
+	if exists.Id > 0 {
+		if exists.Status == model.ReportStatusRemoved || exists.Status == model.ReportStatusBanned {
+			session.Rollback()
+			return errors.New("该内容已被管理员处理")
+		}
+		reportObject = exists
+	}
+
+	num, err := session.Where("objtype=? AND objid=? AND uid=? AND status=?", objtype, objid, me.Uid, model.ReportStatusPending).Count(new(model.Report))
+	if err != nil {
+		session.Rollback()
+		logger.Error("ReportLogic Report count error:", err)
+		return errors.New("服务内部错误")
+	}
+	if num > 0 {
+		session.Rollback()
+		return errors.New("你已经举报过了，请等待管理员处理")
+	}
+
+	_, err = session.Insert(&model.Report{
+		Objtype: objtype,
+		Objid:   objid,
+		Uid:     me.Uid,
+		Reason:  reason,
+		Content: content,
+	})
+	if err != nil {
+		session.Rollback()
+		logger.Error("ReportLogic Report insert error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	reportObject.Num++
+	reportObject.Status = model.ReportStatusPending
+
+	hideNum := UserSetting[model.KeyReportHideNum]
+	needHide := hideNum > 0 && reportObject.Num >= hideNum && !reportObject.Hidden && objtype != model.TypeUser
+	if needHide {
+		err = self.hide(session, reportObject)
+		if err != nil {
+			session.Rollback()
+			logger.Error("ReportLogic Report hide error:", err)
+			return errors.New("服务内部错误")
+		}
+	}
+
+	if reportObject.Id == 0 {
+		_, err = session.Omit("handled_at").Insert(reportObject)
+	} else {
+		_, err = session.Id(reportObject.Id).Cols("num", "status", "hidden", "orig_state").Update(reportObject)
+	}
+	if err != nil {
+		session.Rollback()
+		logger.Error("ReportLogic Report save object error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	session.Commit()
+
+	if needHide {
+		DefaultFeed.setState(objid, objtype, model.FeedOffline)
+	}
+
+	return nil
+}
+
+// Review 内容命中“人工审核”的过滤规则、疑似垃圾内容或作者受处罚：系统放入举报处理队列并立即隐藏，管理员驳回（即审核通过）后恢复。
+// reason 为 ReportReasonFilter、ReportReasonSpamScore 或 ReportReasonSanction
+func (self ReportLogic) Review(ctx context.Context, objtype, objid, reason int, content string) error {
+	reportObject := self.findTarget(ctx, objtype, objid)
+	if reportObject == nil || objtype == model.TypeUser {
+		return nil
+	}
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	exists := &model.ReportObject{}
+	_, err := session.Where("objtype=? AND objid=?", objtype, objid).ForUpdate().Get(exists)
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+	if exists.Id > 0 {
+		if exists.Status == model.ReportStatusRemoved || exists.Status == model.ReportStatusBanned {
+			session.Rollback()
+			return nil
+		}
+		reportObject = exists
+	}
+
+	_, err = session.Insert(&model.Report{
+		Objtype: objtype,
+		Objid:   objid,
+		Reason:  reason,
+		Content: content,
+	})
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	reportObject.Num++
+	reportObject.Status = model.ReportStatusPending
+
+	needHide := !reportObject.Hidden
+	if needHide {
+		if err = self.hide(session, reportObject); err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+
+	if reportObject.Id == 0 {
+		_, err = session.Omit("handled_at").Insert(reportObject)
+	} else {
+		_, err = session.Id(reportObject.Id).Cols("num", "status", "hidden", "orig_state").Update(reportObject)
+	}
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+
+	session.Commit()
+
+	if needHide {
+		DefaultFeed.setState(objid, objtype, model.FeedOffline)
+	}
+
+	return nil
+}
+
+// Handle 处理举报：驳回（恢复被自动隐藏的内容）、删除内容、删除内容并封禁作者，并通知举报人处理结果
+func (self ReportLogic) Handle(ctx context.Context, id, status int, me *model.Me) error {
+	reportObject := &model.ReportObject{}
+	_, err := db.MasterDB.Id(id).Get(reportObject)
+	if err != nil {
+		logger.Error("ReportLogic Handle find error:", err)
+		return errors.New("服务内部错误")
+	}
+	if reportObject.Id == 0 {
+		return NotFoundErr
+	}
+	if reportObject.Status != model.ReportStatusPending {
+		return ReportHandledErr
+	}
+	if status == model.ReportStatusRemoved && reportObject.Objtype == model.TypeUser {
+		return errors.New("举报用户只能驳回或封禁")
+	}
+
+	reports := make([]*model.Report, 0)
+	err = db.MasterDB.Where("objtype=? AND objid=? AND status=?", reportObject.Objtype, reportObject.Objid, model.ReportStatusPending).Find(&reports)
+	if err != nil {
+		logger.Error("ReportLogic Handle find reports error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	wasHidden := reportObject.Hidden
+	switch status {
+	case model.ReportStatusApproved:
+		if reportObject.Hidden {
+			err = self.unhide(session, reportObject)
+		}
+	case model.ReportStatusRemoved, model.ReportStatusBanned:
+		// 内容会放入回收站，先恢复自动隐藏前的状态，从回收站恢复后就是原来的样子
+		if reportObject.Hidden {
+			err = self.unhide(session, reportObject)
+		}
+	default:
+		err = errors.New("invalid status:" + strconv.Itoa(status))
+	}
+	if err != nil {
+		session.Rollback()
+		logger.Error("ReportLogic Handle change object error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	reportObject.Status = status
+	reportObject.Num = 0
+	reportObject.OpUser = me.Username
+	reportObject.HandledAt = time.Now()
+	affected, err := session.Id(reportObject.Id).Cols("num", "status", "hidden", "orig_state", "op_user", "handled_at").
+		Where("status=?", model.ReportStatusPending).Update(reportObject)
+	if err == nil && affected == 0 {
+		// 其他管理员已经抢先处理了
+		session.Rollback()
+		return ReportHandledErr
+	}
+	if err == nil {
+		_, err = session.Table(new(model.Report)).Where("objtype=? AND objid=? AND status=?", reportObject.Objtype, reportObject.Objid, model.ReportStatusPending).
+			Update(map[string]interface{}{"status": status})
+	}
+	if err != nil {
+		session.Rollback()
+		logger.Error("ReportLogic Handle update error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	session.Commit()
+
+	self.addSpamSample(ctx, reportObject, reports, me)
+
+	if status == model.ReportStatusApproved {
+		if wasHidden && feedTypes[reportObject.Objtype] {
+			DefaultFeed.online(reportObject.Objid, reportObject.Objtype)
+		}
+		self.approveSanction(ctx, reportObject, reports)
+	} else if !reportObject.IsUser() {
+		err = DefaultTrash.Delete(ctx, reportObject.Objtype, reportObject.Objid, me.Uid, "被举报，经核实已删除")
+		if err != nil {
+			logger.Error("ReportLogic Handle delete object error:", err)
+		}
+	}
+
+	if status == model.ReportStatusBanned {
+		DefaultUser.UpdateUserStatus(ctx, reportObject.ObjUid, model.UserStatusOutage)
+	}
+
+	self.notify(ctx, reportObject, reports)
+
+	return nil
+}
+
+// FindByPage 举报队列（分页）：后台用
+func (self ReportLogic) FindByPage(ctx context.Context, conds map[string]string, curPage, limit int) ([]*model.ReportObject, int) {
+	session := db.MasterDB.NewSession()
+
+	for k, v := range conds {
+		session.And(k+"=?", v)
+	}
+
+	totalSession := session.Clone()
+
+	offset := (curPage - 1) * limit
+	reportObjects := make([]*model.ReportObject, 0)
+	err := session.OrderBy("num DESC, id DESC").Limit(limit, offset).Find(&reportObjects)
+	if err != nil {
+		logger.Error("ReportLogic FindByPage error:", err)
+		return nil, 0
+	}
+
+	total, err := totalSession.Count(new(model.ReportObject))
+	if err != nil {
+		logger.Error("ReportLogic FindByPage count error:", err)
+		return nil, 0
+	}
+
+	self.fillReasons(reportObjects)
+
+	return reportObjects, int(total)
+}
+
+// fillReasons 统计每个对象待处理（已处理的为最后一次处理）举报的原因分布
+func (ReportLogic) fillReasons(reportObjects []*model.ReportObject) {
+	if len(reportObjects) == 0 {
+		return
+	}
+
+	objids := make([]int, len(reportObjects))
+	for i, reportObject := range reportObjects {
+		objids[i] = reportObject.Objid
+	}
+
+	reports := make([]*model.Report, 0)
+	err := db.MasterDB.In("objid", objids).Find(&reports)
+	if err != nil {
+		logger.Error("ReportLogic fillReasons error:", err)
+		return
+	}
+
+	for _, reportObject := range reportObjects {
+		reportObject.Reasons = make(map[string]int)
+		for _, report := range reports {
+			if report.Objtype == reportObject.Objtype && report.Objid == reportObject.Objid && report.Status == reportObject.Status {
+				reportObject.Reasons[report.ReasonName()]++
+			}
+		}
+	}
+}
+
+// findTarget 被举报的对象，返回填充了标题、链接和作者的 ReportObject，对象不存在时返回 nil
+func (ReportLogic) findTarget(ctx context.Context, objtype, objid int) *model.ReportObject {
+	if objid == 0 {
+		return nil
+	}
+
+	reportObject := &model.ReportObject{Objtype: objtype, Objid: objid}
+
+	switch objtype {
+	case model.TypeTopic:
+		topic := DefaultTopic.findByTid(objid)
+		if topic.Tid == 0 || topic.Flag > model.FlagNormal {
+			return nil
+		}
+		reportObject.ObjUid = topic.Uid
+		reportObject.Title = topic.Title
+		reportObject.Url = fmt.Sprintf("/topics/%d", objid)
+	case model.TypeArticle:
+		article, err := DefaultArticle.FindById(ctx, objid)
+		if err != nil || article.Id == 0 || article.Status == model.ArticleStatusOffline || article.Status == model.ArticleStatusShadow {
+			return nil
+		}
+		reportObject.ObjUid = DefaultArticle.getOwner(objid)
+		reportObject.Title = article.Title
+		reportObject.Url = fmt.Sprintf("/articles/%d", objid)
+	case model.TypeResource:
+		resource := DefaultResource.findById(objid)
+		if resource.Id == 0 {
+			return nil
+		}
+		reportObject.ObjUid = resource.Uid
+		reportObject.Title = resource.Title
+		reportObject.Url = fmt.Sprintf("/resources/%d", objid)
+	case model.TypeComment:
+		comment, err := DefaultComment.FindById(objid)
+		if err != nil || comment.Cid == 0 || comment.Flag == model.FlagAuditDelete || comment.Flag == model.FlagShadow {
+			return nil
+		}
+		reportObject.ObjUid = comment.Uid
+		reportObject.Title = comment.Content
+		if utf8.RuneCountInString(comment.Content) > 50 {
+			reportObject.Title = string([]rune(comment.Content)[:50]) + "..."
+		}
+		reportObject.Url = fmt.Sprintf("%s%d#reply-%d", model.PathUrlMap[comment.Objtype], comment.Objid, comment.Floor)
+	case model.TypeUser:
+		user := DefaultUser.FindOne(ctx, "uid", objid)
+		if user.Uid == 0 {
+			return nil
+		}
+		reportObject.ObjUid = user.Uid
+		reportObject.Title = user.Username
+		reportObject.Url = "/user/" + user.Username
+	default:
+		return nil
+	}
+
+	return reportObject
+}
+
+// hide 隐藏被举报的内容，记录原来的状态以便驳回时恢复。资源没有状态字段，只通过 hidden 过滤
+func (ReportLogic) hide(session *xorm.Session, reportObject *model.ReportObject) error {
+	var err error
+
+	switch reportObject.Objtype {
+	case model.TypeTopic:
+		topic := &model.Topic{}
+		_, err = session.Id(reportObject.Objid).Get(topic)
+		if err == nil {
+			reportObject.OrigState = int(topic.Flag)
+			_, err = session.Exec("UPDATE topics SET flag=?, mtime=mtime WHERE tid=?", model.FlagAuditDelete, reportObject.Objid)
+		}
+	case model.TypeArticle:
+		article := &model.Article{}
+		_, err = session.Id(reportObject.Objid).Get(article)
+		if err == nil {
+			reportObject.OrigState = article.Status
+			_, err = session.Exec("UPDATE articles SET status=?, mtime=mtime WHERE id=?", model.ArticleStatusOffline, reportObject.Objid)
+		}
+	case model.TypeComment:
+		comment := &model.Comment{}
+		_, err = session.Id(reportObject.Objid).Get(comment)
+		if err == nil {
+			reportObject.OrigState = comment.Flag
+			_, err = session.Table(new(model.Comment)).Id(reportObject.Objid).Update(map[string]interface{}{"flag": model.FlagAuditDelete})
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	reportObject.Hidden = true
+	return nil
+}
+
+// unhide 驳回举报时恢复被自动隐藏的内容
+func (ReportLogic) unhide(session *xorm.Session, reportObject *model.ReportObject) error {
+	var err error
+
+	switch reportObject.Objtype {
+	case model.TypeTopic:
+		_, err = session.Exec("UPDATE topics SET flag=?, mtime=mtime WHERE tid=?", reportObject.OrigState, reportObject.Objid)
+	case model.TypeArticle:
+		_, err = session.Exec("UPDATE articles SET status=?, mtime=mtime WHERE id=?", reportObject.OrigState, reportObject.Objid)
+	case model.TypeComment:
+		_, err = session.Table(new(model.Comment)).Id(reportObject.Objid).Update(map[string]interface{}{"flag": reportObject.OrigState})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	reportObject.Hidden = false
+	return nil
+}
+
+// addSpamSample 版主的处理结果作为垃圾内容分类器的训练样本：驳回为正常；因广告或疑似垃圾内容被删除为垃圾，其他原因删除的不记录
+func (ReportLogic) addSpamSample(ctx context.Context, reportObject *model.ReportObject, reports []*model.Report, me *model.Me) {
+	if _, ok := model.SpamTypeMap[reportObject.Objtype]; !ok {
+		return
+	}
+
+	isSpam := false
+	if reportObject.Status != model.ReportStatusApproved {
+		for _, report := range reports {
+			if report.Reason == model.ReportReasonSpam || report.Reason == model.ReportReasonSpamScore {
+				isSpam = true
+				break
+			}
+		}
+		if !isSpam {
+			return
+		}
+	}
+
+	err := DefaultSpam.AddSample(ctx, reportObject.Objtype, reportObject.Objid, isSpam, me.Username)
+	if err != nil {
+		logger.Error("ReportLogic addSpamSample error:", err)
+	}
+}
+
+// approveSanction 观察期用户的内容审核通过，计入观察期
+func (ReportLogic) approveSanction(ctx context.Context, reportObject *model.ReportObject, reports []*model.Report) {
+	for _, report := range reports {
+		if report.Reason == model.ReportReasonSanction {
+			DefaultUserSanction.Approve(ctx, reportObject.ObjUid)
+			return
+		}
+	}
+}
+
+// notify 通知举报人处理结果
+func (ReportLogic) notify(ctx context.Context, reportObject *model.ReportObject, reports []*model.Report) {
+	content := "经核实，该内容没有违规，感谢你的反馈"
+	switch reportObject.Status {
+	case model.ReportStatusRemoved:
+		content = "经核实，该内容已被删除，感谢你的反馈"
+	case model.ReportStatusBanned:
+		content = "经核实，该内容已被删除，发布者已被封禁，感谢你的反馈"
+		if reportObject.Objtype == model.TypeUser {
+			content = "经核实，该用户已被封禁，感谢你的反馈"
+		}
+	}
+
+	for _, report := range reports {
+		// 系统放入队列的审核不需要通知
+		if report.Uid == 0 {
+			continue
+		}
+
+		ext := map[string]interface{}{
+			"objid":    reportObject.Id,
+			"objtype":  reportObject.Objtype,
+			"objtitle": reportObject.Title,
+			"objurl":   reportObject.Url,
+			"content":  content,
+		}
+		DefaultMessage.SendSystemMsgTo(ctx, report.Uid, model.MsgtypeReport, ext)
+	}
+}
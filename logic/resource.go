@@ -7,6 +7,7 @@
 package logic
 
 import (
+	"fmt"
 	"net/url"
 	"strconv"
 	"time"
@@ -22,6 +23,14 @@ import (
 
 type ResourceLogic struct{}
 
+// resourceHiddenCond 资源没有状态字段，被举报隐藏或删除的资源通过举报队列过滤
+var resourceHiddenCond = fmt.Sprintf("resource.id NOT IN(SELECT objid FROM report_object WHERE objtype=%d AND hidden=1)", model.TypeResource)
+
+// resourceVisibleCond 列表中还要去掉影子封禁的资源，作者自己除外
+func resourceVisibleCond(ctx context.Context) string {
+	return fmt.Sprintf("%s AND (resource.flag!=%d OR resource.uid=%d)", resourceHiddenCond, model.FlagShadow, currentUid(ctx))
+}
+
 var DefaultResource = ResourceLogic{}
 
 // Publish 增加（修改）资源
@@ -59,8 +68,11 @@ func (ResourceLogic) Publish(ctx context.Context, me *model.Me, form url.Values)
 			logger.Error("ResourceLogic Publish decode error:", err)
 			return
 		}
-		_, err = db.MasterDB.Id(id).Update(resource)
+		_, err = updateWithVersion(db.MasterDB.Id(id), form.Get("version"), resource)
 		if err != nil {
+			if err == ConflictErr {
+				return
+			}
 			logger.Error("更新资源 【%s】 信息失败：%s\n", id, err)
 			return
 		}
@@ -87,6 +99,11 @@ func (ResourceLogic) Publish(ctx context.Context, me *model.Me, form url.Values)
 			return
 		}
 
+		sanction := DefaultUserSanction.Find(uid)
+		if sanction == model.UserSanctionShadowBan {
+			resource.Flag = model.FlagShadow
+		}
+
 		_, err = session.Insert(resource)
 		if err != nil {
 			session.Rollback()
@@ -110,17 +127,24 @@ func (ResourceLogic) Publish(ctx context.Context, me *model.Me, form url.Values)
 			return
 		}
 
-		// 发布动态
-		DefaultFeed.publish(resource, resourceEx)
-
-		// 给 被@用户 发系统消息
-		ext := map[string]interface{}{
-			"objid":   resource.Id,
-			"objtype": model.TypeResource,
-			"uid":     uid,
-			"msgtype": model.MsgtypePublishAtMe,
+		// 影子封禁的资源只有作者能看到，观察期的资源审核通过前不展示，都不发布动态和通知
+		hidden := resource.Flag == model.FlagShadow
+		if sanction == model.UserSanctionProbation {
+			hidden = DefaultUserSanction.Hold(ctx, sanction, model.TypeResource, resource.Id)
+		}
+		if !hidden {
+			// 发布动态
+			DefaultFeed.publish(resource, resourceEx)
+
+			// 给 被@用户 发系统消息
+			ext := map[string]interface{}{
+				"objid":   resource.Id,
+				"objtype": model.TypeResource,
+				"uid":     uid,
+				"msgtype": model.MsgtypePublishAtMe,
+			}
+			go DefaultMessage.SendSysMsgAtUsernames(ctx, form.Get("usernames"), ext, 0)
 		}
-		go DefaultMessage.SendSysMsgAtUsernames(ctx, form.Get("usernames"), ext, 0)
 
 		go publishObservable.NotifyObservers(uid, model.TypeResource, resource.Id)
 	}
@@ -140,9 +164,9 @@ func (ResourceLogic) Total() int64 {
 // FindBy 获取资源列表（分页）
 func (ResourceLogic) FindBy(ctx context.Context, limit int, lastIds ...int) []*model.Resource {
 
-	dbSession := db.MasterDB.OrderBy("id DESC").Limit(limit)
+	dbSession := db.MasterDB.Where(resourceVisibleCond(ctx)).OrderBy("id DESC").Limit(limit)
 	if len(lastIds) > 0 && lastIds[0] > 0 {
-		dbSession.Where("id<?", lastIds[0])
+		dbSession.And("id<?", lastIds[0])
 	}
 
 	resourceList := make([]*model.Resource, 0)
@@ -163,9 +187,9 @@ func (self ResourceLogic) FindAll(ctx context.Context, paginator *Paginator, ord
 		resourceInfos = make([]*model.ResourceInfo, 0)
 	)
 
-	session := db.MasterDB.Join("INNER", "resource_ex", "resource.id=resource_ex.id")
+	session := db.MasterDB.Join("INNER", "resource_ex", "resource.id=resource_ex.id").Where(resourceVisibleCond(ctx))
 	if querystring != "" {
-		session.Where(querystring, args...)
+		session.And(querystring, args...)
 	}
 	err := session.OrderBy(orderBy).Limit(count, paginator.Offset()).Find(&resourceInfos)
 	if err != nil {
@@ -215,9 +239,9 @@ func (ResourceLogic) Count(ctx context.Context, querystring string, args ...inte
 		err   error
 	)
 	if querystring == "" {
-		total, err = db.MasterDB.Count(new(model.Resource))
+		total, err = db.MasterDB.Where(resourceVisibleCond(ctx)).Count(new(model.Resource))
 	} else {
-		total, err = db.MasterDB.Where(querystring, args...).Count(new(model.Resource))
+		total, err = db.MasterDB.Where(resourceVisibleCond(ctx)).And(querystring, args...).Count(new(model.Resource))
 	}
 
 	if err != nil {
@@ -235,14 +259,14 @@ func (ResourceLogic) FindByCatid(ctx context.Context, paginator *Paginator, cati
 		resourceInfos = make([]*model.ResourceInfo, 0)
 	)
 
-	err := db.MasterDB.Join("INNER", "resource_ex", "resource.id=resource_ex.id").Where("catid=?", catid).
+	err := db.MasterDB.Join("INNER", "resource_ex", "resource.id=resource_ex.id").Where("catid=?", catid).And(resourceVisibleCond(ctx)).
 		Desc("resource.mtime").Limit(count, paginator.Offset()).Find(&resourceInfos)
 	if err != nil {
 		logger.Error("ResourceLogic FindByCatid error:", err)
 		return
 	}
 
-	total, err = db.MasterDB.Where("catid=?", catid).Count(new(model.Resource))
+	total, err = db.MasterDB.Where("catid=?", catid).And(resourceVisibleCond(ctx)).Count(new(model.Resource))
 	if err != nil {
 		logger.Error("ResourceLogic FindByCatid count error:", err)
 		return
@@ -318,11 +342,16 @@ func (ResourceLogic) findByIds(ids []int) map[int]*model.Resource {
 	return resources
 }
 
-// 获得资源详细信息
-func (ResourceLogic) FindById(ctx context.Context, id int) (resourceMap map[string]interface{}, comments []map[string]interface{}) {
+// 获得资源详细信息。影子封禁的资源只有作者自己能看到
+func (ResourceLogic) FindById(ctx context.Context, id int, me *model.Me) (resourceMap map[string]interface{}, comments []map[string]interface{}) {
+	uid := 0
+	if me != nil {
+		uid = me.Uid
+	}
 
 	resourceInfo := &model.ResourceInfo{}
-	_, err := db.MasterDB.Join("INNER", "resource_ex", "resource.id=resource_ex.id").Where("resource.id=?", id).Get(resourceInfo)
+	_, err := db.MasterDB.Join("INNER", "resource_ex", "resource.id=resource_ex.id").Where("resource.id=?", id).And(resourceHiddenCond).
+		And("(resource.flag!=? OR resource.uid=?)", model.FlagShadow, uid).Get(resourceInfo)
 	if err != nil {
 		logger.Error("ResourceLogic FindById error:", err)
 		return
@@ -350,7 +379,7 @@ func (ResourceLogic) FindById(ctx context.Context, id int) (resourceMap map[stri
 	}
 
 	// 评论信息
-	comments, ownerUser, _ := DefaultComment.FindObjComments(ctx, id, model.TypeResource, resource.Uid, 0)
+	comments, ownerUser, _ := DefaultComment.FindObjComments(ctx, id, model.TypeResource, resource.Uid, 0, me)
 	resourceMap["user"] = ownerUser
 	return
 }
@@ -370,7 +399,7 @@ func (ResourceLogic) FindResource(ctx context.Context, id int) *model.Resource {
 // 获得某个用户最近的资源
 func (ResourceLogic) FindRecent(ctx context.Context, uid int) []*model.Resource {
 	resourceList := make([]*model.Resource, 0)
-	err := db.MasterDB.Where("uid=?", uid).Limit(5).OrderBy("id DESC").Find(&resourceList)
+	err := db.MasterDB.Where("uid=? AND (flag!=? OR uid=?)", uid, model.FlagShadow, currentUid(ctx)).Limit(5).OrderBy("id DESC").Find(&resourceList)
 	if err != nil {
 		logger.Error("resource logic FindRecent error:%+v", err)
 		return nil
@@ -10,6 +10,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -39,9 +40,30 @@ func (self SearcherLogic) Indexing(isAll bool) {
 	go self.IndexingOpenProject(isAll)
 	go self.IndexingTopic(isAll)
 	go self.IndexingResource(isAll)
+	go self.IndexingTrash(isAll)
 	self.IndexingArticle(isAll)
 }
 
+// IndexingTrash 从索引中删除放入回收站的内容（恢复后 mtime 会更新，增量索引时重新加入）
+func (self SearcherLogic) IndexingTrash(isAll bool) {
+	solrClient := NewSolrClient()
+
+	since := time.Time{}
+	if !isAll {
+		since = time.Now().Add(-5 * time.Minute)
+	}
+
+	for _, objtype := range []int{model.TypeTopic, model.TypeArticle, model.TypeResource, model.TypeProject} {
+		for _, objid := range DefaultTrash.findDeletedIds(objtype, since) {
+			solrClient.PushDel(&model.DelCommand{Id: fmt.Sprintf("%d%d", objtype, objid)})
+		}
+	}
+
+	if len(solrClient.delCommands) > 0 {
+		solrClient.Post()
+	}
+}
+
 // IndexingArticle 索引博文
 func (self SearcherLogic) IndexingArticle(isAll bool) {
 	solrClient := NewSolrClient()
@@ -85,7 +107,7 @@ func (self SearcherLogic) IndexingArticle(isAll bool) {
 			}
 
 			document := model.NewDocument(article, nil)
-			if article.Status != model.ArticleStatusOffline {
+			if article.Status == model.ArticleStatusNew || article.Status == model.ArticleStatusOnline {
 				solrClient.PushAdd(model.NewDefaultArgsAddCommand(document))
 			} else {
 				solrClient.PushDel(model.NewDelCommand(document))
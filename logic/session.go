@@ -0,0 +1,243 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"sander/db/nosql"
+	"sander/logger"
+	"sander/model"
+
+	"github.com/polaris1119/goutils"
+)
+
+const (
+	// 多久没有访问会话过期
+	sessionExpire = 30 * 24 * 3600
+	// 最后访问时间、IP 最多多久更新一次，避免每个请求都写 redis
+	sessionTouchInterval = 60
+	// 保存的 User-Agent 最长多少
+	sessionUAMaxLen = 255
+)
+
+// 会话数据：session:{sid} 为 hash；session:uid:{uid} 为用户所有会话的 sid 集合
+func sessionKey(sid string) string {
+	return "session:" + sid
+}
+
+func userSessionsKey(uid int) string {
+	return "session:uid:" + strconv.Itoa(uid)
+}
+
+// sessionId 会话标识的摘要，页面上用它代替会话标识
+func sessionId(sid string) string {
+	sum := sha256.Sum256([]byte(sid))
+	return hex.EncodeToString(sum[:8])
+}
+
+type SessionLogic struct{}
+
+var DefaultSession = SessionLogic{}
+
+// Create 登录成功后创建会话，返回会话标识（放入 cookie 或作为 App 的 token）
+func (SessionLogic) Create(uid, typ int, ip, userAgent string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		logger.Error("SessionLogic Create rand error:", err)
+		return "", errors.New("服务内部错误")
+	}
+	sid := hex.EncodeToString(buf)
+
+	if len(userAgent) > sessionUAMaxLen {
+		userAgent = userAgent[:sessionUAMaxLen]
+	}
+
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	now := time.Now().Unix()
+	err := redisClient.HMSET(sessionKey(sid), map[string]interface{}{
+		"uid":        uid,
+		"type":       typ,
+		"ip":         ip,
+		"ua":         userAgent,
+		"created_at": now,
+		"last_seen":  now,
+	})
+	if err == nil {
+		err = redisClient.EXPIRE(sessionKey(sid), sessionExpire)
+	}
+	if err == nil {
+		err = redisClient.SADD(userSessionsKey(uid), sid)
+	}
+	if err == nil {
+		err = redisClient.EXPIRE(userSessionsKey(uid), sessionExpire)
+	}
+	if err != nil {
+		logger.Error("SessionLogic Create error:", err)
+		return "", errors.New("服务内部错误")
+	}
+
+	return sid, nil
+}
+
+// Find 会话对应的用户，会话不存在或已过期时返回 0。同时记录最后访问的时间和 IP
+func (SessionLogic) Find(sid, ip string) int {
+	if sid == "" {
+		return 0
+	}
+
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	fields, err := redisClient.HGETALL(sessionKey(sid))
+	if err != nil {
+		logger.Error("SessionLogic Find error:", err)
+		return 0
+	}
+	uid := goutils.MustInt(fields["uid"])
+	if uid == 0 {
+		return 0
+	}
+
+	now := time.Now().Unix()
+	if now-goutils.MustInt64(fields["last_seen"]) >= sessionTouchInterval || fields["ip"] != ip {
+		err = redisClient.HMSET(sessionKey(sid), map[string]interface{}{"last_seen": now, "ip": ip})
+		if err == nil {
+			redisClient.EXPIRE(sessionKey(sid), sessionExpire)
+			redisClient.EXPIRE(userSessionsKey(uid), sessionExpire)
+		} else {
+			logger.Error("SessionLogic Find touch error:", err)
+		}
+	}
+
+	return uid
+}
+
+// FindByUser 用户所有有效的会话，最近访问的在前。curSid 为当前请求的会话
+func (SessionLogic) FindByUser(uid int, curSid string) []*model.UserSession {
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	sids, err := redisClient.SMEMBERS(userSessionsKey(uid))
+	if err != nil {
+		logger.Error("SessionLogic FindByUser error:", err)
+		return nil
+	}
+
+	sessions := make([]*model.UserSession, 0, len(sids))
+	for _, sid := range sids {
+		fields, err := redisClient.HGETALL(sessionKey(sid))
+		if err != nil {
+			logger.Error("SessionLogic FindByUser get session error:", err)
+			continue
+		}
+		// 已过期的顺便从集合中删除
+		if goutils.MustInt(fields["uid"]) != uid {
+			redisClient.SREM(userSessionsKey(uid), sid)
+			continue
+		}
+
+		sessions = append(sessions, &model.UserSession{
+			Id:        sessionId(sid),
+			Uid:       uid,
+			Type:      goutils.MustInt(fields["type"]),
+			Ip:        fields["ip"],
+			UserAgent: fields["ua"],
+			CreatedAt: time.Unix(goutils.MustInt64(fields["created_at"]), 0),
+			LastSeen:  time.Unix(goutils.MustInt64(fields["last_seen"]), 0),
+			Current:   sid == curSid,
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+
+	return sessions
+}
+
+// Delete 注销一个会话（退出登录）
+func (SessionLogic) Delete(sid string) {
+	if sid == "" {
+		return
+	}
+
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	uid, _ := redisClient.HGET(sessionKey(sid), "uid")
+	err := redisClient.DEL(sessionKey(sid))
+	if err == nil && uid != "" {
+		err = redisClient.SREM(userSessionsKey(goutils.MustInt(uid)), sid)
+	}
+	if err != nil {
+		logger.Error("SessionLogic Delete error:", err)
+	}
+}
+
+// Revoke 用户注销自己的某个会话，id 为 model.UserSession.Id
+func (SessionLogic) Revoke(uid int, id string) error {
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	sids, err := redisClient.SMEMBERS(userSessionsKey(uid))
+	if err != nil {
+		logger.Error("SessionLogic Revoke error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	for _, sid := range sids {
+		if sessionId(sid) != id {
+			continue
+		}
+
+		err = redisClient.DEL(sessionKey(sid))
+		if err == nil {
+			err = redisClient.SREM(userSessionsKey(uid), sid)
+		}
+		if err != nil {
+			logger.Error("SessionLogic Revoke error:", err)
+			return errors.New("服务内部错误")
+		}
+		return nil
+	}
+
+	return errors.New("会话不存在或已过期")
+}
+
+// RevokeAll 注销用户所有的会话：在所有设备上退出、修改密码、账号被冻结时
+func (SessionLogic) RevokeAll(uid int) error {
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	sids, err := redisClient.SMEMBERS(userSessionsKey(uid))
+	if err != nil {
+		logger.Error("SessionLogic RevokeAll error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	for _, sid := range sids {
+		if err = redisClient.DEL(sessionKey(sid)); err != nil {
+			logger.Error("SessionLogic RevokeAll del session error:", err)
+			return errors.New("服务内部错误")
+		}
+	}
+	if err = redisClient.DEL(userSessionsKey(uid)); err != nil {
+		logger.Error("SessionLogic RevokeAll error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	return nil
+}
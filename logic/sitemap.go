@@ -72,7 +72,7 @@ func GenSitemap() {
 	for {
 		sitemapFile := "sitemap_article_" + strconv.Itoa(large) + ".xml"
 
-		err = db.MasterDB.Where("id BETWEEN ? AND ? AND status!=?", little, large, model.ArticleStatusOffline).Select("id,mtime").Find(&articles)
+		err = db.MasterDB.Where("id BETWEEN ? AND ? AND status IN(?,?)", little, large, model.ArticleStatusNew, model.ArticleStatusOnline).Select("id,mtime").Find(&articles)
 		little = large + 1
 		large = little + step
 
@@ -136,7 +136,7 @@ func GenSitemap() {
 	for {
 		sitemapFile := "sitemap_resource_" + strconv.Itoa(large) + ".xml"
 
-		err = db.MasterDB.Where("id BETWEEN ? AND ?", little, large).Select("id,mtime").Find(&resources)
+		err = db.MasterDB.Where("id BETWEEN ? AND ? AND flag!=?", little, large, model.FlagShadow).Select("id,mtime").Find(&resources)
 		little = large + 1
 		large = little + step
 
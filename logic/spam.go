@@ -0,0 +1,242 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"sander/db"
+	"sander/global"
+	"sander/logger"
+	"sander/model"
+	"sander/util"
+
+	"golang.org/x/net/context"
+)
+
+// 每类样本至少这么多才训练，太少时分类结果没有意义
+const spamMinSamples = 20
+
+type SpamLogic struct{}
+
+var DefaultSpam = SpamLogic{}
+
+// Score 内容是垃圾内容的概率（0~1），还没有训练好的模型时返回 false
+func (SpamLogic) Score(title, content string) (float64, bool) {
+	spamLocker.RLock()
+	classifier := curSpamClassifier
+	spamLocker.RUnlock()
+
+	if classifier == nil {
+		return 0, false
+	}
+
+	return classifier.SpamProb(title + "\n" + content), true
+}
+
+// AddSample 记录一个训练样本，同一对象只保留最后一次的判断
+func (SpamLogic) AddSample(ctx context.Context, objtype, objid int, isSpam bool, opUser string) error {
+	if _, ok := model.SpamTypeMap[objtype]; !ok {
+		return nil
+	}
+
+	title, content, ok := findObjectText(objtype, objid)
+	if !ok {
+		return NotFoundErr
+	}
+
+	sample := &model.SpamSample{}
+	_, err := db.MasterDB.Where("objtype=? AND objid=?", objtype, objid).Get(sample)
+	if err != nil {
+		logger.Error("SpamLogic AddSample find error:", err)
+		return err
+	}
+
+	sample.Objtype = objtype
+	sample.Objid = objid
+	sample.Text = strings.TrimSpace(title + "\n" + content)
+	sample.IsSpam = isSpam
+	sample.OpUser = opUser
+	if sample.Id > 0 {
+		_, err = db.MasterDB.Id(sample.Id).Cols("text", "is_spam", "op_user").Update(sample)
+	} else {
+		_, err = db.MasterDB.Insert(sample)
+	}
+	if err != nil {
+		logger.Error("SpamLogic AddSample save error:", err)
+	}
+
+	return err
+}
+
+// SetLabel 后台修正样本的标注
+func (SpamLogic) SetLabel(ctx context.Context, id int, isSpam bool, opUser string) error {
+	_, err := db.MasterDB.Table(new(model.SpamSample)).Id(id).
+		Update(map[string]interface{}{"is_spam": isSpam, "op_user": opUser})
+	if err != nil {
+		logger.Error("SpamLogic SetLabel error:", err)
+		return errors.New("服务内部错误")
+	}
+	return nil
+}
+
+// DeleteSample 删除样本，不再用于训练
+func (SpamLogic) DeleteSample(ctx context.Context, id int) error {
+	_, err := db.MasterDB.Id(id).Delete(new(model.SpamSample))
+	if err != nil {
+		logger.Error("SpamLogic DeleteSample error:", err)
+		return errors.New("服务内部错误")
+	}
+	return nil
+}
+
+// FindSamplesByPage 训练样本（分页）：后台用
+func (SpamLogic) FindSamplesByPage(ctx context.Context, conds map[string]string, curPage, limit int) ([]*model.SpamSample, int) {
+	session := db.MasterDB.NewSession()
+
+	for k, v := range conds {
+		session.And(k+"=?", v)
+	}
+
+	totalSession := session.Clone()
+
+	offset := (curPage - 1) * limit
+	samples := make([]*model.SpamSample, 0)
+	err := session.Desc("id").Limit(limit, offset).Find(&samples)
+	if err != nil {
+		logger.Error("SpamLogic FindSamplesByPage error:", err)
+		return nil, 0
+	}
+
+	total, err := totalSession.Count(new(model.SpamSample))
+	if err != nil {
+		logger.Error("SpamLogic FindSamplesByPage count error:", err)
+		return nil, 0
+	}
+
+	return samples, int(total)
+}
+
+// FindModels 最近的训练记录：后台用
+func (SpamLogic) FindModels(ctx context.Context, limit int) []*model.SpamModel {
+	spamModels := make([]*model.SpamModel, 0)
+	err := db.MasterDB.Omit("data").Desc("id").Limit(limit).Find(&spamModels)
+	if err != nil {
+		logger.Error("SpamLogic FindModels error:", err)
+	}
+	return spamModels
+}
+
+// Retrain 用所有样本重新训练。先留出 20% 的样本评估准确率和召回率，再用全部样本训练出最终的模型
+func (SpamLogic) Retrain(ctx context.Context, opUser string) (*model.SpamModel, error) {
+	samples := make([]*model.SpamSample, 0)
+	err := db.MasterDB.Asc("id").Find(&samples)
+	if err != nil {
+		logger.Error("SpamLogic Retrain find samples error:", err)
+		return nil, errors.New("服务内部错误")
+	}
+
+	spamModel := &model.SpamModel{
+		Threshold: UserSetting[model.KeySpamThreshold],
+		OpUser:    opUser,
+	}
+	for _, sample := range samples {
+		if sample.IsSpam {
+			spamModel.SpamNum++
+		} else {
+			spamModel.HamNum++
+		}
+	}
+	if spamModel.SpamNum < spamMinSamples || spamModel.HamNum < spamMinSamples {
+		return nil, fmt.Errorf("垃圾和正常样本都至少需要 %d 个，当前分别为 %d 个和 %d 个", spamMinSamples, spamModel.SpamNum, spamModel.HamNum)
+	}
+
+	// 每 5 个样本留 1 个评估
+	evalClassifier := util.NewNaiveBayes()
+	for i, sample := range samples {
+		if i%5 != 4 {
+			evalClassifier.Train(sample.Text, sample.IsSpam)
+		}
+	}
+
+	threshold := float64(spamModel.Threshold) / 100
+	if threshold <= 0 {
+		threshold = 0.9
+	}
+	var truePositive, falsePositive, falseNegative int
+	for i := 4; i < len(samples); i += 5 {
+		predicted := evalClassifier.SpamProb(samples[i].Text) >= threshold
+		switch {
+		case predicted && samples[i].IsSpam:
+			truePositive++
+		case predicted && !samples[i].IsSpam:
+			falsePositive++
+		case !predicted && samples[i].IsSpam:
+			falseNegative++
+		}
+	}
+	if truePositive+falsePositive > 0 {
+		spamModel.Precision = float64(truePositive) / float64(truePositive+falsePositive)
+	}
+	if truePositive+falseNegative > 0 {
+		spamModel.Recall = float64(truePositive) / float64(truePositive+falseNegative)
+	}
+
+	classifier := util.NewNaiveBayes()
+	for _, sample := range samples {
+		classifier.Train(sample.Text, sample.IsSpam)
+	}
+	data, err := json.Marshal(classifier)
+	if err != nil {
+		logger.Error("SpamLogic Retrain marshal error:", err)
+		return nil, errors.New("服务内部错误")
+	}
+	spamModel.Data = string(data)
+
+	if _, err = db.MasterDB.Insert(spamModel); err != nil {
+		logger.Error("SpamLogic Retrain save model error:", err)
+		return nil, errors.New("服务内部错误")
+	}
+
+	global.SpamModelChan <- struct{}{}
+
+	return spamModel, nil
+}
+
+// SpamObserver 新发布的主题、资源和回复，垃圾内容概率达到阈值时隐藏并放入举报处理队列
+type SpamObserver struct{}
+
+func (SpamObserver) Update(action string, uid, objtype, objid int) {
+	if action == actionComment {
+		objtype = model.TypeComment
+	} else if objtype != model.TypeTopic && objtype != model.TypeResource {
+		return
+	}
+
+	threshold := UserSetting[model.KeySpamThreshold]
+	if threshold <= 0 {
+		return
+	}
+
+	title, content, ok := findObjectText(objtype, objid)
+	if !ok {
+		return
+	}
+
+	prob, ok := DefaultSpam.Score(title, content)
+	if !ok || prob*100 < float64(threshold) {
+		return
+	}
+
+	err := DefaultReport.Review(context.Background(), objtype, objid, model.ReportReasonSpamScore, fmt.Sprintf("垃圾内容概率 %.1f%%", prob*100))
+	if err != nil {
+		logger.Error("SpamObserver review error:", err)
+	}
+}
@@ -93,6 +93,7 @@ func (self SubjectLogic) FindArticles(ctx context.Context, sid int, paginator *P
 	subjectArticles := make([]*model.SubjectArticles, 0)
 	err := db.MasterDB.Join("INNER", "subject_article", "subject_article.article_id = articles.id").
 		Where("sid=? AND state=?", sid, model.ContributeStateOnline).
+		And("articles.status IN(?,?)", model.ArticleStatusNew, model.ArticleStatusOnline).
 		Limit(paginator.PerPage(), paginator.Offset()).
 		OrderBy(order).Find(&subjectArticles)
 	if err != nil {
@@ -102,10 +103,6 @@ func (self SubjectLogic) FindArticles(ctx context.Context, sid int, paginator *P
 
 	articles := make([]*model.Article, 0, len(subjectArticles))
 	for _, subjectArticle := range subjectArticles {
-		if subjectArticle.Status == model.ArticleStatusOffline {
-			continue
-		}
-
 		articles = append(articles, &subjectArticle.Article)
 	}
 
@@ -7,11 +7,8 @@
 package logic
 
 import (
-	"encoding/json"
 	"errors"
-	"io/ioutil"
 
-	"sander/config"
 	"sander/db"
 	"sander/logger"
 	"sander/model"
@@ -20,119 +17,112 @@ import (
 	"golang.org/x/oauth2"
 )
 
-var githubConf *oauth2.Config
-
-const GithubAPIBaseUrl = "https://api.github.com"
-
-func init() {
-	githubConf = &oauth2.Config{
-		ClientID:     config.ConfigFile.MustValue("github", "client_id"),
-		ClientSecret: config.ConfigFile.MustValue("github", "client_secret"),
-		Scopes:       []string{"user:email"},
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://github.com/login/oauth/authorize",
-			TokenURL: "https://github.com/login/oauth/access_token",
-		},
-	}
-}
-
 type ThirdUserLogic struct{}
 
 var DefaultThirdUser = ThirdUserLogic{}
 
-func (ThirdUserLogic) GithubAuthCodeUrl(ctx context.Context, redirectURL string) string {
-	// Redirect user to consent page to ask for permission
-	// for the scopes specified above.
-	githubConf.RedirectURL = redirectURL
-	return githubConf.AuthCodeURL("state", oauth2.AccessTypeOffline)
+// Providers 启用了的第三方登录方式
+func (ThirdUserLogic) Providers() []*OAuthProvider {
+	return oauthProviders
 }
 
-func (self ThirdUserLogic) LoginFromGithub(ctx context.Context, code string) (*model.User, error) {
+// AuthCodeUrl 跳转到第三方授权页的地址
+func (ThirdUserLogic) AuthCodeUrl(ctx context.Context, name, state, redirectURL string) (string, error) {
+	provider, err := findOAuthProvider(name)
+	if err != nil {
+		return "", err
+	}
+
+	oauthConf, err := provider.config(ctx, redirectURL)
+	if err != nil {
+		return "", err
+	}
+	return oauthConf.AuthCodeURL(state, oauth2.AccessTypeOffline), nil
+}
 
-	githubUser, token, err := self.githubTokenAndUser(ctx, code)
+// Login 第三方账号登录：已绑定的直接登录；第三方验证过的邮箱和本站已激活的账号相同时自动绑定；否则新建本站账号
+func (self ThirdUserLogic) Login(ctx context.Context, name, code, redirectURL string) (*model.User, error) {
+	provider, oauthUser, token, err := self.tokenAndUser(ctx, name, code, redirectURL)
 	if err != nil {
-		logger.Error("LoginFromGithub githubTokenAndUser error:", err)
+		logger.Error("ThirdUserLogic Login tokenAndUser error:", err)
 		return nil, err
 	}
 
 	bindUser := &model.BindUser{}
 	// 是否已经授权过了
-	_, err = db.MasterDB.Where("username=? AND type=?", githubUser.Login, model.BindTypeGithub).Get(bindUser)
+	_, err = db.MasterDB.Where("username=? AND type=?", oauthUser.Key, provider.Type).Get(bindUser)
 	if err != nil {
-		logger.Error("LoginFromGithub Get BindUser error:", err)
+		logger.Error("ThirdUserLogic Login Get BindUser error:", err)
 		return nil, err
 	}
 
 	if bindUser.Uid > 0 {
-		// 更新 token 信息
-		bindUser.AccessToken = token.AccessToken
-		bindUser.RefreshToken = token.RefreshToken
-		if !token.Expiry.IsZero() {
-			bindUser.Expire = int(token.Expiry.Unix())
-		}
-		_, err = db.MasterDB.Where("uid=?", bindUser.Uid).Update(bindUser)
-		if err != nil {
-			logger.Error("LoginFromGithub update token error:", err)
+		if err = self.updateToken(bindUser, token); err != nil {
 			return nil, err
 		}
 
-		user := DefaultUser.FindOne(ctx, "uid", bindUser.Uid)
-		return user, nil
+		return self.checkUser(ctx, bindUser.Uid)
+	}
+
+	// 邮箱相同的本站账号，双方都验证过邮箱时自动绑定。本站账号的邮箱没验证过时，可能是别人抢注的，
+	// 需要用密码登录后在个人资料中绑定
+	if oauthUser.EmailVerified && oauthUser.Email != "" {
+		user := DefaultUser.FindOne(ctx, "email", oauthUser.Email)
+		if user.Uid > 0 && user.Status == model.UserStatusAudit && user.EmailVerified {
+			_, err = db.MasterDB.Insert(self.newBindUser(provider, oauthUser, token, user.Uid))
+			if err != nil {
+				logger.Error("ThirdUserLogic Login bind by email error:", err)
+				return nil, err
+			}
+			return self.checkUser(ctx, user.Uid)
+		}
 	}
 
-	exists := DefaultUser.EmailOrUsernameExists(ctx, githubUser.Email, githubUser.Login)
-	if exists {
-		// TODO: 考虑改进？
-		logger.Error("LoginFromGithub Github 对应的用户信息被占用")
-		return nil, errors.New("Github 对应的用户信息被占用，可能你注册过本站，用户名密码登录试试！")
+	exists := DefaultUser.EmailOrUsernameExists(ctx, oauthUser.Email, oauthUser.Login)
+	if exists || oauthUser.Login == "" {
+		logger.Error("ThirdUserLogic Login %s 对应的用户信息被占用", provider.Title)
+		return nil, errors.New(provider.Title + " 对应的用户信息被占用，可能你注册过本站，用户名密码登录后在个人资料中绑定试试！")
 	}
 
 	session := db.MasterDB.NewSession()
 	defer session.Close()
 	session.Begin()
 
-	// 有可能获取不到 email？加上 @github.com做邮箱后缀
-	if githubUser.Email == "" {
-		githubUser.Email = githubUser.Login + "@github.com"
+	emailVerified := oauthUser.EmailVerified && oauthUser.Email != ""
+	// 有可能获取不到 email？GitHub 加上 @github.com 做邮箱后缀
+	if oauthUser.Email == "" {
+		if provider.Type == model.BindTypeGithub {
+			oauthUser.Email = oauthUser.Login + "@github.com"
+		} else {
+			oauthUser.Email = oauthUser.Login + "@" + provider.Name + ".local"
+		}
 	}
 	// 生成本站用户
 	user := &model.User{
-		Email:    githubUser.Email,
-		Username: githubUser.Login,
-		Name:     githubUser.Name,
-		City:     githubUser.Location,
-		Company:  githubUser.Company,
-		Github:   githubUser.Login,
-		Website:  githubUser.Blog,
-		Avatar:   githubUser.AvatarUrl,
+		Email:    oauthUser.Email,
+		Username: oauthUser.Login,
+		Name:     oauthUser.Name,
+		City:     oauthUser.Location,
+		Company:  oauthUser.Company,
+		Github:   oauthUser.Github,
+		Website:  oauthUser.Website,
+		Avatar:   oauthUser.Avatar,
 		IsThird:  1,
 		Status:   model.UserStatusAudit,
+
+		EmailVerified: emailVerified,
 	}
 	err = DefaultUser.doCreateUser(ctx, session, user)
 	if err != nil {
 		session.Rollback()
-		logger.Error("LoginFromGithub doCreateUser error:", err)
+		logger.Error("ThirdUserLogic Login doCreateUser error:", err)
 		return nil, err
 	}
 
-	bindUser = &model.BindUser{
-		Uid:          user.Uid,
-		Type:         model.BindTypeGithub,
-		Email:        user.Email,
-		Tuid:         githubUser.Id,
-		Username:     githubUser.Login,
-		Name:         githubUser.Name,
-		AccessToken:  token.AccessToken,
-		RefreshToken: token.RefreshToken,
-		Avatar:       githubUser.AvatarUrl,
-	}
-	if !token.Expiry.IsZero() {
-		bindUser.Expire = int(token.Expiry.Unix())
-	}
-	_, err = session.Insert(bindUser)
+	_, err = session.Insert(self.newBindUser(provider, oauthUser, token, user.Uid))
 	if err != nil {
 		session.Rollback()
-		logger.Error("LoginFromGithub bindUser error:", err)
+		logger.Error("ThirdUserLogic Login bindUser error:", err)
 		return nil, err
 	}
 
@@ -141,64 +131,56 @@ func (self ThirdUserLogic) LoginFromGithub(ctx context.Context, code string) (*m
 	return user, nil
 }
 
-func (self ThirdUserLogic) BindGithub(ctx context.Context, code string, me *model.Me) error {
-
-	githubUser, token, err := self.githubTokenAndUser(ctx, code)
+// Bind 已登录用户绑定第三方账号，每种方式只能绑定一个
+func (self ThirdUserLogic) Bind(ctx context.Context, name, code, redirectURL string, me *model.Me) error {
+	provider, oauthUser, token, err := self.tokenAndUser(ctx, name, code, redirectURL)
 	if err != nil {
-		logger.Error("LoginFromGithub githubTokenAndUser error:", err)
+		logger.Error("ThirdUserLogic Bind tokenAndUser error:", err)
 		return err
 	}
 
 	bindUser := &model.BindUser{}
 	// 是否已经授权过了
-	_, err = db.MasterDB.Where("username=? AND type=?", githubUser.Login, model.BindTypeGithub).Get(bindUser)
+	_, err = db.MasterDB.Where("username=? AND type=?", oauthUser.Key, provider.Type).Get(bindUser)
 	if err != nil {
-		logger.Error("LoginFromGithub Get BindUser error:", err)
+		logger.Error("ThirdUserLogic Bind Get BindUser error:", err)
 		return err
 	}
 
 	if bindUser.Uid > 0 {
-		// 更新 token 信息
-		bindUser.AccessToken = token.AccessToken
-		bindUser.RefreshToken = token.RefreshToken
-		if !token.Expiry.IsZero() {
-			bindUser.Expire = int(token.Expiry.Unix())
-		}
-		_, err = db.MasterDB.Where("uid=?", bindUser.Uid).Update(bindUser)
-		if err != nil {
-			logger.Error("LoginFromGithub update token error:", err)
-			return err
+		if bindUser.Uid != me.Uid {
+			return errors.New("该 " + provider.Title + " 账号已经绑定了其他用户")
 		}
-
-		return nil
+		return self.updateToken(bindUser, token)
 	}
 
-	bindUser = &model.BindUser{
-		Uid:          me.Uid,
-		Type:         model.BindTypeGithub,
-		Email:        githubUser.Email,
-		Tuid:         githubUser.Id,
-		Username:     githubUser.Login,
-		Name:         githubUser.Name,
-		AccessToken:  token.AccessToken,
-		RefreshToken: token.RefreshToken,
-		Avatar:       githubUser.AvatarUrl,
-	}
-	if !token.Expiry.IsZero() {
-		bindUser.Expire = int(token.Expiry.Unix())
-	}
-	_, err = db.MasterDB.Insert(bindUser)
+	total, err := db.MasterDB.Where("uid=? AND type=?", me.Uid, provider.Type).Count(new(model.BindUser))
 	if err != nil {
-		logger.Error("LoginFromGithub insert bindUser error:", err)
+		logger.Error("ThirdUserLogic Bind count error:", err)
 		return err
 	}
+	if total > 0 {
+		return errors.New("已经绑定了其他 " + provider.Title + " 账号，请先删除")
+	}
 
-	return nil
+	_, err = db.MasterDB.Insert(self.newBindUser(provider, oauthUser, token, me.Uid))
+	if err != nil {
+		logger.Error("ThirdUserLogic Bind insert bindUser error:", err)
+	}
+	return err
 }
 
+// UnBindUser 解绑第三方账号。没有设置密码时，至少要保留一个第三方账号用于登录
 func (ThirdUserLogic) UnBindUser(ctx context.Context, bindId interface{}, me *model.Me) error {
 	if !DefaultUser.HasPasswd(ctx, me.Uid) {
-		return errors.New("请先设置密码！")
+		total, err := db.MasterDB.Where("uid=?", me.Uid).Count(new(model.BindUser))
+		if err != nil {
+			logger.Error("ThirdUserLogic UnBindUser count error:", err)
+			return err
+		}
+		if total <= 1 {
+			return errors.New("请先设置密码！")
+		}
 	}
 	_, err := db.MasterDB.Where("id=? AND uid=?", bindId, me.Uid).Delete(new(model.BindUser))
 	return err
@@ -214,33 +196,71 @@ func (ThirdUserLogic) findUid(thirdUsername string, typ int) int {
 	return bindUser.Uid
 }
 
-func (ThirdUserLogic) githubTokenAndUser(ctx context.Context, code string) (*model.GithubUser, *oauth2.Token, error) {
-	token, err := githubConf.Exchange(ctx, code)
+// checkUser 已绑定的本站账号被冻结、停号时不能登录
+func (ThirdUserLogic) checkUser(ctx context.Context, uid int) (*model.User, error) {
+	user := DefaultUser.FindOne(ctx, "uid", uid)
+	if user.Uid == 0 {
+		return nil, errors.New("绑定的用户不存在")
+	}
+	if user.Status > model.UserStatusAudit {
+		logger.Info("用户 %q 的状态非审核通过, 用户的状态值：%d", user.Username, user.Status)
+		return nil, errors.New("您的账号已被冻结或停号，请联系管理员！")
+	}
+	return user, nil
+}
+
+func (ThirdUserLogic) updateToken(bindUser *model.BindUser, token *oauth2.Token) error {
+	// 更新 token 信息
+	bindUser.AccessToken = token.AccessToken
+	bindUser.RefreshToken = token.RefreshToken
+	if !token.Expiry.IsZero() {
+		bindUser.Expire = int(token.Expiry.Unix())
+	}
+	_, err := db.MasterDB.Id(bindUser.Id).Update(bindUser)
 	if err != nil {
-		return nil, nil, err
+		logger.Error("ThirdUserLogic update token error:", err)
 	}
+	return err
+}
 
-	httpClient := githubConf.Client(ctx, token)
-	resp, err := httpClient.Get(GithubAPIBaseUrl + "/user")
+func (ThirdUserLogic) newBindUser(provider *OAuthProvider, oauthUser *OAuthUser, token *oauth2.Token, uid int) *model.BindUser {
+	bindUser := &model.BindUser{
+		Uid:          uid,
+		Type:         provider.Type,
+		Email:        oauthUser.Email,
+		Tuid:         oauthUser.Tuid,
+		Username:     oauthUser.Key,
+		Name:         oauthUser.Name,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Avatar:       oauthUser.Avatar,
+	}
+	if !token.Expiry.IsZero() {
+		bindUser.Expire = int(token.Expiry.Unix())
+	}
+	return bindUser
+}
+
+func (ThirdUserLogic) tokenAndUser(ctx context.Context, name, code, redirectURL string) (*OAuthProvider, *OAuthUser, *oauth2.Token, error) {
+	provider, err := findOAuthProvider(name)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	defer resp.Body.Close()
 
-	respBytes, err := ioutil.ReadAll(resp.Body)
+	oauthConf, err := provider.config(ctx, redirectURL)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	githubUser := &model.GithubUser{}
-	err = json.Unmarshal(respBytes, githubUser)
+	token, err := oauthConf.Exchange(ctx, code)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	if githubUser.Id == 0 {
-		return nil, nil, errors.New("get github user info error")
+	oauthUser, err := provider.fetch(ctx, oauthConf.Client(ctx, token))
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	return githubUser, token, nil
+	return provider, oauthUser, token, nil
 }
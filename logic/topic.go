@@ -46,6 +46,13 @@ func (self TopicLogic) Publish(ctx context.Context, me *model.Me, form url.Value
 			return
 		}
 
+		// 版主编辑他人主题时，只能调整到自己管理的节点
+		if newNid := goutils.MustInt(form.Get("nid")); newNid != topic.Nid && me.Uid != topic.Uid &&
+			!(me.IsAdmin && roleCanEdit(model.TopicAdmin, me)) && !DefaultNodeModerator.HasPerm(me.Uid, newNid, model.ModPermEdit) {
+			err = NotModifyAuthorityErr
+			return
+		}
+
 		_, err = self.Modify(ctx, me, form)
 		if err != nil {
 			logger.Error("Publish Topic modify error:", err)
@@ -75,6 +82,12 @@ func (self TopicLogic) Publish(ctx context.Context, me *model.Me, form url.Value
 		usernames := form.Get("usernames")
 		form.Del("usernames")
 
+		var poll *model.Poll
+		poll, err = DefaultPoll.parseForm(form)
+		if err != nil {
+			return
+		}
+
 		topic := &model.Topic{}
 		err = schemaDecoder.Decode(topic, form)
 		if err != nil {
@@ -83,6 +96,13 @@ func (self TopicLogic) Publish(ctx context.Context, me *model.Me, form url.Value
 		}
 		topic.Uid = me.Uid
 		topic.Lastreplytime = model.NewOftenTime()
+		if HasPrivilege(me, model.PrivSkipAudit) {
+			topic.Flag = model.FlagNormal
+		}
+		sanction := DefaultUserSanction.Find(me.Uid)
+		if sanction == model.UserSanctionShadowBan {
+			topic.Flag = model.FlagShadow
+		}
 
 		session := db.MasterDB.NewSession()
 		defer session.Close()
@@ -105,8 +125,18 @@ func (self TopicLogic) Publish(ctx context.Context, me *model.Me, form url.Value
 			logger.Error("TopicLogic Publish Insert TopicEx error:", err)
 			return
 		}
+
+		if poll != nil {
+			if err = DefaultPoll.create(session, topic.Tid, poll); err != nil {
+				session.Rollback()
+				return
+			}
+		}
 		session.Commit()
 
+		// 发布成功，删除草稿
+		DefaultDraft.Delete(ctx, me.Uid, goutils.MustInt(form.Get("draft_id")))
+
 		go func() {
 			// 同一个首页不显示的节点，一天发布主题数超过3个，扣 1 千铜币
 			topicNum, err := db.MasterDB.Where("uid=? AND ctime>?", me.Uid, time.Now().Format("2006-01-02 00:00:00")).Count(new(model.Topic))
@@ -131,17 +161,24 @@ func (self TopicLogic) Publish(ctx context.Context, me *model.Me, form url.Value
 			}
 		}()
 
-		// 发布动态
-		DefaultFeed.publish(topic, topicEx)
-
-		// 给 被@用户 发系统消息
-		ext := map[string]interface{}{
-			"objid":   topic.Tid,
-			"objtype": model.TypeTopic,
-			"uid":     me.Uid,
-			"msgtype": model.MsgtypePublishAtMe,
+		// 影子封禁的主题只有作者能看到，观察期的主题审核通过前不展示，都不发布动态和通知
+		hidden := topic.Flag == model.FlagShadow
+		if sanction == model.UserSanctionProbation {
+			hidden = DefaultUserSanction.Hold(ctx, sanction, model.TypeTopic, topic.Tid)
+		}
+		if !hidden {
+			// 发布动态
+			DefaultFeed.publish(topic, topicEx)
+
+			// 给 被@用户 发系统消息
+			ext := map[string]interface{}{
+				"objid":   topic.Tid,
+				"objtype": model.TypeTopic,
+				"uid":     me.Uid,
+				"msgtype": model.MsgtypePublishAtMe,
+			}
+			go DefaultMessage.SendSysMsgAtUsernames(ctx, usernames, ext, 0)
 		}
-		go DefaultMessage.SendSysMsgAtUsernames(ctx, usernames, ext, 0)
 
 		go publishObservable.NotifyObservers(me.Uid, model.TypeTopic, topic.Tid)
 
@@ -165,13 +202,39 @@ func (TopicLogic) Modify(ctx context.Context, user *model.Me, form url.Values) (
 	}
 
 	tid := form.Get("tid")
-	_, err = db.MasterDB.Table(new(model.Topic)).Id(tid).Update(change)
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	origin, err := DefaultContentRevision.origin(session, model.TypeTopic, goutils.MustInt(tid))
 	if err != nil {
+		session.Rollback()
+		errMsg = "对不起，服务器内部错误，请稍后再试！"
+		return
+	}
+
+	_, err = updateWithVersion(session.Table(new(model.Topic)).Id(tid), form.Get("version"), change)
+	if err != nil {
+		session.Rollback()
+		if err == ConflictErr {
+			errMsg = err.Error()
+			return
+		}
 		logger.Error("更新主题 【%s】 信息失败：%s\n", tid, err)
 		errMsg = "对不起，服务器内部错误，请稍后再试！"
 		return
 	}
 
+	err = DefaultContentRevision.record(session, model.TypeTopic, goutils.MustInt(tid), origin, user.Uid, form.Get("title"), form.Get("content"))
+	if err != nil {
+		session.Rollback()
+		errMsg = "对不起，服务器内部错误，请稍后再试！"
+		return
+	}
+
+	session.Commit()
+
 	go modifyObservable.NotifyObservers(user.Uid, model.TypeTopic, goutils.MustInt(tid))
 
 	return
@@ -215,6 +278,10 @@ func (self TopicLogic) SetTop(ctx context.Context, me *model.Me, tid int) error
 		if topic.Tid == 0 || topic.Uid != me.Uid {
 			return NotFoundErr
 		}
+
+		if !HasPrivilege(me, model.PrivSetTop) {
+			return errors.New("等级不够，还不能置顶主题")
+		}
 	}
 
 	session := db.MasterDB.NewSession()
@@ -298,9 +365,11 @@ func (self TopicLogic) FindAll(ctx context.Context, paginator *Paginator, orderB
 
 	topicInfos := make([]*model.TopicInfo, 0)
 
-	session := db.MasterDB.Join("INNER", "topics_ex", "topics.tid=topics_ex.tid")
+	// 被合并或删除的主题不出现在列表中，影子封禁的主题只有作者能看到
+	session := db.MasterDB.Join("INNER", "topics_ex", "topics.tid=topics_ex.tid").
+		Where("topics.merged_to=0 AND (topics.flag<=? OR (topics.flag=? AND topics.uid=?))", model.FlagNormal, model.FlagShadow, currentUid(ctx))
 	if querystring != "" {
-		session.Where(querystring, args...)
+		session.And(querystring, args...)
 	}
 	err := session.OrderBy(orderBy).Limit(paginator.PerPage(), paginator.Offset()).Find(&topicInfos)
 	if err != nil {
@@ -320,10 +389,10 @@ func (TopicLogic) FindLastList(beginTime string, limit int) ([]*model.Topic, err
 }
 
 // FindRecent 获得最近的主题(uids[0]，则获取某个用户最近的主题)
-func (TopicLogic) FindRecent(limit int, uids ...int) []*model.Topic {
-	dbSession := db.MasterDB.OrderBy("ctime DESC").Limit(limit)
+func (TopicLogic) FindRecent(ctx context.Context, limit int, uids ...int) []*model.Topic {
+	dbSession := db.MasterDB.Where("(flag!=? OR uid=?)", model.FlagShadow, currentUid(ctx)).OrderBy("ctime DESC").Limit(limit)
 	if len(uids) > 0 {
-		dbSession.Where("uid=?", uids[0])
+		dbSession.And("uid=?", uids[0])
 	}
 
 	topics := make([]*model.Topic, 0)
@@ -383,7 +452,8 @@ func (self TopicLogic) FindFullinfoByTids(tids []int) []map[string]interface{} {
 }
 
 // FindByTid 获得主题详细信息（包括详细回复）
-func (self TopicLogic) FindByTid(ctx context.Context, tid int) (topicMap map[string]interface{}, replies []map[string]interface{}, err error) {
+// me 为当前用户（可以为 nil），影子封禁的主题只有作者自己能看到
+func (self TopicLogic) FindByTid(ctx context.Context, tid int, me *model.Me) (topicMap map[string]interface{}, replies []map[string]interface{}, err error) {
 
 	topicInfo := &model.TopicInfo{}
 	_, err = db.MasterDB.Join("INNER", "topics_ex", "topics.tid=topics_ex.tid").Where("topics.tid=?", tid).Get(topicInfo)
@@ -400,7 +470,7 @@ func (self TopicLogic) FindByTid(ctx context.Context, tid int) (topicMap map[str
 		return
 	}
 
-	if topic.Flag > model.FlagNormal {
+	if topic.Flag > model.FlagNormal && !(topic.Flag == model.FlagShadow && me != nil && topic.Uid == me.Uid) {
 		err = errors.New("The topic of tid is not exists or delete")
 		return
 	}
@@ -416,7 +486,7 @@ func (self TopicLogic) FindByTid(ctx context.Context, tid int) (topicMap map[str
 	topicMap["node"] = GetNode(topic.Nid)
 
 	// 回复信息（评论）
-	replies, owerUser, lastReplyUser := DefaultComment.FindObjComments(ctx, topic.Tid, model.TypeTopic, topic.Uid, topic.Lastreplyuid)
+	replies, owerUser, lastReplyUser := DefaultComment.FindObjComments(ctx, topic.Tid, model.TypeTopic, topic.Uid, topic.Lastreplyuid, me)
 	topicMap["user"] = owerUser
 	// 有人回复
 	if topic.Lastreplyuid != 0 {
@@ -550,7 +620,7 @@ func (TopicLogic) FindHotNodes(ctx context.Context) []map[string]interface{} {
 	hotNum := 10
 
 	lastWeek := time.Now().Add(-7 * 24 * time.Hour).Format("2006-01-02 15:04:05")
-	strSql := fmt.Sprintf("SELECT nid, COUNT(1) AS topicnum FROM topics WHERE ctime>='%s' GROUP BY nid ORDER BY topicnum DESC LIMIT 15", lastWeek)
+	strSql := fmt.Sprintf("SELECT nid, COUNT(1) AS topicnum FROM topics WHERE ctime>='%s' AND deleted_at IS NULL GROUP BY nid ORDER BY topicnum DESC LIMIT 15", lastWeek)
 	rows, err := db.MasterDB.DB().DB.Query(strSql)
 	if err != nil {
 		logger.Error("TopicLogic FindHotNodes error:", err)
@@ -615,16 +685,12 @@ func (TopicLogic) JSEscape(topics []*model.Topic) []*model.Topic {
 
 func (TopicLogic) Count(ctx context.Context, querystring string, args ...interface{}) int64 {
 
-	var (
-		total int64
-		err   error
-	)
-	if querystring == "" {
-		total, err = db.MasterDB.Count(new(model.Topic))
-	} else {
-		total, err = db.MasterDB.Where(querystring, args...).Count(new(model.Topic))
+	session := db.MasterDB.Where("merged_to=0 AND (flag<=? OR (flag=? AND uid=?))", model.FlagNormal, model.FlagShadow, currentUid(ctx))
+	if querystring != "" {
+		session.And(querystring, args...)
 	}
 
+	total, err := session.Count(new(model.Topic))
 	if err != nil {
 		logger.Error("TopicLogic Count error:", err)
 	}
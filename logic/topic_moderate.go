@@ -0,0 +1,362 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+
+	"github.com/polaris1119/set"
+	"golang.org/x/net/context"
+)
+
+// 主题管理操作对应的权限（authority 表中的 route），拥有这些权限的管理员可以管理所有节点的主题
+const (
+	TopicLockRoute    = "/admin/community/topic/lock"
+	TopicCloseRoute   = "/admin/community/topic/close"
+	TopicMoveRoute    = "/admin/community/topic/move"
+	TopicMergeRoute   = "/admin/community/topic/merge"
+	TopicDeleteRoute  = "/admin/community/topic/del"
+	TopicNodeTopRoute = "/admin/community/topic/node_top"
+)
+
+var TopicLockedErr = errors.New("主题已被锁定或关闭，不能回复")
+
+// canModerate 拥有全站管理权限，或者是节点 nid 的版主且拥有 perm 权限
+func canModerate(me *model.Me, route string, nid, perm int) bool {
+	if me == nil {
+		return false
+	}
+
+	if route != "" && DefaultAuthority.HasAuthority(me, route) {
+		return true
+	}
+
+	return DefaultNodeModerator.HasPerm(me.Uid, nid, perm)
+}
+
+// CanModerate 当前用户对节点 nid 下主题拥有的管理权限，用于页面展示操作按钮
+func (TopicLogic) CanModerate(me *model.Me, nid int) map[string]bool {
+	if me == nil {
+		return nil
+	}
+
+	return map[string]bool{
+		"lock":     canModerate(me, TopicLockRoute, nid, model.ModPermLock),
+		"close":    canModerate(me, TopicCloseRoute, nid, model.ModPermLock),
+		"move":     canModerate(me, TopicMoveRoute, nid, model.ModPermMove),
+		"merge":    DefaultAuthority.HasAuthority(me, TopicMergeRoute),
+		"delete":   canModerate(me, TopicDeleteRoute, nid, model.ModPermDelete),
+		"node_top": canModerate(me, TopicNodeTopRoute, nid, model.ModPermTop),
+	}
+}
+
+// Lock 锁定（不能回复）或解除锁定
+func (self TopicLogic) Lock(ctx context.Context, me *model.Me, tid int, lock bool) error {
+	topic := self.findByTid(tid)
+	if topic.Tid == 0 || topic.MergedTo > 0 {
+		return NotFoundErr
+	}
+
+	if !canModerate(me, TopicLockRoute, topic.Nid, model.ModPermLock) {
+		return NotModifyAuthorityErr
+	}
+
+	modLog := &model.TopicModLog{Tid: tid, Uid: me.Uid, Action: model.TopicModLock}
+	if !lock {
+		modLog.Action = model.TopicModUnlock
+	}
+
+	return self.moderate(topic, map[string]interface{}{"locked": lock}, modLog)
+}
+
+// Close 关闭主题（需要填写原因）或重新打开
+func (self TopicLogic) Close(ctx context.Context, me *model.Me, tid int, close bool, reason string) error {
+	topic := self.findByTid(tid)
+	if topic.Tid == 0 || topic.MergedTo > 0 {
+		return NotFoundErr
+	}
+
+	if !canModerate(me, TopicCloseRoute, topic.Nid, model.ModPermLock) {
+		return NotModifyAuthorityErr
+	}
+
+	reason = strings.TrimSpace(reason)
+	if close {
+		if reason == "" {
+			return errors.New("请填写关闭原因")
+		}
+		if utf8.RuneCountInString(reason) > 200 {
+			return errors.New("关闭原因不能超过 200 个字")
+		}
+	} else {
+		reason = ""
+	}
+
+	modLog := &model.TopicModLog{Tid: tid, Uid: me.Uid, Action: model.TopicModClose, Reason: reason}
+	if !close {
+		modLog.Action = model.TopicModReopen
+	}
+
+	return self.moderate(topic, map[string]interface{}{"closed": close, "close_reason": reason}, modLog)
+}
+
+// Move 将主题移动到另一个节点。版主需要同时管理原节点和新节点
+func (self TopicLogic) Move(ctx context.Context, me *model.Me, tid, nid int) error {
+	topic := self.findByTid(tid)
+	if topic.Tid == 0 || topic.MergedTo > 0 {
+		return NotFoundErr
+	}
+
+	if !canModerate(me, TopicMoveRoute, topic.Nid, model.ModPermMove) ||
+		!canModerate(me, TopicMoveRoute, nid, model.ModPermMove) {
+		return NotModifyAuthorityErr
+	}
+
+	if topic.Nid == nid {
+		return errors.New("主题已经在该节点下")
+	}
+	if DefaultNode.FindOne(nid).Nid == 0 {
+		return errors.New("节点不存在")
+	}
+
+	modLog := &model.TopicModLog{
+		Tid:     tid,
+		Uid:     me.Uid,
+		Action:  model.TopicModMove,
+		FromNid: topic.Nid,
+		ToNid:   nid,
+	}
+	err := self.moderate(topic, map[string]interface{}{"nid": nid}, modLog)
+	if err != nil {
+		return err
+	}
+
+	DefaultFeed.modifyTopicNode(tid, nid)
+
+	return nil
+}
+
+// Delete 删除主题（放入回收站），并记录管理日志
+func (self TopicLogic) Delete(ctx context.Context, me *model.Me, tid int, reason string) error {
+	topic := self.findByTid(tid)
+	if topic.Tid == 0 || topic.MergedTo > 0 || topic.Flag > model.FlagNormal {
+		return NotFoundErr
+	}
+
+	if !canModerate(me, TopicDeleteRoute, topic.Nid, model.ModPermDelete) {
+		return NotModifyAuthorityErr
+	}
+
+	err := DefaultTrash.Delete(ctx, model.TypeTopic, tid, me.Uid, reason)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.MasterDB.Insert(&model.TopicModLog{
+		Tid:    tid,
+		Uid:    me.Uid,
+		Action: model.TopicModDelete,
+		Reason: strings.TrimSpace(reason),
+	})
+	if err != nil {
+		logger.Error("TopicLogic Delete insert log error:", err)
+	}
+
+	return nil
+}
+
+// NodeTop 在节点内置顶或取消置顶，只影响节点主题列表的顺序
+func (self TopicLogic) NodeTop(ctx context.Context, me *model.Me, tid int, top bool) error {
+	topic := self.findByTid(tid)
+	if topic.Tid == 0 || topic.MergedTo > 0 {
+		return NotFoundErr
+	}
+
+	if !canModerate(me, TopicNodeTopRoute, topic.Nid, model.ModPermTop) {
+		return NotModifyAuthorityErr
+	}
+
+	modLog := &model.TopicModLog{Tid: tid, Uid: me.Uid, Action: model.TopicModNodeTop}
+	if !top {
+		modLog.Action = model.TopicModNodeUntop
+	}
+
+	return self.moderate(topic, map[string]interface{}{"node_top": top}, modLog)
+}
+
+// Merge 将重复的主题 tid 合并到 targetTid：回复移到目标主题末尾并重新编号楼层，原主题访问时跳转到目标主题
+func (self TopicLogic) Merge(ctx context.Context, me *model.Me, tid, targetTid int) error {
+	if !DefaultAuthority.HasAuthority(me, TopicMergeRoute) {
+		return NotModifyAuthorityErr
+	}
+
+	if tid == targetTid {
+		return errors.New("不能合并到自己")
+	}
+
+	topic := self.findByTid(tid)
+	if topic.Tid == 0 || topic.MergedTo > 0 {
+		return NotFoundErr
+	}
+	target := self.findByTid(targetTid)
+	if target.Tid == 0 || target.MergedTo > 0 {
+		return errors.New("目标主题不存在")
+	}
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	lastCmt := &model.Comment{}
+	_, err := session.Unscoped().Where("objid=? AND objtype=?", targetTid, model.TypeTopic).OrderBy("floor DESC").ForUpdate().Get(lastCmt)
+	if err != nil {
+		session.Rollback()
+		logger.Error("TopicLogic Merge find last floor error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	// 已删除（在回收站中）的回复也一起移过去，否则恢复时会回到被合并的主题上，楼层也会和重新编号的冲突
+	comments := make([]*model.Comment, 0)
+	err = session.Unscoped().Where("objid=? AND objtype=?", tid, model.TypeTopic).Asc("floor").Find(&comments)
+	if err != nil {
+		session.Rollback()
+		logger.Error("TopicLogic Merge find comments error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	// 回复数不包括已删除的
+	replyNum := 0
+	for i, comment := range comments {
+		if comment.DeletedAt.IsZero() {
+			replyNum++
+		}
+		_, err = session.Unscoped().Table(new(model.Comment)).Id(comment.Cid).Update(map[string]interface{}{
+			"objid": targetTid,
+			"floor": lastCmt.Floor + i + 1,
+		})
+		if err != nil {
+			session.Rollback()
+			logger.Error("TopicLogic Merge move comment error:", err)
+			return errors.New("服务内部错误")
+		}
+	}
+
+	if replyNum > 0 {
+		_, err = session.Id(targetTid).Incr("reply", replyNum).Update(new(model.TopicUpEx))
+		if err == nil {
+			_, err = session.Table(new(model.TopicUpEx)).Id(tid).Update(map[string]interface{}{"reply": 0})
+		}
+		if err == nil && time.Time(topic.Lastreplytime).After(time.Time(target.Lastreplytime)) {
+			_, err = session.Table(new(model.Topic)).Id(targetTid).Update(map[string]interface{}{
+				"lastreplyuid":  topic.Lastreplyuid,
+				"lastreplytime": time.Time(topic.Lastreplytime),
+			})
+		}
+		if err != nil {
+			session.Rollback()
+			logger.Error("TopicLogic Merge update reply error:", err)
+			return errors.New("服务内部错误")
+		}
+	}
+
+	_, err = session.Table(new(model.Topic)).Id(tid).Update(map[string]interface{}{
+		"merged_to": targetTid,
+		"locked":    true,
+	})
+	if err != nil {
+		session.Rollback()
+		logger.Error("TopicLogic Merge update topic error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	_, err = session.Table(new(model.Feed)).Where("objid=? AND objtype=?", tid, model.TypeTopic).
+		Update(map[string]interface{}{"state": model.FeedOffline})
+	if err == nil && replyNum > 0 {
+		_, err = session.Exec("UPDATE feed SET cmtnum=cmtnum+? WHERE objid=? AND objtype=?", replyNum, targetTid, model.TypeTopic)
+	}
+	if err != nil {
+		session.Rollback()
+		logger.Error("TopicLogic Merge update feed error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	_, err = session.Insert(&model.TopicModLog{
+		Tid:       tid,
+		Uid:       me.Uid,
+		Action:    model.TopicModMerge,
+		TargetTid: targetTid,
+	})
+	if err != nil {
+		session.Rollback()
+		logger.Error("TopicLogic Merge insert log error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	session.Commit()
+
+	return nil
+}
+
+// FindModLogs 主题的管理记录，包括合并到该主题的记录
+func (TopicLogic) FindModLogs(ctx context.Context, tid int) []*model.TopicModLog {
+	modLogs := make([]*model.TopicModLog, 0)
+	err := db.MasterDB.Where("tid=? OR target_tid=?", tid, tid).Asc("id").Find(&modLogs)
+	if err != nil {
+		logger.Error("TopicLogic FindModLogs error:", err)
+		return nil
+	}
+
+	uidSet := set.New(set.NonThreadSafe)
+	for _, modLog := range modLogs {
+		uidSet.Add(modLog.Uid)
+	}
+	usersMap := DefaultUser.FindUserInfos(ctx, set.IntSlice(uidSet))
+	for _, modLog := range modLogs {
+		if user, ok := usersMap[modLog.Uid]; ok {
+			modLog.Username = user.Username
+		}
+		if modLog.Action == model.TopicModMove {
+			modLog.FromNode = GetNodeName(modLog.FromNid)
+			modLog.ToNode = GetNodeName(modLog.ToNid)
+		}
+	}
+
+	return modLogs
+}
+
+// moderate 更新主题并记录管理操作。管理操作不改变主题在列表中的顺序，保持 mtime 不变
+func (TopicLogic) moderate(topic *model.Topic, change map[string]interface{}, modLog *model.TopicModLog) error {
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	change["mtime"] = time.Time(topic.Mtime)
+	_, err := session.Table(new(model.Topic)).Id(topic.Tid).Update(change)
+	if err != nil {
+		session.Rollback()
+		logger.Error("TopicLogic moderate update error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	_, err = session.Insert(modLog)
+	if err != nil {
+		session.Rollback()
+		logger.Error("TopicLogic moderate insert log error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	session.Commit()
+
+	return nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+
+	"github.com/polaris1119/set"
+	"golang.org/x/net/context"
+)
+
+// trashTable 支持软删除的对象对应的表
+type trashTable struct {
+	table string
+	pk    string
+	title string // 回收站中展示的字段
+	mtime string // 自动更新的时间字段，删除时保持不变
+}
+
+var trashTables = map[int]trashTable{
+	model.TypeTopic:    {"topics", "tid", "title", "mtime"},
+	model.TypeArticle:  {"articles", "id", "title", "mtime"},
+	model.TypeResource: {"resource", "id", "title", "mtime"},
+	model.TypeProject:  {"open_project", "id", "name", "mtime"},
+	model.TypeWiki:     {"wiki", "id", "title", "mtime"},
+	model.TypeBook:     {"book", "id", "name", "updated_at"},
+	model.TypeComment:  {"comments", "cid", "content", ""},
+}
+
+// 需要同步动态的对象
+var feedTypes = map[int]bool{
+	model.TypeTopic:    true,
+	model.TypeArticle:  true,
+	model.TypeResource: true,
+	model.TypeProject:  true,
+	model.TypeBook:     true,
+}
+
+type TrashLogic struct{}
+
+var DefaultTrash = TrashLogic{}
+
+// Delete 软删除（放入回收站），记录删除人和原因。
+// 删除后所有列表、动态、搜索和 sitemap 都不再出现（搜索索引由 indexer 增量删除）
+func (self TrashLogic) Delete(ctx context.Context, objtype, objid, uid int, reason string) error {
+	tt, ok := trashTables[objtype]
+	if !ok {
+		return errors.New("该类型不支持删除")
+	}
+
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > 200 {
+		return errors.New("删除原因不能超过 200 个字")
+	}
+
+	comment := self.findComment(objtype, objid)
+
+	strSql := fmt.Sprintf("UPDATE %s SET deleted_at=?, deleted_by=?, delete_reason=?", tt.table)
+	if tt.mtime != "" {
+		strSql += fmt.Sprintf(", %s=%s", tt.mtime, tt.mtime)
+	}
+	strSql += fmt.Sprintf(" WHERE %s=? AND deleted_at IS NULL", tt.pk)
+
+	result, err := db.MasterDB.Exec(strSql, time.Now(), uid, reason, objid)
+	if err != nil {
+		logger.Error("TrashLogic Delete error:", err)
+		return errors.New("服务内部错误")
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return NotFoundErr
+	}
+
+	self.afterChange(objtype, objid, comment, true)
+
+	return nil
+}
+
+// Restore 从回收站恢复。时间字段会更新，indexer 增量索引时会重新加入搜索
+func (self TrashLogic) Restore(ctx context.Context, objtype, objid int) error {
+	tt, ok := trashTables[objtype]
+	if !ok {
+		return errors.New("该类型不支持恢复")
+	}
+
+	strSql := fmt.Sprintf("UPDATE %s SET deleted_at=NULL, deleted_by=0, delete_reason='' WHERE %s=? AND deleted_at IS NOT NULL", tt.table, tt.pk)
+	result, err := db.MasterDB.Exec(strSql, objid)
+	if err != nil {
+		logger.Error("TrashLogic Restore error:", err)
+		return errors.New("服务内部错误")
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return NotFoundErr
+	}
+
+	self.afterChange(objtype, objid, self.findComment(objtype, objid), false)
+
+	return nil
+}
+
+// FindByPage 回收站中某类对象（分页），最近删除的在前：后台用
+func (TrashLogic) FindByPage(ctx context.Context, objtype, curPage, limit int) ([]*model.TrashItem, int) {
+	tt, ok := trashTables[objtype]
+	if !ok {
+		return nil, 0
+	}
+
+	total, err := db.MasterDB.Table(tt.table).Where("deleted_at IS NOT NULL").Count()
+	if err != nil {
+		logger.Error("TrashLogic FindByPage count error:", err)
+		return nil, 0
+	}
+
+	fields := fmt.Sprintf("%s AS objid, %s AS title, deleted_at, deleted_by, delete_reason", tt.pk, tt.title)
+	if objtype == model.TypeComment {
+		fields += ", objid AS parent_id, objtype AS parent_type, floor"
+	}
+
+	offset := (curPage - 1) * limit
+	items := make([]*model.TrashItem, 0)
+	err = db.MasterDB.Table(tt.table).Select(fields).Where("deleted_at IS NOT NULL").
+		Desc("deleted_at").Limit(limit, offset).Find(&items)
+	if err != nil {
+		logger.Error("TrashLogic FindByPage error:", err)
+		return nil, 0
+	}
+
+	uidSet := set.New(set.NonThreadSafe)
+	for _, item := range items {
+		item.Objtype = objtype
+		uidSet.Add(item.DeletedBy)
+	}
+	usersMap := DefaultUser.FindUserInfos(ctx, set.IntSlice(uidSet))
+	for _, item := range items {
+		item.Operator = usersMap[item.DeletedBy]
+	}
+
+	return items, int(total)
+}
+
+// findDeletedIds 某个时间之后删除的对象 id，indexer 用来从搜索中删除
+func (TrashLogic) findDeletedIds(objtype int, since time.Time) []int {
+	tt := trashTables[objtype]
+
+	objids := make([]int, 0)
+	err := db.MasterDB.Table(tt.table).Where("deleted_at>?", since).Cols(tt.pk).Find(&objids)
+	if err != nil {
+		logger.Error("TrashLogic findDeletedIds error:", err)
+	}
+
+	return objids
+}
+
+// findComment 评论删除或恢复后需要更新所属对象，不管是否已删除都要查出来
+func (TrashLogic) findComment(objtype, objid int) *model.Comment {
+	if objtype != model.TypeComment {
+		return nil
+	}
+
+	comment := &model.Comment{}
+	_, err := db.MasterDB.Unscoped().Id(objid).Get(comment)
+	if err != nil {
+		logger.Error("TrashLogic findComment error:", err)
+	}
+
+	return comment
+}
+
+// afterChange 删除或恢复后同步动态，评论则重新统计所属对象的评论数
+func (self TrashLogic) afterChange(objtype, objid int, comment *model.Comment, deleted bool) {
+	if comment != nil {
+		self.recountComments(comment.Objid, comment.Objtype)
+		return
+	}
+
+	if !feedTypes[objtype] {
+		return
+	}
+
+	if deleted {
+		DefaultFeed.setState(objid, objtype, model.FeedOffline)
+	} else {
+		DefaultFeed.online(objid, objtype)
+	}
+}
+
+// recountComments 重新统计对象的评论数（已删除的评论不算）
+func (TrashLogic) recountComments(objid, objtype int) {
+	if objid == 0 {
+		return
+	}
+
+	total, err := db.MasterDB.Where("objid=? AND objtype=?", objid, objtype).Count(new(model.Comment))
+	if err != nil {
+		logger.Error("TrashLogic recountComments count error:", err)
+		return
+	}
+
+	change := map[string]interface{}{"cmtnum": total}
+	switch objtype {
+	case model.TypeTopic:
+		_, err = db.MasterDB.Table(new(model.TopicUpEx)).Id(objid).Update(map[string]interface{}{"reply": total})
+	case model.TypeArticle:
+		_, err = db.MasterDB.Table(new(model.Article)).Id(objid).Update(change)
+	case model.TypeResource:
+		_, err = db.MasterDB.Table(new(model.ResourceEx)).Id(objid).Update(change)
+	case model.TypeProject:
+		_, err = db.MasterDB.Table(new(model.OpenProject)).Id(objid).Update(change)
+	case model.TypeBook:
+		_, err = db.MasterDB.Table(new(model.Book)).Id(objid).Update(change)
+	}
+	if err == nil && feedTypes[objtype] {
+		_, err = db.MasterDB.Table(new(model.Feed)).Where("objid=? AND objtype=?", objid, objtype).Update(change)
+	}
+	if err != nil {
+		logger.Error("TrashLogic recountComments update error:", err)
+	}
+}
@@ -67,6 +67,14 @@ func (self UserLogic) CreateUser(ctx context.Context, form url.Values) (errMsg s
 		return
 	}
 
+	// 安装时创建的站长可以使用随机生成的密码
+	if !user.IsRoot {
+		if err = checkPasswdStrength(form.Get("passwd"), user.Username, user.Email); err != nil {
+			errMsg = err.Error()
+			return
+		}
+	}
+
 	if config.ConfigFile.MustBool("account", "verify_email", true) {
 		if !user.IsRoot {
 			// 避免前端伪造，传递 status=1
@@ -122,8 +130,9 @@ func (self UserLogic) Update(ctx context.Context, me *model.Me, form url.Values)
 	cols := "name,open,city,company,github,weibo,website,monlog,introduce"
 	// 变更了邮箱
 	if user.Email != me.Email {
-		cols += ",email,status"
+		cols += ",email,status,email_verified"
 		user.Status = model.UserStatusNoAudit
+		user.EmailVerified = false
 	}
 
 	session := db.MasterDB.NewSession()
@@ -167,6 +176,12 @@ func (UserLogic) UpdateUserStatus(ctx context.Context, uid, status int) {
 	_, err := db.MasterDB.Table(new(model.User)).Id(uid).Update(map[string]interface{}{"status": status})
 	if err != nil {
 		logger.Error("更新用户 【%d】 状态失败：%s", uid, err)
+		return
+	}
+
+	// 冻结、停号时，所有设备上的登录都失效
+	if status > model.UserStatusAudit {
+		DefaultSession.RevokeAll(uid)
 	}
 }
 
@@ -300,6 +315,9 @@ func (self UserLogic) FindCurrentUser(ctx context.Context, username interface{})
 		MsgNum:   DefaultMessage.FindNotReadMsgNum(ctx, user.Uid),
 		DauAuth:  user.DauAuth,
 
+		Level:      user.Level,
+		Privileges: LevelPrivileges(user.Level),
+
 		Balance: user.Balance,
 		Gold:    user.Gold,
 		Silver:  user.Silver,
@@ -373,6 +391,13 @@ var (
 	ErrPasswd   = errors.New("密码错误")
 )
 
+// 不能登录的用户状态对应的提示
+var userStatusErrMap = map[int]error{
+	model.UserStatusRefuse: errors.New("您的账号审核拒绝"),
+	model.UserStatusFreeze: errors.New("您的账号因为非法发布信息已被冻结，请联系管理员！"),
+	model.UserStatusOutage: errors.New("您的账号因为非法发布信息已被停号，请联系管理员！"),
+}
+
 // Login 登录；成功返回用户登录信息(user_login)
 func (self UserLogic) Login(ctx context.Context, username, passwd string) (*model.UserLogin, error) {
 
@@ -393,24 +418,24 @@ func (self UserLogic) Login(ctx context.Context, username, passwd string) (*mode
 	db.MasterDB.Id(userLogin.Uid).Get(user)
 	if user.Status > model.UserStatusAudit {
 		logger.Info("用户 %q 的状态非审核通过, 用户的状态值：%d", username, user.Status)
-		var errMap = map[int]error{
-			model.UserStatusRefuse: errors.New("您的账号审核拒绝"),
-			model.UserStatusFreeze: errors.New("您的账号因为非法发布信息已被冻结，请联系管理员！"),
-			model.UserStatusOutage: errors.New("您的账号因为非法发布信息已被停号，请联系管理员！"),
-		}
-		return nil, errMap[user.Status]
+		return nil, userStatusErrMap[user.Status]
 	}
 
-	md5Passwd := goutils.Md5(passwd + userLogin.Passcode)
-	logger.Debug("passwd: %s, passcode: %s, md5passwd: %s, dbpasswd: %s", passwd, userLogin.Passcode, md5Passwd, userLogin.Passwd)
-	if md5Passwd != userLogin.Passwd {
+	ok, needUpgrade := userLogin.CheckPasswd(passwd)
+	if !ok {
 		logger.Info("用户名 %q 填写的密码错误", username)
 		return nil, ErrPasswd
 	}
 
+	// 旧的 md5 密码，登录成功时升级为 bcrypt
+	if needUpgrade {
+		self.savePasswd(ctx, "uid", userLogin.Uid, passwd)
+	}
+
 	go func() {
 		self.IncrUserWeight("uid", userLogin.Uid, 1)
 		self.RecordLoginTime(username)
+		DefaultUserLevel.Refresh(userLogin.Uid)
 	}()
 
 	return userLogin, nil
@@ -431,24 +456,16 @@ func (self UserLogic) UpdatePasswd(ctx context.Context, username, curPasswd, new
 		}
 	}
 
-	userLogin = &model.UserLogin{
-		Passwd: newPasswd,
-	}
-	err = userLogin.GenMd5Passwd()
-	if err != nil {
+	if err = checkPasswdStrength(newPasswd, userLogin.Username, userLogin.Email); err != nil {
 		return err.Error(), err
 	}
 
-	changeData := map[string]interface{}{
-		"passwd":   userLogin.Passwd,
-		"passcode": userLogin.Passcode,
-	}
-	_, err = db.MasterDB.Table(userLogin).Where("username=?", username).Update(changeData)
-	if err != nil {
-		logger.Error("用户 %s 更新密码错误：%s", username, err)
-		return "对不起，内部服务错误！", err
+	errMsg, err := self.savePasswd(ctx, "username", username, newPasswd)
+	if err == nil {
+		// 修改密码后，所有设备上的登录都失效
+		DefaultSession.RevokeAll(userLogin.Uid)
 	}
-	return "", nil
+	return errMsg, err
 }
 
 func (UserLogic) HasPasswd(ctx context.Context, uid int) bool {
@@ -462,11 +479,29 @@ func (UserLogic) HasPasswd(ctx context.Context, uid int) bool {
 }
 
 func (self UserLogic) ResetPasswd(ctx context.Context, email, passwd string) (string, error) {
+	userLogin := &model.UserLogin{}
+	_, err := db.MasterDB.Where("email=?", email).Get(userLogin)
+	if err != nil || userLogin.Uid == 0 {
+		return "用户不存在", errors.New("user not exists")
+	}
 
+	if err = checkPasswdStrength(passwd, userLogin.Username, email); err != nil {
+		return err.Error(), err
+	}
+
+	errMsg, err := self.savePasswd(ctx, "email", email, passwd)
+	if err == nil {
+		DefaultSession.RevokeAll(userLogin.Uid)
+	}
+	return errMsg, err
+}
+
+// savePasswd 生成新的密码哈希并保存，field 为 uid、username 或 email
+func (UserLogic) savePasswd(ctx context.Context, field string, val interface{}, passwd string) (string, error) {
 	userLogin := &model.UserLogin{
 		Passwd: passwd,
 	}
-	err := userLogin.GenMd5Passwd()
+	err := userLogin.GenPasswd()
 	if err != nil {
 		return err.Error(), err
 	}
@@ -475,9 +510,9 @@ func (self UserLogic) ResetPasswd(ctx context.Context, email, passwd string) (st
 		"passwd":   userLogin.Passwd,
 		"passcode": userLogin.Passcode,
 	}
-	_, err = db.MasterDB.Table(userLogin).Where("email=?", email).Update(changeData)
+	_, err = db.MasterDB.Table(userLogin).Where(field+"=?", val).Update(changeData)
 	if err != nil {
-		logger.Error("用户 %s 更新密码错误：%s", email, err)
+		logger.Error("用户 %v 更新密码错误：%s", val, err)
 		return "对不起，内部服务错误！", err
 	}
 	return "", nil
@@ -497,6 +532,7 @@ func (self UserLogic) Activate(ctx context.Context, email, uuid string, timestam
 	}
 
 	user.Status = model.UserStatusAudit
+	user.EmailVerified = true
 
 	_, err := db.MasterDB.Id(user.Uid).Update(user)
 	if err != nil {
@@ -683,6 +719,12 @@ func (UserLogic) doCreateUser(ctx context.Context, session *xorm.Session, user *
 
 	user.DauAuth = model.DefaultAuth
 
+	// 新用户进入观察期，前几条内容需要审核
+	if probationNum := UserSetting[model.KeyProbationNum]; probationNum > 0 && !user.IsRoot {
+		user.Sanction = model.UserSanctionProbation
+		user.ProbationLeft = probationNum
+	}
+
 	_, err := session.Insert(user)
 	if err != nil {
 		return err
@@ -696,7 +738,7 @@ func (UserLogic) doCreateUser(ctx context.Context, session *xorm.Session, user *
 	}
 	if len(passwd) > 0 {
 		userLogin.Passwd = passwd[0]
-		err = userLogin.GenMd5Passwd()
+		err = userLogin.GenPasswd()
 		if err != nil {
 			return err
 		}
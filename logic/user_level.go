@@ -0,0 +1,259 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author:polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"sander/db"
+	"sander/global"
+	"sander/logger"
+	"sander/model"
+
+	"github.com/polaris1119/goutils"
+	"golang.org/x/net/context"
+)
+
+type UserLevelLogic struct{}
+
+var DefaultUserLevel = UserLevelLogic{}
+
+// HasPrivilege 当前用户是否拥有某项特权，管理员拥有所有特权
+func HasPrivilege(me *model.Me, priv int) bool {
+	if me == nil || me.Uid == 0 {
+		return false
+	}
+
+	if me.IsAdmin {
+		return true
+	}
+
+	return me.Privileges&priv == priv
+}
+
+// LevelPrivileges 某个等级拥有的特权，低等级解锁的特权高等级同样拥有
+func LevelPrivileges(level int) int {
+	userLevelLocker.RLock()
+	defer userLevelLocker.RUnlock()
+
+	privileges := 0
+	for _, userLevel := range UserLevels {
+		if userLevel.Level <= level {
+			privileges |= userLevel.Privileges
+		}
+	}
+	return privileges
+}
+
+// 没有配置等级时，发布内容、评论需要的最低铜币
+const (
+	defaultPublishBalance = 20
+	defaultCommentBalance = 5
+)
+
+// LevelBalanceFloor 某个等级发布内容、评论时账号至少要有多少铜币，取不高于该等级的最高一级的配置
+func LevelBalanceFloor(level int) (publish, comment int) {
+	userLevelLocker.RLock()
+	defer userLevelLocker.RUnlock()
+
+	publish, comment = defaultPublishBalance, defaultCommentBalance
+	matched := -1
+	for _, userLevel := range UserLevels {
+		if userLevel.Level <= level && userLevel.Level > matched {
+			matched = userLevel.Level
+			publish, comment = userLevel.PublishBalance, userLevel.CommentBalance
+		}
+	}
+	return
+}
+
+// Reputation 计算用户当前的声望数据
+func (UserLevelLogic) Reputation(user *model.User) *model.UserReputation {
+	reputation := &model.UserReputation{
+		Days: int(time.Since(time.Time(user.Ctime)).Hours() / 24),
+	}
+
+	userActive := &model.UserActive{}
+	_, err := db.MasterDB.Where("uid=?", user.Uid).Get(userActive)
+	if err != nil {
+		logger.Error("UserLevelLogic Reputation find user active error:", err)
+	}
+	reputation.Weight = userActive.Weight
+
+	topicNum, err := db.MasterDB.Where("uid=? AND flag IN(?,?)", user.Uid, model.FlagNoAudit, model.FlagNormal).Count(new(model.Topic))
+	if err != nil {
+		logger.Error("UserLevelLogic Reputation count topic error:", err)
+	}
+	articleNum, err := db.MasterDB.Where("author_txt=? AND domain=? AND status IN(?,?)", user.Username, WebsiteSetting.Domain, model.ArticleStatusNew, model.ArticleStatusOnline).Count(new(model.Article))
+	if err != nil {
+		logger.Error("UserLevelLogic Reputation count article error:", err)
+	}
+	resourceNum, err := db.MasterDB.Where("uid=? AND flag!=?", user.Uid, model.FlagShadow).Count(new(model.Resource))
+	if err != nil {
+		logger.Error("UserLevelLogic Reputation count resource error:", err)
+	}
+	reputation.Content = int(topicNum + articleNum + resourceNum)
+
+	return reputation
+}
+
+// Calc 根据声望数据计算能达到的最高等级
+func (UserLevelLogic) Calc(reputation *model.UserReputation) int {
+	userLevelLocker.RLock()
+	defer userLevelLocker.RUnlock()
+
+	level := 0
+	for _, userLevel := range UserLevels {
+		if reputation.Weight >= userLevel.MinWeight &&
+			reputation.Content >= userLevel.MinContent &&
+			reputation.Days >= userLevel.MinDays {
+			level = userLevel.Level
+		}
+	}
+
+	return level
+}
+
+// Refresh 重新计算用户等级，有变化时更新
+func (self UserLevelLogic) Refresh(uid int) {
+	if uid == 0 {
+		return
+	}
+
+	user := DefaultUser.FindOne(nil, "uid", uid)
+	if user.Uid == 0 {
+		return
+	}
+
+	level := self.Calc(self.Reputation(user))
+	if level == user.Level {
+		return
+	}
+
+	_, err := db.MasterDB.Table(new(model.User)).Where("uid=?", uid).Update(map[string]interface{}{"level": level})
+	if err != nil {
+		logger.Error("UserLevelLogic Refresh uid(%d) error:%+v", uid, err)
+	}
+}
+
+// RefreshAll 重新计算活跃用户和已有等级用户的等级（每天执行一次）
+func (self UserLevelLogic) RefreshAll() {
+	results, err := db.MasterDB.Query("SELECT uid FROM user_active UNION SELECT uid FROM user_info WHERE level>0")
+	if err != nil {
+		logger.Error("UserLevelLogic RefreshAll error:", err)
+		return
+	}
+
+	for _, result := range results {
+		self.Refresh(goutils.MustInt(string(result["uid"])))
+	}
+}
+
+// FindLevelInfo 用户当前等级、下一等级及声望数据，供用户查看如何升级
+func (self UserLevelLogic) FindLevelInfo(ctx context.Context, me *model.Me) map[string]interface{} {
+	user := DefaultUser.FindOne(ctx, "uid", me.Uid)
+	reputation := self.Reputation(user)
+
+	userLevelLocker.RLock()
+	defer userLevelLocker.RUnlock()
+
+	var curLevel, nextLevel *model.UserLevel
+	for _, userLevel := range UserLevels {
+		if userLevel.Level <= user.Level {
+			curLevel = userLevel
+		} else if nextLevel == nil {
+			nextLevel = userLevel
+		}
+	}
+
+	return map[string]interface{}{
+		"levels":     UserLevels,
+		"cur_level":  curLevel,
+		"next_level": nextLevel,
+		"reputation": reputation,
+	}
+}
+
+// FindAll 获取所有等级：后台用
+func (UserLevelLogic) FindAll(ctx context.Context) []*model.UserLevel {
+	userLevels := make([]*model.UserLevel, 0)
+	err := db.MasterDB.Asc("level").Find(&userLevels)
+	if err != nil {
+		logger.Error("UserLevelLogic FindAll error:", err)
+		return nil
+	}
+	return userLevels
+}
+
+// FindById 获取单个等级
+func (UserLevelLogic) FindById(ctx context.Context, id int) *model.UserLevel {
+	userLevel := &model.UserLevel{}
+	_, err := db.MasterDB.Id(id).Get(userLevel)
+	if err != nil {
+		logger.Error("UserLevelLogic FindById error:", err)
+		return nil
+	}
+
+	if userLevel.Id == 0 {
+		return nil
+	}
+
+	return userLevel
+}
+
+// Save 新建或修改等级：后台用
+func (UserLevelLogic) Save(ctx context.Context, form url.Values) (errMsg string, err error) {
+	userLevel := &model.UserLevel{}
+	err = schemaDecoder.Decode(userLevel, form)
+	if err != nil {
+		logger.Error("UserLevelLogic Save decode error:", err)
+		errMsg = err.Error()
+		return
+	}
+
+	if userLevel.Level < 0 {
+		errMsg = "等级不能小于 0"
+		err = errors.New(errMsg)
+		return
+	}
+
+	userLevel.Privileges = 0
+	for _, priv := range form["privilege"] {
+		userLevel.Privileges |= goutils.MustInt(priv)
+	}
+
+	if userLevel.Id != 0 {
+		_, err = db.MasterDB.Id(userLevel.Id).AllCols().Omit("created_at").Update(userLevel)
+	} else {
+		_, err = db.MasterDB.Insert(userLevel)
+	}
+
+	if err != nil {
+		errMsg = "内部服务器错误"
+		logger.Error("UserLevelLogic Save error:", err)
+		return
+	}
+
+	global.UserLevelChan <- struct{}{}
+
+	return
+}
+
+// Delete 删除等级：后台用
+func (UserLevelLogic) Delete(ctx context.Context, id int) error {
+	_, err := db.MasterDB.Id(id).Delete(new(model.UserLevel))
+	if err != nil {
+		logger.Error("UserLevelLogic Delete error:", err)
+		return err
+	}
+
+	global.UserLevelChan <- struct{}{}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"errors"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+
+	"golang.org/x/net/context"
+)
+
+type UserSanctionLogic struct{}
+
+var DefaultUserSanction = UserSanctionLogic{}
+
+// Find 用户当前的处罚
+func (UserSanctionLogic) Find(uid int) int {
+	user := &model.User{}
+	_, err := db.MasterDB.Id(uid).Cols("sanction").Get(user)
+	if err != nil {
+		logger.Error("UserSanctionLogic Find error:", err)
+	}
+	return user.Sanction
+}
+
+// Hold 受处罚的用户刚发布的内容：放入举报处理队列并隐藏，管理员驳回（即审核通过）后才展示。
+// 返回 true 表示已放入队列，调用方不需要再发动态、发通知
+func (UserSanctionLogic) Hold(ctx context.Context, sanction, objtype, objid int) bool {
+	err := DefaultReport.Review(ctx, objtype, objid, model.ReportReasonSanction, model.UserSanctionMap[sanction]+"用户发布的内容")
+	if err != nil {
+		logger.Error("UserSanctionLogic Hold error:", err)
+		return false
+	}
+	return true
+}
+
+// Approve 观察期用户的一条内容审核通过，需要审核的内容数减到 0 时结束观察期
+func (UserSanctionLogic) Approve(ctx context.Context, uid int) {
+	_, err := db.MasterDB.Exec("UPDATE user_info SET probation_left=probation_left-1 WHERE uid=? AND sanction=? AND probation_left>0",
+		uid, model.UserSanctionProbation)
+	if err == nil {
+		_, err = db.MasterDB.Exec("UPDATE user_info SET sanction=? WHERE uid=? AND sanction=? AND probation_left=0",
+			model.UserSanctionNone, uid, model.UserSanctionProbation)
+	}
+	if err != nil {
+		logger.Error("UserSanctionLogic Approve error:", err)
+	}
+}
+
+// Set 后台设置用户的处罚。设为观察期时 probationLeft 不大于 0 则使用配置的条数
+func (UserSanctionLogic) Set(ctx context.Context, uid, sanction, probationLeft int) error {
+	if _, ok := model.UserSanctionMap[sanction]; !ok {
+		return errors.New("处罚类型不合法")
+	}
+
+	if sanction == model.UserSanctionProbation {
+		if probationLeft <= 0 {
+			probationLeft = UserSetting[model.KeyProbationNum]
+		}
+		if probationLeft <= 0 {
+			return errors.New("观察期需要审核的内容数必须大于 0")
+		}
+	} else {
+		probationLeft = 0
+	}
+
+	_, err := db.MasterDB.Table(new(model.User)).Id(uid).Update(map[string]interface{}{
+		"sanction":       sanction,
+		"probation_left": probationLeft,
+	})
+	if err != nil {
+		logger.Error("UserSanctionLogic Set error:", err)
+		return errors.New("服务内部错误")
+	}
+	return nil
+}
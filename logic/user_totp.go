@@ -0,0 +1,332 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"sander/db"
+	"sander/db/nosql"
+	"sander/logger"
+	"sander/model"
+	"sander/util"
+
+	"github.com/polaris1119/goutils"
+	"golang.org/x/net/context"
+)
+
+const (
+	// 密码校验通过后，多久内要输入动态码
+	totpLoginExpire = 300
+	// 同一次登录最多输错几次动态码
+	totpLoginMaxFail = 5
+	// 同一个账号一小时内最多输错几次动态码，超过后锁定，防止反复重新登录来穷举动态码
+	totpUidMaxFail    = 10
+	totpUidFailExpire = 3600
+)
+
+var ErrTotpCode = errors.New("验证码错误")
+
+type UserTotpLogic struct{}
+
+var DefaultUserTotp = UserTotpLogic{}
+
+// Find 用户的两步验证设置，没有时返回 nil
+func (UserTotpLogic) Find(uid int) *model.UserTotp {
+	userTotp := &model.UserTotp{}
+	has, err := db.MasterDB.Id(uid).Get(userTotp)
+	if err != nil {
+		logger.Error("UserTotpLogic Find error:", err)
+		return nil
+	}
+	if !has {
+		return nil
+	}
+	return userTotp
+}
+
+// IsEnabled 用户是否启用了两步验证
+func (self UserTotpLogic) IsEnabled(uid int) bool {
+	userTotp := self.Find(uid)
+	return userTotp != nil && userTotp.Enabled
+}
+
+// NeedEnroll 后台设置了管理员必须启用两步验证，而该管理员还没有启用
+func (self UserTotpLogic) NeedEnroll(me *model.Me) bool {
+	if UserSetting[model.KeyRequireAdmin2FA] <= 0 || !me.IsAdmin {
+		return false
+	}
+	return !self.IsEnabled(me.Uid)
+}
+
+// Enroll 生成新的密钥，返回密钥和扫码用的地址。输入动态码确认（Enable）之前不生效
+func (self UserTotpLogic) Enroll(ctx context.Context, me *model.Me) (string, string, error) {
+	userTotp := self.Find(me.Uid)
+	if userTotp != nil && userTotp.Enabled {
+		return "", "", errors.New("已经启用了两步验证")
+	}
+
+	secret, err := util.GenTOTPSecret()
+	if err != nil {
+		logger.Error("UserTotpLogic Enroll gen secret error:", err)
+		return "", "", errors.New("服务内部错误")
+	}
+
+	if userTotp == nil {
+		_, err = db.MasterDB.Insert(&model.UserTotp{Uid: me.Uid, Secret: secret})
+	} else {
+		_, err = db.MasterDB.Id(me.Uid).Cols("secret", "recovery_codes", "last_step").
+			Update(&model.UserTotp{Secret: secret})
+	}
+	if err != nil {
+		logger.Error("UserTotpLogic Enroll save error:", err)
+		return "", "", errors.New("服务内部错误")
+	}
+
+	return secret, util.TOTPURI(WebsiteSetting.Name, me.Username, secret), nil
+}
+
+// Enable 用认证应用上的动态码确认后启用，返回恢复码（只在这时明文显示一次）
+func (self UserTotpLogic) Enable(ctx context.Context, uid int, code string) ([]string, error) {
+	userTotp := self.Find(uid)
+	if userTotp == nil || userTotp.Secret == "" {
+		return nil, errors.New("请先扫码绑定")
+	}
+	if userTotp.Enabled {
+		return nil, errors.New("已经启用了两步验证")
+	}
+
+	step, ok := util.ValidateTOTP(userTotp.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrTotpCode
+	}
+
+	codes, hashes, err := genRecoveryCodes()
+	if err != nil {
+		logger.Error("UserTotpLogic Enable gen recovery codes error:", err)
+		return nil, errors.New("服务内部错误")
+	}
+
+	_, err = db.MasterDB.Table(new(model.UserTotp)).Id(uid).Update(map[string]interface{}{
+		"enabled":        true,
+		"recovery_codes": strings.Join(hashes, ","),
+		"last_step":      step,
+	})
+	if err != nil {
+		logger.Error("UserTotpLogic Enable error:", err)
+		return nil, errors.New("服务内部错误")
+	}
+
+	return codes, nil
+}
+
+// Disable 关闭两步验证，需要动态码或恢复码
+func (self UserTotpLogic) Disable(ctx context.Context, uid int, code string) error {
+	if !self.Verify(ctx, uid, code) {
+		return ErrTotpCode
+	}
+
+	_, err := db.MasterDB.Id(uid).Delete(new(model.UserTotp))
+	if err != nil {
+		logger.Error("UserTotpLogic Disable error:", err)
+		return errors.New("服务内部错误")
+	}
+	return nil
+}
+
+// RegenRecoveryCodes 恢复码用完或泄露时重新生成，旧的全部作废
+func (self UserTotpLogic) RegenRecoveryCodes(ctx context.Context, uid int, code string) ([]string, error) {
+	if !self.Verify(ctx, uid, code) {
+		return nil, ErrTotpCode
+	}
+
+	codes, hashes, err := genRecoveryCodes()
+	if err != nil {
+		logger.Error("UserTotpLogic RegenRecoveryCodes gen error:", err)
+		return nil, errors.New("服务内部错误")
+	}
+
+	_, err = db.MasterDB.Table(new(model.UserTotp)).Id(uid).Update(map[string]interface{}{
+		"recovery_codes": strings.Join(hashes, ","),
+	})
+	if err != nil {
+		logger.Error("UserTotpLogic RegenRecoveryCodes error:", err)
+		return nil, errors.New("服务内部错误")
+	}
+	return codes, nil
+}
+
+// RecoveryCodesLeft 还没有使用的恢复码个数
+func (UserTotpLogic) RecoveryCodesLeft(userTotp *model.UserTotp) int {
+	if userTotp == nil || userTotp.RecoveryCodes == "" {
+		return 0
+	}
+	return len(strings.Split(userTotp.RecoveryCodes, ","))
+}
+
+// Verify 校验动态码或恢复码。动态码在时间窗口内只能用一次，恢复码用过即作废
+func (self UserTotpLogic) Verify(ctx context.Context, uid int, code string) bool {
+	userTotp := self.Find(uid)
+	if userTotp == nil || !userTotp.Enabled {
+		return false
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := util.ValidateTOTP(userTotp.Secret, code, time.Now()); ok {
+		// 条件更新，并发请求同一个动态码时只有一个成功
+		affected, err := db.MasterDB.Table(new(model.UserTotp)).Where("uid=? AND last_step<?", uid, step).
+			Update(map[string]interface{}{"last_step": step})
+		if err != nil {
+			logger.Error("UserTotpLogic Verify update last_step error:", err)
+			return false
+		}
+		return affected > 0
+	}
+
+	return self.useRecoveryCode(userTotp, code)
+}
+
+func (UserTotpLogic) useRecoveryCode(userTotp *model.UserTotp, code string) bool {
+	if userTotp.RecoveryCodes == "" {
+		return false
+	}
+
+	hash := hashRecoveryCode(code)
+	hashes := strings.Split(userTotp.RecoveryCodes, ",")
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) != 1 {
+			continue
+		}
+
+		left := append(hashes[:i:i], hashes[i+1:]...)
+		affected, err := db.MasterDB.Table(new(model.UserTotp)).
+			Where("uid=? AND recovery_codes=?", userTotp.Uid, userTotp.RecoveryCodes).
+			Update(map[string]interface{}{"recovery_codes": strings.Join(left, ",")})
+		if err != nil {
+			logger.Error("UserTotpLogic useRecoveryCode error:", err)
+			return false
+		}
+		return affected > 0
+	}
+
+	return false
+}
+
+// StartLogin 密码校验通过，但还需要输入动态码。返回本次登录的临时凭证
+func (UserTotpLogic) StartLogin(uid int) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		logger.Error("UserTotpLogic StartLogin rand error:", err)
+		return "", errors.New("服务内部错误")
+	}
+	token := hex.EncodeToString(buf)
+
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	if err := redisClient.SET("login:2fa:"+token, uid, totpLoginExpire); err != nil {
+		logger.Error("UserTotpLogic StartLogin save error:", err)
+		return "", errors.New("服务内部错误")
+	}
+	return token, nil
+}
+
+// FinishLogin 校验临时凭证和动态码（或恢复码），通过后返回登录的用户
+func (self UserTotpLogic) FinishLogin(ctx context.Context, token, code string) (*model.UserLogin, error) {
+	redisClient := nosql.NewRedisFromPool()
+	defer redisClient.Close()
+
+	key := "login:2fa:" + token
+	uid := goutils.MustInt(redisClient.GET(key))
+	if token == "" || uid == 0 {
+		return nil, errors.New("登录已过期，请重新输入用户名和密码")
+	}
+
+	uidFailKey := "login:2fa_fail_uid:" + strconv.Itoa(uid)
+	if goutils.MustInt(redisClient.GET(uidFailKey)) >= totpUidMaxFail {
+		redisClient.DEL(key)
+		return nil, errors.New("验证码错误次数太多，请一小时后再试")
+	}
+
+	userLogin := &model.UserLogin{}
+	_, err := db.MasterDB.Where("uid=?", uid).Get(userLogin)
+	if err != nil || userLogin.Uid == 0 {
+		logger.Error("UserTotpLogic FinishLogin find user error:", err)
+		return nil, errors.New("内部错误，请稍后再试！")
+	}
+
+	if !self.Verify(ctx, uid, code) {
+		// 和密码错误一样扣减该用户名的登录次数
+		DefaultRateLimit.LoginFailed(userLogin.Username)
+
+		num, err := redisClient.INCR(uidFailKey)
+		if err == nil && num == 1 {
+			redisClient.EXPIRE(uidFailKey, totpUidFailExpire)
+		}
+		if num >= totpUidMaxFail {
+			redisClient.DEL(key)
+			return nil, errors.New("验证码错误次数太多，请一小时后再试")
+		}
+
+		failKey := "login:2fa_fail:" + token
+		num, err = redisClient.INCR(failKey)
+		if err == nil {
+			redisClient.EXPIRE(failKey, totpLoginExpire)
+		}
+		if num >= totpLoginMaxFail {
+			redisClient.DEL(key)
+			return nil, errors.New("验证码错误次数太多，请重新输入用户名和密码")
+		}
+		return nil, ErrTotpCode
+	}
+	redisClient.DEL(key)
+	redisClient.DEL(uidFailKey)
+
+	// 输入动态码期间账号可能被冻结
+	user := &model.User{}
+	_, err = db.MasterDB.Id(uid).Cols("status").Get(user)
+	if err != nil {
+		logger.Error("UserTotpLogic FinishLogin find user status error:", err)
+		return nil, errors.New("内部错误，请稍后再试！")
+	}
+	if user.Status > model.UserStatusAudit {
+		logger.Info("用户 %d 的状态非审核通过, 用户的状态值：%d", uid, user.Status)
+		return nil, userStatusErrMap[user.Status]
+	}
+
+	return userLogin, nil
+}
+
+// genRecoveryCodes 生成恢复码，返回明文和对应的 sha256
+func genRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, model.RecoveryCodeNum)
+	hashes := make([]string, model.RecoveryCodeNum)
+	buf := make([]byte, 5)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(buf)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode 忽略大小写和分隔符
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
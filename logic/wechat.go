@@ -130,7 +130,7 @@ func (self WechatLogic) AutoReply(ctx context.Context, reqData []byte) (*model.W
 
 func (self WechatLogic) topicContent(ctx context.Context, wechatMsg *model.WechatMsg) (*model.WechatReply, error) {
 
-	topics := DefaultTopic.FindRecent(5)
+	topics := DefaultTopic.FindRecent(ctx, 5)
 
 	respContentSlice := make([]string, len(topics))
 	for i, topic := range topics {
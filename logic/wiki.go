@@ -15,7 +15,9 @@ import (
 	"sander/db"
 	"sander/logger"
 	"sander/model"
+	"sander/util"
 
+	"github.com/go-xorm/xorm"
 	"github.com/polaris1119/goutils"
 	"github.com/polaris1119/set"
 	"golang.org/x/net/context"
@@ -26,7 +28,7 @@ type WikiLogic struct{}
 var DefaultWiki = WikiLogic{}
 
 // Create 创建一个wiki页面
-func (WikiLogic) Create(ctx context.Context, me *model.Me, form url.Values) error {
+func (self WikiLogic) Create(ctx context.Context, me *model.Me, form url.Values) error {
 
 	wiki := &model.Wiki{}
 	err := schemaDecoder.Decode(wiki, form)
@@ -35,12 +37,44 @@ func (WikiLogic) Create(ctx context.Context, me *model.Me, form url.Values) erro
 		return err
 	}
 
+	wiki.Uri = NormalizeWikiUri(wiki.Uri)
+	if wiki.Uri == "" {
+		wiki.Uri = NormalizeWikiUri(wiki.Title)
+	}
+	if !ValidWikiUri(wiki.Uri) {
+		return errors.New("Wiki访问地址不合法")
+	}
+	if self.UriExists(ctx, wiki.Uri) {
+		return errors.New("Wiki访问地址已存在")
+	}
+
 	wiki.Uid = me.Uid
-	if _, err = db.MasterDB.Insert(wiki); err != nil {
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
+
+	if _, err = session.Insert(wiki); err != nil {
+		session.Rollback()
 		logger.Error("Create Wiki error:", err)
 		return err
 	}
 
+	summary := form.Get("summary")
+	if summary == "" {
+		summary = "创建"
+	}
+	if err = self.saveRevision(session, wiki, me.Uid, summary); err != nil {
+		session.Rollback()
+		return err
+	}
+	if err = self.saveLinks(session, wiki); err != nil {
+		session.Rollback()
+		return err
+	}
+
+	session.Commit()
+
 	go publishObservable.NotifyObservers(me.Uid, model.TypeWiki, wiki.Id)
 
 	return nil
@@ -54,6 +88,101 @@ func (self WikiLogic) Modify(ctx context.Context, me *model.Me, form url.Values)
 		return errors.New("没有权限")
 	}
 
+	return self.update(ctx, me, wiki, form.Get("title"), form.Get("content"), form.Get("summary"), form.Get("version"))
+}
+
+// Rollback 将 wiki 回滚到某个历史版本，回滚本身也会保存为一个新版本
+func (self WikiLogic) Rollback(ctx context.Context, me *model.Me, wiki *model.Wiki, version int) error {
+	if !CanEdit(me, wiki) {
+		return errors.New("没有权限")
+	}
+
+	revision := self.FindRevision(ctx, wiki.Id, version)
+	if revision == nil {
+		return errors.New("该版本不存在")
+	}
+
+	return self.update(ctx, me, wiki, revision.Title, revision.Content, "回滚到版本 "+strconv.Itoa(version), "")
+}
+
+// SetState 锁定或保护 wiki 页面：管理员用
+func (WikiLogic) SetState(ctx context.Context, me *model.Me, id, state int) error {
+	if !me.IsAdmin || !roleCanEdit(model.Administrator, me) {
+		return errors.New("没有权限")
+	}
+
+	if _, ok := model.WikiStateMap[state]; !ok {
+		return errors.New("状态不合法")
+	}
+
+	_, err := db.MasterDB.Table(new(model.Wiki)).Id(id).Update(map[string]interface{}{"state": state})
+	if err != nil {
+		logger.Error("WikiLogic SetState error:", err)
+		return errors.New("服务内部错误")
+	}
+
+	return nil
+}
+
+// FindRevisions 某个 wiki 的所有历史版本（不含内容），新版本在前
+func (WikiLogic) FindRevisions(ctx context.Context, wikiId int) []*model.WikiRevision {
+	revisions := make([]*model.WikiRevision, 0)
+	err := db.MasterDB.Where("wiki_id=?", wikiId).Omit("content").Desc("version").Find(&revisions)
+	if err != nil {
+		logger.Error("WikiLogic FindRevisions error:", err)
+		return nil
+	}
+
+	uidSet := set.New(set.NonThreadSafe)
+	for _, revision := range revisions {
+		uidSet.Add(revision.Uid)
+	}
+	usersMap := DefaultUser.FindUserInfos(ctx, set.IntSlice(uidSet))
+	for _, revision := range revisions {
+		revision.User = usersMap[revision.Uid]
+	}
+
+	return revisions
+}
+
+// FindRevision 获取某个历史版本，version 为 0 表示最新版本
+func (WikiLogic) FindRevision(ctx context.Context, wikiId, version int) *model.WikiRevision {
+	revision := &model.WikiRevision{}
+
+	session := db.MasterDB.Where("wiki_id=?", wikiId)
+	if version > 0 {
+		session.And("version=?", version)
+	} else {
+		session.Desc("version")
+	}
+
+	_, err := session.Get(revision)
+	if err != nil {
+		logger.Error("WikiLogic FindRevision error:", err)
+		return nil
+	}
+
+	if revision.Id == 0 {
+		return nil
+	}
+
+	revision.User = DefaultUser.FindOne(ctx, "uid", revision.Uid)
+
+	return revision
+}
+
+// Diff 对比两个版本，按行左右对照
+func (WikiLogic) Diff(from, to *model.WikiRevision) []*util.DiffRow {
+	return util.DiffLines(from.Content, to.Content)
+}
+
+// update 更新 wiki 内容，记录贡献者并保存新版本。内容没有变化时不做任何操作。
+// version 为编辑时看到的页面版本，为空表示不做冲突检查
+func (self WikiLogic) update(ctx context.Context, me *model.Me, wiki *model.Wiki, title, content, summary, version string) error {
+	if wiki.Title == title && wiki.Content == content {
+		return nil
+	}
+
 	if wiki.Uid != me.Uid {
 		hasExists := false
 		cuids := strings.Split(wiki.Cuid, ",")
@@ -70,20 +199,73 @@ func (self WikiLogic) Modify(ctx context.Context, me *model.Me, form url.Values)
 		}
 	}
 
-	wiki.Title = form.Get("title")
-	wiki.Content = form.Get("content")
+	wiki.Title = title
+	wiki.Content = content
+
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+	session.Begin()
 
-	_, err := db.MasterDB.Id(id).Update(wiki)
+	_, err := updateWithVersion(session.Id(wiki.Id), version, wiki)
 	if err != nil {
-		logger.Error("更新wiki 【%d】 信息失败：%s\n", id, err)
+		session.Rollback()
+		if err == ConflictErr {
+			return err
+		}
+		logger.Error("更新wiki 【%d】 信息失败：%s\n", wiki.Id, err)
 		return err
 	}
 
+	if summary == "" {
+		summary = "编辑"
+	}
+	if err = self.saveRevision(session, wiki, me.Uid, summary); err != nil {
+		session.Rollback()
+		return err
+	}
+	if err = self.saveLinks(session, wiki); err != nil {
+		session.Rollback()
+		return err
+	}
+
+	session.Commit()
+
 	go modifyObservable.NotifyObservers(me.Uid, model.TypeWiki, wiki.Id)
 
 	return nil
 }
 
+// saveRevision 保存 wiki 当前内容为一个新版本，通过锁住 wiki 行保证版本号连续
+func (WikiLogic) saveRevision(session *xorm.Session, wiki *model.Wiki, uid int, summary string) error {
+	_, err := session.Id(wiki.Id).ForUpdate().Get(new(model.Wiki))
+	if err != nil {
+		logger.Error("WikiLogic saveRevision lock wiki error:", err)
+		return err
+	}
+
+	lastRevision := &model.WikiRevision{}
+	_, err = session.Where("wiki_id=?", wiki.Id).Desc("version").Get(lastRevision)
+	if err != nil {
+		logger.Error("WikiLogic saveRevision find last revision error:", err)
+		return err
+	}
+
+	revision := &model.WikiRevision{
+		WikiId:  wiki.Id,
+		Version: lastRevision.Version + 1,
+		Title:   wiki.Title,
+		Content: wiki.Content,
+		Uid:     uid,
+		Summary: summary,
+	}
+	if _, err = session.Insert(revision); err != nil {
+		logger.Error("WikiLogic saveRevision insert error:", err)
+		return err
+	}
+
+	return nil
+}
+
 // FindBy 获取 wiki 列表（分页）
 func (WikiLogic) FindBy(ctx context.Context, limit int, lastIds ...int) []*model.Wiki {
 
@@ -0,0 +1,276 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package logic
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"sander/db"
+	"sander/logger"
+	"sander/model"
+
+	"github.com/go-xorm/builder"
+	"github.com/go-xorm/xorm"
+	"golang.org/x/net/context"
+)
+
+// [[页面]] 或 [[页面|显示文字]]
+var wikiLinkReg = regexp.MustCompile(`\[\[([^\[\]|\n]+)(?:\|([^\[\]\n]+))?\]\]`)
+
+// wiki 地址中不能使用的层级名，它们被页面的操作占用
+var wikiReservedNames = map[string]bool{
+	"new":      true,
+	"modify":   true,
+	"uri":      true,
+	"history":  true,
+	"diff":     true,
+	"revision": true,
+	"rollback": true,
+	"state":    true,
+}
+
+// NormalizeWikiUri 规范化 wiki 地址：去掉多余的 /，每一级去掉首尾空白，中间的空白替换为 -
+func NormalizeWikiUri(uri string) string {
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(uri, "/") {
+		segment = strings.Join(strings.Fields(segment), "-")
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// ValidWikiUri wiki 地址是否可用（不含被占用的层级名）
+func ValidWikiUri(uri string) bool {
+	if uri == "" {
+		return false
+	}
+	for _, segment := range strings.Split(uri, "/") {
+		if wikiReservedNames[strings.ToLower(segment)] {
+			return false
+		}
+	}
+	return true
+}
+
+// UriExists 地址对应的 wiki 页是否已存在
+func (WikiLogic) UriExists(ctx context.Context, uri string) bool {
+	total, err := db.MasterDB.Where("uri=?", uri).Count(new(model.Wiki))
+	if err != nil || total == 0 {
+		return false
+	}
+	return true
+}
+
+// FindBreadcrumbs 上级页面，从顶层开始。上级页面不存在时用该级名字作为标题（目录页）
+func (WikiLogic) FindBreadcrumbs(ctx context.Context, uri string) []map[string]string {
+	segments := strings.Split(uri, "/")
+	if len(segments) < 2 {
+		return nil
+	}
+
+	uris := make([]string, len(segments)-1)
+	for i := range uris {
+		uris[i] = strings.Join(segments[:i+1], "/")
+	}
+
+	wikis := make([]*model.Wiki, 0)
+	err := db.MasterDB.In("uri", uris).Cols("uri", "title").Find(&wikis)
+	if err != nil {
+		logger.Error("WikiLogic FindBreadcrumbs error:", err)
+	}
+	titles := make(map[string]string, len(wikis))
+	for _, wiki := range wikis {
+		titles[wiki.Uri] = wiki.Title
+	}
+
+	crumbs := make([]map[string]string, len(uris))
+	for i, parent := range uris {
+		title, ok := titles[parent]
+		if !ok {
+			title = segments[i]
+		}
+		crumbs[i] = map[string]string{"uri": parent, "title": title}
+	}
+
+	return crumbs
+}
+
+// FindChildren 某个地址下的所有下级页面，按地址排序，depth 为相对 uri 的层级（从 1 开始）
+func (WikiLogic) FindChildren(ctx context.Context, uri string) []map[string]interface{} {
+	wikis := make([]*model.Wiki, 0)
+	err := db.MasterDB.Where("uri LIKE ?", escapeLike(uri)+"/%").Cols("id", "uri", "title").Asc("uri").Find(&wikis)
+	if err != nil {
+		logger.Error("WikiLogic FindChildren error:", err)
+		return nil
+	}
+
+	children := make([]map[string]interface{}, len(wikis))
+	for i, wiki := range wikis {
+		children[i] = map[string]interface{}{
+			"uri":   wiki.Uri,
+			"title": wiki.Title,
+			"depth": strings.Count(wiki.Uri[len(uri)+1:], "/") + 1,
+		}
+	}
+
+	return children
+}
+
+// FindBacklinks 链接到 uri 的页面（链入页面）
+func (WikiLogic) FindBacklinks(ctx context.Context, uri string) []*model.Wiki {
+	wikis := make([]*model.Wiki, 0)
+	err := db.MasterDB.Join("INNER", "wiki_link", "wiki_link.from_id=wiki.id").
+		Where("wiki_link.to_uri=? AND wiki.uri!=?", uri, uri).
+		Cols("wiki.id", "wiki.uri", "wiki.title").Asc("wiki.title").Find(&wikis)
+	if err != nil {
+		logger.Error("WikiLogic FindBacklinks error:", err)
+		return nil
+	}
+	return wikis
+}
+
+// RenderLinks 将内容中的 [[页面]] 解析为 Markdown 链接，页面不存在时链接到创建页
+func (WikiLogic) RenderLinks(ctx context.Context, content string) string {
+	session := db.MasterDB.NewSession()
+	defer session.Close()
+
+	resolved := resolveWikiLinks(session, parseWikiLinks(content))
+
+	return replaceWikiLinks(content, func(target, text string) string {
+		if uri, ok := resolved[target]; ok {
+			return "[" + text + "](/wiki/" + (&url.URL{Path: uri}).EscapedPath() + ")"
+		}
+
+		query := url.Values{}
+		query.Set("uri", NormalizeWikiUri(target))
+		query.Set("title", target)
+		return "[" + text + "](/wiki/new?" + query.Encode() + ")"
+	})
+}
+
+// saveLinks 重建某个 wiki 页的链出记录。链接到的页面不存在时记录规范化后的地址，页面创建后自动生效
+func (WikiLogic) saveLinks(session *xorm.Session, wiki *model.Wiki) error {
+	_, err := session.Where("from_id=?", wiki.Id).Delete(new(model.WikiLink))
+	if err != nil {
+		logger.Error("WikiLogic saveLinks delete error:", err)
+		return err
+	}
+
+	targets := parseWikiLinks(wiki.Content)
+	resolved := resolveWikiLinks(session, targets)
+
+	links := make([]*model.WikiLink, 0, len(targets))
+	uris := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		uri, ok := resolved[target]
+		if !ok {
+			uri = NormalizeWikiUri(target)
+		}
+		if uri == "" || uris[uri] {
+			continue
+		}
+		uris[uri] = true
+		links = append(links, &model.WikiLink{FromId: wiki.Id, ToUri: uri})
+	}
+
+	if len(links) == 0 {
+		return nil
+	}
+
+	if _, err = session.Insert(&links); err != nil {
+		logger.Error("WikiLogic saveLinks insert error:", err)
+		return err
+	}
+
+	return nil
+}
+
+// parseWikiLinks 内容中所有 [[链接]] 的目标（去重）
+func parseWikiLinks(content string) []string {
+	targets := make([]string, 0)
+	seen := make(map[string]bool)
+	replaceWikiLinks(content, func(target, text string) string {
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+		return ""
+	})
+	return targets
+}
+
+// resolveWikiLinks 查找链接目标对应的页面地址：优先按地址匹配，其次按标题匹配
+func resolveWikiLinks(session *xorm.Session, targets []string) map[string]string {
+	resolved := make(map[string]string, len(targets))
+	if len(targets) == 0 {
+		return resolved
+	}
+
+	uris := make([]string, len(targets))
+	for i, target := range targets {
+		uris[i] = NormalizeWikiUri(target)
+	}
+
+	wikis := make([]*model.Wiki, 0)
+	err := session.Where(builder.Or(builder.In("uri", uris), builder.In("title", targets))).Cols("uri", "title").Find(&wikis)
+	if err != nil {
+		logger.Error("resolveWikiLinks error:", err)
+		return resolved
+	}
+
+	byUri := make(map[string]string, len(wikis))
+	byTitle := make(map[string]string, len(wikis))
+	for _, wiki := range wikis {
+		byUri[strings.ToLower(wiki.Uri)] = wiki.Uri
+		byTitle[wiki.Title] = wiki.Uri
+	}
+
+	for i, target := range targets {
+		if uri, ok := byUri[strings.ToLower(uris[i])]; ok {
+			resolved[target] = uri
+		} else if uri, ok := byTitle[target]; ok {
+			resolved[target] = uri
+		}
+	}
+
+	return resolved
+}
+
+// replaceWikiLinks 替换内容中的 [[链接]]，跳过代码块
+func replaceWikiLinks(content string, repl func(target, text string) string) string {
+	lines := strings.Split(content, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		lines[i] = wikiLinkReg.ReplaceAllStringFunc(line, func(match string) string {
+			sub := wikiLinkReg.FindStringSubmatch(match)
+			target := strings.TrimSpace(sub[1])
+			text := strings.TrimSpace(sub[2])
+			if text == "" {
+				text = target
+			}
+			return repl(target, text)
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
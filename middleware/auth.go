@@ -1,35 +1,101 @@
 package middleware
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo"
 )
 
-// AuthConfig .
+// AuthConfig 签名校验的配置。请求需要带上参数：from（调用方标识）、timestamp（unix 秒）、nonce（随机串）和 sign
 type AuthConfig struct {
-	signature func(url.Values, string) string
-	secretKey string
+	// Signature 用调用方的密钥对请求方法、路径和参数（不含 sign）签名
+	Signature func(method, path string, params url.Values, secretKey string) string
+	// SecretKey 根据 from 找到调用方的密钥，调用方不存在或已停用时返回空
+	SecretKey func(from string) string
+	// Expire timestamp 和服务器时间最多相差多少
+	Expire time.Duration
+	// CheckNonce 同一个调用方的 nonce 在 Expire 内只能用一次，返回 false 表示已经用过。为 nil 时不检查
+	CheckNonce func(from, nonce string, expire time.Duration) bool
 }
 
-// DefaultAuthConfig .
-var DefaultAuthConfig = &AuthConfig{}
+// DefaultAuthConfig 没有设置 SecretKey，所有请求都会校验失败
+var DefaultAuthConfig = &AuthConfig{
+	Signature: HmacSignature,
+	Expire:    5 * time.Minute,
+}
 
 // EchoAuth .
 func EchoAuth() echo.MiddlewareFunc {
 	return EchoAuthWithConfig(DefaultAuthConfig)
 }
 
-// EchoAuthWithConfig 用于 echo 框架的签名校验中间件
+// EchoAuthWithConfig 用于 echo 框架的签名校验中间件。校验通过后 ctx 中的 auth_from 为调用方标识
 func EchoAuthWithConfig(authConfig *AuthConfig) echo.MiddlewareFunc {
+	if authConfig.Signature == nil {
+		authConfig.Signature = DefaultAuthConfig.Signature
+	}
+	if authConfig.Expire == 0 {
+		authConfig.Expire = DefaultAuthConfig.Expire
+	}
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			sign := authConfig.signature(ctx.FormParams(), authConfig.secretKey)
-			if sign != ctx.FormValue("sign") {
+			from := ctx.FormValue("from")
+			secretKey := ""
+			if from != "" && authConfig.SecretKey != nil {
+				secretKey = authConfig.SecretKey(from)
+			}
+			if secretKey == "" {
+				return ctx.String(http.StatusUnauthorized, `401 Unauthorized`)
+			}
+
+			timestamp, err := strconv.ParseInt(ctx.FormValue("timestamp"), 10, 64)
+			if err != nil {
+				return ctx.String(http.StatusBadRequest, `400 Bad Request`)
+			}
+			diff := time.Since(time.Unix(timestamp, 0))
+			if diff > authConfig.Expire || diff < -authConfig.Expire {
 				return ctx.String(http.StatusBadRequest, `400 Bad Request`)
 			}
+
+			params := url.Values(ctx.FormParams())
+			sign := authConfig.Signature(ctx.Request().Method(), ctx.Request().URL().Path(), params, secretKey)
+			if !hmac.Equal([]byte(sign), []byte(ctx.FormValue("sign"))) {
+				return ctx.String(http.StatusBadRequest, `400 Bad Request`)
+			}
+
+			// 签名正确后再记录 nonce，避免伪造的请求占用
+			nonce := ctx.FormValue("nonce")
+			if authConfig.CheckNonce != nil {
+				if nonce == "" || !authConfig.CheckNonce(from, nonce, authConfig.Expire) {
+					return ctx.String(http.StatusBadRequest, `400 Bad Request`)
+				}
+			}
+
+			ctx.Set("auth_from", from)
+
 			return next(ctx)
 		}
 	}
 }
+
+// HmacSignature 默认的签名算法：除 sign 外的参数按 URL 编码规则编码（按名字排序，同名参数的值保持原顺序），
+// 和大写的请求方法、路径用换行拼成 METHOD\npath\nk1=v1&k2=v2，再用密钥计算 HMAC-SHA256，结果为小写十六进制
+func HmacSignature(method, path string, params url.Values, secretKey string) string {
+	values := make(url.Values, len(params))
+	for k, v := range params {
+		if k != "sign" {
+			values[k] = v
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(method + "\n" + path + "\n" + values.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
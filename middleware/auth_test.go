@@ -0,0 +1,63 @@
+package middleware_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"testing"
+
+	"sander/middleware"
+)
+
+func TestHmacSignature(t *testing.T) {
+	params := url.Values{
+		"b":    {"2"},
+		"a":    {"1 2", "x&y"},
+		"sign": {"ignored"},
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("POST\n/app/topics\na=1+2&a=x%26y&b=2"))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := middleware.HmacSignature("POST", "/app/topics", params, "secret"); got != want {
+		t.Fatalf("HmacSignature() = %v, want %v", got, want)
+	}
+
+	// sign 不参与签名
+	params.Set("sign", "other")
+	if got := middleware.HmacSignature("POST", "/app/topics", params, "secret"); got != want {
+		t.Errorf("HmacSignature() with other sign = %v, want %v", got, want)
+	}
+
+	// 同名参数的多个值和逗号连接的一个值不同
+	params.Set("a", "1 2,x&y")
+	if got := middleware.HmacSignature("POST", "/app/topics", params, "secret"); got == want {
+		t.Errorf("HmacSignature() with joined values = %v, should differ", got)
+	}
+}
+
+func TestHmacSignatureDiffers(t *testing.T) {
+	base := middleware.HmacSignature("POST", "/app/topics", url.Values{"a": {"1"}, "b": {"2"}}, "secret")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		params url.Values
+		secret string
+	}{
+		{"请求方法不同", "GET", "/app/topics", url.Values{"a": {"1"}, "b": {"2"}}, "secret"},
+		{"路径不同", "POST", "/app/comment", url.Values{"a": {"1"}, "b": {"2"}}, "secret"},
+		{"值中的 & 和 = 不能伪造参数", "POST", "/app/topics", url.Values{"a": {"1&b=2"}}, "secret"},
+		{"密钥不同", "POST", "/app/topics", url.Values{"a": {"1"}, "b": {"2"}}, "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := middleware.HmacSignature(tt.method, tt.path, tt.params, tt.secret); got == base {
+				t.Errorf("HmacSignature() = %v, should differ", got)
+			}
+		})
+	}
+}
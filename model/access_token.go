@@ -0,0 +1,113 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// 个人访问令牌和应用密钥能调用的接口范围
+const (
+	ScopeRead    = "read"
+	ScopePublish = "publish"
+	ScopeComment = "comment"
+	ScopeMessage = "message"
+)
+
+// Scopes 所有权限，页面上按这个顺序显示
+var Scopes = []string{ScopeRead, ScopePublish, ScopeComment, ScopeMessage}
+
+var ScopeMap = map[string]string{
+	ScopeRead:    "读取个人信息",
+	ScopePublish: "发布、修改主题",
+	ScopeComment: "发表评论",
+	ScopeMessage: "读取、发送站内信",
+}
+
+// AccessTokenPrefix 个人访问令牌的前缀，便于识别（如在代码中扫描泄露的令牌）
+const AccessTokenPrefix = "sgp_"
+
+// AccessToken 用户自己生成的个人访问令牌，供脚本、机器人调用 API。库中只保存哈希
+type AccessToken struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Uid       int       `json:"uid"`
+	Name      string    `json:"name"`
+	TokenHash string    `json:"-"`    // 令牌的 sha256
+	Hint      string    `json:"hint"` // 令牌的前几位，方便用户辨认
+	Scopes    string    `json:"scopes"`
+	Expire    int       `json:"expire"`    // 过期时间（unix 时间戳），0 表示永不过期
+	LastUsed  int       `json:"last_used"` // 最后使用时间（unix 时间戳）
+	CreatedAt time.Time `json:"created_at" xorm:"created"`
+}
+
+func (this *AccessToken) HasScope(scope string) bool {
+	return hasScope(this.Scopes, scope)
+}
+
+func (this *AccessToken) ScopeNames() []string {
+	return scopeNames(this.Scopes)
+}
+
+func (this *AccessToken) Expired() bool {
+	return this.Expire > 0 && int64(this.Expire) <= time.Now().Unix()
+}
+
+func (this *AccessToken) ExpireTime() time.Time {
+	return time.Unix(int64(this.Expire), 0)
+}
+
+func (this *AccessToken) LastUsedTime() time.Time {
+	return time.Unix(int64(this.LastUsed), 0)
+}
+
+// AppKey 管理员发放给其他服务的应用密钥，调用方用 secret 对请求签名
+type AppKey struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Name      string    `json:"name"`
+	AppKey    string    `json:"app_key"` // 请求参数中的 from
+	Secret    string    `json:"-"`
+	Uid       int       `json:"uid"` // 以哪个用户的身份调用需要登录的接口，0 表示只能调用公开接口
+	Scopes    string    `json:"scopes"`
+	Enabled   bool      `json:"enabled"`
+	LastUsed  int       `json:"last_used"`
+	Remark    string    `json:"remark"`
+	OpUser    string    `json:"op_user"`
+	CreatedAt time.Time `json:"created_at" xorm:"created"`
+	UpdatedAt time.Time `json:"updated_at" xorm:"<-"`
+}
+
+func (this *AppKey) HasScope(scope string) bool {
+	return hasScope(this.Scopes, scope)
+}
+
+func (this *AppKey) ScopeNames() []string {
+	return scopeNames(this.Scopes)
+}
+
+func (this *AppKey) LastUsedTime() time.Time {
+	return time.Unix(int64(this.LastUsed), 0)
+}
+
+func hasScope(scopes, scope string) bool {
+	for _, s := range strings.Split(scopes, ",") {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeNames(scopes string) []string {
+	names := make([]string, 0, len(Scopes))
+	for _, scope := range Scopes {
+		if hasScope(scopes, scope) {
+			names = append(names, ScopeMap[scope])
+		}
+	}
+	return names
+}
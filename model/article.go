@@ -21,10 +21,12 @@ const (
 	ArticleStatusNew = iota
 	ArticleStatusOnline
 	ArticleStatusOffline
+	ArticleStatusScheduled // 定时发布：到发布时间前不展示
+	ArticleStatusShadow    // 作者被影子封禁时发布的：只有作者自己能看到
 )
 
 var LangSlice = []string{"中文", "英文"}
-var ArticleStatusSlice = []string{"未上线", "已上线", "已下线"}
+var ArticleStatusSlice = []string{"未上线", "已上线", "已下线", "定时发布", "影子封禁"}
 
 // 抓取的文章信息
 type Article struct {
@@ -51,9 +53,12 @@ type Article struct {
 	Markdown      bool      `json:"markdown"`
 	GCTT          bool      `json:"gctt" xorm:"gctt"`
 	Status        int       `json:"status"`
+	Version       int       `json:"version"`
+	PublishAt     time.Time `json:"publish_at"` // 定时发布时间
 	OpUser        string    `json:"op_user"`
 	Ctime         OftenTime `json:"ctime" xorm:"created"`
 	Mtime         OftenTime `json:"mtime" xorm:"<-"`
+	DeletedAt     time.Time `json:"-" xorm:"deleted"` // 软删除时间，见 TrashLogic
 
 	IsSelf bool  `json:"is_self" xorm:"-"`
 	User   *User `json:"-" xorm:"-"`
@@ -76,6 +81,11 @@ func (this *Article) BeforeInsert() {
 }
 
 func (this *Article) AfterInsert() {
+	// 定时发布的文章，到发布时间后再发布动态；影子封禁的文章不发布动态
+	if this.Status == ArticleStatusScheduled || this.Status == ArticleStatusShadow {
+		return
+	}
+
 	go func() {
 		// AfterInsert 时，自增 ID 还未赋值，这里 sleep 一会，确保自增 ID 有值
 		for {
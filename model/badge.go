@@ -0,0 +1,73 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+// 徽章授予规则
+const (
+	BadgeRuleManual     = iota // 仅手动授予
+	BadgeRuleTopicNum          // 发布主题数
+	BadgeRuleArticleNum        // 发表文章数
+	BadgeRuleCommentNum        // 回复数
+	BadgeRuleWeight            // 活跃度
+	BadgeRuleGCTTNum           // GCTT 翻译文章数
+	BadgeRuleMemberDays        // 注册天数
+)
+
+var BadgeRuleMap = map[int]string{
+	BadgeRuleManual:     "仅手动授予",
+	BadgeRuleTopicNum:   "发布主题数",
+	BadgeRuleArticleNum: "发表文章数",
+	BadgeRuleCommentNum: "回复数",
+	BadgeRuleWeight:     "活跃度",
+	BadgeRuleGCTTNum:    "GCTT 翻译文章数",
+	BadgeRuleMemberDays: "注册天数",
+}
+
+const (
+	BadgeStateOnline = iota
+	BadgeStateOffline
+)
+
+const (
+	UserBadgeStateGranted = iota
+	UserBadgeStateRevoked // 被管理员收回，不再自动授予
+)
+
+// Badge 成就徽章
+type Badge struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Name      string    `json:"name"`
+	Desc      string    `json:"desc"`
+	Icon      string    `json:"icon"` // font-awesome 图标，如 fa-trophy
+	Color     string    `json:"color"`
+	Rule      int       `json:"rule"`
+	Threshold int       `json:"threshold"`
+	Seq       int       `json:"seq"`
+	State     int       `json:"state"`
+	CreatedAt time.Time `json:"created_at" xorm:"<-"`
+}
+
+// UserBadge 用户获得的徽章
+type UserBadge struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Uid       int       `json:"uid"`
+	BadgeId   int       `json:"badge_id"`
+	OpUid     int       `json:"op_uid"` // 0 表示按规则自动授予
+	State     int       `json:"state"`
+	CreatedAt time.Time `json:"created_at" xorm:"<-"`
+}
+
+type UserBadgeInfo struct {
+	UserBadge `json:"user_badge" xorm:"extends"`
+	Badge     `json:"badge" xorm:"extends"`
+}
+
+func (*UserBadgeInfo) TableName() string {
+	return "user_badge"
+}
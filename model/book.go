@@ -38,6 +38,7 @@ type Book struct {
 	Uid           int       `json:"uid"`
 	CreatedAt     OftenTime `json:"created_at" xorm:"created"`
 	UpdatedAt     OftenTime `json:"updated_at" xorm:"<-"`
+	DeletedAt     time.Time `json:"-" xorm:"deleted"` // 软删除时间，见 TrashLogic
 
 	// 排行榜阅读量
 	RankView int `json:"rank_view" xorm:"-"`
@@ -6,6 +6,8 @@
 
 package model
 
+import "time"
+
 // 不要修改常量的顺序
 const (
 	TypeTopic    = iota // 主题
@@ -54,8 +56,11 @@ type Comment struct {
 	Flag    int       `json:"flag"`
 	Ctime   OftenTime `json:"ctime" xorm:"created"`
 
+	DeletedAt time.Time `json:"-" xorm:"deleted"` // 软删除时间，见 TrashLogic
+
 	Objinfo    map[string]interface{} `json:"objinfo" xorm:"-"`
 	ReplyFloor int                    `json:"reply_floor" xorm:"-"` // 回复某一楼层
+	EditedAt   string                 `json:"edited_at" xorm:"-"`   // 最后编辑时间，没编辑过为空
 }
 
 func (*Comment) TableName() string {
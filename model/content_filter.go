@@ -0,0 +1,96 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+// 过滤规则的类型
+const (
+	FilterTypeWord   = iota + 1 // 关键词，不区分大小写
+	FilterTypeRegexp            // 正则表达式
+	FilterTypeDomain            // 链接域名（包括子域名）
+)
+
+var FilterTypeMap = map[int]string{
+	FilterTypeWord:   "关键词",
+	FilterTypeRegexp: "正则",
+	FilterTypeDomain: "链接域名",
+}
+
+// 命中规则后的处理方式，值越大越严重，同时命中多条规则时取最严重的
+const (
+	FilterActionMask   = iota + 1 // 用 * 替换命中的内容后发布
+	FilterActionReview            // 发布后隐藏，放入举报处理队列等待管理员审核
+	FilterActionReject            // 拒绝发布
+	FilterActionFreeze            // 拒绝发布并冻结账号
+)
+
+var FilterActionMap = map[int]string{
+	FilterActionMask:   "替换为 *",
+	FilterActionReview: "人工审核",
+	FilterActionReject: "拒绝发布",
+	FilterActionFreeze: "冻结账号",
+}
+
+// 规则检查的字段
+const (
+	FilterScopeTitle   = 1 << iota // 标题
+	FilterScopeContent             // 内容
+)
+
+var FilterScopeMap = map[int]string{
+	FilterScopeTitle:                      "标题",
+	FilterScopeContent:                    "内容",
+	FilterScopeTitle | FilterScopeContent: "标题和内容",
+}
+
+// FilterRule 内容过滤规则，后台管理
+type FilterRule struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Type      int       `json:"type"`
+	Pattern   string    `json:"pattern"`
+	Scope     int       `json:"scope"`
+	Action    int       `json:"action"`
+	Hits      int       `json:"hits"` // 累计命中次数
+	Enabled   bool      `json:"enabled"`
+	Remark    string    `json:"remark"`
+	OpUser    string    `json:"op_user"`
+	CreatedAt time.Time `json:"created_at" xorm:"created"`
+	UpdatedAt time.Time `json:"updated_at" xorm:"<-"`
+}
+
+func (this *FilterRule) TypeName() string {
+	return FilterTypeMap[this.Type]
+}
+
+func (this *FilterRule) ActionName() string {
+	return FilterActionMap[this.Action]
+}
+
+func (this *FilterRule) ScopeName() string {
+	return FilterScopeMap[this.Scope]
+}
+
+// FilterLog 规则命中记录，用于排查误判、调整规则
+type FilterLog struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	RuleId    int       `json:"rule_id"`
+	Uid       int       `json:"uid"`
+	Field     string    `json:"field"` // title 或 content
+	Matched   string    `json:"matched"`
+	Action    int       `json:"action"` // 命中时规则的处理方式
+	Uri       string    `json:"uri"`
+	Excerpt   string    `json:"excerpt"` // 命中位置附近的内容
+	CreatedAt time.Time `json:"created_at" xorm:"created"`
+
+	Rule *FilterRule `json:"-" xorm:"-"`
+	User *User       `json:"-" xorm:"-"`
+}
+
+func (this *FilterLog) ActionName() string {
+	return FilterActionMap[this.Action]
+}
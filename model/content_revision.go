@@ -0,0 +1,24 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+// ContentRevision 主题、文章、评论的历史版本。
+// 第一次编辑时会先把原始内容保存为版本 1，之后每次编辑保存编辑后的内容
+type ContentRevision struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Objtype   int       `json:"objtype"`
+	Objid     int       `json:"objid"`
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Uid       int       `json:"uid"`
+	CreatedAt time.Time `json:"created_at"`
+
+	User *User `json:"user" xorm:"-"`
+}
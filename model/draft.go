@@ -0,0 +1,28 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+const (
+	// 每个用户最多保存的草稿数
+	MaxDraftNum = 50
+	// 草稿内容最大字节数
+	MaxDraftSize = 256 * 1024
+)
+
+// Draft 发布主题、文章时编辑器自动保存的草稿，发布成功后删除
+type Draft struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Uid       int       `json:"uid"`
+	Objtype   int       `json:"objtype"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Extra     string    `json:"extra"` // 其他表单字段，json 格式，如主题的节点
+	CreatedAt OftenTime `json:"created_at" xorm:"created"`
+	UpdatedAt time.Time `json:"updated_at" xorm:"<-"`
+}
@@ -13,7 +13,9 @@ import (
 )
 
 const (
+	GiftStateNew     = 0
 	GiftStateOnline  = 1
+	GiftStateOffline = 2
 	GiftStateExpired = 3
 
 	GiftTypRedeem   = 0
@@ -25,21 +27,28 @@ var GiftTypeMap = map[int]string{
 	GiftTypDiscount: "折扣",
 }
 
+var GiftStateMap = map[int]string{
+	GiftStateNew:     "未上线",
+	GiftStateOnline:  "已上线",
+	GiftStateOffline: "已下线",
+	GiftStateExpired: "已过期",
+}
+
 type Gift struct {
-	Id          int `json:"id" xorm:"pk autoincr"`
-	Name        string
-	Description string
-	Price       int
-	TotalNum    int
-	RemainNum   int
-	ExpireTime  time.Time `xorm:"int"`
-	Supplier    string
-	BuyLimit    int
-	Typ         int
-	State       int
-	CreatedAt   OftenTime `xorm:"<-"`
+	Id          int       `json:"id" xorm:"pk autoincr"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Price       int       `json:"price"`
+	TotalNum    int       `json:"total_num"`
+	RemainNum   int       `json:"remain_num"`
+	ExpireTime  time.Time `json:"expire_time" xorm:"int"`
+	Supplier    string    `json:"supplier"`
+	BuyLimit    int       `json:"buy_limit"`
+	Typ         int       `json:"typ"`
+	State       int       `json:"state"`
+	CreatedAt   OftenTime `json:"created_at" xorm:"<-"`
 
-	TypShow string `xorm:"-"`
+	TypShow string `json:"typ_show" xorm:"-"`
 }
 
 func (this *Gift) AfterSet(name string, cell xorm.Cell) {
@@ -49,19 +58,19 @@ func (this *Gift) AfterSet(name string, cell xorm.Cell) {
 }
 
 type GiftRedeem struct {
-	Id        int `json:"id" xorm:"pk autoincr"`
-	GiftId    int
-	Code      string
-	Exchange  int
-	Uid       int
-	UpdatedAt OftenTime `xorm:"<-"`
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	GiftId    int       `json:"gift_id"`
+	Code      string    `json:"code"`
+	Exchange  int       `json:"exchange"`
+	Uid       int       `json:"uid"`
+	UpdatedAt OftenTime `json:"updated_at" xorm:"<-"`
 }
 
 type UserExchangeRecord struct {
-	Id         int `json:"id" xorm:"pk autoincr"`
-	GiftId     int
-	Uid        int
-	Remark     string
-	ExpireTime time.Time `xorm:"int"`
-	CreatedAt  OftenTime `xorm:"<-"`
+	Id         int       `json:"id" xorm:"pk autoincr"`
+	GiftId     int       `json:"gift_id"`
+	Uid        int       `json:"uid"`
+	Remark     string    `json:"remark"`
+	ExpireTime time.Time `json:"expire_time" xorm:"int"`
+	CreatedAt  OftenTime `json:"created_at" xorm:"<-"`
 }
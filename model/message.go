@@ -46,6 +46,10 @@ const (
 	MsgtypePublishAtMe = 11 // 发布时提到我
 
 	MsgtypeSubjectContribute = 12 //专栏投稿
+
+	MsgtypeBadge = 13 // 获得徽章
+
+	MsgtypeReport = 14 // 举报处理结果
 )
 
 // 系统消息
@@ -35,9 +35,13 @@ const (
 	MissionTypeAward = 80
 	// 活跃奖励
 	MissionTypeActive = 81
+	// 管理员自定义的规则任务
+	MissionTypeRule = 90
 
 	// 物品兑换
 	MissionTypeGift = 100
+	// 购买补签卡
+	MissionTypeMakeup = 101
 
 	// 管理员操作后处罚
 	MissionTypePunish = 120
@@ -49,6 +53,52 @@ const (
 	InitialMissionId = 1
 )
 
+// 补签卡价格（铜币）
+const LoginMakeupPrice = 200
+
+// 规则任务统计的事件
+const (
+	MissionEventPublish = "publish" // 发布
+	MissionEventComment = "comment" // 回复
+	MissionEventLike    = "like"    // 喜欢别人的内容
+	MissionEventLiked   = "liked"   // 内容被别人喜欢
+	MissionEventView    = "view"    // 浏览
+	MissionEventLogin   = "login"   // 登录（每天只计一次）
+)
+
+var MissionEventMap = map[string]string{
+	MissionEventPublish: "发布",
+	MissionEventComment: "回复",
+	MissionEventLike:    "喜欢",
+	MissionEventLiked:   "被喜欢",
+	MissionEventView:    "浏览",
+	MissionEventLogin:   "登录",
+}
+
+// 规则任务的统计周期
+const (
+	MissionPeriodOnce = iota // 一次性
+	MissionPeriodDaily
+	MissionPeriodWeekly
+	MissionPeriodMonthly
+)
+
+var MissionPeriodMap = map[int]string{
+	MissionPeriodOnce:    "一次性",
+	MissionPeriodDaily:   "每天",
+	MissionPeriodWeekly:  "每周",
+	MissionPeriodMonthly: "每月",
+}
+
+const (
+	MissionStateNormal  = iota
+	MissionStateExpired // 已过期
+	MissionStateOffline // 已下线
+)
+
+// MissionObjtypeAll 规则任务不限对象类型
+const MissionObjtypeAll = -1
+
 type Mission struct {
 	Id        int       `json:"id" xorm:"pk autoincr"`
 	Name      string    `json:"name"`
@@ -59,6 +109,33 @@ type Mission struct {
 	Incr      int       `json:"incr"`
 	State     int       `json:"state"`
 	CreatedAt time.Time `json:"created_at" xorm:"<-"`
+
+	// 以下字段只对规则任务（MissionTypeRule）有效，奖励为 Fixed
+	Desc       string    `json:"desc"`
+	Event      string    `json:"event"`
+	Objtype    int       `json:"objtype"`
+	Target     int       `json:"target"`
+	Period     int       `json:"period"`
+	PerObject  bool      `json:"per_object"`
+	Continuous bool      `json:"continuous"`
+	StartAt    time.Time `json:"start_at"`
+	EndAt      time.Time `json:"end_at"`
+}
+
+// IsActive 规则任务当前是否在进行中
+func (this *Mission) IsActive() bool {
+	if this.State != MissionStateNormal {
+		return false
+	}
+
+	now := time.Now()
+	if !this.StartAt.IsZero() && now.Before(this.StartAt) {
+		return false
+	}
+	if !this.EndAt.IsZero() && now.After(this.EndAt) {
+		return false
+	}
+	return true
 }
 
 type UserLoginMission struct {
@@ -69,3 +146,33 @@ type UserLoginMission struct {
 	TotalDays int       `json:"total_days"`
 	UpdatedAt time.Time `json:"updated_at" xorm:"<-"`
 }
+
+// UserLoginRecord 每日登录领取记录，用于签到日历和补签
+type UserLoginRecord struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Uid       int       `json:"uid"`
+	Date      int       `json:"date"`
+	Days      int       `json:"days"` // 当天的连续天数
+	Award     int       `json:"award"`
+	Makeup    bool      `json:"makeup"` // 是否为补签
+	CreatedAt time.Time `json:"created_at" xorm:"<-"`
+}
+
+// UserMission 用户规则任务进度
+type UserMission struct {
+	Id         int       `json:"id" xorm:"pk autoincr"`
+	Uid        int       `json:"uid"`
+	MissionId  int       `json:"mission_id"`
+	Period     int       `json:"period"` // 周期标识：一次性为 0，每天为 20060102，每周为 200601（年+周），每月为 200601
+	Objid      int       `json:"objid"`  // 按单个对象统计时的对象 id
+	Progress   int       `json:"progress"`
+	LastDate   int       `json:"last_date"` // 最后一次计数的日期，用于连续天数和登录去重
+	State      int       `json:"state"`     // 0-进行中；1-已完成
+	FinishedAt time.Time `json:"finished_at"`
+	UpdatedAt  time.Time `json:"updated_at" xorm:"<-"`
+}
+
+const (
+	UserMissionStateDoing = iota
+	UserMissionStateFinished
+)
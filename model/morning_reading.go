@@ -18,17 +18,24 @@ const (
 	RtypeComp        // 综合技术晨读
 )
 
+const (
+	ReadingStatusOnline    = iota
+	ReadingStatusScheduled // 定时发布：到发布时间前不展示
+)
+
 // 技术晨读
 type MorningReading struct {
-	Id       int       `json:"id" xorm:"pk autoincr"`
-	Content  string    `json:"content"`
-	Rtype    int       `json:"rtype"`
-	Inner    int       `json:"inner"`
-	Url      string    `json:"url"`
-	Moreurls string    `json:"moreurls"`
-	Username string    `json:"username"`
-	Clicknum int       `json:"clicknum,omitempty"`
-	Ctime    OftenTime `json:"ctime" xorm:"<-"`
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Content   string    `json:"content"`
+	Rtype     int       `json:"rtype"`
+	Inner     int       `json:"inner"`
+	Url       string    `json:"url"`
+	Moreurls  string    `json:"moreurls"`
+	Username  string    `json:"username"`
+	Clicknum  int       `json:"clicknum,omitempty"`
+	Status    int       `json:"status"`
+	PublishAt time.Time `json:"publish_at"` // 定时发布时间
+	Ctime     OftenTime `json:"ctime" xorm:"<-"`
 
 	// 晨读日期，从 ctime 中提取
 	Rdate string `json:"rdate,omitempty" xorm:"-"`
@@ -0,0 +1,54 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+const (
+	// 版主在所管理节点（含子节点）内拥有的权限
+	ModPermEdit   = 1 << iota // 编辑主题
+	ModPermLock               // 锁定、关闭主题
+	ModPermMove               // 移动主题
+	ModPermDelete             // 删除主题
+	ModPermTop                // 节点内置顶
+)
+
+var ModPermMap = map[int]string{
+	ModPermEdit:   "编辑",
+	ModPermLock:   "锁定/关闭",
+	ModPermMove:   "移动",
+	ModPermDelete: "删除",
+	ModPermTop:    "节点内置顶",
+}
+
+// NodeModerator 节点版主，管理范围包括该节点的所有子节点
+type NodeModerator struct {
+	Id         int       `json:"id" xorm:"pk autoincr"`
+	Nid        int       `json:"nid"`
+	Uid        int       `json:"uid"`
+	Privileges int       `json:"privileges"`
+	OpUser     string    `json:"op_user"`
+	CreatedAt  time.Time `json:"created_at" xorm:"<-"`
+
+	User *User  `json:"user" xorm:"-"`
+	Node string `json:"node" xorm:"-"`
+}
+
+func (this *NodeModerator) HasPrivilege(priv int) bool {
+	return this.Privileges&priv == priv
+}
+
+// PrivilegeNames 版主拥有的权限名称
+func (this *NodeModerator) PrivilegeNames() []string {
+	names := make([]string, 0, len(ModPermMap))
+	for priv := ModPermEdit; priv <= ModPermTop; priv <<= 1 {
+		if this.HasPrivilege(priv) {
+			names = append(names, ModPermMap[priv])
+		}
+	}
+	return names
+}
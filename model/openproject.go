@@ -43,8 +43,10 @@ type OpenProject struct {
 	Lastreplyuid  int       `json:"lastreplyuid"`
 	Lastreplytime OftenTime `json:"lastreplytime"`
 	Status        int       `json:"status"`
+	Version       int       `json:"version"`
 	Ctime         OftenTime `json:"ctime,omitempty" xorm:"created"`
 	Mtime         OftenTime `json:"mtime,omitempty" xorm:"<-"`
+	DeletedAt     time.Time `json:"-" xorm:"deleted"` // 软删除时间，见 TrashLogic
 
 	User *User `json:"user" xorm:"-"`
 	// 排行榜阅读量
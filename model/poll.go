@@ -0,0 +1,60 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+const (
+	PollMinOptions = 2
+	PollMaxOptions = 20
+)
+
+// Poll 主题中的投票，一个主题最多一个投票
+type Poll struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Tid       int       `json:"tid"`
+	Multiple  bool      `json:"multiple"`  // 是否多选
+	Anonymous bool      `json:"anonymous"` // 匿名投票：不展示投票人
+	Deadline  time.Time `json:"deadline"`  // 截止时间，0 表示不限
+	Closed    bool      `json:"closed"`    // 是否被作者提前结束
+	Voters    int       `json:"voters"`
+	CreatedAt OftenTime `json:"created_at" xorm:"created"`
+
+	Options []*PollOption `json:"options" xorm:"-"`
+	// 当前用户是否投过票
+	Voted bool `json:"voted" xorm:"-"`
+	// 是否展示结果：投过票或投票已结束才能看到结果
+	ShowResult bool `json:"show_result" xorm:"-"`
+	Ended      bool `json:"ended" xorm:"-"`
+}
+
+// IsEnded 投票是否已结束（提前结束或过了截止时间）
+func (this *Poll) IsEnded() bool {
+	return this.Closed || (!this.Deadline.IsZero() && time.Now().After(this.Deadline))
+}
+
+// PollOption 投票选项
+type PollOption struct {
+	Id      int    `json:"id" xorm:"pk autoincr"`
+	PollId  int    `json:"poll_id"`
+	Content string `json:"content"`
+	Votes   int    `json:"votes"`
+
+	Percent int  `json:"percent" xorm:"-"`
+	Chosen  bool `json:"chosen" xorm:"-"`
+	// 非匿名投票时，选择该项的用户
+	Usernames []string `json:"usernames,omitempty" xorm:"-"`
+}
+
+// PollVote 用户的投票，每人每个投票只能投一次，多选时 OptionIds 逗号分隔
+type PollVote struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	PollId    int       `json:"poll_id"`
+	Uid       int       `json:"uid"`
+	OptionIds string    `json:"option_ids"`
+	CreatedAt OftenTime `json:"created_at" xorm:"<-"`
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+// TypeUser 举报用户。主题、文章、资源、评论沿用 TypeTopic、TypeArticle、TypeResource、TypeComment
+const TypeUser = 102
+
+// 可以被举报的对象
+var ReportTypeMap = map[int]string{
+	TypeTopic:    "主题",
+	TypeArticle:  "文章",
+	TypeResource: "资源",
+	TypeComment:  "评论",
+	TypeUser:     "用户",
+}
+
+// 举报原因
+const (
+	ReportReasonSpam    = iota + 1 // 广告、垃圾信息
+	ReportReasonAbuse              // 辱骂、人身攻击
+	ReportReasonPorn               // 色情、低俗
+	ReportReasonIllegal            // 违法违规
+	ReportReasonOther              // 其他
+)
+
+// 由系统放入举报处理队列的原因，用户不能选
+const (
+	ReportReasonFilter    = 100 // 内容命中“人工审核”的过滤规则
+	ReportReasonSpamScore = 101 // 垃圾内容分类器判断为疑似垃圾内容
+	ReportReasonSanction  = 102 // 观察期或被影子封禁的用户发布的内容
+)
+
+var ReportReasonMap = map[int]string{
+	ReportReasonSpam:    "广告、垃圾信息",
+	ReportReasonAbuse:   "辱骂、人身攻击",
+	ReportReasonPorn:    "色情、低俗",
+	ReportReasonIllegal: "违法违规",
+	ReportReasonOther:   "其他",
+}
+
+// 举报处理状态
+const (
+	ReportStatusPending  = iota // 待处理
+	ReportStatusApproved        // 内容没问题，驳回举报
+	ReportStatusRemoved         // 已删除内容
+	ReportStatusBanned          // 已删除内容并封禁作者
+)
+
+var ReportStatusMap = map[int]string{
+	ReportStatusPending:  "待处理",
+	ReportStatusApproved: "已驳回",
+	ReportStatusRemoved:  "已删除",
+	ReportStatusBanned:   "已删除并封禁",
+}
+
+// Report 用户的一次举报
+type Report struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Objtype   int       `json:"objtype"`
+	Objid     int       `json:"objid"`
+	Uid       int       `json:"uid"` // 举报人
+	Reason    int       `json:"reason"`
+	Content   string    `json:"content"` // 补充说明
+	Status    int       `json:"status"`  // 处理结果，和 ReportObject 一致
+	CreatedAt OftenTime `json:"created_at" xorm:"created"`
+}
+
+func (this *Report) ReasonName() string {
+	switch this.Reason {
+	case ReportReasonFilter:
+		return "命中过滤规则"
+	case ReportReasonSpamScore:
+		return "疑似垃圾内容"
+	case ReportReasonSanction:
+		return "用户受处罚"
+	}
+	return ReportReasonMap[this.Reason]
+}
+
+// ReportObject 被举报对象的汇总，即后台的举报处理队列
+type ReportObject struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Objtype   int       `json:"objtype"`
+	Objid     int       `json:"objid"`
+	ObjUid    int       `json:"obj_uid"` // 被举报内容的作者（举报用户时是该用户）
+	Title     string    `json:"title"`   // 举报时的标题（评论为内容摘要，用户为用户名）
+	Url       string    `json:"url"`
+	Num       int       `json:"num"`    // 待处理的举报数
+	Hidden    bool      `json:"hidden"` // 举报数达到阈值后自动隐藏
+	OrigState int       `json:"orig_state"`
+	Status    int       `json:"status"`
+	OpUser    string    `json:"op_user"`
+	HandledAt time.Time `json:"handled_at"`
+	CreatedAt OftenTime `json:"created_at" xorm:"created"`
+	UpdatedAt OftenTime `json:"updated_at" xorm:"<-"`
+
+	Reasons map[string]int `json:"reasons" xorm:"-"` // 待处理举报的原因分布
+}
+
+func (*ReportObject) TableName() string {
+	return "report_object"
+}
+
+func (this *ReportObject) TypeName() string {
+	return ReportTypeMap[this.Objtype]
+}
+
+func (this *ReportObject) StatusName() string {
+	return ReportStatusMap[this.Status]
+}
+
+// IsUser 举报的是用户而不是内容
+func (this *ReportObject) IsUser() bool {
+	return this.Objtype == TypeUser
+}
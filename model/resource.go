@@ -6,6 +6,8 @@
 
 package model
 
+import "time"
+
 const (
 	LinkForm    = "只是链接"
 	ContentForm = "包括内容"
@@ -24,8 +26,11 @@ type Resource struct {
 	Lastreplyuid  int       `json:"lastreplyuid"`
 	Lastreplytime OftenTime `json:"lastreplytime"`
 	Tags          string    `json:"tags"`
+	Flag          uint8     `json:"flag"` // 只用到 FlagShadow：作者被影子封禁时发布的
+	Version       int       `json:"version"`
 	Ctime         OftenTime `json:"ctime" xorm:"created"`
 	Mtime         OftenTime `json:"mtime" xorm:"<-"`
+	DeletedAt     time.Time `json:"-" xorm:"deleted"` // 软删除时间，见 TrashLogic
 
 	// 排行榜阅读量
 	RankView int `json:"rank_view" xorm:"-"`
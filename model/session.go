@@ -0,0 +1,73 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// 登录会话类型
+const (
+	SessionTypeWeb = iota
+	SessionTypeApp
+)
+
+var SessionTypeMap = map[int]string{
+	SessionTypeWeb: "网页",
+	SessionTypeApp: "App",
+}
+
+// UserSession 登录会话（网页 cookie 或 App token），保存在 redis 中，可以随时注销
+type UserSession struct {
+	Id        string    `json:"id"` // 会话标识的摘要，页面上用它来注销；会话标识本身只在 cookie 或 App 中
+	Uid       int       `json:"-"`
+	Type      int       `json:"type"`
+	Ip        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	Current   bool      `json:"current"` // 是否当前请求所用的会话
+}
+
+func (this *UserSession) TypeName() string {
+	return SessionTypeMap[this.Type]
+}
+
+// 按顺序匹配，先匹配到的为准
+var (
+	deviceOSes = []struct{ keyword, name string }{
+		{"iPhone", "iPhone"}, {"iPad", "iPad"}, {"Android", "Android"},
+		{"Windows", "Windows"}, {"Mac OS", "Mac"}, {"Linux", "Linux"},
+	}
+	deviceBrowsers = []struct{ keyword, name string }{
+		{"MicroMessenger", "微信"}, {"Edg", "Edge"}, {"OPR", "Opera"},
+		{"Chrome", "Chrome"}, {"Firefox", "Firefox"}, {"Safari", "Safari"},
+	}
+)
+
+// Device 从 User-Agent 粗略识别的设备，如 "Mac Chrome"
+func (this *UserSession) Device() string {
+	device := make([]string, 0, 2)
+	for _, os := range deviceOSes {
+		if strings.Contains(this.UserAgent, os.keyword) {
+			device = append(device, os.name)
+			break
+		}
+	}
+	for _, browser := range deviceBrowsers {
+		if strings.Contains(this.UserAgent, browser.keyword) {
+			device = append(device, browser.name)
+			break
+		}
+	}
+
+	if len(device) == 0 {
+		return "未知设备"
+	}
+	return strings.Join(device, " ")
+}
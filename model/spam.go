@@ -0,0 +1,54 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+// 可以判断是否垃圾内容的对象
+var SpamTypeMap = map[int]string{
+	TypeTopic:    "主题",
+	TypeArticle:  "文章",
+	TypeResource: "资源",
+	TypeComment:  "评论",
+}
+
+// SpamSample 垃圾内容分类器的训练样本。版主处理举报时自动记录（因广告被删除为垃圾，驳回为正常），后台可以修正
+type SpamSample struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Objtype   int       `json:"objtype"`
+	Objid     int       `json:"objid"`
+	Text      string    `json:"text"` // 标题和内容
+	IsSpam    bool      `json:"is_spam"`
+	OpUser    string    `json:"op_user"`
+	CreatedAt time.Time `json:"created_at" xorm:"created"`
+	UpdatedAt time.Time `json:"updated_at" xorm:"<-"`
+}
+
+func (this *SpamSample) TypeName() string {
+	return SpamTypeMap[this.Objtype]
+}
+
+// SpamModel 一次训练的结果：模型数据及留出 20% 样本评估的准确率、召回率
+type SpamModel struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Data      string    `json:"-"` // util.NaiveBayes 的 JSON
+	SpamNum   int       `json:"spam_num"`
+	HamNum    int       `json:"ham_num"`
+	Threshold int       `json:"threshold"` // 评估时的阈值（百分比）
+	Precision float64   `json:"precision"`
+	Recall    float64   `json:"recall"`
+	OpUser    string    `json:"op_user"`
+	CreatedAt time.Time `json:"created_at" xorm:"created"`
+}
+
+func (this *SpamModel) PrecisionPercent() float64 {
+	return this.Precision * 100
+}
+
+func (this *SpamModel) RecallPercent() float64 {
+	return this.Recall * 100
+}
@@ -13,6 +13,7 @@ const (
 	FlagNormal
 	FlagAuditDelete
 	FlagUserDelete
+	FlagShadow // 作者被影子封禁时发布的：只有作者自己能看到
 )
 
 const (
@@ -41,8 +42,15 @@ type Topic struct {
 	TopTime       int64     `json:"top_time"`
 	Tags          string    `json:"tags"`
 	Permission    int       `json:"permission"`
+	Locked        bool      `json:"locked"`       // 锁定：不能回复
+	Closed        bool      `json:"closed"`       // 关闭：不能回复，并展示关闭原因
+	CloseReason   string    `json:"close_reason"` // 关闭原因
+	MergedTo      int       `json:"merged_to"`    // 被合并到的主题 tid，访问时跳转
+	NodeTop       bool      `json:"node_top"`     // 版主在节点内置顶
+	Version       int       `json:"version"`
 	Ctime         OftenTime `json:"ctime" xorm:"created"`
 	Mtime         OftenTime `json:"mtime" xorm:"<-"`
+	DeletedAt     time.Time `json:"-" xorm:"deleted"` // 软删除时间，见 TrashLogic
 
 	// 为了方便，加上Node（节点名称，数据表没有）
 	Node string `xorm:"-"`
@@ -139,3 +147,48 @@ type NodeInfo struct {
 func (*NodeInfo) TableName() string {
 	return "recommend_node"
 }
+
+const (
+	TopicModLock = iota + 1
+	TopicModUnlock
+	TopicModClose
+	TopicModReopen
+	TopicModMove
+	TopicModMerge
+	TopicModDelete
+	TopicModNodeTop
+	TopicModNodeUntop
+)
+
+var TopicModActions = map[int]string{
+	TopicModLock:      "锁定了主题",
+	TopicModUnlock:    "解除了锁定",
+	TopicModClose:     "关闭了主题",
+	TopicModReopen:    "重新打开了主题",
+	TopicModMove:      "移动了主题",
+	TopicModMerge:     "合并了主题",
+	TopicModDelete:    "删除了主题",
+	TopicModNodeTop:   "在节点内置顶了主题",
+	TopicModNodeUntop: "取消了节点内置顶",
+}
+
+// TopicModLog 版主对主题的管理操作记录
+type TopicModLog struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	Tid       int       `json:"tid"`
+	Uid       int       `json:"uid"` // 操作人
+	Action    int       `json:"action"`
+	FromNid   int       `json:"from_nid"`   // 移动：原节点
+	ToNid     int       `json:"to_nid"`     // 移动：新节点
+	TargetTid int       `json:"target_tid"` // 合并：合并到的主题
+	Reason    string    `json:"reason"`
+	CreatedAt OftenTime `json:"created_at" xorm:"created"`
+
+	Username string `json:"username" xorm:"-"`
+	FromNode string `json:"from_node" xorm:"-"`
+	ToNode   string `json:"to_node" xorm:"-"`
+}
+
+func (this *TopicModLog) ActionName() string {
+	return TopicModActions[this.Action]
+}
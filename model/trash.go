@@ -0,0 +1,45 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+// 支持软删除（回收站）的对象
+var TrashTypeMap = map[int]string{
+	TypeTopic:    "主题",
+	TypeArticle:  "文章",
+	TypeResource: "资源",
+	TypeProject:  "项目",
+	TypeWiki:     "Wiki",
+	TypeBook:     "图书",
+	TypeComment:  "评论",
+}
+
+// TrashItem 回收站中的一条记录，各类对象软删除后的统一视图（没有对应的表）
+type TrashItem struct {
+	Objid        int       `json:"objid"`
+	Title        string    `json:"title"` // 评论为内容
+	DeletedAt    time.Time `json:"deleted_at"`
+	DeletedBy    int       `json:"deleted_by"`
+	DeleteReason string    `json:"delete_reason"`
+
+	// 评论所属的对象和楼层
+	ParentId   int `json:"parent_id"`
+	ParentType int `json:"parent_type"`
+	Floor      int `json:"floor"`
+
+	Objtype  int   `json:"objtype" xorm:"-"`
+	Operator *User `json:"operator" xorm:"-"`
+}
+
+func (this *TrashItem) TypeName() string {
+	return TrashTypeMap[this.Objtype]
+}
+
+func (this *TrashItem) ParentTypeName() string {
+	return TypeNameMap[this.ParentType]
+}
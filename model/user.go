@@ -7,13 +7,14 @@
 package model
 
 import (
+	"crypto/subtle"
 	"errors"
-	"fmt"
-	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/go-xorm/xorm"
 	"github.com/polaris1119/goutils"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // 用户登录信息
@@ -23,24 +24,46 @@ type UserLogin struct {
 	Passwd    string    `json:"passwd"`
 	Email     string    `json:"email"`
 	LoginTime time.Time `json:"login_time" xorm:"<-"`
-	Passcode  string    `json:"passcode"` // 加密随机串
+	Passcode  string    `json:"passcode"` // 加密随机串，旧的 md5 密码用
 }
 
 func (this *UserLogin) TableName() string {
 	return "user_login"
 }
 
-// 生成加密密码
-func (this *UserLogin) GenMd5Passwd() error {
+// 密码哈希的格式前缀。没有前缀的是旧的 md5(passwd+passcode)，登录成功时自动升级
+const PasswdPrefixBcrypt = "bcrypt$"
+
+// 生成加密密码：bcrypt，盐已经包含在哈希中，不再需要 passcode
+func (this *UserLogin) GenPasswd() error {
 	if this.Passwd == "" {
 		return errors.New("password is empty!")
 	}
-	this.Passcode = fmt.Sprintf("%x", rand.Int31())
-	// 密码经过md5(passwd+passcode)加密保存
-	this.Passwd = goutils.Md5(this.Passwd + this.Passcode)
+	hash, err := bcrypt.GenerateFromPassword([]byte(this.Passwd), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	this.Passcode = ""
+	this.Passwd = PasswdPrefixBcrypt + string(hash)
 	return nil
 }
 
+// CheckPasswd 校验密码，needUpgrade 表示密码正确但还是旧的哈希格式，需要重新生成
+func (this *UserLogin) CheckPasswd(passwd string) (ok, needUpgrade bool) {
+	if this.Passwd == "" || passwd == "" {
+		return false, false
+	}
+
+	if strings.HasPrefix(this.Passwd, PasswdPrefixBcrypt) {
+		err := bcrypt.CompareHashAndPassword([]byte(this.Passwd[len(PasswdPrefixBcrypt):]), []byte(passwd))
+		return err == nil, false
+	}
+
+	md5Passwd := goutils.Md5(passwd + this.Passcode)
+	ok = subtle.ConstantTimeCompare([]byte(md5Passwd), []byte(this.Passwd)) == 1
+	return ok, ok
+}
+
 const (
 	UserStatusNoAudit = iota
 	UserStatusAudit   // 已激活
@@ -49,6 +72,19 @@ const (
 	UserStatusOutage // 停用
 )
 
+// 处罚：和冻结、停用不同，账号一切正常，用户不会察觉
+const (
+	UserSanctionNone      = iota
+	UserSanctionProbation // 观察期：发布的内容审核通过后才展示
+	UserSanctionShadowBan // 影子封禁：发布的内容只有自己能看到
+)
+
+var UserSanctionMap = map[int]string{
+	UserSanctionNone:      "无",
+	UserSanctionProbation: "观察期",
+	UserSanctionShadowBan: "影子封禁",
+}
+
 const (
 	// 用户拥有的权限设置
 	DauAuthTopic = 1 << iota
@@ -88,11 +124,19 @@ type User struct {
 	Balance     int       `json:"balance"`
 	IsThird     int       `json:"is_third"`
 	DauAuth     int       `json:"dau_auth"`
+	Level       int       `json:"level"`
 	Status      int       `json:"status"`
 	IsRoot      bool      `json:"is_root"`
 	Ctime       OftenTime `json:"ctime" xorm:"created"`
 	Mtime       time.Time `json:"mtime" xorm:"<-"`
 
+	// 处罚，不对外展示
+	Sanction      int `json:"-"`
+	ProbationLeft int `json:"-"` // 观察期还需要审核的内容数
+
+	// 邮箱是否验证过（激活邮件或第三方确认过）。不开启邮箱验证时，已审核账号的邮箱也可能没验证过
+	EmailVerified bool `json:"email_verified"`
+
 	// 非用户表中的信息，为了方便放在这里
 	Roleids   []int    `xorm:"-"`
 	Rolenames []string `xorm:"-"`
@@ -104,6 +148,8 @@ type User struct {
 	Copper int `json:"copper" xorm:"-"`
 
 	IsOnline bool `json:"is_online" xorm:"-"`
+
+	Badges []*Badge `json:"badges,omitempty" xorm:"-"`
 }
 
 func (this *User) TableName() string {
@@ -120,6 +166,10 @@ func (this *User) String() string {
 	return buffer.String()
 }
 
+func (this *User) SanctionName() string {
+	return UserSanctionMap[this.Sanction]
+}
+
 func (this *User) AfterSet(name string, cell xorm.Cell) {
 	if name == "balance" {
 		this.Gold = this.Balance / 10000
@@ -144,6 +194,9 @@ type Me struct {
 	IsRoot   bool   `json:"is_root"`
 	DauAuth  int    `json:"dau_auth"`
 
+	Level      int `json:"level"`
+	Privileges int `json:"privileges"`
+
 	Balance int `json:"balance"`
 	Gold    int `json:"gold"`
 	Silver  int `json:"silver"`
@@ -176,17 +229,28 @@ type UserRole struct {
 	ctime  string `xorm:"-"`
 }
 
+// 第三方账号类型，对应 logic 中注册的 OAuth 登录方式
 const (
 	BindTypeGithub = iota
+	BindTypeGitee
+	BindTypeGitlab
+	BindTypeOIDC
 )
 
+var BindTypeMap = map[int]string{
+	BindTypeGithub: "GitHub",
+	BindTypeGitee:  "Gitee",
+	BindTypeGitlab: "GitLab",
+	BindTypeOIDC:   "OpenID",
+}
+
 type BindUser struct {
 	Id           int       `json:"id" xorm:"pk autoincr"`
 	Uid          int       `json:"uid"`
 	Type         int       `json:"type"`
 	Email        string    `json:"email"`
 	Tuid         int       `json:"tuid"`
-	Username     string    `json:"username"`
+	Username     string    `json:"username"` // 第三方账号的唯一标识：登录名，Gitee、GitLab 为数字 id，OpenID 为 sub
 	Name         string    `json:"name"`
 	AccessToken  string    `json:"access_token"`
 	RefreshToken string    `json:"refresh_token"`
@@ -194,3 +258,7 @@ type BindUser struct {
 	Avatar       string    `json:"avatar"`
 	CreatedAt    time.Time `json:"created_at" xorm:"<-"`
 }
+
+func (this *BindUser) TypeName() string {
+	return BindTypeMap[this.Type]
+}
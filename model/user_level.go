@@ -0,0 +1,63 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+const (
+	// 等级解锁的特权
+	PrivPostLink    = 1 << iota // 发布内容中带外链
+	PrivUploadImage             // 上传图片
+	PrivSetTop                  // 置顶自己的主题
+	PrivEditWiki                // 编辑他人的 Wiki
+	PrivSkipAudit               // 发布内容免审核
+)
+
+var PrivilegeMap = map[int]string{
+	PrivPostLink:    "发布外链",
+	PrivUploadImage: "上传图片",
+	PrivSetTop:      "置顶主题",
+	PrivEditWiki:    "编辑 Wiki",
+	PrivSkipAudit:   "免审核",
+}
+
+// UserLevel 声望等级，同时满足各项门槛才能达到该等级
+type UserLevel struct {
+	Id         int    `json:"id" xorm:"pk autoincr"`
+	Level      int    `json:"level"`
+	Name       string `json:"name"`
+	MinWeight  int    `json:"min_weight"`  // 活跃度
+	MinContent int    `json:"min_content"` // 发布且未被删除的主题、文章、资源数
+	MinDays    int    `json:"min_days"`    // 注册天数
+	Privileges int    `json:"privileges"`
+	// 该等级发布内容、评论时账号至少要有多少铜币
+	PublishBalance int       `json:"publish_balance"`
+	CommentBalance int       `json:"comment_balance"`
+	CreatedAt      time.Time `json:"created_at" xorm:"<-"`
+}
+
+func (this *UserLevel) HasPrivilege(priv int) bool {
+	return this.Privileges&priv == priv
+}
+
+// PrivilegeNames 该等级拥有的特权名称
+func (this *UserLevel) PrivilegeNames() []string {
+	names := make([]string, 0, len(PrivilegeMap))
+	for priv := PrivPostLink; priv <= PrivSkipAudit; priv <<= 1 {
+		if this.HasPrivilege(priv) {
+			names = append(names, PrivilegeMap[priv])
+		}
+	}
+	return names
+}
+
+// UserReputation 用户当前的声望数据
+type UserReputation struct {
+	Weight  int `json:"weight"`
+	Content int `json:"content"`
+	Days    int `json:"days"`
+}
@@ -30,7 +30,9 @@ var BalanceTypeMap = map[int]string{
 	MissionTypeReplied:  "回复收益",
 	MissionTypeAward:    "额外赠予",
 	MissionTypeActive:   "活跃奖励",
+	MissionTypeRule:     "完成任务",
 	MissionTypeGift:     "兑换物品",
+	MissionTypeMakeup:   "购买补签卡",
 	MissionTypePunish:   "处罚",
 	MissionTypeSpam:     "Spam",
 }
@@ -11,6 +11,16 @@ import "time"
 const (
 	KeyNewUserWait = "new_user_wait" // 新用户注册多久能发布帖子，单位秒，0表示没限制
 	KeyCanEditTime = "can_edit_time" // 发布后多久内能够编辑，单位秒
+
+	KeyArticleEditTime = "article_edit_time" // 文章发布后多久内作者能够编辑，单位秒，0表示没限制
+
+	KeyReportHideNum = "report_hide_num" // 内容被多少人举报后自动隐藏，0表示不自动隐藏
+
+	KeySpamThreshold = "spam_threshold" // 垃圾内容概率达到多少（百分比）时放入举报处理队列，0表示不检查
+
+	KeyProbationNum = "probation_num" // 新用户的前多少条内容需要审核通过后才展示，0表示不需要
+
+	KeyRequireAdmin2FA = "require_admin_2fa" // 管理员是否必须启用两步验证，1表示必须，0表示不要求
 )
 
 type UserSetting struct {
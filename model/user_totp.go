@@ -0,0 +1,23 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package model
+
+import "time"
+
+// RecoveryCodeNum 启用两步验证时生成的恢复码个数，每个只能用一次
+const RecoveryCodeNum = 10
+
+// UserTotp 用户的两步验证（TOTP）。扫码后输入动态码确认才启用
+type UserTotp struct {
+	Uid           int       `json:"uid" xorm:"pk"`
+	Secret        string    `json:"-"`
+	Enabled       bool      `json:"enabled"`
+	RecoveryCodes string    `json:"-"` // 未使用的恢复码的 sha256，逗号分隔
+	LastStep      int64     `json:"-"` // 最后一次使用的时间步，同一个动态码不能用两次
+	CreatedAt     time.Time `json:"created_at" xorm:"created"`
+	UpdatedAt     time.Time `json:"updated_at" xorm:"<-"`
+}
@@ -8,6 +8,19 @@ package model
 
 import "time"
 
+// Wiki 页面状态
+const (
+	WikiStateNormal    = iota
+	WikiStateProtected // 保护：只有管理员和有编辑 Wiki 特权的用户能编辑
+	WikiStateLocked    // 锁定：只有管理员能编辑
+)
+
+var WikiStateMap = map[int]string{
+	WikiStateNormal:    "正常",
+	WikiStateProtected: "保护",
+	WikiStateLocked:    "锁定",
+}
+
 // Wiki .
 type Wiki struct {
 	Id      int       `json:"id" xorm:"pk autoincr"`
@@ -18,9 +31,13 @@ type Wiki struct {
 	Cuid    string    `json:"cuid"`
 	Viewnum int       `json:"viewnum"`
 	Tags    string    `json:"tags"`
+	State   int       `json:"state"`
+	Version int       `json:"version"`
 	Ctime   OftenTime `json:"ctime" xorm:"created"`
 	Mtime   time.Time `json:"mtime" xorm:"<-"`
 
+	DeletedAt time.Time `json:"-" xorm:"deleted"` // 软删除时间，见 TrashLogic
+
 	Users map[int]*User `xorm:"-"`
 }
 
@@ -30,3 +47,25 @@ func (t *Wiki) BeforeInsert() {
 		t.Tags = AutoTag(t.Title, t.Content, 4)
 	}
 }
+
+// WikiRevision wiki 的历史版本，每次创建、编辑、回滚都会保存一个版本
+type WikiRevision struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	WikiId    int       `json:"wiki_id"`
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Uid       int       `json:"uid"`
+	Summary   string    `json:"summary"`
+	CreatedAt time.Time `json:"created_at" xorm:"<-"`
+
+	User *User `json:"user" xorm:"-"`
+}
+
+// WikiLink wiki 页面之间的 [[链接]]，保存时维护，用于“链入页面”
+type WikiLink struct {
+	Id        int       `json:"id" xorm:"pk autoincr"`
+	FromId    int       `json:"from_id"`
+	ToUri     string    `json:"to_uri"`
+	CreatedAt time.Time `json:"created_at" xorm:"<-"`
+}
@@ -316,6 +316,11 @@ jQuery(document).ready(function($) {
 
 		$.post('/account/login', $(this).serialize(), function(data){
 			if (data.ok) {
+				// 启用了两步验证，跳转到输入动态码页面
+				if (data.data && data.data.redirect) {
+					location.href = data.data.redirect;
+					return;
+				}
 				location.reload();
 			} else {
 				$('#login-pop .login-form .error').text(data.error).show();
@@ -0,0 +1,126 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package util
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// ACMatch 一次匹配：模式串下标及其在文本中的字节位置 [Start, End)
+type ACMatch struct {
+	Index int
+	Start int
+	End   int
+}
+
+type acNode struct {
+	next   map[rune]int
+	fail   int
+	output []int // 以该节点结尾的模式串（包括 fail 链上的）
+}
+
+// ACMatcher Aho-Corasick 多模式匹配自动机，扫描一遍文本找出所有模式串的出现位置，不区分大小写。
+// 构建后只读，可以并发使用
+type ACMatcher struct {
+	nodes   []*acNode
+	lengths []int // 每个模式串的字符数
+}
+
+// NewACMatcher 用模式串构建自动机，空串会被忽略
+func NewACMatcher(patterns []string) *ACMatcher {
+	m := &ACMatcher{
+		nodes:   []*acNode{{next: make(map[rune]int)}},
+		lengths: make([]int, len(patterns)),
+	}
+
+	for i, pattern := range patterns {
+		cur := 0
+		for _, r := range pattern {
+			r = unicode.ToLower(r)
+			next, ok := m.nodes[cur].next[r]
+			if !ok {
+				next = len(m.nodes)
+				m.nodes = append(m.nodes, &acNode{next: make(map[rune]int)})
+				m.nodes[cur].next[r] = next
+			}
+			cur = next
+			m.lengths[i]++
+		}
+		if cur != 0 {
+			m.nodes[cur].output = append(m.nodes[cur].output, i)
+		}
+	}
+
+	// 按层次遍历构建 fail 指针
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			fail := m.nodes[cur].fail
+			for {
+				if next, ok := m.nodes[fail].next[r]; ok && next != child {
+					m.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			m.nodes[child].output = append(m.nodes[child].output, m.nodes[m.nodes[child].fail].output...)
+			queue = append(queue, child)
+		}
+	}
+
+	return m
+}
+
+// FindAll 找出文本中所有模式串的出现（可能重叠），按结束位置排序
+func (m *ACMatcher) FindAll(text string) []ACMatch {
+	if m == nil || len(m.nodes[0].next) == 0 {
+		return nil
+	}
+
+	var (
+		matches []ACMatch
+		// 已扫描字符的起始字节位置，用于根据模式串字符数算出匹配的起始位置
+		offsets = make([]int, 0, utf8.RuneCountInString(text))
+		cur     = 0
+	)
+	for pos, r := range text {
+		offsets = append(offsets, pos)
+		_, size := utf8.DecodeRuneInString(text[pos:])
+		r = unicode.ToLower(r)
+
+		for {
+			if next, ok := m.nodes[cur].next[r]; ok {
+				cur = next
+				break
+			}
+			if cur == 0 {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+
+		end := pos + size
+		for _, i := range m.nodes[cur].output {
+			matches = append(matches, ACMatch{
+				Index: i,
+				Start: offsets[len(offsets)-m.lengths[i]],
+				End:   end,
+			})
+		}
+	}
+
+	return matches
+}
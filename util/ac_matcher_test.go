@@ -0,0 +1,77 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package util_test
+
+import (
+	"reflect"
+	"testing"
+
+	"sander/util"
+)
+
+func TestACMatcherFindAll(t *testing.T) {
+	type args struct {
+		patterns []string
+		text     string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []util.ACMatch
+	}{
+		{
+			"重叠的匹配按结束位置排序",
+			args{[]string{"he", "she", "his", "hers"}, "ushers"},
+			[]util.ACMatch{{1, 1, 4}, {0, 2, 4}, {3, 2, 6}},
+		},
+		{
+			"不区分大小写",
+			args{[]string{"Go"}, "GOLANG go"},
+			[]util.ACMatch{{0, 0, 2}, {0, 7, 9}},
+		},
+		{
+			"中文按字节位置",
+			args{[]string{"世界"}, "你好，世界"},
+			[]util.ACMatch{{0, 9, 15}},
+		},
+		{
+			"中英文混合",
+			args{[]string{"golang中文"}, "学 GoLang中文"},
+			[]util.ACMatch{{0, 4, 16}},
+		},
+		{
+			"小写后字节数变化时仍是原文的位置",
+			args{[]string{"i"}, "İx"},
+			[]util.ACMatch{{0, 0, 2}},
+		},
+		{
+			"没有匹配",
+			args{[]string{"spam"}, "golang"},
+			nil,
+		},
+		{
+			"忽略空模式串",
+			args{[]string{""}, "abc"},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := util.NewACMatcher(tt.args.patterns).FindAll(tt.args.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindAll() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACMatcherNil(t *testing.T) {
+	var m *util.ACMatcher
+	if got := m.FindAll("abc"); got != nil {
+		t.Errorf("FindAll() = %v, want nil", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package util
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// NaiveBayes 二分类（垃圾/正常）朴素贝叶斯分类器，使用拉普拉斯平滑。
+// 字段导出是为了能序列化为 JSON 保存；训练完成后只读，可以并发使用
+type NaiveBayes struct {
+	SpamDocs   int            `json:"spam_docs"`
+	HamDocs    int            `json:"ham_docs"`
+	SpamTokens int            `json:"spam_tokens"`
+	HamTokens  int            `json:"ham_tokens"`
+	Vocab      int            `json:"vocab"`
+	Spam       map[string]int `json:"spam"`
+	Ham        map[string]int `json:"ham"`
+}
+
+func NewNaiveBayes() *NaiveBayes {
+	return &NaiveBayes{
+		Spam: make(map[string]int),
+		Ham:  make(map[string]int),
+	}
+}
+
+// Train 用一篇文档训练
+func (nb *NaiveBayes) Train(text string, isSpam bool) {
+	for _, token := range Tokenize(text) {
+		if nb.Spam[token] == 0 && nb.Ham[token] == 0 {
+			nb.Vocab++
+		}
+
+		if isSpam {
+			nb.Spam[token]++
+			nb.SpamTokens++
+		} else {
+			nb.Ham[token]++
+			nb.HamTokens++
+		}
+	}
+
+	if isSpam {
+		nb.SpamDocs++
+	} else {
+		nb.HamDocs++
+	}
+}
+
+// SpamProb 文档是垃圾内容的概率，两类都没有训练数据时返回 0
+func (nb *NaiveBayes) SpamProb(text string) float64 {
+	if nb.SpamDocs == 0 || nb.HamDocs == 0 {
+		return 0
+	}
+
+	total := float64(nb.SpamDocs + nb.HamDocs)
+	spamLog := math.Log(float64(nb.SpamDocs) / total)
+	hamLog := math.Log(float64(nb.HamDocs) / total)
+
+	vocab := float64(nb.Vocab + 1)
+	for _, token := range Tokenize(text) {
+		spamLog += math.Log((float64(nb.Spam[token]) + 1) / (float64(nb.SpamTokens) + vocab))
+		hamLog += math.Log((float64(nb.Ham[token]) + 1) / (float64(nb.HamTokens) + vocab))
+	}
+
+	// 1 / (1 + e^(ham-spam))，避免直接求 e^spamLog 下溢
+	return 1 / (1 + math.Exp(hamLog-spamLog))
+}
+
+// Tokenize 把文本切分为去重后的词：连续的字母数字为一个词（小写），
+// 中日韩文字没有分隔符，按相邻两个字切分（只有一个字时取单字）
+func Tokenize(text string) []string {
+	seen := make(map[string]bool)
+	tokens := make([]string, 0)
+	add := func(token string) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	var (
+		word []rune
+		cjk  []rune
+	)
+	flushWord := func() {
+		if len(word) > 1 {
+			add(strings.ToLower(string(word)))
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			add(string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			add(string(cjk[i : i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+
+	return tokens
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package util_test
+
+import (
+	"reflect"
+	"testing"
+
+	"sander/util"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			"英文小写并去重",
+			"Hello, World! hello",
+			[]string{"hello", "world"},
+		},
+		{
+			"忽略单个字母",
+			"a go b",
+			[]string{"go"},
+		},
+		{
+			"中文按相邻两个字切分",
+			"中文分词",
+			[]string{"中文", "文分", "分词"},
+		},
+		{
+			"只有一个字时取单字",
+			"字 golang",
+			[]string{"字", "golang"},
+		},
+		{
+			"中英文混合",
+			"学习Go语言",
+			[]string{"学习", "go", "语言"},
+		},
+		{
+			"空文本",
+			"",
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := util.Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpamProb(t *testing.T) {
+	nb := util.NewNaiveBayes()
+	if prob := nb.SpamProb("代开发票"); prob != 0 {
+		t.Fatalf("untrained SpamProb() = %v, want 0", prob)
+	}
+
+	nb.Train("代开发票，联系微信", true)
+	if prob := nb.SpamProb("代开发票"); prob != 0 {
+		t.Fatalf("only spam trained SpamProb() = %v, want 0", prob)
+	}
+
+	nb.Train("低价代开各类发票", true)
+	nb.Train("golang 并发编程的问题", false)
+	nb.Train("请问 goroutine 泄漏怎么排查", false)
+
+	if prob := nb.SpamProb("专业代开发票"); prob <= 0.5 {
+		t.Errorf("spam SpamProb() = %v, want > 0.5", prob)
+	}
+	if prob := nb.SpamProb("golang 并发的问题"); prob >= 0.5 {
+		t.Errorf("ham SpamProb() = %v, want < 0.5", prob)
+	}
+}
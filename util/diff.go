@@ -0,0 +1,142 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package util
+
+import "strings"
+
+// 对比行的类型
+const (
+	DiffEqual  = "equal"
+	DiffDelete = "delete"
+	DiffInsert = "insert"
+	DiffChange = "change"
+)
+
+// maxDiffCells 两边不同的部分超过这个规模（行数相乘）时不再计算 LCS，避免大文本占用过多内存
+const maxDiffCells = 1000 * 1000
+
+// DiffRow 左右对照显示的一行，行号为 0 表示该侧没有内容
+type DiffRow struct {
+	Type    string `json:"type"`
+	LeftNo  int    `json:"left_no"`
+	Left    string `json:"left"`
+	RightNo int    `json:"right_no"`
+	Right   string `json:"right"`
+}
+
+// DiffLines 按行对比两段文本（基于最长公共子序列），返回左右对照的结果。
+// 相邻的删除和新增会合并为修改行。不同的部分太大时，整段按修改显示
+func DiffLines(a, b string) []*DiffRow {
+	left := splitLines(a)
+	right := splitLines(b)
+
+	// 去掉相同的头尾，减小 LCS 的计算量
+	prefix := 0
+	for prefix < len(left) && prefix < len(right) && left[prefix] == right[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(left)-prefix && suffix < len(right)-prefix &&
+		left[len(left)-1-suffix] == right[len(right)-1-suffix] {
+		suffix++
+	}
+
+	rows := make([]*DiffRow, 0, len(left)+len(right))
+	for i := 0; i < prefix; i++ {
+		rows = append(rows, &DiffRow{Type: DiffEqual, LeftNo: i + 1, Left: left[i], RightNo: i + 1, Right: right[i]})
+	}
+
+	midLeft, midRight := left[prefix:len(left)-suffix], right[prefix:len(right)-suffix]
+	rows = append(rows, diffMiddle(midLeft, midRight, prefix)...)
+
+	for i := suffix; i > 0; i-- {
+		l, r := len(left)-i, len(right)-i
+		rows = append(rows, &DiffRow{Type: DiffEqual, LeftNo: l + 1, Left: left[l], RightNo: r + 1, Right: right[r]})
+	}
+
+	return rows
+}
+
+func diffMiddle(left, right []string, offset int) []*DiffRow {
+	n, m := len(left), len(right)
+
+	rows := make([]*DiffRow, 0, n+m)
+	deletes, inserts := make([]int, 0), make([]int, 0)
+	flush := func() {
+		for k := 0; k < len(deletes) || k < len(inserts); k++ {
+			row := &DiffRow{Type: DiffChange}
+			if k < len(deletes) {
+				row.LeftNo, row.Left = offset+deletes[k]+1, left[deletes[k]]
+			} else {
+				row.Type = DiffInsert
+			}
+			if k < len(inserts) {
+				row.RightNo, row.Right = offset+inserts[k]+1, right[inserts[k]]
+			} else {
+				row.Type = DiffDelete
+			}
+			rows = append(rows, row)
+		}
+		deletes, inserts = deletes[:0], inserts[:0]
+	}
+
+	if n*m > maxDiffCells {
+		for i := 0; i < n; i++ {
+			deletes = append(deletes, i)
+		}
+		for j := 0; j < m; j++ {
+			inserts = append(inserts, j)
+		}
+		flush()
+		return rows
+	}
+
+	// lcs[i][j] 为 left[i:] 和 right[j:] 的最长公共子序列长度
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if left[i] == right[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && left[i] == right[j]:
+			flush()
+			rows = append(rows, &DiffRow{Type: DiffEqual, LeftNo: offset + i + 1, Left: left[i], RightNo: offset + j + 1, Right: right[j]})
+			i++
+			j++
+		case j >= m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			deletes = append(deletes, i)
+			i++
+		default:
+			inserts = append(inserts, j)
+			j++
+		}
+	}
+	flush()
+
+	return rows
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package util_test
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sander/util"
+)
+
+func TestDiffLines(t *testing.T) {
+	type args struct {
+		a string
+		b string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []util.DiffRow
+	}{
+		{
+			"两边都为空",
+			args{"", ""},
+			[]util.DiffRow{},
+		},
+		{
+			"完全相同",
+			args{"a\nb", "a\nb"},
+			[]util.DiffRow{
+				{util.DiffEqual, 1, "a", 1, "a"},
+				{util.DiffEqual, 2, "b", 2, "b"},
+			},
+		},
+		{
+			"换行符和末尾换行不算差异",
+			args{"a\r\nb\r\n", "a\nb"},
+			[]util.DiffRow{
+				{util.DiffEqual, 1, "a", 1, "a"},
+				{util.DiffEqual, 2, "b", 2, "b"},
+			},
+		},
+		{
+			"从空文本新增",
+			args{"", "a\nb"},
+			[]util.DiffRow{
+				{util.DiffInsert, 0, "", 1, "a"},
+				{util.DiffInsert, 0, "", 2, "b"},
+			},
+		},
+		{
+			"删除中间一行",
+			args{"a\nb\nc", "a\nc"},
+			[]util.DiffRow{
+				{util.DiffEqual, 1, "a", 1, "a"},
+				{util.DiffDelete, 2, "b", 0, ""},
+				{util.DiffEqual, 3, "c", 2, "c"},
+			},
+		},
+		{
+			"修改中间一行",
+			args{"a\nb\nc", "a\nx\nc"},
+			[]util.DiffRow{
+				{util.DiffEqual, 1, "a", 1, "a"},
+				{util.DiffChange, 2, "b", 2, "x"},
+				{util.DiffEqual, 3, "c", 3, "c"},
+			},
+		},
+		{
+			"删除多于新增时多出的为删除",
+			args{"a\nb\nc\nd", "a\nx\nd"},
+			[]util.DiffRow{
+				{util.DiffEqual, 1, "a", 1, "a"},
+				{util.DiffChange, 2, "b", 2, "x"},
+				{util.DiffDelete, 3, "c", 0, ""},
+				{util.DiffEqual, 4, "d", 3, "d"},
+			},
+		},
+		{
+			"中间有相同的行",
+			args{"a\nb\nc\nd\ne", "a\nx\nc\ny\ne"},
+			[]util.DiffRow{
+				{util.DiffEqual, 1, "a", 1, "a"},
+				{util.DiffChange, 2, "b", 2, "x"},
+				{util.DiffEqual, 3, "c", 3, "c"},
+				{util.DiffChange, 4, "d", 4, "y"},
+				{util.DiffEqual, 5, "e", 5, "e"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]util.DiffRow, 0)
+			for _, row := range util.DiffLines(tt.args.a, tt.args.b) {
+				got = append(got, *row)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffLines() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesTooLarge(t *testing.T) {
+	left, right := make([]string, 0), make([]string, 0)
+	for i := 0; i < 1200; i++ {
+		left = append(left, "a"+strconv.Itoa(i))
+		right = append(right, "b"+strconv.Itoa(i))
+	}
+	right = append(right, "c")
+
+	rows := util.DiffLines("x\n"+strings.Join(left, "\n"), "x\n"+strings.Join(right, "\n"))
+	if len(rows) != 1202 {
+		t.Fatalf("DiffLines() got %d rows, want 1202", len(rows))
+	}
+	if row := *rows[0]; row != (util.DiffRow{util.DiffEqual, 1, "x", 1, "x"}) {
+		t.Errorf("DiffLines() first row = %+v", row)
+	}
+	if row := *rows[1]; row != (util.DiffRow{util.DiffChange, 2, "a0", 2, "b0"}) {
+		t.Errorf("DiffLines() second row = %+v", row)
+	}
+	if row := *rows[1201]; row != (util.DiffRow{util.DiffInsert, 0, "", 1202, "c"}) {
+		t.Errorf("DiffLines() last row = %+v", row)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 The StudyGolang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+// http://studygolang.com
+// Author: polaris	polaris@studygolang.com
+
+package util_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"sander/util"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试向量，取 8 位动态码的后 6 位
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestValidateTOTP(t *testing.T) {
+	tests := []struct {
+		name string
+		unix int64
+		code string
+	}{
+		{"59", 59, "287082"},
+		{"1111111109", 1111111109, "081804"},
+		{"1111111111", 1111111111, "050471"},
+		{"1234567890", 1234567890, "005924"},
+		{"2000000000", 2000000000, "279037"},
+		{"20000000000", 20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := util.ValidateTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0))
+			if !ok || step != tt.unix/30 {
+				t.Errorf("ValidateTOTP() = %v, %v, want %v, true", step, ok, tt.unix/30)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code := "050471"
+
+	if _, ok := util.ValidateTOTP(rfc6238Secret, code, now.Add(30*time.Second)); !ok {
+		t.Error("code of the previous step should be accepted")
+	}
+	if _, ok := util.ValidateTOTP(rfc6238Secret, code, now.Add(-30*time.Second)); !ok {
+		t.Error("code of the next step should be accepted")
+	}
+	if _, ok := util.ValidateTOTP(rfc6238Secret, code, now.Add(90*time.Second)); ok {
+		t.Error("code of two steps ago should be rejected")
+	}
+}
+
+func TestValidateTOTPInvalid(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+	}{
+		{"密钥小写、动态码有空白", strings.ToLower(strings.TrimRight(rfc6238Secret, "=")), " 050471 ", true},
+		{"动态码错误", rfc6238Secret, "050472", false},
+		{"位数不对", rfc6238Secret, "50471", false},
+		{"密钥不是 base32", "1234567890", "050471", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := util.ValidateTOTP(tt.secret, tt.code, now); ok != tt.ok {
+				t.Errorf("ValidateTOTP() = %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}